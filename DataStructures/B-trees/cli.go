@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+	"github.com/Kush/Database-internals/pkg/relational"
+)
+
+const usage = `Commands:
+  insert <key> <value>         store a value; also set
+  search <key>                 print the value of key; also get
+  delete <key>                 delete key
+  range <start> <end> [limit]  print the entries in [start, end); "" is unbounded
+  scan [limit]                 print every entry
+  count [<start> <end>]        count the keys, or those in [start, end)
+  stats                        describe the engine
+  .dump [file]                 write every entry and table as commands
+  .load <file>                 run the commands in a file
+  sql <statement>              run a SQL statement on the tables (btree only)
+  .tables                      list the SQL tables
+  .schema [table]              print the CREATE TABLE of every table, or one
+  help                         print this help
+  exit                         leave; also quit
+Values are typed literals: "text", 42, 3.5f, true, 0x00ff or null.
+Keys may be quoted to hold spaces.
+SQL statements: CREATE TABLE, DROP TABLE, INSERT INTO, SELECT ... WHERE,
+UPDATE and DELETE FROM, with 'quoted' strings.`
+
+// cli runs commands against an engine and writes their results to out.
+type cli struct {
+	engine *engine.Engine
+	out    io.Writer
+	// loading guards .load against files that load themselves.
+	loading map[string]bool
+	// db runs the sql commands; it is opened by the first one.
+	db *relational.DB
+}
+
+func newCLI(e *engine.Engine, out io.Writer) *cli {
+	return &cli{engine: e, out: out, loading: make(map[string]bool)}
+}
+
+// execute runs one command line and reports whether it asked to exit.
+// Blank lines and lines starting with # are skipped.
+func (c *cli) execute(line string) (bool, lib.Error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return false, lib.EmptyError()
+	}
+	// A statement keeps its own quoting, so it is passed on untokenized.
+	if statement, ok := strings.CutPrefix(trimmed, "sql"); ok && (statement == "" || strings.IndexByte(" \t", statement[0]) >= 0) {
+		return false, c.sql(strings.TrimSpace(statement))
+	}
+	tokens, err := tokenize(trimmed)
+	if err.IsNotEmpty() {
+		return false, err
+	}
+	args := tokens[1:]
+
+	switch tokens[0].text {
+	case "exit", "quit":
+		return true, lib.EmptyError()
+	case "help":
+		fmt.Fprintln(c.out, usage)
+		return false, lib.EmptyError()
+	case "insert", "set":
+		return false, c.insert(args)
+	case "search", "get":
+		return false, c.search(args)
+	case "delete":
+		return false, c.delete(args)
+	case "range":
+		return false, c.rangeCommand(args)
+	case "scan":
+		return false, c.scan(args)
+	case "count":
+		return false, c.count(args)
+	case "stats":
+		return false, c.stats(args)
+	case ".dump":
+		return false, c.dump(args)
+	case ".load":
+		return false, c.load(args)
+	case ".tables":
+		return false, c.tables(args)
+	case ".schema":
+		return false, c.schema(args)
+	}
+	return false, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("unknown command %s; try help", tokens[0].text))
+}
+
+// run executes the commands read from r until it ends or a command exits.
+// Unless keepGoing is set it stops at the first failing command. It returns
+// how many commands failed; only reading r failing is an error.
+func (c *cli) run(r io.Reader, name string, keepGoing bool, errOut io.Writer) (int, bool, lib.Error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	failed := 0
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		exit, err := c.execute(scanner.Text())
+		if err.IsNotEmpty() {
+			failed++
+			fmt.Fprintf(errOut, "%s:%d: %v\n", name, lineNumber, err)
+			if !keepGoing {
+				return failed, false, lib.EmptyError()
+			}
+		}
+		if exit {
+			return failed, true, lib.EmptyError()
+		}
+	}
+	if e := scanner.Err(); e != nil {
+		return failed, false, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("read %s: %w", name, e))
+	}
+	return failed, false, lib.EmptyError()
+}
+
+func usageError(format string) lib.Error {
+	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("usage: %s", format))
+}
+
+func (c *cli) insert(args []token) lib.Error {
+	if len(args) != 2 {
+		return usageError("insert <key> <value>")
+	}
+	value, err := parseLiteral(args[1])
+	if err.IsNotEmpty() {
+		return err
+	}
+	return c.engine.Insert(args[0].text, value)
+}
+
+func (c *cli) search(args []token) lib.Error {
+	if len(args) != 1 {
+		return usageError("search <key>")
+	}
+	value, found, err := c.engine.Search(args[0].text)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if !found {
+		fmt.Fprintln(c.out, "(not found)")
+		return lib.EmptyError()
+	}
+	fmt.Fprintln(c.out, formatLiteral(value))
+	return lib.EmptyError()
+}
+
+func (c *cli) delete(args []token) lib.Error {
+	if len(args) != 1 {
+		return usageError("delete <key>")
+	}
+	return c.engine.Delete(args[0].text)
+}
+
+func (c *cli) rangeCommand(args []token) lib.Error {
+	if len(args) != 2 && len(args) != 3 {
+		return usageError("range <start> <end> [limit]")
+	}
+	limit := 0
+	if len(args) == 3 {
+		n, err := parseLimit(args[2])
+		if err.IsNotEmpty() {
+			return err
+		}
+		limit = n
+	}
+	return c.printEntries(args[0].text, args[1].text, limit)
+}
+
+func (c *cli) scan(args []token) lib.Error {
+	if len(args) > 1 {
+		return usageError("scan [limit]")
+	}
+	limit := 0
+	if len(args) == 1 {
+		n, err := parseLimit(args[0])
+		if err.IsNotEmpty() {
+			return err
+		}
+		limit = n
+	}
+	return c.printEntries("", "", limit)
+}
+
+func (c *cli) printEntries(start, end string, limit int) lib.Error {
+	it, err := c.engine.Scan(start, end, engine.ScanOptions{Limit: limit})
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		fmt.Fprintf(c.out, "%s = %s\n", formatKey(it.Key()), formatLiteral(it.Value()))
+	}
+	return it.Err()
+}
+
+func (c *cli) count(args []token) lib.Error {
+	var start, end string
+	switch len(args) {
+	case 0:
+	case 2:
+		start, end = args[0].text, args[1].text
+	default:
+		return usageError("count [<start> <end>]")
+	}
+	it, err := c.engine.Scan(start, end, engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		return err
+	}
+	fmt.Fprintln(c.out, n)
+	return lib.EmptyError()
+}
+
+func (c *cli) stats(args []token) lib.Error {
+	if len(args) != 0 {
+		return usageError("stats")
+	}
+	stats, err := c.engine.Stats()
+	if err.IsNotEmpty() {
+		return err
+	}
+	fmt.Fprintf(c.out, "engine      %s\n", stats.Kind)
+	fmt.Fprintf(c.out, "path        %s\n", stats.Path)
+	if stats.PageSize != 0 {
+		fmt.Fprintf(c.out, "page size   %d\n", stats.PageSize)
+		fmt.Fprintf(c.out, "pages       %d\n", stats.Pages)
+		fmt.Fprintf(c.out, "free pages  %d\n", stats.FreePages)
+	}
+	return lib.EmptyError()
+}
+
+// dump writes a script that .load turns back into the same entries. The
+// engine does not show the entries of the SQL tables, so on a B+ tree they
+// follow as sql commands.
+func (c *cli) dump(args []token) lib.Error {
+	if len(args) > 1 {
+		return usageError(".dump [file]")
+	}
+	it, err := c.engine.Scan("", "", engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer it.Close()
+	var db *relational.DB
+	if c.engine.Atomic() {
+		if db, err = c.sqlDB(); err.IsNotEmpty() {
+			return err
+		}
+	}
+
+	out, file := c.out, (*os.File)(nil)
+	if len(args) == 1 {
+		f, e := os.Create(args[0].text)
+		if e != nil {
+			return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf(".dump: %w", e))
+		}
+		out, file = f, f
+	}
+	w := bufio.NewWriter(out)
+	for it.Next() {
+		fmt.Fprintf(w, "insert %s %s\n", formatKey(it.Key()), formatLiteral(it.Value()))
+	}
+	err = it.Err()
+	if err.IsEmpty() && db != nil {
+		err = db.Dump(func(statement string) { fmt.Fprintf(w, "sql %s\n", statement) })
+	}
+	if err.IsNotEmpty() {
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}
+	e := w.Flush()
+	if file != nil {
+		if closeErr := file.Close(); e == nil {
+			e = closeErr
+		}
+	}
+	if e != nil {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf(".dump: %w", e))
+	}
+	return lib.EmptyError()
+}
+
+// load runs a script, stopping at its first failing command.
+func (c *cli) load(args []token) lib.Error {
+	if len(args) != 1 {
+		return usageError(".load <file>")
+	}
+	path := args[0].text
+	if c.loading[path] {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf(".load: %s is already being loaded", path))
+	}
+	f, e := os.Open(path)
+	if e != nil {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf(".load: %w", e))
+	}
+	defer f.Close()
+
+	c.loading[path] = true
+	defer delete(c.loading, path)
+	var messages strings.Builder
+	failed, _, err := c.run(f, path, false, &messages)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if failed > 0 {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf(".load: %s", strings.TrimSpace(messages.String())))
+	}
+	return lib.EmptyError()
+}
+
+func (c *cli) sqlDB() (*relational.DB, lib.Error) {
+	if c.db == nil {
+		db, err := relational.Open(c.engine)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		c.db = db
+	}
+	return c.db, lib.EmptyError()
+}
+
+// sql runs a statement and prints the rows of a SELECT as a table, or the
+// tag of any other statement.
+func (c *cli) sql(statement string) lib.Error {
+	if statement == "" {
+		return usageError("sql <statement>")
+	}
+	db, err := c.sqlDB()
+	if err.IsNotEmpty() {
+		return err
+	}
+	rows, err := db.Query(statement)
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer rows.Close()
+	if rows.Columns() == nil {
+		fmt.Fprintln(c.out, rows.Tag())
+		return lib.EmptyError()
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(rows.Columns(), "\t"))
+	n := 0
+	for rows.Next() {
+		values := make([]string, len(rows.Row()))
+		for i, value := range rows.Row() {
+			values[i] = formatLiteral(value)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+		n++
+	}
+	if err := rows.Err(); err.IsNotEmpty() {
+		return err
+	}
+	w.Flush()
+	if n == 1 {
+		fmt.Fprintln(c.out, "(1 row)")
+	} else {
+		fmt.Fprintf(c.out, "(%d rows)\n", n)
+	}
+	return lib.EmptyError()
+}
+
+func (c *cli) tables(args []token) lib.Error {
+	if len(args) != 0 {
+		return usageError(".tables")
+	}
+	db, err := c.sqlDB()
+	if err.IsNotEmpty() {
+		return err
+	}
+	schemas, err := db.Tables()
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, schema := range schemas {
+		fmt.Fprintln(c.out, schema.Name)
+	}
+	return lib.EmptyError()
+}
+
+func (c *cli) schema(args []token) lib.Error {
+	if len(args) > 1 {
+		return usageError(".schema [table]")
+	}
+	db, err := c.sqlDB()
+	if err.IsNotEmpty() {
+		return err
+	}
+	if len(args) == 1 {
+		schema, found, err := db.Table(args[0].text)
+		if err.IsNotEmpty() {
+			return err
+		}
+		if !found {
+			return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("no table named %s", args[0].text))
+		}
+		fmt.Fprintf(c.out, "%s;\n", schema)
+		return lib.EmptyError()
+	}
+	schemas, err := db.Tables()
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, schema := range schemas {
+		fmt.Fprintf(c.out, "%s;\n", schema)
+	}
+	return lib.EmptyError()
+}
+
+func parseLimit(t token) (int, lib.Error) {
+	n, e := strconv.Atoi(t.text)
+	if e != nil || n < 1 {
+		return 0, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("limit %s is not a positive number", t.text))
+	}
+	return n, lib.EmptyError()
+}
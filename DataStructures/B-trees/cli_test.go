@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+func TestLiterals(t *testing.T) {
+	values := []common.Value{
+		common.NewStringValue("with \"quotes\" and spaces"),
+		common.NewStringValue(""),
+		common.NewIntValue(-42),
+		common.NewFloatValue(3.5),
+		common.NewFloatValue(2),
+		common.NewFloatValue(math.Inf(1)),
+		common.NewBoolValue(true),
+		common.NewBytesValue([]byte{0, 0xff}),
+		common.NewNullValue(),
+	}
+	for _, value := range values {
+		literal := formatLiteral(value)
+		tokens, err := tokenize(literal)
+		if err.IsNotEmpty() || len(tokens) != 1 {
+			t.Fatalf("tokenize(%s) = %v, %v", literal, tokens, err)
+		}
+		got, err := parseLiteral(tokens[0])
+		if err.IsNotEmpty() {
+			t.Fatalf("parseLiteral(%s): %v", literal, err)
+		}
+		if got.Type() != value.Type() || !got.Equal(value) {
+			t.Fatalf("%s read back as %s %v, want %s %v", literal, got.Type(), got, value.Type(), value)
+		}
+	}
+
+	for literal, want := range map[string]common.Value{
+		"42":   common.NewIntValue(42),
+		"3.5":  common.NewFloatValue(3.5),
+		"1e3":  common.NewFloatValue(1000),
+		"NaNf": common.NewFloatValue(math.NaN()),
+	} {
+		got, err := parseLiteral(token{text: literal})
+		if err.IsNotEmpty() || got.Type() != want.Type() || !got.Equal(want) {
+			t.Fatalf("parseLiteral(%s) = %v, %v, want %v", literal, got, err, want)
+		}
+	}
+	for _, bad := range []string{"hello", "0xzz", "12abc", "Inf"} {
+		if got, err := parseLiteral(token{text: bad}); err.IsEmpty() {
+			t.Fatalf("parseLiteral(%s) = %v, want an error", bad, got)
+		}
+	}
+	if _, err := tokenize(`insert k "open`); err.IsEmpty() {
+		t.Fatalf("tokenize accepted an unterminated string")
+	}
+}
+
+func TestScript(t *testing.T) {
+	dir := t.TempDir()
+	e, err := engine.Open(engine.BPlusTree, filepath.Join(dir, "cli.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	defer e.Close()
+	var out strings.Builder
+	c := newCLI(e, &out)
+
+	dump := filepath.Join(dir, "dump.txt")
+	script := `# comment
+insert a 1
+insert "b c" "two words"
+insert d 4.5f
+delete a
+range "" ""
+count
+.dump ` + dump + `
+nonsense
+insert never 1
+`
+	failed, exit, err := c.run(strings.NewReader(script), "script", false, io.Discard)
+	if err.IsNotEmpty() || exit || failed != 1 {
+		t.Fatalf("run = %d, %t, %v; want one failure", failed, exit, err)
+	}
+	want := "\"b c\" = \"two words\"\nd = 4.5f\n2\n"
+	if out.String() != want {
+		t.Fatalf("output %q, want %q", out.String(), want)
+	}
+	// The script stopped at the unknown command.
+	if _, found, _ := e.Search("never"); found {
+		t.Fatalf("commands after the failure ran")
+	}
+
+	dumped, readErr := os.ReadFile(dump)
+	if readErr != nil {
+		t.Fatalf("read dump: %v", readErr)
+	}
+	if string(dumped) != "insert \"b c\" \"two words\"\ninsert d 4.5f\n" {
+		t.Fatalf("dump %q", dumped)
+	}
+
+	other, err := engine.Open(engine.BPlusTree, filepath.Join(dir, "other.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	defer other.Close()
+	out.Reset()
+	c = newCLI(other, &out)
+	if failed, _, err := c.run(strings.NewReader(".load "+dump+"\nscan\n"), "script", false, io.Discard); err.IsNotEmpty() || failed != 0 {
+		t.Fatalf("load = %d, %v", failed, err)
+	}
+	if out.String() != "\"b c\" = \"two words\"\nd = 4.5f\n" {
+		t.Fatalf("loaded entries %q", out.String())
+	}
+}
+
+func TestSQL(t *testing.T) {
+	dir := t.TempDir()
+	e, err := engine.Open(engine.BPlusTree, filepath.Join(dir, "cli.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	defer e.Close()
+	var out strings.Builder
+	c := newCLI(e, &out)
+
+	dump := filepath.Join(dir, "dump.txt")
+	script := `sql CREATE TABLE pets (name TEXT PRIMARY KEY, legs INT)
+sql INSERT INTO pets VALUES ('cat', 4), ('bird "tweety"', 2)
+sql UPDATE pets SET legs = legs + 1 WHERE name = 'cat'
+sql SELECT name, legs FROM pets WHERE legs > 1
+.tables
+.schema pets
+insert k 1
+count
+scan
+range "" ""
+.dump ` + dump + `
+`
+	if failed, _, err := c.run(strings.NewReader(script), "script", false, io.Discard); err.IsNotEmpty() || failed != 0 {
+		t.Fatalf("run = %d, %v", failed, err)
+	}
+	// The key-value commands see neither the catalog nor the rows.
+	want := `CREATE TABLE
+INSERT 2
+UPDATE 1
+name               legs
+"bird \"tweety\""  2
+"cat"              5
+(2 rows)
+pets
+CREATE TABLE pets (name TEXT PRIMARY KEY, legs INT);
+1
+k = 1
+k = 1
+`
+	if out.String() != want {
+		t.Fatalf("output %q, want %q", out.String(), want)
+	}
+	for _, command := range []string{`insert "\x00sql\x00table\x00pets" 1`, `delete "\x00sql\x00row\x00pets\x00cat"`} {
+		if _, err := c.execute(command); !err.ContainsError(lib.InvalidInputError) {
+			t.Fatalf("%s: got %v, want an invalid input error", command, err)
+		}
+	}
+
+	dumped, readErr := os.ReadFile(dump)
+	if readErr != nil {
+		t.Fatalf("read dump: %v", readErr)
+	}
+	wantDump := `insert k 1
+sql CREATE TABLE pets (name TEXT PRIMARY KEY, legs INT)
+sql INSERT INTO pets VALUES ('bird "tweety"', 2)
+sql INSERT INTO pets VALUES ('cat', 5)
+`
+	if string(dumped) != wantDump {
+		t.Fatalf("dump %q, want %q", dumped, wantDump)
+	}
+
+	// The dump carries the tables over as sql commands.
+	other, err := engine.Open(engine.BPlusTree, filepath.Join(dir, "other.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	defer other.Close()
+	out.Reset()
+	c = newCLI(other, &out)
+	if failed, _, err := c.run(strings.NewReader(".load "+dump+"\nsql SELECT legs FROM pets WHERE name = 'cat'\n"), "script", false, io.Discard); err.IsNotEmpty() || failed != 0 {
+		t.Fatalf("load = %d, %v", failed, err)
+	}
+	if want := "CREATE TABLE\nINSERT 1\nINSERT 1\nlegs\n5\n(1 row)\n"; out.String() != want {
+		t.Fatalf("output %q, want %q", out.String(), want)
+	}
+
+	lsm, err := engine.Open(engine.LSMTree, filepath.Join(dir, "lsm"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	defer lsm.Close()
+	if _, err := newCLI(lsm, io.Discard).execute("sql SELECT * FROM pets"); err.IsEmpty() {
+		t.Fatalf("sql ran on an LSM tree")
+	}
+}
+
+// runScript runs the shell on the database in dir with the given script
+// and returns its exit status.
+func runScript(t *testing.T, dir, script string) int {
+	t.Helper()
+	path := filepath.Join(dir, "script.txt")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args, commandLine := os.Args, flag.CommandLine
+	defer func() { os.Args, flag.CommandLine = args, commandLine }()
+	os.Args = []string{"B-trees", "-db", filepath.Join(dir, "cli.db"), "-f", path}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	return runMain()
+}
+
+func TestExitStatusOnFailedClose(t *testing.T) {
+	dir := t.TempDir()
+	if status := runScript(t, dir, "insert k 1\n"); status != exitOK {
+		t.Fatalf("script exited with %d, want %d", status, exitOK)
+	}
+
+	// An engine closed underneath the shell fails its final flush.
+	defer func() { openEngine = engine.OpenWithOptions }()
+	openEngine = func(kind engine.Kind, path string, options engine.Options) (*engine.Engine, lib.Error) {
+		e, err := engine.OpenWithOptions(kind, path, options)
+		if err.IsEmpty() {
+			err = e.Close()
+		}
+		return e, err
+	}
+	if status := runScript(t, dir, ""); status != exitFailed {
+		t.Fatalf("script exited with %d after a failed close, want %d", status, exitFailed)
+	}
+}
@@ -1,6 +1,28 @@
 package constants
 
 const (
-	MaxNodesInTreeNode = 2 // max 113
-	PageSize           = 4096
+	PageSize = 4096
+
+	// TreeNodeHeaderSize is the serialized size of the fixed part of a tree
+	// node: page ID, leaf flag, entry count, next and parent page IDs.
+	TreeNodeHeaderSize = 15
+	ChildPointerSize   = 4
+
+	// A non-root node underflows once it fills less than 1/MinFillFraction of
+	// its page. A single entry may take at most 1/MaxEntryFraction of a page,
+	// which guarantees an overflowing node always splits into two valid halves
+	// and two underflowing siblings can always be merged or rebalanced.
+	MinFillFraction  = 4
+	MaxEntryFraction = 4
+
+	// String values longer than 1/OverflowFraction of a page are moved to a
+	// chain of overflow pages and the leaf only keeps a reference to them.
+	OverflowFraction = 8
+
+	// A key that would make its entry too large is moved to a chain of
+	// overflow pages as well. The node then stores OverflowKeyMarker where
+	// the key length goes, followed by the chain's first page and the key
+	// length, 4 bytes each.
+	OverflowKeyMarker = 0xffff
+	OverflowKeySize   = 2 + 4 + 4
 )
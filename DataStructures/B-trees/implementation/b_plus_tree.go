@@ -2,18 +2,48 @@ package implementation
 
 import (
 	"fmt"
+	"sync"
 
+	datastructures "github.com/Kush/Database-internals/DataStructures"
 	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
 	"github.com/Kush/Database-internals/diskStorage/pagination"
 	"github.com/Kush/Database-internals/lib"
 	"github.com/Kush/Database-internals/pkg/serialization"
 )
 
+var _ datastructures.BaseDatabaseStructure = (*BPlusTree)(nil)
+
+// BPlusTree is safe for concurrent use. Operations couple page latches on
+// their way down (see latch.go); on top of that:
+//   - rootLatch guards root and height,
+//   - headerMu serializes everything that rewrites the header page, i.e.
+//     allocation, freeing and root changes,
+//   - writers hold syncLatch shared while they change pages and exclusively
+//     while they commit, so a Sync never captures half an operation; a
+//     transaction holds it exclusively throughout,
+//   - every operation outside a transaction holds rollbackLatch shared, and
+//     a rollback takes it exclusively so nobody reads pages while staged
+//     writes are discarded; a transaction holds it exclusively throughout,
+//     so its writes are not seen before it commits.
+//
+// Snapshots of a copy-on-write tree bypass all of this (see copy_on_write.go).
 type BPlusTree struct {
 	root             pagination.PageID
+	height           int
 	pager            pagination.BasePagination
 	serializer       serialization.BaseNodeSerializer[*node.TreeNode]
 	binarySerializer serialization.BaseSerializer
+
+	latches       *pageLatches
+	rootLatch     sync.RWMutex
+	headerMu      sync.Mutex
+	syncLatch     sync.RWMutex
+	rollbackLatch sync.RWMutex
+	// epoch counts rollbacks, which also discard the uncommitted writes of
+	// operations that finished concurrently with the failed one.
+	epoch uint64
+	// cow is set in copy-on-write mode.
+	cow *cowState
 }
 
 func NewBPlusTree(
@@ -27,66 +57,88 @@ func NewBPlusTree(
 		pager:            pager,
 		serializer:       serializer,
 		binarySerializer: binarySerializer,
+		latches:          newPageLatches(),
 	}
 }
 
+// Init opens the tree stored in the pager. A fresh file gets a superblock and
+// an empty root leaf; an existing one must carry a header this build
+// understands, otherwise Init refuses it with a FileFormatError.
 func (b *BPlusTree) Init() lib.Error {
-	var initPage pagination.PageID = 0
-	var err lib.Error
-	if b.pager.NumPages() == 0 {
-		initPage, err = b.pager.AllocatePage()
-		if err.IsNotEmpty() {
+	if logged, ok := b.pager.(pagination.LoggedPagination); ok {
+		if err := logged.Recover(); err.IsNotEmpty() {
 			return err
 		}
 	}
-	buf, err := b.pager.ReadPage(initPage)
-	if err.IsNotEmpty() {
-		return err
-	}
 
-	if len(buf) < 4 {
-		return lib.EmptyError()
+	if b.pager.NumPages() == 0 {
+		if err := pagination.WriteHeader(b.pager, pagination.NewHeader(b.pager.PageSize())); err.IsNotEmpty() {
+			return err
+		}
 	}
-
-	var rootPageID uint32
-	if e := b.binarySerializer.Deserialize(buf[:4], &rootPageID); e.IsNotEmpty() {
-		return e
+	if err := b.loadRoot(); err.IsNotEmpty() {
+		return err
 	}
 
-	if rootPageID == 0 {
-		newPageID, err := b.pager.AllocatePage()
+	if b.root == 0 {
+		newPageID, err := b.allocatePage()
 		if err.IsNotEmpty() {
 			return err
 		}
 
 		newRoot := node.NewLeafTreeNode(newPageID)
-		if err.IsNotEmpty() {
-			return err
-		}
-		rootPageID = uint32(newPageID)
 		if err := b.SaveNode(newRoot); err.IsNotEmpty() {
 			return err
 		}
-		err = b.updateRoot(newPageID)
+		err = b.updateRoot(newPageID, 1)
 		if err.IsNotEmpty() {
 			return err
 		}
 	}
-	b.root = pagination.PageID(rootPageID)
 
-	return lib.EmptyError()
+	return b.syncAndPublish()
 }
 
-func (b *BPlusTree) updateRoot(newRoot pagination.PageID) lib.Error {
+// updateRoot records a new root of the given height. Callers hold rootLatch
+// exclusively.
+func (b *BPlusTree) updateRoot(newRoot pagination.PageID, height int) lib.Error {
 	if newRoot == 0 {
 		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("updateRoot: newRoot is zero"))
 	}
-	b.root = newRoot
-	buff, err := b.binarySerializer.Serialize(uint32(newRoot))
+	b.headerMu.Lock()
+	defer b.headerMu.Unlock()
+
+	header, err := pagination.ReadHeader(b.pager)
 	if err.IsNotEmpty() {
 		return err
 	}
-	return b.pager.WritePage(0, buff)
+	header.RootPage = newRoot
+	if err := pagination.WriteHeader(b.pager, header); err.IsNotEmpty() {
+		return err
+	}
+	b.root, b.height = newRoot, height
+	return lib.EmptyError()
+}
+
+func (b *BPlusTree) rootPage() pagination.PageID {
+	b.rootLatch.RLock()
+	defer b.rootLatch.RUnlock()
+
+	return b.root
+}
+
+func (b *BPlusTree) allocatePage() (pagination.PageID, lib.Error) {
+	b.headerMu.Lock()
+	defer b.headerMu.Unlock()
+
+	return b.pager.AllocatePage()
+}
+
+func (b *BPlusTree) freePage(pageID pagination.PageID) lib.Error {
+	b.headerMu.Lock()
+	defer b.headerMu.Unlock()
+
+	return b.pager.FreePage(pageID)
 }
 
 func (b *BPlusTree) LoadTreeNode(pageID pagination.PageID) (*node.TreeNode, lib.Error) {
@@ -102,17 +154,134 @@ func (b *BPlusTree) LoadTreeNode(pageID pagination.PageID) (*node.TreeNode, lib.
 	if e := b.serializer.Deserialize(buf, node); e.IsNotEmpty() {
 		return nil, e
 	}
+	if e := b.loadKeys(node); e.IsNotEmpty() {
+		return nil, e
+	}
 	return node, lib.EmptyError()
 }
 
 func (b *BPlusTree) SaveNode(node *node.TreeNode) lib.Error {
+	if _, err := b.storeKeys(node); err.IsNotEmpty() {
+		return err
+	}
 	buf, err := b.serializer.Serialize(node)
 	if err.IsNotEmpty() {
 		return err
 	}
-	err = b.pager.WritePage(node.PageID(), buf)
+	if len(buf) > pagination.UsablePageSize(b.pager) {
+		return lib.EmptyError().AddErr(lib.SerializationError, fmt.Errorf("tree node %d needs %d bytes, only %d fit next to the page trailer", node.PageID(), len(buf), pagination.UsablePageSize(b.pager)))
+	}
+	return b.pager.WritePage(node.PageID(), buf)
+}
+
+// nodeCapacity is the number of bytes a serialized tree node may occupy.
+func (b *BPlusTree) nodeCapacity() int {
+	return pagination.UsablePageSize(b.pager)
+}
+
+// mutate runs op as one atomic write. Writers change pages concurrently
+// under the shared side of syncLatch and commit under its exclusive side.
+func (b *BPlusTree) mutate(op func() lib.Error) lib.Error {
+	epoch, opErr := b.apply(op)
+
+	b.syncLatch.Lock()
+	defer b.unlockSync()
+	return b.commit(epoch, opErr)
+}
+
+// Sync commits whatever the pager still has staged. It waits for running
+// writes and transactions like a commit does, so that it never captures half
+// of one. Every operation commits on its own, so this only matters before
+// closing the pager.
+func (b *BPlusTree) Sync() lib.Error {
+	b.syncLatch.Lock()
+	defer b.unlockSync()
+	return b.pager.Sync()
+}
+
+// apply runs op under the shared latches. A panic in op becomes its error,
+// so that commit rolls the operation back like any other failed one.
+func (b *BPlusTree) apply(op func() lib.Error) (epoch uint64, err lib.Error) {
+	b.syncLatch.RLock()
+	defer b.syncLatch.RUnlock()
+	b.rollbackLatch.RLock()
+	defer b.rollbackLatch.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	epoch = b.epoch
+	return epoch, op()
+}
+
+// commit ends a mutating operation. On success every page it wrote is made
+// durable with a single Sync, which a logged pager turns into one atomic
+// commit. On failure a logged pager drops the partial writes and the root
+// pointer is reloaded from page 0. Either failure also loses the writes of
+// operations that finished since the last commit; they notice through epoch.
+func (b *BPlusTree) commit(epoch uint64, opErr lib.Error) lib.Error {
+	if b.epoch != epoch {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("commit: writes were discarded by the rollback of a concurrent operation"))
+	}
+	if opErr.IsEmpty() {
+		if err := b.syncAndPublish(); err.IsNotEmpty() {
+			b.epoch++
+			return err
+		}
+		return lib.EmptyError()
+	}
+
+	if logged, ok := b.pager.(pagination.LoggedPagination); ok {
+		b.rollbackLatch.Lock()
+		defer b.rollbackLatch.Unlock()
+
+		b.epoch++
+		if err := b.rollback(logged); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return opErr
+}
+
+// rollback drops the staged writes and the root changes that came with them.
+func (b *BPlusTree) rollback(logged pagination.LoggedPagination) lib.Error {
+	if err := logged.Rollback(); err.IsNotEmpty() {
+		return err
+	}
+	if b.copyOnWrite() {
+		b.cow.discard()
+	}
+	return b.loadRoot()
+}
+
+// loadRoot reads the root from the header and measures the tree's height.
+func (b *BPlusTree) loadRoot() lib.Error {
+	header, err := pagination.ReadHeader(b.pager)
 	if err.IsNotEmpty() {
 		return err
 	}
-	return b.pager.Sync()
+
+	height := 0
+	for pageID := header.RootPage; pageID != 0; {
+		tn, err := b.LoadTreeNode(pageID)
+		if err.IsNotEmpty() {
+			return err
+		}
+		height++
+		if tn.IsLeaf() {
+			break
+		}
+		if len(tn.ChildTreeNodes()) == 0 {
+			return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("loadRoot: internal page %d has no children", pageID))
+		}
+		pageID = tn.ChildTreeNodes()[0]
+	}
+
+	b.rootLatch.Lock()
+	defer b.rootLatch.Unlock()
+
+	b.root, b.height = header.RootPage, height
+	return lib.EmptyError()
 }
@@ -0,0 +1,191 @@
+package implementation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/bufferpool"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// openLoggedTree opens the tree in dir behind a buffer pool and a
+// write-ahead log, as the engines do. The caller closes the returned pager.
+func openLoggedTree(t *testing.T, dir string, copyOnWrite bool) (*BPlusTree, *wal.Pager) {
+	t.Helper()
+	pager, err := pagination.NewPager(filepath.Join(dir, "tree.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	pool := bufferpool.NewBufferPool(pager, 32, bufferpool.NewLRUReplacer(32))
+	walPager, err := wal.NewPager(pool, filepath.Join(dir, "tree.db.wal"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open wal: %v", err.Errors())
+	}
+	if err := walPager.Recover(); err.IsNotEmpty() {
+		t.Fatalf("recover: %v", err.Errors())
+	}
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := NewBPlusTree(0, walPager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	tree.SetCopyOnWrite(copyOnWrite)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return tree, walPager
+}
+
+func insertKeys(t *testing.T, tree *BPlusTree, from, to int) {
+	t.Helper()
+	if err := insertRange(tree, from, to); err.IsNotEmpty() {
+		t.Fatalf("insert: %v", err.Errors())
+	}
+}
+
+func insertRange(tree *BPlusTree, from, to int) lib.Error {
+	for i := from; i < to; i++ {
+		if err := tree.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// checkKeys checks that exactly the keys from to to are in the tree.
+func checkKeys(t *testing.T, tree *BPlusTree, from, to int) {
+	t.Helper()
+	it := tree.Scan("", "")
+	defer it.Close()
+	i := from
+	for ; it.Next(); i++ {
+		if want := fmt.Sprintf("key-%05d", i); it.Key() != want {
+			t.Fatalf("scan found %s, want %s", it.Key(), want)
+		}
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	if i != to {
+		t.Fatalf("scan found keys %d to %d, want up to %d", from, i, to)
+	}
+}
+
+// checkSound fails the test if Verify finds anything wrong with the tree,
+// such as pages neither in the tree nor on the free list.
+func checkSound(t *testing.T, tree *BPlusTree) VerifyReport {
+	t.Helper()
+	report, err := tree.Verify()
+	if err.IsNotEmpty() {
+		t.Fatalf("verify: %v", err.Errors())
+	}
+	if !report.OK() {
+		t.Fatalf("violations: %+v", report.Violations)
+	}
+	return report
+}
+
+// within runs fn and fails the test if it does not return in time, e.g.
+// because a latch was left held.
+func within(t *testing.T, what string, fn func() error) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s did not return: a latch is still held", what)
+	}
+}
+
+func TestPanicInWriteRollsBack(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+	insertKeys(t, tree, 0, 500)
+
+	err := tree.mutate(func() lib.Error {
+		for i := 500; i < 1000; i++ {
+			tree.insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i)))
+		}
+		panic("boom")
+	})
+	if !errors.Is(err, lib.ErrPanicFound) {
+		t.Fatalf("mutate returned %v, want the recovered panic", err.Errors())
+	}
+
+	within(t, "insert after a panic", func() error { return insertRange(tree, 500, 600).Err() })
+	checkKeys(t, tree, 0, 600)
+}
+
+// noPage is a page number no file reaches.
+const noPage = ^uint32(0)
+
+// panickyPager panics on reads of one page.
+type panickyPager struct {
+	*wal.Pager
+	page atomic.Uint32
+}
+
+func (p *panickyPager) ReadPage(id pagination.PageID) ([]byte, lib.Error) {
+	if uint32(id) == p.page.Load() {
+		panic(fmt.Sprintf("reading page %d", id))
+	}
+	return p.Pager.ReadPage(id)
+}
+
+func TestPanicInDescentReleasesLatches(t *testing.T) {
+	tree, walPager := openLoggedTree(t, t.TempDir(), false)
+	defer walPager.Close()
+	insertKeys(t, tree, 0, 2000)
+
+	pager := &panickyPager{Pager: walPager}
+	pager.page.Store(noPage)
+	tree.pager = pager
+	root, err := tree.LoadTreeNode(tree.rootPage())
+	if err.IsNotEmpty() {
+		t.Fatalf("load root: %v", err.Errors())
+	}
+	if root.IsLeaf() {
+		t.Fatalf("tree of 2000 keys has a single leaf")
+	}
+
+	// Optimistic and pessimistic writes, and reads, all give up on the
+	// child of the root.
+	pager.page.Store(uint32(root.ChildTreeNodes()[0]))
+	within(t, "operations on a panicking page", func() error {
+		if err := tree.Insert("key-00000", common.NewIntValue(0)); !errors.Is(err, lib.ErrPanicFound) {
+			return fmt.Errorf("insert returned %v, want the recovered panic", err)
+		}
+		if err := tree.Delete("key-00000"); !errors.Is(err, lib.ErrPanicFound) {
+			return fmt.Errorf("delete returned %v, want the recovered panic", err)
+		}
+		if _, _, err := tree.Search("key-00000"); !errors.Is(err, lib.ErrPanicFound) {
+			return fmt.Errorf("search returned %v, want the recovered panic", err)
+		}
+		return nil
+	})
+	pager.page.Store(noPage)
+
+	within(t, "writes after a panic", func() error {
+		if err := insertRange(tree, 2000, 2100); err.IsNotEmpty() {
+			return err
+		}
+		for i := 0; i < 100; i++ {
+			if err := tree.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+				return err
+			}
+		}
+		return nil
+	})
+	checkKeys(t, tree, 100, 2100)
+}
@@ -0,0 +1,457 @@
+package implementation
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// DefaultFillFactor leaves some room in bulk loaded nodes so that the first
+// inserts after a load do not split every leaf.
+const DefaultFillFactor = 0.9
+
+// bulkSyncPages is how many pages a bulk load writes between two Syncs, so
+// that a large load does not have to be staged in memory all at once.
+const bulkSyncPages = 4096
+
+// EntryIterator yields entries in increasing key order. *Iterator implements
+// it, so a scan of one tree can feed the bulk load of another.
+type EntryIterator interface {
+	Next() bool
+	Key() string
+	Value() common.Value
+	Err() lib.Error
+}
+
+// BulkLoadOptions tunes a bulk load. FillFactor is the share of a page each
+// node is packed to, between 0.5 and 1; zero means DefaultFillFactor.
+type BulkLoadOptions struct {
+	FillFactor float64
+}
+
+// BulkLoad fills an empty tree from entries sorted by key.
+func (b *BPlusTree) BulkLoad(entries EntryIterator) lib.Error {
+	return b.BulkLoadWithOptions(entries, BulkLoadOptions{})
+}
+
+// BulkLoadWithOptions packs the entries into leaves from left to right,
+// filling the internal levels above them as it goes so that every node is
+// written knowing its parent, and only switches the header to the new root
+// at the very end. Until then the new pages are
+// unreachable, so concurrent readers keep seeing the empty tree, and a load
+// that fails returns the pages it wrote to the free list. A crash during the
+// load leaks them.
+//
+// Entries must come in strictly increasing key order; otherwise the load
+// stops with an InvalidInputError and the tree stays empty.
+func (b *BPlusTree) BulkLoadWithOptions(entries EntryIterator, options BulkLoadOptions) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	fillFactor := options.FillFactor
+	if fillFactor == 0 {
+		fillFactor = DefaultFillFactor
+	}
+	if fillFactor < 0.5 || fillFactor > 1 {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("bulk load: fill factor %v is outside [0.5, 1]", fillFactor))
+	}
+	if b.rootPage() == 0 {
+		return lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("bulk load: tree is not initialized"))
+	}
+
+	// Writers and transactions wait for the load; readers do not need to.
+	b.syncLatch.Lock()
+	defer b.unlockSync()
+	if err := b.syncAndPublish(); err.IsNotEmpty() {
+		b.epoch++
+		return err
+	}
+
+	oldRoot, err := b.emptyRoot()
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	loader := &bulkLoader{tree: b, target: int(fillFactor * float64(b.nodeCapacity()))}
+	root, height, err := loader.run(entries)
+	if err.IsNotEmpty() {
+		loader.abort()
+		return err
+	}
+	if root == 0 {
+		return lib.EmptyError()
+	}
+	if err := b.switchRoot(oldRoot, root, height); err.IsNotEmpty() {
+		loader.abort()
+		return err
+	}
+	if err := b.syncAndPublish(); err.IsNotEmpty() {
+		b.epoch++
+		return err
+	}
+	return lib.EmptyError()
+}
+
+// emptyRoot returns the root page if the tree holds no entries.
+func (b *BPlusTree) emptyRoot() (pagination.PageID, lib.Error) {
+	root := b.rootPage()
+	b.latches.lock(root, shared)
+	defer b.latches.unlock(root, shared)
+
+	tn, err := b.LoadTreeNode(root)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	if !tn.IsLeaf() || tn.NodesCount() > 0 {
+		return 0, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("bulk load: tree is not empty"))
+	}
+	return root, lib.EmptyError()
+}
+
+// switchRoot replaces the empty root leaf with the loaded tree. It only
+// fails if the header still points at the old root; once it points at the
+// new one the load stands, and an old root that cannot be freed leaks.
+func (b *BPlusTree) switchRoot(oldRoot, newRoot pagination.PageID, height int) lib.Error {
+	b.rootLatch.Lock()
+	defer b.rootLatch.Unlock()
+
+	if err := b.updateRoot(newRoot, height); err.IsNotEmpty() {
+		return err
+	}
+	if b.copyOnWrite() {
+		b.cow.retire(oldRoot)
+		return lib.EmptyError()
+	}
+	// Wait for readers still looking at the old root.
+	b.latches.lock(oldRoot, exclusive)
+	defer b.latches.unlock(oldRoot, exclusive)
+	b.freePage(oldRoot)
+	return lib.EmptyError()
+}
+
+// bulkLoader builds a tree bottom-up and remembers every page it wrote, so
+// that a failed load can give them back.
+type bulkLoader struct {
+	tree   *BPlusTree
+	target int
+	// pages and overflows are the tree pages and overflow chain heads
+	// written so far; the first durablePages and durableOverflows of them
+	// survived a Sync.
+	pages            []pagination.PageID
+	overflows        []pagination.PageID
+	durablePages     int
+	durableOverflows int
+	unsynced         int
+}
+
+// childRef is a node of a finished level: its page and the separator for
+// the smallest key below it, which routes to it from the level above.
+type childRef struct {
+	low  node.Node
+	page pagination.PageID
+}
+
+// levelBuilder packs the nodes of one level from left to right and hands
+// each finished one to the level above. The previous node is held back
+// until the level ends, so that a last node too small to stand on its own
+// can be merged into it or evened out with it.
+type levelBuilder struct {
+	loader        *bulkLoader
+	leaf          bool
+	prev, current *node.TreeNode
+	prevLow, low  node.Node
+	// first is the first finished node, held back until a second one shows
+	// that the level is not the root and parent is started.
+	first    *node.TreeNode
+	firstLow node.Node
+	parent   *levelBuilder
+}
+
+// run is build with a panic, e.g. of the entry iterator, turned into an
+// error, so that the load is aborted like any other failed one.
+func (l *bulkLoader) run(entries EntryIterator) (root pagination.PageID, height int, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	return l.build(entries)
+}
+
+// build loads the entries and returns the root and height of the new tree,
+// or a zero root if there were no entries.
+func (l *bulkLoader) build(entries EntryIterator) (pagination.PageID, int, lib.Error) {
+	leaves := &levelBuilder{loader: l, leaf: true}
+	previous, first := "", true
+	for entries.Next() {
+		key, value := entries.Key(), entries.Value()
+		if !first && key <= previous {
+			return 0, 0, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("bulk load: key %q comes after %q, entries must be sorted by key without duplicates", key, previous))
+		}
+		previous, first = key, false
+
+		entry, err := l.tree.newEntry(key, value)
+		if err.IsNotEmpty() {
+			return 0, 0, err
+		}
+		if entry.HasOverflow() {
+			l.overflows = append(l.overflows, entry.OverflowPage())
+			l.unsynced += value.Len()/pagination.UsablePageSize(l.tree.pager) + 1
+		}
+		if err := leaves.addEntry(entry); err.IsNotEmpty() {
+			return 0, 0, err
+		}
+	}
+	if err := entries.Err(); err.IsNotEmpty() {
+		return 0, 0, err
+	}
+	if first {
+		return 0, 0, lib.EmptyError()
+	}
+
+	// Finishing a level hands its last nodes to the one above, and the level
+	// left with a single node holds the root.
+	height := 1
+	level := leaves
+	for ; ; height++ {
+		if err := level.finish(); err.IsNotEmpty() {
+			return 0, 0, err
+		}
+		if level.parent == nil {
+			break
+		}
+		level = level.parent
+	}
+	if err := l.write(level.first); err.IsNotEmpty() {
+		return 0, 0, err
+	}
+	return level.first.PageID(), height, lib.EmptyError()
+}
+
+// allocate takes a page for a new node.
+func (l *bulkLoader) allocate() (pagination.PageID, lib.Error) {
+	pageID, err := l.tree.allocatePage()
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	l.pages = append(l.pages, pageID)
+	return pageID, lib.EmptyError()
+}
+
+// write saves a finished node and syncs once enough pages piled up.
+func (l *bulkLoader) write(tn *node.TreeNode) lib.Error {
+	heads, err := l.tree.storeKeys(tn)
+	l.overflows = append(l.overflows, heads...)
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, n := range tn.Nodes() {
+		if slices.Contains(heads, n.KeyOverflowPage()) {
+			l.unsynced += int(n.KeyOverflowLength())/pagination.UsablePageSize(l.tree.pager) + 1
+		}
+	}
+	if err := l.tree.SaveNode(tn); err.IsNotEmpty() {
+		return err
+	}
+	l.unsynced++
+	if l.unsynced < bulkSyncPages {
+		return lib.EmptyError()
+	}
+	if err := l.tree.pager.Sync(); err.IsNotEmpty() {
+		return err
+	}
+	l.unsynced = 0
+	l.durablePages, l.durableOverflows = len(l.pages), len(l.overflows)
+	return lib.EmptyError()
+}
+
+// reparent rewrites a written node that moved to another parent.
+func (l *bulkLoader) reparent(child, parent pagination.PageID) lib.Error {
+	tn, err := l.tree.LoadTreeNode(child)
+	if err.IsNotEmpty() {
+		return err
+	}
+	tn.SetParentNode(parent)
+	return l.write(tn)
+}
+
+// abort frees what a failed load wrote. With a logged pager the writes since
+// the last Sync are rolled back instead. Pages that cannot be freed leak.
+func (l *bulkLoader) abort() {
+	b := l.tree
+	pages, overflows := l.pages, l.overflows
+	if logged, ok := b.pager.(pagination.LoggedPagination); ok {
+		b.rollbackLatch.Lock()
+		err := b.rollback(logged)
+		b.rollbackLatch.Unlock()
+		if err.IsNotEmpty() {
+			return
+		}
+		pages, overflows = pages[:l.durablePages], overflows[:l.durableOverflows]
+	}
+
+	b.headerMu.Lock()
+	defer b.headerMu.Unlock()
+	for _, head := range overflows {
+		if err := pagination.FreeOverflow(b.pager, head); err.IsNotEmpty() {
+			return
+		}
+	}
+	for _, pageID := range pages {
+		if err := b.pager.FreePage(pageID); err.IsNotEmpty() {
+			return
+		}
+	}
+	b.pager.Sync()
+}
+
+func (lb *levelBuilder) addEntry(entry node.Node) lib.Error {
+	if lb.current == nil || (lb.current.NodesCount() > 0 && lb.current.ByteSize()+entry.ByteSize() > lb.loader.target) {
+		if err := lb.startNode(entry.Separator()); err.IsNotEmpty() {
+			return err
+		}
+	}
+	lb.current.AddNode(entry)
+	return lib.EmptyError()
+}
+
+func (lb *levelBuilder) addChild(ref childRef) lib.Error {
+	separator := ref.low
+	if lb.current == nil || (len(lb.current.ChildTreeNodes()) > 1 && lb.current.ByteSize()+separator.ByteSize()+constants.ChildPointerSize > lb.loader.target) {
+		if err := lb.startNode(ref.low); err.IsNotEmpty() {
+			return err
+		}
+		lb.current.AddChildTreeNode(ref.page)
+		return lib.EmptyError()
+	}
+	lb.current.AddNode(separator)
+	lb.current.AddChildTreeNode(ref.page)
+	return lib.EmptyError()
+}
+
+// startNode writes the held back node and holds back the current one.
+func (lb *levelBuilder) startNode(low node.Node) lib.Error {
+	pageID, err := lb.loader.allocate()
+	if err.IsNotEmpty() {
+		return err
+	}
+	if lb.prev != nil {
+		if err := lb.emit(lb.prev, lb.prevLow); err.IsNotEmpty() {
+			return err
+		}
+	}
+	if lb.current != nil && lb.leaf {
+		lb.current.SetNext(pageID)
+	}
+	lb.prev, lb.prevLow = lb.current, lb.low
+
+	if lb.leaf {
+		lb.current = node.NewLeafTreeNode(pageID)
+	} else {
+		lb.current = node.NewInternalNode(pageID)
+	}
+	lb.low = low
+	return lib.EmptyError()
+}
+
+// emit adds a finished node to the level above and writes it.
+func (lb *levelBuilder) emit(tn *node.TreeNode, low node.Node) lib.Error {
+	if lb.parent == nil {
+		if lb.first == nil {
+			lb.first, lb.firstLow = tn, low
+			return lib.EmptyError()
+		}
+		lb.parent = &levelBuilder{loader: lb.loader}
+		first := lb.first
+		lb.first = nil
+		if err := lb.emit(first, lb.firstLow); err.IsNotEmpty() {
+			return err
+		}
+	}
+
+	if err := lb.parent.addChild(childRef{low: low, page: tn.PageID()}); err.IsNotEmpty() {
+		return err
+	}
+	tn.SetParentNode(lb.parent.current.PageID())
+	return lb.loader.write(tn)
+}
+
+// finish emits the last nodes of the level. A last node that underflows is
+// merged into its left neighbour when both fit in one page; otherwise their
+// entries are split evenly between the two. Children that end up under the
+// other one of them are rewritten to point at it.
+func (lb *levelBuilder) finish() lib.Error {
+	capacity := lb.loader.tree.nodeCapacity()
+	if lb.prev == nil || !lb.current.IsUnderflow(capacity) {
+		if lb.prev != nil {
+			if err := lb.emit(lb.prev, lb.prevLow); err.IsNotEmpty() {
+				return err
+			}
+		}
+		return lb.emit(lb.current, lb.low)
+	}
+
+	prevChildren := append([]pagination.PageID{}, lb.prev.ChildTreeNodes()...)
+	combined := lb.prev
+	if !lb.leaf {
+		combined.AddNode(lb.low)
+	}
+	combined.AddNodes(lb.current.Nodes()...)
+	for _, child := range lb.current.ChildTreeNodes() {
+		combined.AddChildTreeNode(child)
+	}
+
+	if !combined.IsFull(capacity) {
+		combined.SetNext(0)
+		// The page of the last node was never written, so it can go straight
+		// back to the free list.
+		if err := lb.loader.tree.freePage(lb.current.PageID()); err.IsNotEmpty() {
+			return err
+		}
+		if i := slices.Index(lb.loader.pages, lb.current.PageID()); i >= 0 {
+			lb.loader.pages = slices.Delete(lb.loader.pages, i, i+1)
+		}
+		for _, child := range lb.current.ChildTreeNodes() {
+			if err := lb.loader.reparent(child, combined.PageID()); err.IsNotEmpty() {
+				return err
+			}
+		}
+		return lb.emit(combined, lb.prevLow)
+	}
+
+	left, right, separator, err := node.SplitTreeNode(combined)
+	if err.IsNotEmpty() {
+		return err
+	}
+	right.SetPageID(lb.current.PageID())
+	if lb.leaf {
+		left.SetNext(right.PageID())
+	}
+	for _, child := range left.ChildTreeNodes() {
+		if !slices.Contains(prevChildren, child) {
+			if err := lb.loader.reparent(child, left.PageID()); err.IsNotEmpty() {
+				return err
+			}
+		}
+	}
+	for _, child := range right.ChildTreeNodes() {
+		if slices.Contains(prevChildren, child) {
+			if err := lb.loader.reparent(child, right.PageID()); err.IsNotEmpty() {
+				return err
+			}
+		}
+	}
+	if err := lb.emit(left, lb.prevLow); err.IsNotEmpty() {
+		return err
+	}
+	return lb.emit(right, separator)
+}
@@ -0,0 +1,292 @@
+package implementation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// keyEntries yields key-%05d for each of keys, with the number as value,
+// or a string of valueSize bytes if that is set. It panics instead of
+// yielding the entry at panicAt, if that is set.
+type keyEntries struct {
+	keys      []int
+	next      int
+	panicAt   int
+	valueSize int
+}
+
+func (e *keyEntries) Next() bool {
+	e.next++
+	if e.panicAt > 0 && e.next == e.panicAt {
+		panic("entry source failed")
+	}
+	return e.next <= len(e.keys)
+}
+
+func (e *keyEntries) Key() string {
+	return fmt.Sprintf("key-%05d", e.keys[e.next-1])
+}
+
+func (e *keyEntries) Value() common.Value {
+	if e.valueSize > 0 {
+		return common.NewStringValue(strings.Repeat("v", e.valueSize))
+	}
+	return common.NewIntValue(int64(e.keys[e.next-1]))
+}
+
+func (e *keyEntries) Err() lib.Error {
+	return lib.EmptyError()
+}
+
+func keyRangeEntries(from, to int) *keyEntries {
+	e := &keyEntries{}
+	for i := from; i < to; i++ {
+		e.keys = append(e.keys, i)
+	}
+	return e
+}
+
+func TestBulkLoadPanicAborts(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+
+	entries := keyRangeEntries(0, 5000)
+	entries.panicAt = 4000
+	if err := tree.BulkLoad(entries); !errors.Is(err, lib.ErrPanicFound) {
+		t.Fatalf("bulk load returned %v, want the recovered panic", err.Errors())
+	}
+	checkSound(t, tree)
+	checkKeys(t, tree, 0, 0)
+
+	if err := tree.BulkLoad(keyRangeEntries(0, 5000)); err.IsNotEmpty() {
+		t.Fatalf("bulk load: %v", err.Errors())
+	}
+	checkSound(t, tree)
+	checkKeys(t, tree, 0, 5000)
+}
+
+func TestBulkLoadSorted(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+
+	if err := tree.BulkLoad(keyRangeEntries(0, 100000)); err.IsNotEmpty() {
+		t.Fatalf("bulk load: %v", err.Errors())
+	}
+	report := checkSound(t, tree)
+	if report.Keys != 100000 || report.Height < 3 {
+		t.Fatalf("unexpected report after loading 100000 keys: %+v", report)
+	}
+	checkKeys(t, tree, 0, 100000)
+
+	// The loaded tree takes ordinary writes.
+	insertKeys(t, tree, 100000, 101000)
+	deleteKeys(t, tree, 0, 101000, 50)
+	checkSound(t, tree)
+}
+
+// checkEmptyAfterAbort checks that a failed load left the tree empty and
+// gave back every page it wrote.
+func checkEmptyAfterAbort(t *testing.T, tree *BPlusTree) {
+	t.Helper()
+	report := checkSound(t, tree)
+	if report.Keys != 0 || report.TreePages != 1 || report.FreePages != report.PageCount-2 {
+		t.Fatalf("unexpected report after an aborted load: %+v", report)
+	}
+}
+
+func TestBulkLoadRejectsUnsortedInput(t *testing.T) {
+	unsorted := keyRangeEntries(0, 5000)
+	unsorted.keys[4000], unsorted.keys[4001] = unsorted.keys[4001], unsorted.keys[4000]
+	duplicate := keyRangeEntries(0, 5000)
+	duplicate.keys[4001] = duplicate.keys[4000]
+
+	for name, entries := range map[string]*keyEntries{"unsorted": unsorted, "duplicate": duplicate} {
+		t.Run(name, func(t *testing.T) {
+			tree, pager := openLoggedTree(t, t.TempDir(), false)
+			defer pager.Close()
+
+			if err := tree.BulkLoad(entries); !errors.Is(err, lib.ErrInvalidInput) {
+				t.Fatalf("bulk load returned %v, want an InvalidInputError", err.Errors())
+			}
+			checkEmptyAfterAbort(t, tree)
+		})
+	}
+}
+
+// TestBulkLoadAbortAfterSync fails a load that already synced some of its
+// pages, which have to be freed as well.
+func TestBulkLoadAbortAfterSync(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+
+	entries := keyRangeEntries(0, 2500)
+	entries.valueSize = 3 * pager.PageSize()
+	entries.keys[2499] = 0
+	if err := tree.BulkLoad(entries); !errors.Is(err, lib.ErrInvalidInput) {
+		t.Fatalf("bulk load returned %v, want an InvalidInputError", err.Errors())
+	}
+	if report := checkSound(t, tree); report.FreePages < bulkSyncPages {
+		t.Fatalf("only %d free pages after the load, it synced at least %d", report.FreePages, bulkSyncPages)
+	}
+	checkEmptyAfterAbort(t, tree)
+}
+
+func TestBulkLoadFillFactor(t *testing.T) {
+	for _, fillFactor := range []float64{-1, 0.49, 1.01} {
+		tree, pager := openLoggedTree(t, t.TempDir(), false)
+		err := tree.BulkLoadWithOptions(keyRangeEntries(0, 10), BulkLoadOptions{FillFactor: fillFactor})
+		if !errors.Is(err, lib.ErrInvalidInput) {
+			t.Fatalf("fill factor %v: bulk load returned %v, want an InvalidInputError", fillFactor, err.Errors())
+		}
+		pager.Close()
+	}
+
+	pages := make(map[float64]int)
+	for _, fillFactor := range []float64{0.5, 1} {
+		tree, pager := openLoggedTree(t, t.TempDir(), false)
+		if err := tree.BulkLoadWithOptions(keyRangeEntries(0, 20000), BulkLoadOptions{FillFactor: fillFactor}); err.IsNotEmpty() {
+			t.Fatalf("fill factor %v: bulk load: %v", fillFactor, err.Errors())
+		}
+		pages[fillFactor] = checkSound(t, tree).TreePages
+		checkKeys(t, tree, 0, 20000)
+		pager.Close()
+	}
+	// Half full nodes take about twice the pages.
+	if pages[0.5] < pages[1]*3/2 {
+		t.Fatalf("fill factor 0.5 takes %d pages, 1 takes %d", pages[0.5], pages[1])
+	}
+}
+
+func TestBulkLoadRefusesNonEmptyTree(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+	insertKeys(t, tree, 0, 1)
+
+	if err := tree.BulkLoad(keyRangeEntries(1, 100)); !errors.Is(err, lib.ErrInvalidInput) {
+		t.Fatalf("bulk load returned %v, want an InvalidInputError", err.Errors())
+	}
+	checkKeys(t, tree, 0, 1)
+	checkSound(t, tree)
+
+	// Emptying the tree makes it loadable again.
+	deleteKeys(t, tree, 0, 1, 1)
+	if err := tree.BulkLoad(keyRangeEntries(0, 100)); err.IsNotEmpty() {
+		t.Fatalf("bulk load: %v", err.Errors())
+	}
+	checkKeys(t, tree, 0, 100)
+}
+
+// faultyPager fails a single write of a plain file pager: the freeing of
+// page failFree, or the first page write after any page is freed if
+// failAfterFree is set.
+type faultyPager struct {
+	*pagination.Pager
+	failFree      pagination.PageID
+	failAfterFree bool
+	armed         bool
+}
+
+func (p *faultyPager) FreePage(id pagination.PageID) lib.Error {
+	if id == p.failFree {
+		p.failFree = 0
+		return lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("injected failure freeing page %d", id))
+	}
+	if err := p.Pager.FreePage(id); err.IsNotEmpty() {
+		return err
+	}
+	if p.failAfterFree {
+		p.failAfterFree, p.armed = false, true
+	}
+	return lib.EmptyError()
+}
+
+func (p *faultyPager) WritePage(id pagination.PageID, data []byte) lib.Error {
+	if p.armed {
+		p.armed = false
+		return lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("injected failure writing page %d", id))
+	}
+	return p.Pager.WritePage(id, data)
+}
+
+// openFaultyTree opens a tree directly on a file pager whose failures the
+// test injects.
+func openFaultyTree(t *testing.T) (*BPlusTree, *faultyPager) {
+	t.Helper()
+	filePager, err := pagination.NewPager(filepath.Join(t.TempDir(), "tree.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	t.Cleanup(func() { filePager.Close() })
+	pager := &faultyPager{Pager: filePager}
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := NewBPlusTree(0, pager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return tree, pager
+}
+
+// TestBulkLoadOldRootFreeFails fails the freeing of the empty root once the
+// header already points at the loaded tree. The load has to stand, with the
+// old root leaked.
+func TestBulkLoadOldRootFreeFails(t *testing.T) {
+	tree, pager := openFaultyTree(t)
+	pager.failFree = tree.rootPage()
+
+	if err := tree.BulkLoad(keyRangeEntries(0, 2000)); err.IsNotEmpty() {
+		t.Fatalf("bulk load: %v", err.Errors())
+	}
+	checkKeys(t, tree, 0, 2000)
+	report, err := tree.Verify()
+	if err.IsNotEmpty() {
+		t.Fatalf("verify: %v", err.Errors())
+	}
+	for _, violation := range report.Violations {
+		if violation.Kind != OrphanPage {
+			t.Fatalf("violation other than the leaked old root: %+v", violation)
+		}
+	}
+	if len(report.Violations) != 1 {
+		t.Fatalf("violations: %+v, want only the leaked old root", report.Violations)
+	}
+}
+
+// TestBulkLoadAbortAfterLastLeafMerged fails a load right after its small
+// last leaf was merged into its left neighbour, which happens after the
+// first parent page was allocated.
+func TestBulkLoadAbortAfterLastLeafMerged(t *testing.T) {
+	tree, pager := openFaultyTree(t)
+
+	// Find how many entries a leaf takes, then load three full leaves and
+	// a last one of a single entry.
+	if err := tree.BulkLoad(keyRangeEntries(0, 1000)); err.IsNotEmpty() {
+		t.Fatalf("bulk load: %v", err.Errors())
+	}
+	leaf, err := tree.LoadTreeNode(tree.rootPage())
+	for ; err.IsEmpty() && !leaf.IsLeaf(); leaf, err = tree.LoadTreeNode(leaf.ChildTreeNodes()[0]) {
+	}
+	if err.IsNotEmpty() {
+		t.Fatalf("load leaf: %v", err.Errors())
+	}
+	perLeaf := leaf.NodesCount()
+
+	tree, pager = openFaultyTree(t)
+	pager.failAfterFree = true
+	if err := tree.BulkLoad(keyRangeEntries(0, 3*perLeaf+1)); !errors.Is(err, lib.ErrPagination) {
+		t.Fatalf("bulk load returned %v, want the injected failure", err.Errors())
+	}
+	if pager.failAfterFree || pager.armed {
+		t.Fatalf("the last leaf was not merged")
+	}
+	checkEmptyAfterAbort(t, tree)
+}
@@ -0,0 +1,193 @@
+package implementation
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/bufferpool"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+const (
+	stressWriters       = 8
+	stressReaders       = 4
+	stressKeysPerWriter = 200
+	stressLookups       = 1000
+)
+
+func newStressTree(t *testing.T) *BPlusTree {
+	t.Helper()
+	dir := t.TempDir()
+
+	pager, err := pagination.NewPager(filepath.Join(dir, "stress.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	pool := bufferpool.NewBufferPool(pager, 32, bufferpool.NewLRUReplacer(32))
+	walPager, err := wal.NewPager(pool, filepath.Join(dir, "stress.db.wal"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open wal: %v", err.Errors())
+	}
+	t.Cleanup(func() { walPager.Close() })
+
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := NewBPlusTree(0, walPager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return tree
+}
+
+func stressKey(writer, i int) string {
+	return fmt.Sprintf("w%d-%05d", writer, i)
+}
+
+// stressValue is derived from the key so readers can check what they find.
+// Every tenth value is large enough to go to overflow pages.
+func stressValue(key string, i int) string {
+	if i%10 == 0 {
+		return strings.Repeat(key, 150)
+	}
+	return strings.Repeat(key, 1+i%7)
+}
+
+// TestConcurrentInsertSearchScan runs writers on disjoint key ranges against
+// readers doing point lookups and range scans, then deletes half the keys
+// concurrently. Run it with -race.
+func TestConcurrentInsertSearchScan(t *testing.T) {
+	tree := newStressTree(t)
+
+	var (
+		writers sync.WaitGroup
+		readers sync.WaitGroup
+		stop    = make(chan struct{})
+		errs    = make(chan string, stressWriters+stressReaders+1)
+	)
+
+	for w := 0; w < stressWriters; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < stressKeysPerWriter; i++ {
+				key := stressKey(w, i)
+				if err := tree.Insert(key, common.NewStringValue(stressValue(key, i))); err.IsNotEmpty() {
+					errs <- fmt.Sprintf("insert %s: %v", key, err.Errors())
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < stressReaders; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for n := 0; n < stressLookups; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w, i := (r+n)%stressWriters, (n*31)%stressKeysPerWriter
+				key := stressKey(w, i)
+				value, found, err := tree.Search(key)
+				if err.IsNotEmpty() {
+					errs <- fmt.Sprintf("search %s: %v", key, err.Errors())
+					return
+				}
+				if got := value.StringValue(); found && got != stressValue(key, i) {
+					errs <- fmt.Sprintf("search %s: unexpected value of length %d", key, len(got))
+					return
+				}
+			}
+		}(r)
+	}
+
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			it := tree.Scan("", "")
+			previous := ""
+			for it.Next() {
+				if it.Key() <= previous {
+					errs <- fmt.Sprintf("scan: %q after %q", it.Key(), previous)
+					return
+				}
+				previous = it.Key()
+			}
+			if err := it.Err(); err.IsNotEmpty() {
+				errs <- fmt.Sprintf("scan: %v", err.Errors())
+				return
+			}
+		}
+	}()
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+	if t.Failed() {
+		return
+	}
+
+	var deleters sync.WaitGroup
+	for w := 0; w < stressWriters; w++ {
+		deleters.Add(1)
+		go func(w int) {
+			defer deleters.Done()
+			for i := 0; i < stressKeysPerWriter; i += 2 {
+				if err := tree.Delete(stressKey(w, i)); err.IsNotEmpty() {
+					t.Errorf("delete %s: %v", stressKey(w, i), err.Errors())
+					return
+				}
+			}
+		}(w)
+	}
+	deleters.Wait()
+
+	count := 0
+	it := tree.Scan("", "")
+	for it.Next() {
+		count++
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	if want := stressWriters * stressKeysPerWriter / 2; count != want {
+		t.Fatalf("scan found %d keys, want %d", count, want)
+	}
+
+	for w := 0; w < stressWriters; w++ {
+		for i := 0; i < stressKeysPerWriter; i++ {
+			key := stressKey(w, i)
+			value, found, err := tree.Search(key)
+			if err.IsNotEmpty() {
+				t.Fatalf("search %s: %v", key, err.Errors())
+			}
+			if deleted := i%2 == 0; found == deleted {
+				t.Fatalf("search %s: found is %t after deleting every other key", key, found)
+			}
+			if want := stressValue(key, i); found && value.StringValue() != want {
+				t.Fatalf("search %s: got a value of length %d, want %d", key, len(value.StringValue()), len(want))
+			}
+		}
+	}
+	checkSound(t, tree)
+}
@@ -0,0 +1,30 @@
+package implementation
+
+import (
+	"path/filepath"
+	"testing"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/DataStructures/conformance"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Harness{Open: openConformanceTree})
+}
+
+// openConformanceTree opens a tree directly on a file pager, so every
+// commit goes straight to the file.
+func openConformanceTree(t *testing.T, dir string) (datastructures.BaseDatabaseStructure, func() lib.Error) {
+	pager, err := pagination.NewPager(filepath.Join(dir, "conformance.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := NewBPlusTree(0, pager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	return tree, pager.Close
+}
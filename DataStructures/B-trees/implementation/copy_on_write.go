@@ -0,0 +1,360 @@
+package implementation
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// In copy-on-write mode a writer never changes a page that a committed
+// version of the tree can reach. Every node on its path, and every sibling it
+// rebalances with, is first moved to a fresh page and its parent (or the
+// header, for the root) repointed at the copy. Each commit then publishes the
+// new root as the next version, and a Snapshot pins a published version and
+// reads it without taking any latch.
+//
+// The pages a version stopped using are retired, not freed, and go back to
+// the free list once no open snapshot is older than that version: right
+// after the commit that publishes it, or when the last snapshot pinning them
+// is closed. Those frees are committed with the next write, or when the
+// pager is closed. Retired pages are only tracked in memory, so a crash
+// leaks the ones not freed durably yet.
+//
+// Writers serialize on the root latch for the whole operation, and leaf next
+// links are not kept up to date, as the pages they point at get replaced;
+// scans do not rely on them.
+type cowState struct {
+	mu      sync.Mutex
+	version uint64
+	root    pagination.PageID
+	height  int
+	// pins counts the open snapshots of each version.
+	pins map[uint64]int
+	// retired pages are still used by the versions before their own.
+	retired []retiredPage
+	// pendingRetired are retired by writes that are not committed yet.
+	pendingRetired []pagination.PageID
+	// reclaimDue is set when a closed snapshot made retired pages free to
+	// reclaim while syncLatch was held (see unlockSync).
+	reclaimDue atomic.Bool
+}
+
+type retiredPage struct {
+	page    pagination.PageID
+	version uint64
+}
+
+// SetCopyOnWrite switches copy-on-write mode on or off. It must be called
+// before Init.
+func (b *BPlusTree) SetCopyOnWrite(enabled bool) {
+	if !enabled {
+		b.cow = nil
+		return
+	}
+	b.cow = &cowState{pins: make(map[uint64]int)}
+}
+
+func (b *BPlusTree) copyOnWrite() bool {
+	return b.cow != nil
+}
+
+// shadow moves tn to a fresh page and points its parent at the copy, or the
+// header when parent is nil. The old page is retired. Callers hold rootLatch
+// exclusively.
+func (b *BPlusTree) shadow(tn, parent *node.TreeNode) lib.Error {
+	oldPageID := tn.PageID()
+	newPageID, err := b.allocatePage()
+	if err.IsNotEmpty() {
+		return err
+	}
+	tn.SetPageID(newPageID)
+	if err := b.SaveNode(tn); err.IsNotEmpty() {
+		return err
+	}
+	b.cow.retire(oldPageID)
+
+	if parent == nil {
+		return b.updateRoot(newPageID, b.height)
+	}
+	idx := parent.ChildIndex(oldPageID)
+	if idx < 0 {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("shadow: page %d is not a child of %d", oldPageID, parent.PageID()))
+	}
+	parent.SetChildAt(idx, newPageID)
+	return b.SaveNode(parent)
+}
+
+// retireOverflow retires the pages of an overflow chain that older versions
+// may still read.
+func (b *BPlusTree) retireOverflow(head pagination.PageID) lib.Error {
+	pages, err := pagination.OverflowPages(b.pager, head)
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, page := range pages {
+		b.cow.retire(page)
+	}
+	return lib.EmptyError()
+}
+
+func (c *cowState) retire(page pagination.PageID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pendingRetired = append(c.pendingRetired, page)
+}
+
+// discard forgets the pages retired by writes that were rolled back; the
+// published version still uses them.
+func (c *cowState) discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pendingRetired = nil
+}
+
+// publish makes root the current version. The pages retired on the way are
+// unused from this version on.
+func (c *cowState) publish(root pagination.PageID, height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.root, c.height = root, height
+	for _, page := range c.pendingRetired {
+		c.retired = append(c.retired, retiredPage{page: page, version: c.version})
+	}
+	c.pendingRetired = nil
+}
+
+// reclaimable takes the retired pages that no open snapshot can reach off
+// the retired list.
+func (c *cowState) reclaimable() []pagination.PageID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reclaimDue.Store(false)
+	var pages []pagination.PageID
+	kept := c.retired[:0]
+	for _, r := range c.retired {
+		if c.pinned(r) {
+			kept = append(kept, r)
+			continue
+		}
+		pages = append(pages, r.page)
+	}
+	c.retired = kept
+	return pages
+}
+
+// pinned tells whether an open snapshot may still read the retired page,
+// i.e. is older than the version that retired it. Callers hold mu.
+func (c *cowState) pinned(r retiredPage) bool {
+	for version := range c.pins {
+		if version < r.version {
+			return true
+		}
+	}
+	return false
+}
+
+// retiredPages lists the pages waiting to be reclaimed.
+func (c *cowState) retiredPages() []pagination.PageID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pages := append([]pagination.PageID{}, c.pendingRetired...)
+	for _, r := range c.retired {
+		pages = append(pages, r.page)
+	}
+	return pages
+}
+
+// syncAndPublish commits the pending writes. In copy-on-write mode it also
+// frees the retired pages nobody reads anymore and, once the writes are
+// durable, publishes the writer's root as the new version. Callers hold
+// syncLatch exclusively.
+func (b *BPlusTree) syncAndPublish() lib.Error {
+	if !b.copyOnWrite() {
+		return b.pager.Sync()
+	}
+
+	if err := b.freeReclaimable(); err.IsNotEmpty() {
+		return err
+	}
+	if err := b.pager.Sync(); err.IsNotEmpty() {
+		return err
+	}
+
+	b.rootLatch.RLock()
+	root, height := b.root, b.height
+	b.rootLatch.RUnlock()
+	b.cow.publish(root, height)
+
+	// The pages this commit retired are free already unless a snapshot
+	// reads them. The commit stands even if freeing them fails.
+	b.freeReclaimable()
+	return lib.EmptyError()
+}
+
+// freeReclaimable returns the retired pages nobody reads anymore to the
+// free list. A page that fails to be freed is leaked rather than freed
+// twice later.
+func (b *BPlusTree) freeReclaimable() lib.Error {
+	for _, page := range b.cow.reclaimable() {
+		if err := b.freePage(page); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// unlockSync releases syncLatch taken exclusively. In copy-on-write mode it
+// first frees the pages that snapshots closed in the meantime let go of, as
+// Snapshot.Close leaves that to whoever holds the latch. The latch is taken
+// again if another snapshot was closed just as it was released.
+func (b *BPlusTree) unlockSync() {
+	for {
+		if b.copyOnWrite() && b.cow.reclaimDue.Load() {
+			b.freeReclaimable()
+		}
+		b.syncLatch.Unlock()
+		if !b.copyOnWrite() || !b.cow.reclaimDue.Load() || !b.syncLatch.TryLock() {
+			return
+		}
+	}
+}
+
+// Snapshot is a pinned, read-only version of a copy-on-write tree. Reads
+// take no latches, so they neither wait for writers nor hold them up, and
+// always see the tree as it was committed when the snapshot was taken.
+// A Snapshot may be shared between goroutines but must not be used after
+// Close, which lets the pages it pins be reused.
+type Snapshot struct {
+	tree    *BPlusTree
+	version uint64
+	root    pagination.PageID
+	height  int
+	closed  atomic.Bool
+}
+
+func (b *BPlusTree) Snapshot() (*Snapshot, lib.Error) {
+	if !b.copyOnWrite() {
+		return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("snapshot: tree is not in copy-on-write mode"))
+	}
+
+	b.cow.mu.Lock()
+	defer b.cow.mu.Unlock()
+
+	if b.cow.root == 0 {
+		return nil, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("snapshot: tree is not initialized"))
+	}
+	b.cow.pins[b.cow.version]++
+	return &Snapshot{tree: b, version: b.cow.version, root: b.cow.root, height: b.cow.height}, lib.EmptyError()
+}
+
+// Version is the commit the snapshot sees; later commits have higher ones.
+func (s *Snapshot) Version() uint64 {
+	return s.version
+}
+
+func (s *Snapshot) Search(primaryKey string) (_ common.Value, _ bool, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	ref, err := s.descend(primaryKey)
+	if err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	entry, ok := ref.leaf.FindNode(primaryKey)
+	if !ok {
+		return common.Value{}, false, lib.EmptyError()
+	}
+	value, err := s.tree.resolveValue(entry)
+	if err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	return value, true, lib.EmptyError()
+}
+
+// Scan iterates over the half-open key range [start, end) of the snapshot.
+func (s *Snapshot) Scan(start, end string) *Iterator {
+	return s.ScanWithOptions(start, end, ScanOptions{})
+}
+
+func (s *Snapshot) ScanWithOptions(start, end string, options ScanOptions) *Iterator {
+	it := s.tree.newIterator(start, end, options)
+	it.snapshot = s
+	return it
+}
+
+// Close unpins the snapshot. Closing it again does nothing. The pages only
+// this snapshot still kept are freed right away, unless the tree is busy
+// committing; they are then freed once the commit is done.
+func (s *Snapshot) Close() {
+	if !s.closed.CompareAndSwap(false, true) {
+		return
+	}
+	c := s.tree.cow
+	c.mu.Lock()
+	c.pins[s.version]--
+	if c.pins[s.version] == 0 {
+		delete(c.pins, s.version)
+	}
+	due := false
+	for _, r := range c.retired {
+		if !c.pinned(r) {
+			due = true
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if due {
+		c.reclaimDue.Store(true)
+		if s.tree.syncLatch.TryLock() {
+			s.tree.unlockSync()
+		}
+	}
+}
+
+// descend finds the leaf for primaryKey in the snapshot. Nothing is latched:
+// the pages of a pinned version are neither changed nor freed.
+func (s *Snapshot) descend(primaryKey string) (latchedLeaf, lib.Error) {
+	if s.closed.Load() {
+		return latchedLeaf{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("snapshot: version %d is closed", s.version))
+	}
+
+	ref := latchedLeaf{isRoot: s.height == 1}
+	pageID := s.root
+	for level := 1; ; level++ {
+		tn, err := s.tree.LoadTreeNode(pageID)
+		if err.IsNotEmpty() {
+			return latchedLeaf{}, err
+		}
+		if tn.IsLeaf() != (level == s.height) {
+			return latchedLeaf{}, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("snapshot: page %d at level %d of a tree of height %d has leaf flag %t", pageID, level, s.height, tn.IsLeaf()))
+		}
+		if tn.IsLeaf() {
+			ref.leaf = tn
+			return ref, lib.EmptyError()
+		}
+
+		childIdx := routeIndex(tn, primaryKey)
+		if childIdx >= len(tn.ChildTreeNodes()) {
+			return latchedLeaf{}, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("snapshot: childIdx %d out of bounds for primary key %s", childIdx, primaryKey))
+		}
+		if childIdx < tn.NodesCount() {
+			ref.upper, ref.hasUpper = tn.Nodes()[childIdx].PrimaryKey(), true
+		}
+		pageID = tn.ChildTreeNodes()[childIdx]
+	}
+}
@@ -0,0 +1,159 @@
+package implementation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+)
+
+func takeSnapshot(t *testing.T, tree *BPlusTree) *Snapshot {
+	t.Helper()
+	snapshot, err := tree.Snapshot()
+	if err.IsNotEmpty() {
+		t.Fatalf("snapshot: %v", err.Errors())
+	}
+	return snapshot
+}
+
+func deleteKeys(t *testing.T, tree *BPlusTree, from, to, step int) {
+	t.Helper()
+	for i := from; i < to; i += step {
+		if err := tree.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete %d: %v", i, err.Errors())
+		}
+	}
+}
+
+// checkSnapshot checks that the snapshot holds the keys from to to, with
+// value i+offset for key i.
+func checkSnapshot(t *testing.T, snapshot *Snapshot, from, to int, offset int64) {
+	t.Helper()
+	it := snapshot.Scan("", "")
+	defer it.Close()
+	i := from
+	for ; it.Next(); i++ {
+		if want := fmt.Sprintf("key-%05d", i); it.Key() != want || it.Value().IntValue() != int64(i)+offset {
+			t.Fatalf("snapshot %d scans %s=%v, want %s=%d", snapshot.Version(), it.Key(), it.Value(), want, int64(i)+offset)
+		}
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	if i != to {
+		t.Fatalf("snapshot %d scans keys %d to %d, want up to %d", snapshot.Version(), from, i, to)
+	}
+
+	key := fmt.Sprintf("key-%05d", from)
+	value, found, err := snapshot.Search(key)
+	if err.IsNotEmpty() || found != (from < to) || (found && value.IntValue() != int64(from)+offset) {
+		t.Fatalf("snapshot %d finds %s=%v (%t, %v)", snapshot.Version(), key, value, found, err.Errors())
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), true)
+	defer pager.Close()
+	insertKeys(t, tree, 0, 2000)
+
+	before := takeSnapshot(t, tree)
+	defer before.Close()
+
+	// Overwrite every value, then drop the first half of the keys.
+	for i := 0; i < 2000; i++ {
+		if err := tree.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i)+10000)); err.IsNotEmpty() {
+			t.Fatalf("update %d: %v", i, err.Errors())
+		}
+	}
+	middle := takeSnapshot(t, tree)
+	defer middle.Close()
+	deleteKeys(t, tree, 0, 1000, 1)
+
+	if before.Version() >= middle.Version() {
+		t.Fatalf("snapshot versions %d and %d do not increase", before.Version(), middle.Version())
+	}
+	checkSnapshot(t, before, 0, 2000, 0)
+	checkSnapshot(t, middle, 0, 2000, 10000)
+	after := takeSnapshot(t, tree)
+	defer after.Close()
+	checkSnapshot(t, after, 1000, 2000, 10000)
+
+	after.Close()
+	if _, _, err := after.Search("key-01000"); err.IsEmpty() {
+		t.Fatalf("search of a closed snapshot succeeded")
+	}
+}
+
+// TestSnapshotCloseReclaimsPages checks that neither the pages a snapshot
+// kept from being freed nor those retired by the last commit leak when the
+// pager is closed.
+func TestSnapshotCloseReclaimsPages(t *testing.T) {
+	dir := t.TempDir()
+	tree, pager := openLoggedTree(t, dir, true)
+	insertKeys(t, tree, 0, 3000)
+
+	snapshot := takeSnapshot(t, tree)
+	deleteKeys(t, tree, 0, 3000, 2)
+	if len(tree.cow.retiredPages()) == 0 {
+		t.Fatalf("deletes under a snapshot retired no pages")
+	}
+	checkSnapshot(t, snapshot, 0, 3000, 0)
+	snapshot.Close()
+	if retired := tree.cow.retiredPages(); len(retired) != 0 {
+		t.Fatalf("%d pages still retired after the last snapshot closed", len(retired))
+	}
+	// Without a snapshot, a commit frees what it retired itself.
+	deleteKeys(t, tree, 1, 1000, 2)
+	if retired := tree.cow.retiredPages(); len(retired) != 0 {
+		t.Fatalf("%d pages still retired after commits without snapshots", len(retired))
+	}
+	if err := pager.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+
+	tree, pager = openLoggedTree(t, dir, true)
+	defer pager.Close()
+	checkSound(t, tree)
+}
+
+// TestSnapshotCloseDuringTransaction closes the last snapshot while a
+// transaction holds the tree, which has to free the pages when it ends.
+func TestSnapshotCloseDuringTransaction(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		t.Run(fmt.Sprintf("commit=%t", commit), func(t *testing.T) {
+			dir := t.TempDir()
+			tree, pager := openLoggedTree(t, dir, true)
+			insertKeys(t, tree, 0, 3000)
+
+			snapshot := takeSnapshot(t, tree)
+			deleteKeys(t, tree, 0, 3000, 2)
+
+			tx, err := tree.Begin()
+			if err.IsNotEmpty() {
+				t.Fatalf("begin: %v", err.Errors())
+			}
+			if err := tx.Insert("key-x", common.NewIntValue(1)); err.IsNotEmpty() {
+				t.Fatalf("insert: %v", err.Errors())
+			}
+			snapshot.Close()
+			if commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err.IsNotEmpty() {
+				t.Fatalf("finish: %v", err.Errors())
+			}
+			if retired := tree.cow.retiredPages(); len(retired) != 0 {
+				t.Fatalf("%d pages still retired after the transaction", len(retired))
+			}
+			if err := pager.Close(); err.IsNotEmpty() {
+				t.Fatalf("close: %v", err.Errors())
+			}
+
+			tree, pager = openLoggedTree(t, dir, true)
+			defer pager.Close()
+			checkSound(t, tree)
+		})
+	}
+}
@@ -1,5 +1,266 @@
 package implementation
 
-func (b *BPlusTree) Delete(primaryKey string) error {
-	panic("implement me!")
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func (b *BPlusTree) Delete(primaryKey string) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	if b.rootPage() == 0 {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("delete: tree is empty, cannot delete key %s", primaryKey))
+	}
+
+	return b.mutate(func() lib.Error {
+		return b.delete(primaryKey)
+	})
+}
+
+// delete mirrors insert: optimistic when the leaf cannot underflow, otherwise
+// pessimistic with exclusive latches on every node a merge may reach.
+func (b *BPlusTree) delete(primaryKey string) lib.Error {
+	if b.copyOnWrite() {
+		// Copying the path is only worth it when there is something to delete.
+		if _, found, err := b.search(primaryKey); err.IsNotEmpty() || !found {
+			return err
+		}
+	} else {
+		ref, err := b.descend(primaryKey, exclusive)
+		if err.IsNotEmpty() {
+			return err
+		}
+		if b.deleteSafe(ref.leaf, primaryKey, ref.isRoot) {
+			defer b.releaseLeaf(ref)
+			existing, ok := ref.leaf.FindNode(primaryKey)
+			if !ok {
+				return lib.EmptyError()
+			}
+			if err := b.freeOverflow(existing); err.IsNotEmpty() {
+				return err
+			}
+			ref.leaf.RemoveNode(primaryKey)
+			return b.SaveNode(ref.leaf)
+		}
+		b.releaseLeaf(ref)
+	}
+
+	path, err := b.latchPath(primaryKey, func(tn *node.TreeNode, isRoot bool) bool {
+		return b.deleteSafe(tn, primaryKey, isRoot)
+	})
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer path.release()
+
+	leaf := path.leaf()
+	existing, ok := leaf.FindNode(primaryKey)
+	if !ok {
+		return lib.EmptyError()
+	}
+	if err := b.freeOverflow(existing); err.IsNotEmpty() {
+		return err
+	}
+	leaf.RemoveNode(primaryKey)
+
+	return b.rebalance(path, leaf)
+}
+
+// deleteSafe tells whether deleting primaryKey below tn cannot make tn
+// underflow, collapse or, through a longer separator after a
+// redistribution, split.
+func (b *BPlusTree) deleteSafe(tn *node.TreeNode, primaryKey string, isRoot bool) bool {
+	capacity := b.nodeCapacity()
+	if tn.IsLeaf() {
+		existing, ok := tn.FindNode(primaryKey)
+		if isRoot || !ok {
+			return true
+		}
+		return tn.ByteSize()-existing.ByteSize() >= capacity/constants.MinFillFraction
+	}
+	if tn.ByteSize()+b.maxEntrySize() > capacity {
+		return false
+	}
+	if isRoot {
+		return len(tn.ChildTreeNodes()) > 2
+	}
+	return tn.ByteSize()-b.maxEntrySize()-constants.ChildPointerSize >= capacity/constants.MinFillFraction
+}
+
+// rebalance persists tn after a removal and restores the minimum fill
+// invariant. An underflowing node is merged with a sibling when both fit in
+// one page, otherwise their entries are redistributed evenly; merges walk up
+// the latched path while the parent underflows in turn.
+func (b *BPlusTree) rebalance(path *writePath, tn *node.TreeNode) lib.Error {
+	if path.isRoot(tn) {
+		return b.collapseRoot(path, tn)
+	}
+
+	if !tn.IsUnderflow(b.nodeCapacity()) {
+		return b.SaveNode(tn)
+	}
+
+	parent, err := path.parentOf(tn.PageID())
+	if err.IsNotEmpty() {
+		return err
+	}
+	idx := parent.ChildIndex(tn.PageID())
+	if idx < 0 {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("rebalance: page %d is not a child of its parent %d", tn.PageID(), parent.PageID()))
+	}
+	if len(parent.ChildTreeNodes()) < 2 {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("rebalance: page %d has no siblings", tn.PageID()))
+	}
+
+	sepIdx, left, right := idx-1, (*node.TreeNode)(nil), tn
+	if idx == 0 {
+		sepIdx, left = 0, tn
+		right, err = path.latchSibling(parent, 1)
+	} else {
+		left, err = path.latchSibling(parent, idx-1)
+	}
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	if node.MergedByteSize(left, right, parent.Nodes()[sepIdx]) <= b.nodeCapacity() {
+		if err := b.mergeTreeNodes(path, parent, sepIdx, left, right); err.IsNotEmpty() {
+			return err
+		}
+		return b.rebalance(path, parent)
+	}
+
+	if err := b.redistribute(path, parent, sepIdx, left, right); err.IsNotEmpty() {
+		return err
+	}
+	// The new separator may be longer than the one it replaced.
+	if parent.IsFull(b.nodeCapacity()) {
+		return b.splitInternalNode(path, parent)
+	}
+	return b.SaveNode(parent)
+}
+
+// collapseRoot saves the root and, when an internal root is left with a single
+// child, promotes that child to be the new root.
+func (b *BPlusTree) collapseRoot(path *writePath, root *node.TreeNode) lib.Error {
+	if root.IsLeaf() || root.NodesCount() > 0 {
+		return b.SaveNode(root)
+	}
+	if len(root.ChildTreeNodes()) != 1 {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("collapseRoot: empty root %d has %d children", root.PageID(), len(root.ChildTreeNodes())))
+	}
+
+	child := root.ChildTreeNodes()[0]
+	if err := path.setParent(child, 0); err.IsNotEmpty() {
+		return err
+	}
+	if err := b.updateRoot(child, b.height-1); err.IsNotEmpty() {
+		return err
+	}
+	return b.freePage(root.PageID())
+}
+
+// redistribute evens out two siblings that do not fit in one page by
+// splitting their combined entries again, and replaces the separator between
+// them in parent. The parent is left for the caller to save.
+func (b *BPlusTree) redistribute(path *writePath, parent *node.TreeNode, sepIdx int, left, right *node.TreeNode) lib.Error {
+	combined := node.NewTreeNode()
+	combined.SetLeaf(left.IsLeaf())
+	combined.SetPageID(left.PageID())
+	combined.SetParentNode(left.ParentNode())
+	combined.AddNodes(left.Nodes()...)
+	if !left.IsLeaf() {
+		combined.AddNode(parent.Nodes()[sepIdx])
+	}
+	combined.AddNodes(right.Nodes()...)
+	combined.SetChildTreeNodes(append(append([]pagination.PageID{}, left.ChildTreeNodes()...), right.ChildTreeNodes()...))
+
+	newLeft, newRight, separator, err := node.SplitTreeNode(combined)
+	if err.IsNotEmpty() {
+		return err
+	}
+	newRight.SetPageID(right.PageID())
+	newLeft.SetNext(right.PageID())
+	newRight.SetNext(right.Next())
+	// Between leaves the old separator is dropped rather than moved down.
+	if left.IsLeaf() {
+		if err := b.freeKey(parent.Nodes()[sepIdx]); err.IsNotEmpty() {
+			return err
+		}
+	}
+	parent.SetNodeAt(sepIdx, separator)
+	path.replace(newLeft)
+	path.replace(newRight)
+
+	if err := b.saveNodes(newLeft, newRight); err.IsNotEmpty() {
+		return err
+	}
+	if err := b.reparentMovedChildren(path, left, newLeft); err.IsNotEmpty() {
+		return err
+	}
+	return b.reparentMovedChildren(path, right, newRight)
+}
+
+// reparentMovedChildren points the children that after has gained relative
+// to before back at after's page.
+func (b *BPlusTree) reparentMovedChildren(path *writePath, before, after *node.TreeNode) lib.Error {
+	for _, child := range after.ChildTreeNodes() {
+		if before.ChildIndex(child) >= 0 {
+			continue
+		}
+		if err := path.setParent(child, after.PageID()); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// mergeTreeNodes folds right into left, removing the separator at sepIdx and
+// the pointer to right from parent, and returns right's page to the free
+// list. The parent is left for the caller to save.
+//
+// In copy-on-write mode right is a copy made by this write, so freeing it
+// right away is safe.
+func (b *BPlusTree) mergeTreeNodes(path *writePath, parent *node.TreeNode, sepIdx int, left, right *node.TreeNode) lib.Error {
+	separator := parent.RemoveNodeAt(sepIdx)
+	parent.RemoveChildAt(sepIdx + 1)
+	if err := b.freePage(right.PageID()); err.IsNotEmpty() {
+		return err
+	}
+
+	if left.IsLeaf() {
+		if err := b.freeKey(separator); err.IsNotEmpty() {
+			return err
+		}
+		left.AddNodes(right.Nodes()...)
+		left.SetNext(right.Next())
+		return b.SaveNode(left)
+	}
+
+	left.AddNode(separator)
+	left.AddNodes(right.Nodes()...)
+	for _, child := range right.ChildTreeNodes() {
+		left.AddChildTreeNode(child)
+	}
+	if err := b.SaveNode(left); err.IsNotEmpty() {
+		return err
+	}
+	return path.setParents(right.ChildTreeNodes(), left.PageID())
+}
+
+func (b *BPlusTree) saveNodes(treeNodes ...*node.TreeNode) lib.Error {
+	for _, tn := range treeNodes {
+		if err := b.SaveNode(tn); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
 }
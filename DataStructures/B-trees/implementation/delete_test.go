@@ -0,0 +1,169 @@
+package implementation
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// openDeleteTree opens a tree directly on a file pager.
+func openDeleteTree(t *testing.T) *BPlusTree {
+	t.Helper()
+	pager, err := pagination.NewPager(filepath.Join(t.TempDir(), "delete.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	t.Cleanup(func() { pager.Close() })
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := NewBPlusTree(0, pager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return tree
+}
+
+// leafLayout returns the keys of each leaf from left to right and the height
+// of the tree, failing the test if the leaves are not all at the same depth.
+func leafLayout(t *testing.T, tree *BPlusTree) ([][]string, int) {
+	t.Helper()
+	var leaves [][]string
+	height := 0
+	var walk func(pageID pagination.PageID, depth int)
+	walk = func(pageID pagination.PageID, depth int) {
+		tn, err := tree.LoadTreeNode(pageID)
+		if err.IsNotEmpty() {
+			t.Fatalf("load page %d: %v", pageID, err.Errors())
+		}
+		if !tn.IsLeaf() {
+			for _, child := range tn.ChildTreeNodes() {
+				walk(child, depth+1)
+			}
+			return
+		}
+		if height == 0 {
+			height = depth
+		} else if depth != height {
+			t.Fatalf("leaf %d is at depth %d, the first leaf at %d", pageID, depth, height)
+		}
+		keys := []string{}
+		for _, n := range tn.Nodes() {
+			keys = append(keys, n.PrimaryKey())
+		}
+		leaves = append(leaves, keys)
+	}
+	walk(tree.root, 1)
+	return leaves, height
+}
+
+// rebalanceKind tells what deleting key did to the leaves around it.
+func rebalanceKind(before, after [][]string, key string) string {
+	if len(after) < len(before) {
+		return "merge"
+	}
+	for i, keys := range before {
+		if !slices.Contains(keys, key) {
+			continue
+		}
+		switch {
+		case len(after[i]) == 0:
+			return "none"
+		case after[i][0] < keys[0]:
+			return "borrow from left"
+		case after[i][len(after[i])-1] > keys[len(keys)-1]:
+			return "borrow from right"
+		}
+	}
+	return "none"
+}
+
+// deleteKey is padded so that nodes hold few keys and a few hundred of them
+// make a tree of several levels.
+func deleteKey(i int) string {
+	return fmt.Sprintf("key-%05d-%s", i, strings.Repeat("x", 300))
+}
+
+func TestDeleteRebalances(t *testing.T) {
+	orders := map[string]func(keys []int){
+		"ascending":  func(keys []int) {},
+		"descending": func(keys []int) { slices.Reverse(keys) },
+		"random": func(keys []int) {
+			r := rand.New(rand.NewSource(1))
+			r.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		},
+	}
+
+	seen := make(map[string]int)
+	for name, order := range orders {
+		t.Run(name, func(t *testing.T) {
+			tree := openDeleteTree(t)
+			// Grow the tree to three levels at least, whatever its nodes hold.
+			// Keys come in random order so that siblings differ in size.
+			var keys []int
+			for _, i := range rand.New(rand.NewSource(2)).Perm(100000) {
+				if err := tree.Insert(deleteKey(i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+					t.Fatalf("insert: %v", err.Errors())
+				}
+				keys = append(keys, i)
+				if _, height := leafLayout(t, tree); height >= 3 && len(keys) >= 64 {
+					break
+				}
+			}
+			slices.Sort(keys)
+			order(keys)
+
+			remaining := make(map[string]bool)
+			for _, i := range keys {
+				remaining[deleteKey(i)] = true
+			}
+			for _, i := range keys {
+				key := deleteKey(i)
+				before, height := leafLayout(t, tree)
+				if err := tree.Delete(key); err.IsNotEmpty() {
+					t.Fatalf("delete %s: %v", key, err.Errors())
+				}
+				delete(remaining, key)
+				after, newHeight := leafLayout(t, tree)
+				seen[rebalanceKind(before, after, key)]++
+				if newHeight < height {
+					seen["root collapse"]++
+				}
+
+				var got, want []string
+				for _, leaf := range after {
+					got = append(got, leaf...)
+				}
+				for key := range remaining {
+					want = append(want, key)
+				}
+				slices.Sort(want)
+				if !slices.Equal(got, want) {
+					t.Fatalf("after deleting %s the leaves hold %v, want %v", key, got, want)
+				}
+			}
+
+			// Everything is gone and the root is an empty leaf again.
+			if leaves, height := leafLayout(t, tree); height != 1 || len(leaves) != 1 || len(leaves[0]) != 0 {
+				t.Fatalf("tree of height %d with leaves %v after deleting every key", height, leaves)
+			}
+			// Deleting a key that is not there changes nothing.
+			if err := tree.Delete(deleteKey(0)); err.IsNotEmpty() {
+				t.Fatalf("delete a missing key: %v", err.Errors())
+			}
+		})
+	}
+
+	for _, kind := range []string{"borrow from left", "borrow from right", "merge", "root collapse"} {
+		if seen[kind] == 0 {
+			t.Errorf("no delete caused a %s: %v", kind, seen)
+		}
+	}
+}
@@ -2,120 +2,141 @@ package implementation
 
 import (
 	"fmt"
-	"runtime/debug"
 
 	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
 	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
 	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
-	"github.com/Kush/Database-internals/diskStorage/pagination"
 	"github.com/Kush/Database-internals/lib"
 )
 
-func (b *BPlusTree) Insert(primaryKey string, value common.Value) lib.Error {
-
+func (b *BPlusTree) Insert(primaryKey string, value common.Value) (err lib.Error) {
 	defer func() {
 		if r := recover(); r != nil {
-			lib.EmptyError().AddErr(lib.PanicFound, fmt.Errorf("panic recovered: %+v , stack : %v", r, string(debug.Stack())))
+			err = lib.Recovered(r)
 		}
 	}()
 
-	if b.root == 0 {
+	if b.rootPage() == 0 {
 		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("insert: tree is empty, cannot insert key %s", primaryKey))
 	}
-	leafPageID, err := b.findLeafNode(primaryKey)
+
+	return b.mutate(func() lib.Error {
+		return b.insert(primaryKey, value)
+	})
+}
+
+// insert first tries optimistically: shared latches down to an exclusively
+// latched leaf, which is enough as long as the leaf does not split. Otherwise
+// it starts over holding exclusive latches on every node the split may reach.
+func (b *BPlusTree) insert(primaryKey string, value common.Value) lib.Error {
+	entry, err := b.newEntry(primaryKey, value)
 	if err.IsNotEmpty() {
 		return err
 	}
 
-	leaf, err2 := b.LoadTreeNode(leafPageID)
-	if err2.IsNotEmpty() {
-		return err2
+	// Copy-on-write has to replace the whole path anyway.
+	if !b.copyOnWrite() {
+		ref, err := b.descend(primaryKey, exclusive)
+		if err.IsNotEmpty() {
+			return err
+		}
+		if b.insertSafe(ref.leaf, entry) {
+			defer b.releaseLeaf(ref)
+			return b.insertInLeaf(ref.leaf, entry)
+		}
+		b.releaseLeaf(ref)
 	}
 
-	if !leaf.IsFull() || leaf.IsPrimaryKeyfound(primaryKey) {
-		return b.insertIntoLeaf(leaf, primaryKey, value)
+	path, err := b.latchPath(primaryKey, func(tn *node.TreeNode, _ bool) bool {
+		return b.insertSafe(tn, entry)
+	})
+	if err.IsNotEmpty() {
+		return err
 	}
+	defer path.release()
 
-	leaf, newLeaf, middleNode, err3 := b.splitLeafAndInsert(leaf, primaryKey, value)
-	if err3.IsNotEmpty() {
-		return err3
+	leaf := path.leaf()
+	if err := b.insertInLeaf(leaf, entry); err.IsNotEmpty() || !leaf.IsFull(b.nodeCapacity()) {
+		return err
 	}
 
-	return b.insertIntoParent(leaf, newLeaf, middleNode)
-}
-
-func (b *BPlusTree) findLeafNode(primaryKey string) (pagination.PageID, lib.Error) {
-	if b.root == 0 {
-		return 0, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("findLeafNode: tree is empty"))
+	leaf, newLeaf, middleNode, err := b.splitLeaf(leaf)
+	if err.IsNotEmpty() {
+		return err
 	}
-	currentPage := b.root
-
-	for {
-		treeNode, err := b.LoadTreeNode(currentPage)
-		if err.IsNotEmpty() {
-			return 0, err
-		}
-		if treeNode == nil {
-			return 0, lib.EmptyError()
-		}
+	path.replace(leaf)
 
-		if treeNode.IsLeaf() {
-			return currentPage, lib.EmptyError()
-		}
+	return b.insertIntoParent(path, leaf, newLeaf, middleNode)
+}
 
-		childIdx := treeNode.NodesCount()
-		for idx, n := range treeNode.Nodes() {
-			if primaryKey < n.PrimaryKey() {
-				childIdx = idx
-				break
-			}
+// insertInLeaf adds or replaces entry in leaf and saves the leaf unless it
+// now has to be split.
+func (b *BPlusTree) insertInLeaf(leaf *node.TreeNode, entry node.Node) lib.Error {
+	// An update replaces the old value, so its overflow chain goes away. The
+	// key's chain is kept if the key stays in one.
+	if existing, ok := leaf.FindNode(entry.PrimaryKey()); ok {
+		if entry.HasOverflowKey() && existing.HasOverflowKey() {
+			entry = entry.WithKeyChain(existing.KeyOverflowPage(), existing.KeyOverflowLength())
+			existing = existing.WithKeyChain(0, 0)
 		}
-
-		if childIdx >= len(treeNode.ChildTreeNodes()) {
-			return 0, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("findLeafNode: childIdx %d out of bounds for primary key %s", childIdx, primaryKey))
+		if err := b.freeOverflow(existing); err.IsNotEmpty() {
+			return err
 		}
+	}
 
-		currentPage = treeNode.ChildTreeNodes()[childIdx]
+	if err := leaf.InsertInOrder(entry); err.IsNotEmpty() {
+		return err
+	}
+	if leaf.IsFull(b.nodeCapacity()) {
+		return lib.EmptyError()
 	}
+	return b.SaveNode(leaf)
 }
 
-func (b *BPlusTree) insertIntoLeaf(leaf *node.TreeNode, key string, value common.Value) lib.Error {
-	if leaf == nil {
-		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("insertIntoLeaf: leaf is nil"))
+// insertSafe tells whether inserting entry below tn cannot split tn.
+func (b *BPlusTree) insertSafe(tn *node.TreeNode, entry node.Node) bool {
+	if tn.IsLeaf() {
+		size := tn.ByteSize() + entry.ByteSize()
+		if existing, ok := tn.FindNode(entry.PrimaryKey()); ok {
+			size -= existing.ByteSize()
+		}
+		return size <= b.nodeCapacity()
 	}
+	// A split below adds a separator no larger than an entry and a pointer.
+	return tn.ByteSize()+b.maxEntrySize()+constants.ChildPointerSize <= b.nodeCapacity()
+}
 
-	err := leaf.InsertInOrder(node.NewNode(key, value))
-	if leaf.NodesCount() > constants.MaxNodesInTreeNode {
-		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("more than expected number of treeNode, MaxNodesInTreeNode: %v, currentNumberOfNodes: %v", constants.MaxNodesInTreeNode, leaf.NodesCount()))
-	}
-	if err.IsNotEmpty() {
-		return err
-	}
+func (b *BPlusTree) maxEntrySize() int {
+	return b.nodeCapacity() / constants.MaxEntryFraction
+}
 
-	// Mark as dirty and persist to disk
-	return b.SaveNode(leaf)
+// validateEntry rejects entries too large for a node to hold alongside
+// enough others to be split.
+func (b *BPlusTree) validateEntry(entry node.Node) lib.Error {
+	if limit := b.maxEntrySize(); entry.ByteSize() > limit {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("insert: entry for key %q takes %d bytes, at most %d fit in a tree node", entry.PrimaryKey(), entry.ByteSize(), limit))
+	}
+	return lib.EmptyError()
 }
 
-func (b *BPlusTree) splitLeafAndInsert(leaf *node.TreeNode, key string, value common.Value) (*node.TreeNode, *node.TreeNode, node.Node, lib.Error) {
+func (b *BPlusTree) splitLeaf(leaf *node.TreeNode) (*node.TreeNode, *node.TreeNode, node.Node, lib.Error) {
 	if !leaf.IsLeaf() {
-		return nil, nil, node.EmptyNode(), lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("splitLeafAndInsert: not a leaf node"))
+		return nil, nil, node.EmptyNode(), lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("splitLeaf: not a leaf node"))
 	}
 
-	leaf.InsertInOrder(node.NewNode(key, value))
-
 	left, right, middleNode, err := node.SplitTreeNode(leaf)
 	if err.IsNotEmpty() {
 		return nil, nil, node.EmptyNode(), err
 	}
 
-	newPageID, err2 := b.pager.AllocatePage()
+	newPageID, err2 := b.allocatePage()
 	if err2.IsNotEmpty() {
 		return nil, nil, node.EmptyNode(), err2
 	}
 	right.SetPageID(newPageID)
 	left.SetPageID(leaf.PageID())
-	left.SetParentNode(leaf.ParentNode())
-	right.SetParentNode(leaf.ParentNode())
+	right.SetNext(leaf.Next())
+	left.SetNext(newPageID)
 
 	if err := b.SaveNode(left); err.IsNotEmpty() {
 		return nil, nil, node.EmptyNode(), err
@@ -124,15 +145,14 @@ func (b *BPlusTree) splitLeafAndInsert(leaf *node.TreeNode, key string, value co
 		return nil, nil, node.EmptyNode(), err
 	}
 
-	// Update leaf links (if doubly-linked list structure is used)
-	// Optional: leaf.SetNext(right.PageID())
-
 	return left, right, middleNode, lib.EmptyError()
 }
 
-func (b *BPlusTree) insertIntoParent(leftNode, rightNode *node.TreeNode, middleNode node.Node) lib.Error {
-	if leftNode.ParentNode() == 0 {
-		rootPageID, err := b.pager.AllocatePage()
+// insertIntoParent links the new right half of a split into the parent held
+// by path, splitting further up as needed.
+func (b *BPlusTree) insertIntoParent(path *writePath, leftNode, rightNode *node.TreeNode, middleNode node.Node) lib.Error {
+	if path.isRoot(leftNode) {
+		rootPageID, err := b.allocatePage()
 		if err.IsNotEmpty() {
 			return err
 		}
@@ -143,27 +163,19 @@ func (b *BPlusTree) insertIntoParent(leftNode, rightNode *node.TreeNode, middleN
 			return err
 		}
 
-		leftNode.SetParentNode(rootPageID)
-		rightNode.SetParentNode(rootPageID)
-
 		// Persist
 		if err := b.SaveNode(newRoot); err.IsNotEmpty() {
 			return err
 		}
-		if err := b.SaveNode(leftNode); err.IsNotEmpty() {
-			return err
-		}
-		if err := b.SaveNode(rightNode); err.IsNotEmpty() {
+		if err := path.setParents(newRoot.ChildTreeNodes(), rootPageID); err.IsNotEmpty() {
 			return err
 		}
 
 		// Update root pointer
-		b.updateRoot(rootPageID)
-		return lib.EmptyError()
+		return b.updateRoot(rootPageID, b.height+1)
 	}
 
-	// Load parent
-	parent, err := b.LoadTreeNode(leftNode.ParentNode())
+	parent, err := path.parentOf(leftNode.PageID())
 	if err.IsNotEmpty() {
 		return err
 	}
@@ -173,26 +185,27 @@ func (b *BPlusTree) insertIntoParent(leftNode, rightNode *node.TreeNode, middleN
 		return err
 	}
 
-	if parent.IsValidNumberOfNodes() {
+	if !parent.IsFull(b.nodeCapacity()) {
 		return b.SaveNode(parent)
 	}
 
-	// Parent split
+	return b.splitInternalNode(path, parent)
+}
+
+// splitInternalNode splits an overflowing internal node and pushes the
+// promoted key into its parent, splitting further up as needed.
+func (b *BPlusTree) splitInternalNode(path *writePath, parent *node.TreeNode) lib.Error {
 	newleft, newRight, promotedKey, err := node.SplitTreeNode(parent)
 	if err.IsNotEmpty() {
 		return err
 	}
-	newPageID, err2 := b.pager.AllocatePage()
+	newPageID, err2 := b.allocatePage()
 	if err2.IsNotEmpty() {
 		return err2
 	}
-	err = b.SetPageId(newRight, newPageID)
-	if err.IsNotEmpty() {
-		return err
-	}
+	newRight.SetPageID(newPageID)
 	newleft.SetPageID(parent.PageID())
-	newRight.SetParentNode(parent.ParentNode())
-	newleft.SetParentNode(parent.ParentNode())
+	path.replace(newleft)
 
 	if err := b.SaveNode(newleft); err.IsNotEmpty() {
 		return err
@@ -200,22 +213,9 @@ func (b *BPlusTree) insertIntoParent(leftNode, rightNode *node.TreeNode, middleN
 	if err := b.SaveNode(newRight); err.IsNotEmpty() {
 		return err
 	}
-
-	return b.insertIntoParent(newleft, newRight, promotedKey)
-}
-
-func (b *BPlusTree) SetPageId(tn *node.TreeNode, pageID pagination.PageID) lib.Error {
-	tn.SetPageID(pageID)
-
-	for _, childPageId := range tn.ChildTreeNodes() {
-		childTreeNode, err := b.LoadTreeNode(childPageId)
-		if err.IsNotEmpty() {
-			return err
-		}
-		childTreeNode.SetParentNode(pageID)
-		if err := b.SaveNode(childTreeNode); err.IsNotEmpty() {
-			return err
-		}
+	if err := path.setParents(newRight.ChildTreeNodes(), newPageID); err.IsNotEmpty() {
+		return err
 	}
-	return lib.EmptyError()
+
+	return b.insertIntoParent(path, newleft, newRight, promotedKey)
 }
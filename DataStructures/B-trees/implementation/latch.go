@@ -0,0 +1,332 @@
+package implementation
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+type latchMode int
+
+const (
+	shared latchMode = iota
+	exclusive
+)
+
+// pageLatches hands out one reader/writer latch per page. A latch is created
+// on first use and dropped again once nobody holds or waits for it.
+//
+// Latches are always taken top-down, and between siblings only while their
+// parent is held exclusively, so two goroutines never wait on each other.
+type pageLatches struct {
+	mu      sync.Mutex
+	latches map[pagination.PageID]*pageLatch
+}
+
+type pageLatch struct {
+	sync.RWMutex
+	users int
+}
+
+func newPageLatches() *pageLatches {
+	return &pageLatches{latches: make(map[pagination.PageID]*pageLatch)}
+}
+
+func (l *pageLatches) lock(id pagination.PageID, mode latchMode) {
+	l.mu.Lock()
+	latch, ok := l.latches[id]
+	if !ok {
+		latch = &pageLatch{}
+		l.latches[id] = latch
+	}
+	latch.users++
+	l.mu.Unlock()
+
+	if mode == exclusive {
+		latch.Lock()
+	} else {
+		latch.RLock()
+	}
+}
+
+func (l *pageLatches) unlock(id pagination.PageID, mode latchMode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latch := l.latches[id]
+	if mode == exclusive {
+		latch.Unlock()
+	} else {
+		latch.RUnlock()
+	}
+	latch.users--
+	if latch.users == 0 {
+		delete(l.latches, id)
+	}
+}
+
+// latchedLeaf is a leaf reached by descend, still latched in mode.
+type latchedLeaf struct {
+	leaf *node.TreeNode
+	mode latchMode
+	// isRoot tells whether the leaf is the whole tree.
+	isRoot bool
+	// upper is the separator to the right of the descent path, i.e. the
+	// smallest key that belongs to a later leaf. hasUpper is false for the
+	// rightmost leaf.
+	upper    string
+	hasUpper bool
+}
+
+// descend walks from the root to the leaf responsible for primaryKey,
+// coupling shared latches on the way down so that at most a parent and a
+// child are held at a time. The leaf is returned latched in leafMode; the
+// caller releases it with b.releaseLeaf.
+func (b *BPlusTree) descend(primaryKey string, leafMode latchMode) (latchedLeaf, lib.Error) {
+	b.rootLatch.RLock()
+	pageID, height := b.root, b.height
+	if pageID == 0 {
+		b.rootLatch.RUnlock()
+		return latchedLeaf{}, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("root page Id is not set"))
+	}
+	mode := shared
+	if height == 1 {
+		mode = leafMode
+	}
+	b.latches.lock(pageID, mode)
+	b.rootLatch.RUnlock()
+	defer func() {
+		if r := recover(); r != nil {
+			b.latches.unlock(pageID, mode)
+			panic(r)
+		}
+	}()
+
+	ref := latchedLeaf{isRoot: height == 1}
+	for level := 1; ; level++ {
+		tn, err := b.LoadTreeNode(pageID)
+		if err.IsNotEmpty() {
+			b.latches.unlock(pageID, mode)
+			return latchedLeaf{}, err
+		}
+
+		if tn.IsLeaf() != (level == height) {
+			b.latches.unlock(pageID, mode)
+			return latchedLeaf{}, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("descend: page %d at level %d of a tree of height %d has leaf flag %t", pageID, level, height, tn.IsLeaf()))
+		}
+		if tn.IsLeaf() {
+			ref.leaf, ref.mode = tn, mode
+			return ref, lib.EmptyError()
+		}
+
+		childIdx := routeIndex(tn, primaryKey)
+		if childIdx >= len(tn.ChildTreeNodes()) {
+			b.latches.unlock(pageID, mode)
+			return latchedLeaf{}, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("descend: childIdx %d out of bounds for primary key %s", childIdx, primaryKey))
+		}
+		if childIdx < tn.NodesCount() {
+			ref.upper, ref.hasUpper = tn.Nodes()[childIdx].PrimaryKey(), true
+		}
+
+		childMode := shared
+		if level+1 == height {
+			childMode = leafMode
+		}
+		child := tn.ChildTreeNodes()[childIdx]
+		b.latches.lock(child, childMode)
+		b.latches.unlock(pageID, mode)
+		pageID, mode = child, childMode
+	}
+}
+
+func (b *BPlusTree) releaseLeaf(ref latchedLeaf) {
+	b.latches.unlock(ref.leaf.PageID(), ref.mode)
+}
+
+// writePath holds the exclusively latched nodes of a pessimistic write, from
+// the highest node the write may still change down to the leaf. Splits and
+// merges find parents here: following a node's parent pointer would take a
+// latch bottom-up.
+type writePath struct {
+	tree  *BPlusTree
+	nodes []*node.TreeNode
+	// latched are the pages to unlock on release, including siblings latched
+	// while rebalancing. In copy-on-write mode they differ from the pages the
+	// nodes end up on.
+	latched []pagination.PageID
+	// root is the root page when the descent started; it stays the root for
+	// as long as the path holds it.
+	root       pagination.PageID
+	rootLocked bool
+}
+
+// latchPath descends to the leaf for primaryKey taking exclusive latches.
+// Whenever a node is safe, i.e. the write cannot propagate above it, every
+// latch above it is released, including the root latch. In copy-on-write
+// mode nothing is released and every node is moved to a fresh page.
+func (b *BPlusTree) latchPath(primaryKey string, safe func(tn *node.TreeNode, isRoot bool) bool) (*writePath, lib.Error) {
+	b.rootLatch.Lock()
+	path := &writePath{tree: b, root: b.root, rootLocked: true}
+	if path.root == 0 {
+		path.release()
+		return nil, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("root page Id is not set"))
+	}
+
+	// held is latched but not yet on the path.
+	var held pagination.PageID
+	defer func() {
+		if r := recover(); r != nil {
+			if held != 0 {
+				b.latches.unlock(held, exclusive)
+			}
+			path.release()
+			panic(r)
+		}
+	}()
+
+	pageID := path.root
+	for {
+		b.latches.lock(pageID, exclusive)
+		held = pageID
+		tn, err := b.LoadTreeNode(pageID)
+		if err.IsNotEmpty() {
+			b.latches.unlock(pageID, exclusive)
+			path.release()
+			return nil, err
+		}
+		if b.copyOnWrite() {
+			path.latched, held = append(path.latched, pageID), 0
+			if err := path.shadow(tn); err.IsNotEmpty() {
+				path.release()
+				return nil, err
+			}
+		} else {
+			if safe(tn, path.isRoot(tn)) {
+				path.release()
+			}
+			path.latched, held = append(path.latched, pageID), 0
+		}
+		path.nodes = append(path.nodes, tn)
+		if tn.IsLeaf() {
+			return path, lib.EmptyError()
+		}
+
+		childIdx := routeIndex(tn, primaryKey)
+		if childIdx >= len(tn.ChildTreeNodes()) {
+			path.release()
+			return nil, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("latchPath: childIdx %d out of bounds for primary key %s", childIdx, primaryKey))
+		}
+		pageID = tn.ChildTreeNodes()[childIdx]
+	}
+}
+
+func (p *writePath) leaf() *node.TreeNode {
+	return p.nodes[len(p.nodes)-1]
+}
+
+func (p *writePath) isRoot(tn *node.TreeNode) bool {
+	return tn.PageID() == p.root
+}
+
+// parentOf returns the latched parent of the page, if the path holds it.
+func (p *writePath) parentOf(pageID pagination.PageID) (*node.TreeNode, lib.Error) {
+	for i := len(p.nodes) - 1; i > 0; i-- {
+		if p.nodes[i].PageID() == pageID {
+			return p.nodes[i-1], lib.EmptyError()
+		}
+	}
+	return nil, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("parent of page %d is not latched", pageID))
+}
+
+// replace swaps in a new version of a node on the path, matched by page.
+func (p *writePath) replace(tn *node.TreeNode) {
+	for i, held := range p.nodes {
+		if held.PageID() == tn.PageID() {
+			p.nodes[i] = tn
+			return
+		}
+	}
+}
+
+// shadow moves a node about to join the path to a fresh page, repointing
+// the last node on the path, or the root, at it.
+func (p *writePath) shadow(tn *node.TreeNode) lib.Error {
+	if len(p.nodes) == 0 {
+		if err := p.tree.shadow(tn, nil); err.IsNotEmpty() {
+			return err
+		}
+		p.root = tn.PageID()
+		return lib.EmptyError()
+	}
+	return p.tree.shadow(tn, p.nodes[len(p.nodes)-1])
+}
+
+// setParent points the child at page parent. It is saved nodes that are
+// updated, so callers save a changed child first. A child the path does not
+// hold is latched for the update, which cannot deadlock: it lies below a
+// node the path holds exclusively, so whoever holds it only waits on pages
+// further down. Copy-on-write mode does not keep parent pointers.
+func (p *writePath) setParent(child, parent pagination.PageID) lib.Error {
+	b := p.tree
+	if b.copyOnWrite() {
+		return lib.EmptyError()
+	}
+	if !slices.Contains(p.latched, child) {
+		b.latches.lock(child, exclusive)
+		defer b.latches.unlock(child, exclusive)
+	}
+	tn, err := b.LoadTreeNode(child)
+	if err.IsNotEmpty() || tn.ParentNode() == parent {
+		return err
+	}
+	tn.SetParentNode(parent)
+	p.replace(tn)
+	return b.SaveNode(tn)
+}
+
+// setParents points each of children at page parent.
+func (p *writePath) setParents(children []pagination.PageID, parent pagination.PageID) lib.Error {
+	for _, child := range children {
+		if err := p.setParent(child, parent); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// latchSibling latches the child at idx of parent, a node on the path.
+func (p *writePath) latchSibling(parent *node.TreeNode, idx int) (*node.TreeNode, lib.Error) {
+	pageID := parent.ChildTreeNodes()[idx]
+	p.tree.latches.lock(pageID, exclusive)
+	p.latched = append(p.latched, pageID)
+	sibling, err := p.tree.LoadTreeNode(pageID)
+	if err.IsNotEmpty() || !p.tree.copyOnWrite() {
+		return sibling, err
+	}
+	return sibling, p.tree.shadow(sibling, parent)
+}
+
+func (p *writePath) release() {
+	for _, pageID := range p.latched {
+		p.tree.latches.unlock(pageID, exclusive)
+	}
+	p.nodes, p.latched = nil, nil
+	if p.rootLocked {
+		p.rootLocked = false
+		p.tree.rootLatch.Unlock()
+	}
+}
+
+// routeIndex is the index of the child of an internal node that covers key.
+func routeIndex(tn *node.TreeNode, key string) int {
+	for idx, n := range tn.Nodes() {
+		if key < n.PrimaryKey() {
+			return idx
+		}
+	}
+	return tn.NodesCount()
+}
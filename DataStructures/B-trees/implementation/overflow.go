@@ -0,0 +1,131 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// newEntry builds the leaf entry for a key and value. A string or bytes
+// payload too large to keep inline is written to an overflow chain first. A
+// key that still leaves the entry too large is moved to a chain of its own,
+// written when the leaf is saved.
+func (b *BPlusTree) newEntry(primaryKey string, value common.Value) (node.Node, lib.Error) {
+	if err := b.checkEntry(primaryKey, value); err.IsNotEmpty() {
+		return node.EmptyNode(), err
+	}
+	entry := node.NewNode(primaryKey, value)
+	if b.needsOverflow(value) {
+		b.headerMu.Lock()
+		page, err := serialization.WriteOverflowValue(b.pager, value)
+		b.headerMu.Unlock()
+		if err.IsNotEmpty() {
+			return node.EmptyNode(), err
+		}
+		entry = node.NewOverflowNode(primaryKey, serialization.PayloadValue(value.Type(), nil), page, uint32(value.Len()))
+	}
+	return b.placeKey(entry), lib.EmptyError()
+}
+
+// checkEntry rejects a key and value that cannot be stored, before anything
+// is written for them.
+func (b *BPlusTree) checkEntry(primaryKey string, value common.Value) lib.Error {
+	if !b.needsOverflow(value) {
+		return b.validateEntry(b.placeKey(node.NewNode(primaryKey, value)))
+	}
+	// Size the entry with a placeholder reference to its overflow chain.
+	return b.validateEntry(b.placeKey(node.NewOverflowNode(primaryKey, serialization.PayloadValue(value.Type(), nil), 1, uint32(value.Len()))))
+}
+
+// placeKey moves the key of an entry too large for a node to an overflow
+// chain. Its separators then keep their key in a chain too, so they are never
+// larger than an entry either.
+func (b *BPlusTree) placeKey(entry node.Node) node.Node {
+	if entry.ByteSize() > b.maxEntrySize() {
+		return entry.WithOverflowKey()
+	}
+	return entry
+}
+
+func (b *BPlusTree) needsOverflow(value common.Value) bool {
+	return value.Len() > b.nodeCapacity()/constants.OverflowFraction
+}
+
+// resolveValue returns the entry's value with its payload read back from the
+// overflow chain when it has one. The caller must hold the entry's leaf
+// latched, or read it from a snapshot, otherwise a concurrent update could
+// free the chain.
+func (b *BPlusTree) resolveValue(entry node.Node) (common.Value, lib.Error) {
+	if !entry.HasOverflow() {
+		return entry.Value(), lib.EmptyError()
+	}
+	return serialization.ReadOverflowValue(b.pager, entry.Value().Type(), entry.OverflowPage(), entry.OverflowLength())
+}
+
+// freeOverflow frees the overflow chains of an entry that is removed.
+func (b *BPlusTree) freeOverflow(entry node.Node) lib.Error {
+	if entry.HasOverflow() {
+		if err := b.freeChain(entry.OverflowPage()); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return b.freeKey(entry)
+}
+
+// freeKey frees the chain holding the key of an entry or separator that is
+// removed.
+func (b *BPlusTree) freeKey(entry node.Node) lib.Error {
+	if entry.KeyOverflowPage() == 0 {
+		return lib.EmptyError()
+	}
+	return b.freeChain(entry.KeyOverflowPage())
+}
+
+func (b *BPlusTree) freeChain(head pagination.PageID) lib.Error {
+	if b.copyOnWrite() {
+		return b.retireOverflow(head)
+	}
+	b.headerMu.Lock()
+	defer b.headerMu.Unlock()
+
+	return pagination.FreeOverflow(b.pager, head)
+}
+
+// storeKeys writes the overflow chains of the keys in tn that do not have
+// one yet and returns their first pages. Keys only get their chain here, as
+// separators are copied and moved between nodes before they are saved.
+func (b *BPlusTree) storeKeys(tn *node.TreeNode) ([]pagination.PageID, lib.Error) {
+	var heads []pagination.PageID
+	for i, n := range tn.Nodes() {
+		if !n.HasOverflowKey() || n.KeyOverflowPage() != 0 {
+			continue
+		}
+		b.headerMu.Lock()
+		page, err := pagination.WriteOverflow(b.pager, []byte(n.PrimaryKey()))
+		b.headerMu.Unlock()
+		if err.IsNotEmpty() {
+			return heads, err
+		}
+		tn.SetNodeAt(i, n.WithKeyChain(page, n.KeyOverflowLength()))
+		heads = append(heads, page)
+	}
+	return heads, lib.EmptyError()
+}
+
+// loadKeys reads back the keys of tn kept in overflow chains.
+func (b *BPlusTree) loadKeys(tn *node.TreeNode) lib.Error {
+	for i, n := range tn.Nodes() {
+		if !n.HasOverflowKey() {
+			continue
+		}
+		key, err := pagination.ReadOverflow(b.pager, n.KeyOverflowPage(), int(n.KeyOverflowLength()))
+		if err.IsNotEmpty() {
+			return err
+		}
+		tn.SetNodeAt(i, n.WithPrimaryKey(string(key)))
+	}
+	return lib.EmptyError()
+}
@@ -0,0 +1,105 @@
+package implementation
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+)
+
+// largeKey is key-%05d, padded for even i to a key too long for a node, so
+// that the separators between them have to live in overflow chains too. Odd
+// keys stay inline, but are long enough for internal nodes to split often.
+func largeKey(i int) string {
+	key := fmt.Sprintf("key-%05d", i)
+	if i%2 == 0 {
+		return key + strings.Repeat("x", 3000)
+	}
+	return key + strings.Repeat("y", 900)
+}
+
+func largeKeyValue(i, round int) common.Value {
+	if (i+round)%3 == 0 {
+		return common.NewStringValue(strings.Repeat(fmt.Sprint(i%10), 5000))
+	}
+	return common.NewIntValue(int64(i + round))
+}
+
+// checkLargeKeys checks that the tree holds exactly the keys from to to with
+// the values of the given round.
+func checkLargeKeys(t *testing.T, tree *BPlusTree, from, to, round int) {
+	t.Helper()
+	it := tree.Scan("", "")
+	defer it.Close()
+	i := from
+	for ; it.Next(); i++ {
+		if it.Key() != largeKey(i) || !it.Value().Equal(largeKeyValue(i, round)) {
+			t.Fatalf("scan found a %d byte key where key %d belongs", len(it.Key()), i)
+		}
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	if i != to {
+		t.Fatalf("scan found keys %d to %d, want up to %d", from, i, to)
+	}
+	value, found, err := tree.Search(largeKey(from))
+	if err.IsNotEmpty() || found != (from < to) || (found && !value.Equal(largeKeyValue(from, round))) {
+		t.Fatalf("search key %d: %t, %v", from, found, err.Errors())
+	}
+}
+
+func TestLargeKeys(t *testing.T) {
+	for _, copyOnWrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy-on-write %t", copyOnWrite), func(t *testing.T) {
+			tree, pager := openLoggedTree(t, t.TempDir(), copyOnWrite)
+			defer pager.Close()
+
+			const keys = 3000
+			for i := 0; i < keys; i++ {
+				if err := tree.Insert(largeKey(i), largeKeyValue(i, 0)); err.IsNotEmpty() {
+					t.Fatalf("insert: %v", err.Errors())
+				}
+			}
+			if report := checkSound(t, tree); report.Height < 3 || report.OverflowPages < keys/2 {
+				t.Fatalf("unexpected report: %+v", report)
+			}
+			checkLargeKeys(t, tree, 0, keys, 0)
+
+			// The loaded tree gets separators of its own.
+			loaded, loadedPager := openLoggedTree(t, t.TempDir(), false)
+			defer loadedPager.Close()
+			if err := loaded.BulkLoad(tree.Scan("", "")); err.IsNotEmpty() {
+				t.Fatalf("bulk load: %v", err.Errors())
+			}
+			checkSound(t, loaded)
+			checkLargeKeys(t, loaded, 0, keys, 0)
+
+			// Updates move values in and out of overflow chains.
+			for i := 0; i < keys; i++ {
+				if err := tree.Insert(largeKey(i), largeKeyValue(i, 1)); err.IsNotEmpty() {
+					t.Fatalf("update: %v", err.Errors())
+				}
+			}
+			checkSound(t, tree)
+			checkLargeKeys(t, tree, 0, keys, 1)
+
+			// Deleting everything merges all the way up and frees every
+			// chain, or Verify would find them orphaned.
+			for i := 0; i < keys; i++ {
+				if err := tree.Delete(largeKey(i)); err.IsNotEmpty() {
+					t.Fatalf("delete: %v", err.Errors())
+				}
+				if i == keys/2 {
+					checkSound(t, tree)
+					checkLargeKeys(t, tree, i+1, keys, 1)
+				}
+			}
+			report := checkSound(t, tree)
+			if report.Keys != 0 || report.OverflowPages != 0 || report.TreePages != 1 {
+				t.Fatalf("unexpected report after deleting every key: %+v", report)
+			}
+		})
+	}
+}
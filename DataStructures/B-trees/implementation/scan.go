@@ -0,0 +1,188 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// ScanOptions controls the bounds of a range scan. An empty Start scans from
+// the smallest key and an empty End up to the largest one. Limit <= 0 means
+// no limit.
+type ScanOptions struct {
+	ExcludeStart bool
+	IncludeEnd   bool
+	Limit        int
+}
+
+// Iterator walks the leaves in key order. Callers advance it with Next until
+// it returns false, then check Err. Stopping early is fine; Close just
+// releases the iterator.
+//
+// No latch is held between calls: the entries of a leaf are copied out while
+// it is latched, and the next leaf is found by descending again from the
+// separator bounding the previous one. A leaf's next link cannot be used for
+// that, since the page it points to may have been merged away in between.
+// Writes that happen during the scan may or may not be seen, but keys are
+// always returned in increasing order and at most once. A scan of a snapshot,
+// which every scan of a copy-on-write tree is, sees no concurrent writes.
+type Iterator struct {
+	tree    *BPlusTree
+	start   string
+	end     string
+	options ScanOptions
+	entries []scanEntry
+	idx     int
+	next    string
+	hasNext bool
+	count   int
+	current scanEntry
+	started bool
+	done    bool
+	err     lib.Error
+
+	snapshot *Snapshot
+	// ownsSnapshot is set when the iterator took the snapshot itself.
+	ownsSnapshot bool
+}
+
+type scanEntry struct {
+	key   string
+	value common.Value
+}
+
+// Scan iterates over the half-open key range [start, end).
+func (b *BPlusTree) Scan(start, end string) *Iterator {
+	return b.ScanWithOptions(start, end, ScanOptions{})
+}
+
+func (b *BPlusTree) ScanWithOptions(start, end string, options ScanOptions) *Iterator {
+	it := b.newIterator(start, end, options)
+	if b.copyOnWrite() {
+		snapshot, err := b.Snapshot()
+		if err.IsNotEmpty() {
+			it.finish(err)
+			return it
+		}
+		it.snapshot, it.ownsSnapshot = snapshot, true
+	}
+	return it
+}
+
+func (b *BPlusTree) newIterator(start, end string, options ScanOptions) *Iterator {
+	return &Iterator{
+		tree:    b,
+		start:   start,
+		end:     end,
+		options: options,
+		err:     lib.EmptyError(),
+	}
+}
+
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.options.Limit > 0 && it.count >= it.options.Limit {
+		return it.finish(lib.EmptyError())
+	}
+	if !it.started {
+		it.started = true
+		if err := it.load(it.start, !it.options.ExcludeStart); err.IsNotEmpty() {
+			return it.finish(err)
+		}
+	}
+
+	for it.idx >= len(it.entries) {
+		if !it.hasNext {
+			return it.finish(lib.EmptyError())
+		}
+		if err := it.load(it.next, true); err.IsNotEmpty() {
+			return it.finish(err)
+		}
+	}
+
+	it.current = it.entries[it.idx]
+	it.idx++
+	it.count++
+	return true
+}
+
+func (it *Iterator) Key() string {
+	return it.current.key
+}
+
+func (it *Iterator) Value() common.Value {
+	return it.current.value
+}
+
+func (it *Iterator) Err() lib.Error {
+	return it.err
+}
+
+func (it *Iterator) Close() {
+	it.finish(lib.EmptyError())
+}
+
+// load copies the entries of the leaf responsible for from, starting at from
+// and stopping at the upper bound of the scan, and remembers where the next
+// leaf starts.
+func (it *Iterator) load(from string, inclusive bool) lib.Error {
+	var ref latchedLeaf
+	var err lib.Error
+	if it.snapshot != nil {
+		ref, err = it.snapshot.descend(from)
+	} else {
+		it.tree.rollbackLatch.RLock()
+		defer it.tree.rollbackLatch.RUnlock()
+
+		ref, err = it.tree.descend(from, shared)
+		if err.IsEmpty() {
+			defer it.tree.releaseLeaf(ref)
+		}
+	}
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	it.entries, it.idx = it.entries[:0], 0
+	it.next, it.hasNext = ref.upper, ref.hasUpper
+	for _, n := range ref.leaf.Nodes() {
+		if n.PrimaryKey() < from || (n.PrimaryKey() == from && !inclusive) {
+			continue
+		}
+		if it.pastEnd(n.PrimaryKey()) {
+			it.hasNext = false
+			break
+		}
+		value, err := it.tree.resolveValue(n)
+		if err.IsNotEmpty() {
+			return err
+		}
+		it.entries = append(it.entries, scanEntry{key: n.PrimaryKey(), value: value})
+	}
+	if it.hasNext && it.pastEnd(it.next) {
+		it.hasNext = false
+	}
+	return lib.EmptyError()
+}
+
+func (it *Iterator) pastEnd(key string) bool {
+	if it.end == "" {
+		return false
+	}
+	if it.options.IncludeEnd {
+		return key > it.end
+	}
+	return key >= it.end
+}
+
+func (it *Iterator) finish(err lib.Error) bool {
+	it.done = true
+	if it.ownsSnapshot {
+		it.snapshot.Close()
+	}
+	it.entries = nil
+	it.current = scanEntry{}
+	it.err = err
+	return false
+}
@@ -0,0 +1,130 @@
+package implementation
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+)
+
+// scanKeys collects the keys of a scan, failing the test on an error.
+func scanKeys(t *testing.T, it *Iterator) []string {
+	t.Helper()
+	defer it.Close()
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	return keys
+}
+
+// evenKeys lists key-%05d for the even numbers from from up to to.
+func evenKeys(from, to int) []string {
+	var keys []string
+	for i := from; i < to; i += 2 {
+		keys = append(keys, fmt.Sprintf("key-%05d", i))
+	}
+	return keys
+}
+
+func TestScanWithOptions(t *testing.T) {
+	// Every even key is present and every odd one absent; 1000 keys span
+	// many leaves, so the ranges below cross leaf boundaries.
+	const keys = 2000
+	cases := []struct {
+		name       string
+		start, end string
+		options    ScanOptions
+		want       []string
+	}{
+		{name: "everything", want: evenKeys(0, keys)},
+		{name: "present bounds", start: "key-00100", end: "key-01500", want: evenKeys(100, 1500)},
+		{name: "absent bounds", start: "key-00101", end: "key-01501", want: evenKeys(102, 1502)},
+		{name: "exclude present start", start: "key-00100", end: "key-01500", options: ScanOptions{ExcludeStart: true}, want: evenKeys(102, 1500)},
+		{name: "exclude absent start", start: "key-00101", end: "key-01500", options: ScanOptions{ExcludeStart: true}, want: evenKeys(102, 1500)},
+		{name: "include present end", start: "key-00100", end: "key-01500", options: ScanOptions{IncludeEnd: true}, want: evenKeys(100, 1502)},
+		{name: "include absent end", start: "key-00100", end: "key-01501", options: ScanOptions{IncludeEnd: true}, want: evenKeys(100, 1502)},
+		{name: "exclude start include end", start: "key-00100", end: "key-01500", options: ScanOptions{ExcludeStart: true, IncludeEnd: true}, want: evenKeys(102, 1502)},
+		{name: "start before every key", start: "a", end: "key-00010", want: evenKeys(0, 10)},
+		{name: "end after every key", start: "key-01990", end: "z", want: evenKeys(1990, keys)},
+		{name: "start equals end", start: "key-00100", end: "key-00100"},
+		{name: "start after end", start: "key-01500", end: "key-00100"},
+		{name: "range between two keys", start: "key-00101", end: "key-00102"},
+		{name: "start after every key", start: "z"},
+		{name: "start equals present end included", start: "key-00100", end: "key-00100", options: ScanOptions{IncludeEnd: true}, want: evenKeys(100, 102)},
+		{name: "start equals absent end included", start: "key-00101", end: "key-00101", options: ScanOptions{IncludeEnd: true}},
+		{name: "start equals end included but start excluded", start: "key-00100", end: "key-00100", options: ScanOptions{ExcludeStart: true, IncludeEnd: true}},
+		{name: "limit 0", start: "key-00100", end: "key-01500", options: ScanOptions{Limit: 0}, want: evenKeys(100, 1500)},
+		{name: "limit 1", start: "key-00101", end: "key-01500", options: ScanOptions{Limit: 1}, want: evenKeys(102, 104)},
+		{name: "limit across leaves", start: "key-00100", options: ScanOptions{Limit: 700}, want: evenKeys(100, 1500)},
+		{name: "limit exact", start: "key-00100", end: "key-01500", options: ScanOptions{Limit: 700}, want: evenKeys(100, 1500)},
+		{name: "limit above range", start: "key-00100", end: "key-01500", options: ScanOptions{Limit: 701}, want: evenKeys(100, 1500)},
+	}
+
+	for _, copyOnWrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy-on-write=%v", copyOnWrite), func(t *testing.T) {
+			tree, pager := openLoggedTree(t, t.TempDir(), copyOnWrite)
+			defer pager.Close()
+			for i := 0; i < keys; i += 2 {
+				if err := tree.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+					t.Fatalf("insert: %v", err.Errors())
+				}
+			}
+			if checkSound(t, tree).Height < 2 {
+				t.Fatal("the keys fit in a single leaf")
+			}
+
+			for _, c := range cases {
+				got := scanKeys(t, tree.ScanWithOptions(c.start, c.end, c.options))
+				if !slices.Equal(got, c.want) {
+					t.Errorf("%s: scan found %d keys %v, want %d keys %v", c.name, len(got), got, len(c.want), c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScanClosedEarly(t *testing.T) {
+	for _, copyOnWrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy-on-write=%v", copyOnWrite), func(t *testing.T) {
+			tree, pager := openLoggedTree(t, t.TempDir(), copyOnWrite)
+			defer pager.Close()
+			insertKeys(t, tree, 0, 1000)
+
+			it := tree.Scan("", "")
+			for i := 0; i < 10; i++ {
+				if !it.Next() {
+					t.Fatalf("scan stopped after %d keys", i)
+				}
+			}
+			it.Close()
+			if it.Next() {
+				t.Fatalf("closed scan returned %s", it.Key())
+			}
+			if err := it.Err(); err.IsNotEmpty() {
+				t.Fatalf("closed scan: %v", err.Errors())
+			}
+			it.Close()
+			if copyOnWrite {
+				tree.cow.mu.Lock()
+				for version, pins := range tree.cow.pins {
+					if pins != 0 {
+						t.Errorf("version %d still has %d snapshots open", version, pins)
+					}
+				}
+				tree.cow.mu.Unlock()
+			}
+
+			// Closing released whatever the scan held, so writes and a new
+			// scan go through.
+			within(t, "writes after a closed scan", func() error {
+				return tree.Delete("key-00000").Err()
+			})
+			checkKeys(t, tree, 1, 1000)
+		})
+	}
+}
@@ -1,60 +1,53 @@
 package implementation
 
 import (
-	"fmt"
-	"runtime/debug"
-
 	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
-	"github.com/Kush/Database-internals/diskStorage/pagination"
 	"github.com/Kush/Database-internals/lib"
 )
 
-func (b *BPlusTree) Search(primaryKey string) (common.Value, lib.Error) {
+// Search looks primaryKey up and reports whether it is stored, so a missing
+// key can be told apart from one stored with a zero or NULL value.
+func (b *BPlusTree) Search(primaryKey string) (_ common.Value, _ bool, err lib.Error) {
 	defer func() {
 		if r := recover(); r != nil {
-			lib.EmptyError().AddErr(lib.PanicFound, fmt.Errorf("panic recovered: %+v , stack : %v", r, string(debug.Stack())))
+			err = lib.Recovered(r)
 		}
 	}()
 	if b == nil {
-		return common.Value{}, lib.EmptyError()
+		return common.Value{}, false, lib.EmptyError()
+	}
+	// A copy-on-write tree answers from the latest committed version.
+	if b.copyOnWrite() {
+		snapshot, err := b.Snapshot()
+		if err.IsNotEmpty() {
+			return common.Value{}, false, err
+		}
+		defer snapshot.Close()
+		return snapshot.Search(primaryKey)
 	}
 
-	return b.search(b.root, primaryKey)
-}
+	b.rollbackLatch.RLock()
+	defer b.rollbackLatch.RUnlock()
 
-func (b *BPlusTree) search(nodePage pagination.PageID, primaryKey string) (common.Value, lib.Error) {
-	if nodePage == 0 {
-		return common.Value{}, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("root page Id is not set"))
-	}
+	return b.search(primaryKey)
+}
 
-	treeNode, err := b.LoadTreeNode(nodePage)
+// search descends with shared latches only, so lookups run in parallel with
+// each other and with writers working on other leaves.
+func (b *BPlusTree) search(primaryKey string) (common.Value, bool, lib.Error) {
+	ref, err := b.descend(primaryKey, shared)
 	if err.IsNotEmpty() {
-		return common.Value{}, err
-	}
-	if treeNode == nil {
-		return common.Value{}, lib.EmptyError()
+		return common.Value{}, false, err
 	}
+	defer b.releaseLeaf(ref)
 
-	childIdx := treeNode.NodesCount()
-	for idx, node := range treeNode.Nodes() {
-		if primaryKey < node.PrimaryKey() {
-			childIdx = idx
-			break
-		}
-	}
-
-	if treeNode.IsLeaf() {
-		if childIdx > 0 {
-			if treeNode.Nodes()[childIdx-1].PrimaryKey() == primaryKey {
-				return treeNode.Nodes()[childIdx-1].Value(), lib.EmptyError()
-			}
-		}
-		return common.Value{}, lib.EmptyError()
+	entry, ok := ref.leaf.FindNode(primaryKey)
+	if !ok {
+		return common.Value{}, false, lib.EmptyError()
 	}
-
-	if childIdx < len(treeNode.ChildTreeNodes()) {
-		return b.search(treeNode.ChildTreeNodes()[childIdx], primaryKey)
+	value, err := b.resolveValue(entry)
+	if err.IsNotEmpty() {
+		return common.Value{}, false, err
 	}
-
-	return common.Value{}, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("search: primary key %s not found", primaryKey))
+	return value, true, lib.EmptyError()
 }
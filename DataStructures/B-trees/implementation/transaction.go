@@ -0,0 +1,156 @@
+package implementation
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Tx groups inserts and deletes so that they are committed together or not
+// at all. It needs a logged pager: its writes stay staged in the log's
+// pager until Commit syncs them as one log commit, and Rollback drops them.
+//
+// A transaction has the tree to itself. Begin waits for running writes to
+// finish, and every other operation waits until the transaction commits or
+// rolls back, so that nobody sees writes that may still be rolled back; it
+// must not call back into the tree directly. Reads of a copy-on-write tree
+// are the exception: they are served from the last committed version and
+// go ahead. A Tx is not safe for concurrent use.
+type Tx struct {
+	tree   *BPlusTree
+	logged pagination.LoggedPagination
+	done   bool
+	// failed is set once an operation failed after it started changing
+	// pages; the transaction can then only be rolled back.
+	failed lib.Error
+}
+
+func (b *BPlusTree) Begin() (*Tx, lib.Error) {
+	logged, ok := b.pager.(pagination.LoggedPagination)
+	if !ok {
+		return nil, lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("begin: transactions need a logged pager to roll back, got %T", b.pager))
+	}
+	if b.rootPage() == 0 {
+		return nil, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("begin: tree is not initialized"))
+	}
+
+	b.syncLatch.Lock()
+	b.rollbackLatch.Lock()
+	// Operations that finished but are still waiting to commit go first, so
+	// a rollback of the transaction only drops its own writes.
+	if err := b.syncAndPublish(); err.IsNotEmpty() {
+		b.epoch++
+		b.rollbackLatch.Unlock()
+		b.unlockSync()
+		return nil, err
+	}
+	return &Tx{tree: b, logged: logged, failed: lib.EmptyError()}, lib.EmptyError()
+}
+
+func (tx *Tx) Insert(primaryKey string, value common.Value) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = tx.track(lib.Recovered(r))
+		}
+	}()
+
+	if err := tx.usable(); err.IsNotEmpty() {
+		return err
+	}
+	// Refusing the entry up front leaves the transaction usable.
+	if err := tx.tree.checkEntry(primaryKey, value); err.IsNotEmpty() {
+		return err
+	}
+	return tx.track(tx.tree.insert(primaryKey, value))
+}
+
+func (tx *Tx) Delete(primaryKey string) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = tx.track(lib.Recovered(r))
+		}
+	}()
+
+	if err := tx.usable(); err.IsNotEmpty() {
+		return err
+	}
+	return tx.track(tx.tree.delete(primaryKey))
+}
+
+// Search sees the transaction's own uncommitted writes.
+func (tx *Tx) Search(primaryKey string) (_ common.Value, _ bool, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	if err := tx.usable(); err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	return tx.tree.search(primaryKey)
+}
+
+// Commit makes every write of the transaction durable at once. If that
+// fails, or an operation of the transaction failed earlier, the transaction
+// is rolled back instead and the error returned.
+func (tx *Tx) Commit() lib.Error {
+	if tx.done {
+		return lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("commit: transaction already finished"))
+	}
+	if tx.failed.IsNotEmpty() {
+		if err := tx.finish(true); err.IsNotEmpty() {
+			return err
+		}
+		return tx.failed
+	}
+
+	if err := tx.tree.syncAndPublish(); err.IsNotEmpty() {
+		tx.finish(true)
+		return err
+	}
+	return tx.finish(false)
+}
+
+// Rollback discards every write of the transaction. Rolling back a finished
+// transaction does nothing, so it is safe to defer.
+func (tx *Tx) Rollback() lib.Error {
+	if tx.done {
+		return lib.EmptyError()
+	}
+	return tx.finish(true)
+}
+
+func (tx *Tx) usable() lib.Error {
+	if tx.done {
+		return lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("transaction already finished"))
+	}
+	if tx.failed.IsNotEmpty() {
+		return lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("transaction failed earlier and can only be rolled back"))
+	}
+	return lib.EmptyError()
+}
+
+func (tx *Tx) track(err lib.Error) lib.Error {
+	if err.IsNotEmpty() {
+		tx.failed = err
+	}
+	return err
+}
+
+// finish ends the transaction, dropping its staged writes if rollback is
+// set, and hands the tree back to other operations.
+func (tx *Tx) finish(rollback bool) lib.Error {
+	b := tx.tree
+	tx.done = true
+	defer b.unlockSync()
+	defer b.rollbackLatch.Unlock()
+
+	if !rollback {
+		return lib.EmptyError()
+	}
+	// The rollback also undoes any root change made by the transaction.
+	return b.rollback(tx.logged)
+}
@@ -0,0 +1,207 @@
+package implementation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func begin(t *testing.T, tree *BPlusTree) *Tx {
+	t.Helper()
+	tx, err := tree.Begin()
+	if err.IsNotEmpty() {
+		t.Fatalf("begin: %v", err.Errors())
+	}
+	return tx
+}
+
+func txInsertKeys(t *testing.T, tx *Tx, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := tx.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			t.Fatalf("insert %d: %v", i, err.Errors())
+		}
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	dir := t.TempDir()
+	tree, pager := openLoggedTree(t, dir, false)
+	insertKeys(t, tree, 0, 100)
+
+	tx := begin(t, tree)
+	txInsertKeys(t, tx, 100, 2000)
+	for i := 0; i < 50; i++ {
+		if err := tx.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete %d: %v", i, err.Errors())
+		}
+	}
+	if _, found, err := tx.Search("key-01999"); err.IsNotEmpty() || !found {
+		t.Fatalf("transaction does not see its own insert: %t, %v", found, err.Errors())
+	}
+	if err := tx.Commit(); err.IsNotEmpty() {
+		t.Fatalf("commit: %v", err.Errors())
+	}
+	if err := tx.Insert("key-x", common.NewIntValue(0)); err.IsEmpty() {
+		t.Fatalf("insert into a committed transaction succeeded")
+	}
+	if err := pager.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+
+	tree, pager = openLoggedTree(t, dir, false)
+	defer pager.Close()
+	checkKeys(t, tree, 50, 2000)
+	checkSound(t, tree)
+}
+
+func TestTxRollbackUndoesSplits(t *testing.T) {
+	tree, pager := openLoggedTree(t, t.TempDir(), false)
+	defer pager.Close()
+	insertKeys(t, tree, 0, 10)
+	root, height := tree.rootPage(), tree.height
+	free, err := pager.FreePageCount()
+	if err.IsNotEmpty() {
+		t.Fatalf("free pages: %v", err.Errors())
+	}
+
+	tx := begin(t, tree)
+	txInsertKeys(t, tx, 10, 5000)
+	if tree.height == height {
+		t.Fatalf("inserting 5000 keys did not split the root")
+	}
+	if err := tx.Rollback(); err.IsNotEmpty() {
+		t.Fatalf("rollback: %v", err.Errors())
+	}
+
+	if tree.rootPage() != root || tree.height != height {
+		t.Fatalf("rollback left root %d at height %d, want %d at %d", tree.rootPage(), tree.height, root, height)
+	}
+	if now, _ := pager.FreePageCount(); now != free {
+		t.Fatalf("%d free pages after the rollback, want %d", now, free)
+	}
+	checkKeys(t, tree, 0, 10)
+	checkSound(t, tree)
+	insertKeys(t, tree, 10, 1000)
+	checkKeys(t, tree, 0, 1000)
+}
+
+func TestTxFailedOperationForcesRollback(t *testing.T) {
+	tree, walPager := openLoggedTree(t, t.TempDir(), false)
+	defer walPager.Close()
+	insertKeys(t, tree, 0, 2000)
+	pager := &panickyPager{Pager: walPager}
+	pager.page.Store(noPage)
+	tree.pager = pager
+	root, err := tree.LoadTreeNode(tree.rootPage())
+	if err.IsNotEmpty() {
+		t.Fatalf("load root: %v", err.Errors())
+	}
+
+	tx := begin(t, tree)
+	txInsertKeys(t, tx, 5000, 5100)
+	pager.page.Store(uint32(root.ChildTreeNodes()[0]))
+	if err := tx.Delete("key-00000"); !errors.Is(err, lib.ErrPanicFound) {
+		t.Fatalf("delete returned %v, want the recovered panic", err.Errors())
+	}
+	pager.page.Store(noPage)
+
+	if err := tx.Insert("key-05100", common.NewIntValue(0)); !errors.Is(err, lib.ErrTransaction) {
+		t.Fatalf("insert after a failed operation returned %v", err.Errors())
+	}
+	if err := tx.Commit(); !errors.Is(err, lib.ErrPanicFound) {
+		t.Fatalf("commit returned %v, want the failure of the delete", err.Errors())
+	}
+	checkKeys(t, tree, 0, 2000)
+	checkSound(t, tree)
+}
+
+// TestTxHidesUncommittedWrites reads keys a transaction inserted and then
+// rolls back. Outside copy-on-write mode the read waits for the rollback;
+// in copy-on-write mode it reads the committed version right away.
+func TestTxHidesUncommittedWrites(t *testing.T) {
+	for _, copyOnWrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copyOnWrite=%t", copyOnWrite), func(t *testing.T) {
+			tree, pager := openLoggedTree(t, t.TempDir(), copyOnWrite)
+			defer pager.Close()
+			insertKeys(t, tree, 0, 1000)
+
+			tx := begin(t, tree)
+			defer tx.Rollback()
+			txInsertKeys(t, tx, 1000, 2000)
+
+			read := make(chan error, 1)
+			go func() {
+				if _, found, err := tree.Search("key-01500"); err.IsNotEmpty() || found {
+					read <- fmt.Errorf("search found %t: %v", found, err)
+					return
+				}
+				it := tree.Scan("key-00990", "key-01010")
+				defer it.Close()
+				n := 0
+				for it.Next() {
+					n++
+				}
+				if n != 10 {
+					read <- fmt.Errorf("scan found %d keys, want the 10 committed ones", n)
+					return
+				}
+				read <- it.Err().Err()
+			}()
+
+			if !copyOnWrite {
+				select {
+				case err := <-read:
+					t.Fatalf("read finished during the transaction: %v", err)
+				case <-time.After(100 * time.Millisecond):
+				}
+				if err := tx.Rollback(); err.IsNotEmpty() {
+					t.Fatalf("rollback: %v", err.Errors())
+				}
+			}
+			select {
+			case err := <-read:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("read did not return")
+			}
+		})
+	}
+}
+
+// TestSyncWaitsForTransactions syncs while a transaction has staged writes.
+// The sync waits for the transaction, so the writes it rolls back never
+// reach the file.
+func TestSyncWaitsForTransactions(t *testing.T) {
+	dir := t.TempDir()
+	tree, pager := openLoggedTree(t, dir, false)
+	insertKeys(t, tree, 0, 100)
+
+	tx := begin(t, tree)
+	txInsertKeys(t, tx, 100, 1000)
+	synced := make(chan lib.Error, 1)
+	go func() { synced <- tree.Sync() }()
+	select {
+	case err := <-synced:
+		t.Fatalf("sync finished during the transaction: %v", err.Errors())
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := tx.Rollback(); err.IsNotEmpty() {
+		t.Fatalf("rollback: %v", err.Errors())
+	}
+	within(t, "sync after the rollback", func() error { return (<-synced).Err() })
+	if err := pager.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+
+	tree, pager = openLoggedTree(t, dir, false)
+	defer pager.Close()
+	checkKeys(t, tree, 0, 100)
+	checkSound(t, tree)
+}
@@ -0,0 +1,276 @@
+package implementation
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// ViolationKind names the rule a page breaks.
+type ViolationKind string
+
+const (
+	UnreadablePage     ViolationKind = "unreadable-page"
+	PageOutOfRange     ViolationKind = "page-out-of-range"
+	PageIDMismatch     ViolationKind = "page-id-mismatch"
+	DuplicateReference ViolationKind = "duplicate-reference"
+	UnsortedKeys       ViolationKind = "unsorted-keys"
+	KeyOutOfBounds     ViolationKind = "key-out-of-bounds"
+	ChildCount         ViolationKind = "child-count"
+	LeafDepth          ViolationKind = "leaf-depth"
+	ParentMismatch     ViolationKind = "parent-mismatch"
+	LeafLink           ViolationKind = "leaf-link"
+	BrokenOverflow     ViolationKind = "broken-overflow"
+	BrokenFreeList     ViolationKind = "broken-free-list"
+	FreePageInUse      ViolationKind = "free-page-in-use"
+	FreeCountMismatch  ViolationKind = "free-count-mismatch"
+	OrphanPage         ViolationKind = "orphan-page"
+)
+
+type Violation struct {
+	Kind   ViolationKind     `json:"kind"`
+	Page   pagination.PageID `json:"page"`
+	Detail string            `json:"detail"`
+}
+
+// VerifyReport is the outcome of Verify. Page counts only cover pages that
+// could be read.
+type VerifyReport struct {
+	RootPage      pagination.PageID `json:"root_page"`
+	Height        int               `json:"height"`
+	PageCount     int               `json:"page_count"`
+	TreePages     int               `json:"tree_pages"`
+	OverflowPages int               `json:"overflow_pages"`
+	FreePages     int               `json:"free_pages"`
+	Keys          int               `json:"keys"`
+	Violations    []Violation       `json:"violations"`
+}
+
+func (r VerifyReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// Verify walks every page reachable from the root recorded in the header
+// and checks that:
+//   - keys are strictly increasing within a node and lie within the bounds
+//     the separators above them set,
+//   - an internal node has one child more than it has keys,
+//   - every leaf sits at the same depth,
+//   - a node's parent pointer names the node it hangs from, 0 for the root,
+//   - leaf next links chain the leaves in key order,
+//   - no page is reached twice, and overflow chains are intact,
+//   - the free list is sound and shares no page with the tree,
+//   - every other page of the file is accounted for.
+//
+// Leaf links and parent pointers are not checked in copy-on-write mode,
+// which does not keep them, and pages retired by copy-on-write writes but
+// not reclaimed yet do not count as orphans.
+//
+// Verify reads the root from the header and does not need Init. It waits
+// for running writes and holds new ones back while it runs. The error is only
+// set when the header cannot be read; everything else is reported as a
+// violation.
+func (b *BPlusTree) Verify() (VerifyReport, lib.Error) {
+	b.syncLatch.Lock()
+	defer b.unlockSync()
+
+	header, err := pagination.ReadHeader(b.pager)
+	if err.IsNotEmpty() {
+		return VerifyReport{}, err
+	}
+	v := &verifier{
+		tree:      b,
+		header:    header,
+		reachable: map[pagination.PageID]bool{0: true},
+		report:    VerifyReport{RootPage: header.RootPage, PageCount: int(header.PageCount), Violations: []Violation{}},
+	}
+	if header.RootPage != 0 {
+		v.walk(header.RootPage, 0, 1, "", "", false)
+		if !b.copyOnWrite() {
+			v.checkLeafLinks()
+		}
+	}
+	v.checkFreeList()
+	v.checkOrphans()
+	return v.report, lib.EmptyError()
+}
+
+type verifier struct {
+	tree      *BPlusTree
+	header    pagination.Header
+	reachable map[pagination.PageID]bool
+	// leaves are the leaves in key order.
+	leaves []*node.TreeNode
+	report VerifyReport
+}
+
+func (v *verifier) add(kind ViolationKind, page pagination.PageID, format string, args ...any) {
+	v.report.Violations = append(v.report.Violations, Violation{Kind: kind, Page: page, Detail: fmt.Sprintf(format, args...)})
+}
+
+// claim marks page as reached and reports whether it was new and inside
+// the file.
+func (v *verifier) claim(page, from pagination.PageID) bool {
+	if page == 0 || uint32(page) >= v.header.PageCount {
+		v.add(PageOutOfRange, from, "references page %d, the file has %d pages", page, v.header.PageCount)
+		return false
+	}
+	if v.reachable[page] {
+		v.add(DuplicateReference, page, "reached a second time, from page %d", from)
+		return false
+	}
+	v.reachable[page] = true
+	return true
+}
+
+// walk checks the subtree at pageID, whose keys must lie in [lower, upper);
+// an empty lower means no bound, as does hasUpper being unset.
+func (v *verifier) walk(pageID, parent pagination.PageID, depth int, lower, upper string, hasUpper bool) {
+	if !v.claim(pageID, parent) {
+		return
+	}
+	tn, err := v.tree.LoadTreeNode(pageID)
+	if err.IsNotEmpty() {
+		v.add(UnreadablePage, pageID, "%v", err)
+		return
+	}
+	v.report.TreePages++
+
+	if tn.PageID() != pageID {
+		v.add(PageIDMismatch, pageID, "node records page %d", tn.PageID())
+	}
+	if !v.tree.copyOnWrite() && tn.ParentNode() != parent {
+		v.add(ParentMismatch, pageID, "parent pointer is %d, actual parent is %d", tn.ParentNode(), parent)
+	}
+
+	nodes := tn.Nodes()
+	for i, n := range nodes {
+		if n.HasOverflowKey() {
+			v.claimChain(n.KeyOverflowPage(), pageID)
+		}
+		key := n.PrimaryKey()
+		if i > 0 && key <= nodes[i-1].PrimaryKey() {
+			v.add(UnsortedKeys, pageID, "key %q at %d does not follow %q", key, i, nodes[i-1].PrimaryKey())
+		}
+		if key < lower || (hasUpper && key >= upper) {
+			v.add(KeyOutOfBounds, pageID, "key %q is outside [%q, %q)", key, lower, upper)
+		}
+	}
+
+	if tn.IsLeaf() {
+		v.checkLeaf(tn, depth)
+		return
+	}
+
+	children := tn.ChildTreeNodes()
+	if len(children) != len(nodes)+1 {
+		v.add(ChildCount, pageID, "%d keys but %d children", len(nodes), len(children))
+		return
+	}
+	for i, child := range children {
+		childLower, childUpper, childHasUpper := lower, upper, hasUpper
+		if i > 0 {
+			childLower = nodes[i-1].PrimaryKey()
+		}
+		if i < len(nodes) {
+			childUpper, childHasUpper = nodes[i].PrimaryKey(), true
+		}
+		v.walk(child, pageID, depth+1, childLower, childUpper, childHasUpper)
+	}
+}
+
+func (v *verifier) checkLeaf(leaf *node.TreeNode, depth int) {
+	if v.report.Height == 0 {
+		v.report.Height = depth
+	} else if depth != v.report.Height {
+		v.add(LeafDepth, leaf.PageID(), "leaf at depth %d, the first leaf is at depth %d", depth, v.report.Height)
+	}
+	v.leaves = append(v.leaves, leaf)
+	v.report.Keys += leaf.NodesCount()
+
+	for _, entry := range leaf.Nodes() {
+		if !entry.HasOverflow() {
+			continue
+		}
+		if _, err := pagination.ReadOverflow(v.tree.pager, entry.OverflowPage(), int(entry.OverflowLength())); err.IsNotEmpty() {
+			v.add(BrokenOverflow, leaf.PageID(), "value of key %q: %v", entry.PrimaryKey(), err)
+		}
+		v.claimChain(entry.OverflowPage(), leaf.PageID())
+	}
+}
+
+// claimChain claims the pages of the overflow chain at head, referenced from
+// page from. A chain that cannot be read was reported when its contents were.
+func (v *verifier) claimChain(head, from pagination.PageID) {
+	pages, err := pagination.OverflowPages(v.tree.pager, head)
+	if err.IsNotEmpty() {
+		return
+	}
+	for _, page := range pages {
+		if !v.claim(page, from) {
+			return
+		}
+		v.report.OverflowPages++
+		from = page
+	}
+}
+
+// checkLeafLinks checks that each leaf links to the next one in key order
+// and the last one to nothing.
+func (v *verifier) checkLeafLinks() {
+	for i, leaf := range v.leaves {
+		want := pagination.PageID(0)
+		if i+1 < len(v.leaves) {
+			want = v.leaves[i+1].PageID()
+		}
+		if leaf.Next() != want {
+			v.add(LeafLink, leaf.PageID(), "next link is %d, the next leaf is %d", leaf.Next(), want)
+		}
+	}
+}
+
+// checkFreeList checks the free list, trunk pages included, against the
+// tree and the header's count.
+func (v *verifier) checkFreeList() {
+	pages, err := pagination.FreePages(v.tree.pager)
+	if err.IsNotEmpty() {
+		v.add(BrokenFreeList, v.header.FreeListHead, "%v", err)
+		return
+	}
+	free := make(map[pagination.PageID]bool, len(pages))
+	for _, page := range pages {
+		switch {
+		case page == 0 || uint32(page) >= v.header.PageCount:
+			v.add(BrokenFreeList, page, "free list holds page %d, the file has %d pages", page, v.header.PageCount)
+		case free[page]:
+			v.add(BrokenFreeList, page, "page is on the free list twice")
+		case v.reachable[page]:
+			v.add(FreePageInUse, page, "page is on the free list but used by the tree")
+		default:
+			free[page] = true
+			v.reachable[page] = true
+		}
+	}
+	v.report.FreePages = len(free)
+	if len(pages) != int(v.header.FreePageCount) {
+		v.add(FreeCountMismatch, 0, "header counts %d free pages, the free list holds %d", v.header.FreePageCount, len(pages))
+	}
+}
+
+// checkOrphans reports the pages neither the tree nor the free list
+// accounts for. They are lost until the file is rebuilt.
+func (v *verifier) checkOrphans() {
+	retired := make(map[pagination.PageID]bool)
+	if v.tree.copyOnWrite() {
+		for _, page := range v.tree.cow.retiredPages() {
+			retired[page] = true
+		}
+	}
+	for page := pagination.PageID(1); uint32(page) < v.header.PageCount; page++ {
+		if !v.reachable[page] && !retired[page] {
+			v.add(OrphanPage, page, "page is neither reachable from the root nor free")
+		}
+	}
+}
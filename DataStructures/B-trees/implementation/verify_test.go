@@ -0,0 +1,90 @@
+package implementation
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+)
+
+func newVerifyTree(t *testing.T) *BPlusTree {
+	t.Helper()
+	engine, closeFn := openConformanceTree(t, t.TempDir())
+	t.Cleanup(func() { closeFn() })
+	tree := engine.(*BPlusTree)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	for i := 0; i < 2000; i++ {
+		value := common.NewIntValue(int64(i))
+		if i%100 == 0 {
+			value = common.NewStringValue(strings.Repeat("v", 2000))
+		}
+		if err := tree.Insert(fmt.Sprintf("key-%05d", i), value); err.IsNotEmpty() {
+			t.Fatalf("insert: %v", err.Errors())
+		}
+	}
+	for i := 0; i < 2000; i += 3 {
+		if err := tree.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete: %v", err.Errors())
+		}
+	}
+	return tree
+}
+
+func TestVerifySoundTree(t *testing.T) {
+	tree := newVerifyTree(t)
+	report, err := tree.Verify()
+	if err.IsNotEmpty() {
+		t.Fatalf("verify: %v", err.Errors())
+	}
+	if !report.OK() {
+		t.Fatalf("violations in a sound tree: %+v", report.Violations)
+	}
+	if report.Height < 2 || report.Keys != 2000-667 || report.OverflowPages == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestVerifyReportsViolations(t *testing.T) {
+	tree := newVerifyTree(t)
+
+	// Swap two keys of the first leaf, point it at itself as its parent and
+	// leak a page.
+	leaf, err := tree.LoadTreeNode(tree.root)
+	for err.IsEmpty() && !leaf.IsLeaf() {
+		leaf, err = tree.LoadTreeNode(leaf.ChildTreeNodes()[0])
+	}
+	if err.IsNotEmpty() {
+		t.Fatalf("load: %v", err.Errors())
+	}
+	nodes := leaf.Nodes()
+	nodes[0], nodes[1] = nodes[1], nodes[0]
+	leaf.SetParentNode(leaf.PageID())
+	if err := tree.SaveNode(leaf); err.IsNotEmpty() {
+		t.Fatalf("save: %v", err.Errors())
+	}
+	leaked, err := tree.allocatePage()
+	if err.IsNotEmpty() {
+		t.Fatalf("allocate: %v", err.Errors())
+	}
+
+	report, err := tree.Verify()
+	if err.IsNotEmpty() {
+		t.Fatalf("verify: %v", err.Errors())
+	}
+	found := make(map[ViolationKind]Violation)
+	for _, violation := range report.Violations {
+		found[violation.Kind] = violation
+	}
+	if v, ok := found[UnsortedKeys]; !ok || v.Page != leaf.PageID() {
+		t.Errorf("unsorted keys of page %d not reported: %+v", leaf.PageID(), report.Violations)
+	}
+	if v, ok := found[ParentMismatch]; !ok || v.Page != leaf.PageID() {
+		t.Errorf("parent pointer of page %d not reported: %+v", leaf.PageID(), report.Violations)
+	}
+	if v, ok := found[OrphanPage]; !ok || v.Page != leaked {
+		t.Errorf("orphan page %d not reported: %+v", leaked, report.Violations)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// token is a word of a command line. Quoted tokens are unquoted already;
+// quoted records that they were, which makes them string literals.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits line on whitespace. Double-quoted tokens may contain
+// whitespace and Go escapes.
+func tokenize(line string) ([]token, lib.Error) {
+	var tokens []token
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("unterminated string %s", line[i:]))
+			}
+			text, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("invalid string %s: %w", line[i:end+1], err))
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t\r\n\"", rune(line[end])) {
+				end++
+			}
+			tokens = append(tokens, token{text: line[i:end]})
+			i = end
+		}
+	}
+	return tokens, lib.EmptyError()
+}
+
+// parseLiteral reads a typed value:
+//
+//	"text"        string
+//	42, -7        int64
+//	3.5, 3.5f, 2f float64; NaNf, +Inff and -Inff too
+//	true, false   bool
+//	0x00ff        bytes
+//	null          NULL
+func parseLiteral(t token) (common.Value, lib.Error) {
+	if t.quoted {
+		return common.NewStringValue(t.text), lib.EmptyError()
+	}
+	text := t.text
+	switch text {
+	case "true", "false":
+		return common.NewBoolValue(text == "true"), lib.EmptyError()
+	case "null", "NULL":
+		return common.NewNullValue(), lib.EmptyError()
+	}
+	if hexDigits, ok := strings.CutPrefix(text, "0x"); ok {
+		b, err := hex.DecodeString(hexDigits)
+		if err != nil {
+			return common.Value{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("invalid bytes literal %s: %w", text, err))
+		}
+		return common.NewBytesValue(b), lib.EmptyError()
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return common.NewIntValue(n), lib.EmptyError()
+	}
+	if digits, ok := strings.CutSuffix(text, "f"); ok || strings.ContainsAny(text, ".eE") {
+		if f, err := strconv.ParseFloat(digits, 64); err == nil {
+			return common.NewFloatValue(f), lib.EmptyError()
+		}
+	}
+	return common.Value{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("invalid literal %s; quote strings, as in \"%s\"", text, text))
+}
+
+// formatLiteral writes value so that parseLiteral reads it back.
+func formatLiteral(value common.Value) string {
+	switch value.Type() {
+	case common.StringType:
+		return strconv.Quote(value.StringValue())
+	case common.FloatType:
+		return strconv.FormatFloat(value.FloatValue(), 'g', -1, 64) + "f"
+	case common.NullType:
+		return "null"
+	}
+	// Bools, ints and bytes print as their literals.
+	return value.String()
+}
+
+// formatKey quotes keys that would not read back as a single bare token.
+func formatKey(key string) string {
+	if key == "" || strings.ContainsAny(key, " \t\r\n\"\\") || !strconv.CanBackquote(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
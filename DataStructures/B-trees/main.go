@@ -1,94 +1,130 @@
+// Command B-trees is a shell for a database file.
+//
+//	B-trees [-db path] [-page-size n] [-engine btree|lsm|hash] [-f script] [-keep-going]
+//
+// It reads commands from a terminal interactively. Given a script with -f,
+// or input that is not a terminal, it runs the commands without prompting
+// and exits with status 1 if one of them failed; by default it stops at the
+// first failure. Type help for the commands; on a B+ tree they include SQL
+// statements over tables stored in the same file.
 package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strings"
 
-	"github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
-	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
-	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
-	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
-	"github.com/Kush/Database-internals/diskStorage/pagination"
-	"github.com/Kush/Database-internals/pkg/serialization"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
 )
 
+// Exit statuses for scripts.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// openEngine opens the engine the shell runs on; tests replace it.
+var openEngine = engine.OpenWithOptions
+
 func main() {
-	pager, err := pagination.NewPager("test.db")
-	if err.IsNotEmpty() {
-		fmt.Printf("Error initializing pager: %v\n", err)
-		return
+	os.Exit(runMain())
+}
+
+// runMain runs the shell and returns its exit status, which is exitFailed
+// if closing the engine fails, even after every command succeeded.
+func runMain() (status int) {
+	dbPath := flag.String("db", "test.db", "database file, or directory of an LSM tree")
+	pageSize := flag.Int("page-size", 0, "page size of a new file (default 4096); an existing file keeps its own")
+	kindName := flag.String("engine", string(engine.BPlusTree), "storage engine: btree, lsm or hash")
+	script := flag.String("f", "", "run the commands in this file, - for standard input, and exit")
+	keepGoing := flag.Bool("keep-going", false, "in a script, run the remaining commands after one fails")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		return exitUsage
 	}
-	defer pager.Close()
 
-	baseSerializer := serialization.NewBinarySerializer()
-	serializer := serializer.NewTreeNodeSerializer[*node.TreeNode](baseSerializer)
-	btree := implementation.NewBPlusTree(0, pager, serializer, baseSerializer)
-	err = btree.Init()
+	kind, err := engine.ParseKind(*kindName)
 	if err.IsNotEmpty() {
-		fmt.Printf("Error initializing B+ Tree: %v\n", err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
 	}
-	scanner := bufio.NewScanner(os.Stdin)
+	e, err := openEngine(kind, *dbPath, engine.Options{PageSize: *pageSize})
+	if err.IsNotEmpty() {
+		fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", *dbPath, err)
+		return exitUsage
+	}
+	defer func() {
+		if err := e.Close(); err.IsNotEmpty() {
+			fmt.Fprintf(os.Stderr, "Error closing %s: %v\n", *dbPath, err)
+			if status == exitOK {
+				status = exitFailed
+			}
+		}
+	}()
 
-	fmt.Println("B+ Tree CLI: Enter commands like:")
-	fmt.Println("  insert <key> <int-value>")
-	fmt.Println("  search <key>")
-	fmt.Println("  exit")
+	c := newCLI(e, os.Stdout)
+	input, name := io.Reader(os.Stdin), "stdin"
+	switch {
+	case *script != "" && *script != "-":
+		f, openErr := os.Open(*script)
+		if openErr != nil {
+			fmt.Fprintln(os.Stderr, openErr)
+			return exitUsage
+		}
+		defer f.Close()
+		input, name = f, *script
+	case *script == "" && isTerminal(os.Stdin):
+		return interactive(c)
+	}
 
+	failed, _, err := c.run(input, name, *keepGoing, os.Stderr)
+	if err.IsNotEmpty() {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+func interactive(c *cli) int {
+	fmt.Printf("%s shell on %s. Type help for the commands.\n", c.engine.Kind(), c.engine.Path())
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
 	for {
 		fmt.Print("> ")
 		if !scanner.Scan() {
-			break
+			fmt.Println()
+			return exitOK
 		}
-		line := scanner.Text()
-		tokens := strings.Fields(line)
-
-		if len(tokens) == 0 {
-			continue
+		exit, err := c.execute(scanner.Text())
+		if err.IsNotEmpty() {
+			fmt.Printf("Error: %v\n", conciseError(err))
 		}
+		if exit {
+			return exitOK
+		}
+	}
+}
 
-		switch tokens[0] {
-		case "exit":
-			return
-
-		case "insert":
-			if len(tokens) != 3 {
-				fmt.Println("Usage: insert <key> <int-value>")
-				continue
-			}
-			key := tokens[1]
-			var val int64
-			_, err := fmt.Sscanf(tokens[2], "%d", &val)
-			if err != nil {
-				fmt.Println("Invalid value; must be an integer")
-				continue
-			}
-			errObj := btree.Insert(key, common.NewIntValue(val))
-			if errObj.IsNotEmpty() {
-				fmt.Printf("Insert error: %v\n", errObj)
-			} else {
-				fmt.Println("Inserted successfully.")
-			}
-
-		case "search":
-			if len(tokens) != 2 {
-				fmt.Println("Usage: search <key>")
-				continue
-			}
-			key := tokens[1]
-			val, errObj := btree.Search(key)
-			if errObj.IsNotEmpty() {
-				fmt.Printf("Search error: %v\n", errObj)
-			} else if val.IsEmpty() {
-				fmt.Println("Key not found.")
-			} else {
-				fmt.Printf("Found: %v\n", val)
-			}
-
-		default:
-			fmt.Println("Unknown command. Use insert/search/exit.")
+// conciseError drops the error codes of a single error, which say little to
+// someone at the prompt.
+func conciseError(err lib.Error) error {
+	if errs := err.Errors(); len(errs) == 1 {
+		for _, e := range errs {
+			return e
 		}
 	}
+	return err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
@@ -1,10 +1,25 @@
 package node
 
-import "github.com/Kush/Database-internals/DataStructures/aggregates/common"
+import (
+	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
 
 type Node struct {
 	primaryKey string
 	value      common.Value
+	// overflowPage is the first page of the chain holding a string or bytes
+	// payload too large to be stored inline; the value itself then only
+	// keeps its type.
+	overflowPage   pagination.PageID
+	overflowLength uint32
+	// keyLength is set for a key kept in an overflow chain instead of the
+	// node, and keyPage is the chain's first page. keyPage stays 0 until the
+	// node holding the key is saved, which writes the chain.
+	keyPage   pagination.PageID
+	keyLength uint32
 }
 
 func EmptyNode() Node {
@@ -18,6 +33,54 @@ func NewNode(primaryKey string, value common.Value) Node {
 	}
 }
 
+// NewOverflowNode builds a leaf entry whose string or bytes payload of
+// length bytes lives in the overflow chain starting at page.
+func NewOverflowNode(primaryKey string, value common.Value, page pagination.PageID, length uint32) Node {
+	return Node{
+		primaryKey:     primaryKey,
+		value:          value,
+		overflowPage:   page,
+		overflowLength: length,
+	}
+}
+
+// NewSeparatorNode builds an internal-node entry, which only routes by key
+// and carries no value.
+func NewSeparatorNode(primaryKey string) Node {
+	return Node{
+		primaryKey: primaryKey,
+		value:      common.EmptyValue(),
+	}
+}
+
+// Separator builds the internal-node entry routing to n's key. A key kept in
+// an overflow chain gets a chain of its own once the separator is saved.
+func (n Node) Separator() Node {
+	separator := NewSeparatorNode(n.primaryKey)
+	separator.keyLength = n.keyLength
+	return separator
+}
+
+// WithOverflowKey moves the key to an overflow chain, which is written when
+// the node holding the entry is saved.
+func (n Node) WithOverflowKey() Node {
+	n.keyPage, n.keyLength = 0, uint32(len(n.primaryKey))
+	return n
+}
+
+// WithKeyChain records that the key of length bytes is stored in the
+// overflow chain starting at page.
+func (n Node) WithKeyChain(page pagination.PageID, length uint32) Node {
+	n.keyPage, n.keyLength = page, length
+	return n
+}
+
+// WithPrimaryKey sets the key read back from the entry's key chain.
+func (n Node) WithPrimaryKey(primaryKey string) Node {
+	n.primaryKey = primaryKey
+	return n
+}
+
 func (n Node) PrimaryKey() string {
 	return n.primaryKey
 }
@@ -25,3 +88,41 @@ func (n Node) PrimaryKey() string {
 func (n Node) Value() common.Value {
 	return n.value
 }
+
+func (n Node) HasOverflow() bool {
+	return n.overflowPage != 0
+}
+
+func (n Node) OverflowPage() pagination.PageID {
+	return n.overflowPage
+}
+
+func (n Node) OverflowLength() uint32 {
+	return n.overflowLength
+}
+
+func (n Node) HasOverflowKey() bool {
+	return n.keyLength != 0
+}
+
+func (n Node) KeyOverflowPage() pagination.PageID {
+	return n.keyPage
+}
+
+func (n Node) KeyOverflowLength() uint32 {
+	return n.keyLength
+}
+
+// ByteSize is the serialized size of the entry: the length-prefixed key or
+// the reference to its overflow chain, then the value as
+// serialization.AppendValue encodes it or the reference to its chain.
+func (n Node) ByteSize() int {
+	size := 2 + len(n.primaryKey)
+	if n.HasOverflowKey() {
+		size = constants.OverflowKeySize
+	}
+	if n.HasOverflow() {
+		return size + serialization.OverflowValueSize
+	}
+	return size + serialization.ValueSize(n.value)
+}
@@ -52,12 +52,26 @@ func (tn *TreeNode) IsLeaf() bool {
 	return tn.leaf
 }
 
-func (tn *TreeNode) IsFull() bool {
-	return (len(tn.nodes) >= constants.MaxNodesInTreeNode)
+// ByteSize is the number of bytes the node takes once serialized.
+func (tn *TreeNode) ByteSize() int {
+	size := constants.TreeNodeHeaderSize
+	for _, node := range tn.nodes {
+		size += node.ByteSize()
+	}
+	if !tn.leaf {
+		size += len(tn.childTreeNodes) * constants.ChildPointerSize
+	}
+	return size
+}
+
+// IsFull reports whether the node no longer fits in capacity bytes and has
+// to be split.
+func (tn *TreeNode) IsFull(capacity int) bool {
+	return tn.ByteSize() > capacity
 }
 
-func (tn *TreeNode) IsValidNumberOfNodes() bool {
-	return len(tn.nodes) <= constants.MaxNodesInTreeNode
+func (tn *TreeNode) IsUnderflow(capacity int) bool {
+	return tn.ByteSize() < capacity/constants.MinFillFraction
 }
 
 func (tn *TreeNode) SetLeaf(leaf bool) {
@@ -67,6 +81,9 @@ func (tn *TreeNode) SetParentNode(parent pagination.PageID) {
 	tn.parentNode = parent
 }
 
+// ParentNode is the page of the node's parent, 0 for the root. Copy-on-write
+// writes move the nodes on their path to new pages without rewriting the
+// children of those nodes, so in that mode it goes stale.
 func (tn *TreeNode) ParentNode() pagination.PageID {
 	return tn.parentNode
 }
@@ -135,31 +152,41 @@ func (tn *TreeNode) SortNodes() {
 	})
 }
 
+// SplitTreeNode splits an overflowing node in two halves of roughly equal
+// serialized size. For a leaf the returned separator is the first key of the
+// right half, which stays in the leaf; for an internal node it is the key
+// that moves up into the parent.
 func SplitTreeNode(n *TreeNode) (*TreeNode, *TreeNode, Node, lib.Error) {
 	if n.IsLeaf() {
-		mid := len(n.Nodes()) / 2
+		if len(n.Nodes()) < 2 {
+			return nil, nil, EmptyNode(), lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("SplitTreeNode: leaf %d has %d entries", n.PageID(), len(n.Nodes())))
+		}
+		mid := n.balancedSplitIndex()
 		leftTreeNode := NewTreeNode()
 		rightTreeNode := NewTreeNode()
 		leftTreeNode.SetLeaf(true)
 		rightTreeNode.SetLeaf(true)
 		leftTreeNode.SetPageID(n.PageID())
-		leftTreeNode.SetNodes(n.Nodes()[:mid])
-		rightTreeNode.SetNodes(n.Nodes()[mid:])
+		leftTreeNode.SetNodes(append([]Node{}, n.Nodes()[:mid]...))
+		rightTreeNode.SetNodes(append([]Node{}, n.Nodes()[mid:]...))
 		leftTreeNode.SetParentNode(n.ParentNode())
 		rightTreeNode.SetParentNode(n.ParentNode())
 
-		middleNode := rightTreeNode.Nodes()[0]
+		middleNode := rightTreeNode.Nodes()[0].Separator()
 		return leftTreeNode, rightTreeNode, middleNode, lib.EmptyError()
 	}
 
-	mid := len(n.Nodes()) / 2
+	if len(n.Nodes()) < 3 {
+		return nil, nil, EmptyNode(), lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("SplitTreeNode: internal node %d has %d keys", n.PageID(), len(n.Nodes())))
+	}
+	mid := n.balancedSplitIndex()
 	leftTreeNode := NewTreeNode()
 	rightTreeNode := NewTreeNode()
 	leftTreeNode.SetPageID(n.PageID())
-	leftTreeNode.SetNodes(n.Nodes()[:mid])
-	rightTreeNode.SetNodes(n.Nodes()[mid+1:])
-	leftTreeNode.SetChildTreeNodes(n.ChildTreeNodes()[:mid+1])
-	rightTreeNode.SetChildTreeNodes(n.ChildTreeNodes()[mid+1:])
+	leftTreeNode.SetNodes(append([]Node{}, n.Nodes()[:mid]...))
+	rightTreeNode.SetNodes(append([]Node{}, n.Nodes()[mid+1:]...))
+	leftTreeNode.SetChildTreeNodes(append([]pagination.PageID{}, n.ChildTreeNodes()[:mid+1]...))
+	rightTreeNode.SetChildTreeNodes(append([]pagination.PageID{}, n.ChildTreeNodes()[mid+1:]...))
 	leftTreeNode.SetParentNode(n.ParentNode())
 	rightTreeNode.SetParentNode(n.ParentNode())
 
@@ -167,6 +194,55 @@ func SplitTreeNode(n *TreeNode) (*TreeNode, *TreeNode, Node, lib.Error) {
 	return leftTreeNode, rightTreeNode, middleNode, lib.EmptyError()
 }
 
+// balancedSplitIndex picks the split point that leaves both halves closest
+// in size. For a leaf the right half starts at the returned index; for an
+// internal node the key at the index is promoted and the halves keep at
+// least one key each.
+func (n *TreeNode) balancedSplitIndex() int {
+	sizes := make([]int, len(n.nodes))
+	total := 0
+	for i, node := range n.nodes {
+		sizes[i] = node.ByteSize()
+		if !n.leaf {
+			sizes[i] += constants.ChildPointerSize
+		}
+		total += sizes[i]
+	}
+
+	last := len(n.nodes) - 1
+	if !n.leaf {
+		last = len(n.nodes) - 2
+	}
+	best, bestDiff := 1, -1
+	left := 0
+	for i := 0; i < last; i++ {
+		left += sizes[i]
+		right := total - left
+		if !n.leaf {
+			right -= sizes[i+1]
+		}
+		diff := left - right
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = i+1, diff
+		}
+	}
+	return best
+}
+
+// MergedByteSize is the serialized size of left and right folded into one
+// node, including the separator pulled down from the parent for internal
+// nodes.
+func MergedByteSize(left, right *TreeNode, separator Node) int {
+	size := left.ByteSize() + right.ByteSize() - constants.TreeNodeHeaderSize
+	if !left.IsLeaf() {
+		size += separator.ByteSize()
+	}
+	return size
+}
+
 func (n *TreeNode) InsertInternalKey(node Node, leftPageID, rightPageID pagination.PageID) lib.Error {
 	if n.IsLeaf() {
 		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("InsertInternalKey: called on leaf node"))
@@ -199,7 +275,7 @@ func (n *TreeNode) InsertInOrder(node Node) lib.Error {
 	insertIdx := len(n.Nodes())
 	for i, entry := range n.Nodes() {
 		if entry.PrimaryKey() == node.primaryKey {
-			n.nodes[i].value = node.Value()
+			n.nodes[i] = node
 			return lib.EmptyError()
 		}
 		if node.primaryKey < entry.PrimaryKey() {
@@ -215,3 +291,65 @@ func (n *TreeNode) InsertInOrder(node Node) lib.Error {
 
 	return lib.EmptyError()
 }
+
+func (tn *TreeNode) ChildIndex(child pagination.PageID) int {
+	for i, childPageID := range tn.childTreeNodes {
+		if childPageID == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (tn *TreeNode) SetNodeAt(idx int, node Node) {
+	tn.nodes[idx] = node
+}
+
+func (tn *TreeNode) InsertNodeAt(idx int, node Node) {
+	tn.nodes = append(tn.nodes, EmptyNode())
+	copy(tn.nodes[idx+1:], tn.nodes[idx:])
+	tn.nodes[idx] = node
+}
+
+func (tn *TreeNode) RemoveNodeAt(idx int) Node {
+	removed := tn.nodes[idx]
+	tn.nodes = append(tn.nodes[:idx], tn.nodes[idx+1:]...)
+	return removed
+}
+
+func (tn *TreeNode) SetChildAt(idx int, child pagination.PageID) {
+	tn.childTreeNodes[idx] = child
+}
+
+func (tn *TreeNode) InsertChildAt(idx int, child pagination.PageID) {
+	tn.childTreeNodes = append(tn.childTreeNodes, 0)
+	copy(tn.childTreeNodes[idx+1:], tn.childTreeNodes[idx:])
+	tn.childTreeNodes[idx] = child
+}
+
+func (tn *TreeNode) RemoveChildAt(idx int) pagination.PageID {
+	removed := tn.childTreeNodes[idx]
+	tn.childTreeNodes = append(tn.childTreeNodes[:idx], tn.childTreeNodes[idx+1:]...)
+	return removed
+}
+
+// FindNode returns the entry with the given key and whether it is present.
+func (tn *TreeNode) FindNode(key string) (Node, bool) {
+	for _, node := range tn.nodes {
+		if node.PrimaryKey() == key {
+			return node, true
+		}
+	}
+	return EmptyNode(), false
+}
+
+// RemoveNode deletes the entry with the given key and reports whether it was present.
+func (tn *TreeNode) RemoveNode(key string) bool {
+	for i, node := range tn.nodes {
+		if node.PrimaryKey() == key {
+			tn.RemoveNodeAt(i)
+			return true
+		}
+	}
+	return false
+}
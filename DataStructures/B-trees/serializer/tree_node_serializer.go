@@ -1,16 +1,20 @@
 package serializer
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/Kush/Database-internals/DataStructures/B-trees/constants"
 	data_node "github.com/Kush/Database-internals/DataStructures/B-trees/node"
-	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
 	"github.com/Kush/Database-internals/diskStorage/pagination"
 	"github.com/Kush/Database-internals/lib"
 	"github.com/Kush/Database-internals/pkg/serialization"
 )
 
+// nodeHeaderSize covers the page ID, leaf flag, entry count, and the next
+// and parent page IDs that start every node.
+const nodeHeaderSize = 4 + 1 + 2 + 4 + 4
+
 type TreeNodeSerializer[T any] struct {
 	serializer serialization.BaseSerializer
 }
@@ -68,47 +72,34 @@ func (tn *TreeNodeSerializer[T]) Serialize(val T) ([]byte, lib.Error) {
 
 	// Serialize each node
 	for _, n := range node.Nodes() {
-		// Key
-		b, err := tn.serializer.Serialize(n.PrimaryKey())
-		if err.IsNotEmpty() {
-			return nil, err
-		}
-		buf = append(buf, b...)
-
-		// Value
-		v := n.Value()
-
-		// stringValue
-		strVal, err := tn.serializer.Serialize(v.StringValue())
-		if err.IsNotEmpty() {
-			return nil, err
-		}
-		buf = append(buf, strVal...)
-
-		// boolValue
-		if v.BoolValue() {
-			buf = append(buf, byte(1))
+		// Key, or the reference to its overflow chain
+		if n.HasOverflowKey() {
+			if n.KeyOverflowPage() == 0 {
+				return nil, lib.EmptyError().AddErr(lib.SerializationError, fmt.Errorf("key %q has no overflow chain yet", n.PrimaryKey()))
+			}
+			buf = binary.LittleEndian.AppendUint16(buf, constants.OverflowKeyMarker)
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(n.KeyOverflowPage()))
+			buf = binary.LittleEndian.AppendUint32(buf, n.KeyOverflowLength())
 		} else {
-			buf = append(buf, byte(0))
-		}
-
-		// float32
-		b, err = tn.serializer.Serialize(v.FloatValue())
-		if err.IsNotEmpty() {
-			return nil, err
+			b, err := tn.serializer.Serialize(n.PrimaryKey())
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			buf = append(buf, b...)
 		}
-		buf = append(buf, b...)
 
-		// int64
-		b, err = tn.serializer.Serialize(v.IntValue())
-		if err.IsNotEmpty() {
-			return nil, err
+		if n.HasOverflow() {
+			buf = serialization.AppendOverflowValue(buf, n.Value().Type(), n.OverflowPage(), n.OverflowLength())
+		} else {
+			buf = serialization.AppendValue(buf, n.Value())
 		}
-		buf = append(buf, b...)
 	}
 
-	// Serialize child references as page IDs (uint32 placeholders)
+	// Serialize child references as page IDs; leaves have none
 	childCount := len(node.ChildTreeNodes())
+	if node.IsLeaf() {
+		childCount = 0
+	}
 	for i := 0; i < childCount; i++ {
 		childID := uint32(node.ChildTreeNodes()[i])
 		b, err = tn.serializer.Serialize(childID)
@@ -118,19 +109,20 @@ func (tn *TreeNodeSerializer[T]) Serialize(val T) ([]byte, lib.Error) {
 		buf = append(buf, b...)
 	}
 
-	// Ensure we don’t exceed page size
-	if len(buf) > constants.PageSize {
-		return nil, lib.EmptyError().AddErr(lib.SerializationError, fmt.Errorf("tree node exceeds 4KB page size"))
-	}
-
 	return buf, lib.EmptyError()
 }
 
+// Deserialize checks every count, length and offset against len(data), so
+// that a corrupt page, e.g. one read under ChecksumLogOnly, fails with a
+// DeserializationError instead of a panic.
 func (tn *TreeNodeSerializer[T]) Deserialize(data []byte, v T) lib.Error {
 	node, ok := any(v).(*data_node.TreeNode)
 	if !ok {
 		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("v must implement *node.TreeNode"))
 	}
+	if len(data) < nodeHeaderSize {
+		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %d bytes cannot hold the %d byte node header", len(data), nodeHeaderSize))
+	}
 	offset := 0
 
 	var pageId uint32
@@ -141,10 +133,10 @@ func (tn *TreeNodeSerializer[T]) Deserialize(data []byte, v T) lib.Error {
 	offset += 4
 
 	// 1. Leaf flag
-	if offset+1 > len(data) {
-		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: missing leaf flag"))
-	}
 	leafByte := data[offset]
+	if leafByte > 1 {
+		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: leaf flag is %d", leafByte))
+	}
 	node.SetLeaf(leafByte == 1)
 	offset += 1
 
@@ -172,53 +164,69 @@ func (tn *TreeNodeSerializer[T]) Deserialize(data []byte, v T) lib.Error {
 	node.SetParentNode(pagination.PageID(parentID))
 	offset += 4
 
+	// Every entry takes at least a key length and a type tag.
+	if minSize := int(numNodes) * 3; minSize > len(data)-offset {
+		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %d entries cannot fit in %d bytes", numNodes, len(data)-offset))
+	}
+
 	// 5. Deserialize nodes
 	nodes := make([]data_node.Node, 0, numNodes)
 	for i := 0; i < int(numNodes); i++ {
 		// Primary key (string)
-		var key string
-		if err := tn.serializer.Deserialize(data[offset:], &key); err.IsNotEmpty() {
+		raw, err := field(data, offset, 2, "key length")
+		if err.IsNotEmpty() {
 			return err
 		}
-		keyLen := len(key)
-		offset += 2 + keyLen // assumes prefix length encoding
-
-		// StringValue (string)
-		var strVal string
-		if err := tn.serializer.Deserialize(data[offset:], &strVal); err.IsNotEmpty() {
+		var keyLen uint16
+		if err := tn.serializer.Deserialize(raw, &keyLen); err.IsNotEmpty() {
 			return err
 		}
-		strValLen := len(strVal)
-		offset += 2 + strValLen
-
-		// BoolValue
-		boolVal := data[offset] == 1
-		offset += 1
-
-		// Float32
-		var floatVal float32
-		if err := tn.serializer.Deserialize(data[offset:offset+4], &floatVal); err.IsNotEmpty() {
-			return err
+		// A key in an overflow chain is left empty for the tree to read back.
+		var key string
+		var keyPage, keyLength uint32
+		if keyLen == constants.OverflowKeyMarker {
+			if raw, err = field(data, offset, constants.OverflowKeySize, "key reference"); err.IsNotEmpty() {
+				return err
+			}
+			keyPage, keyLength = binary.LittleEndian.Uint32(raw[2:6]), binary.LittleEndian.Uint32(raw[6:10])
+			if keyPage == 0 || keyLength == 0 {
+				return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: key of %d bytes in an overflow chain at page %d", keyLength, keyPage))
+			}
+		} else {
+			if raw, err = field(data, offset, 2+int(keyLen), "key"); err.IsNotEmpty() {
+				return err
+			}
+			if err := tn.serializer.Deserialize(raw, &key); err.IsNotEmpty() {
+				return err
+			}
 		}
-		offset += 4
+		offset += len(raw)
 
-		// Int64
-		var intVal int64
-		if err := tn.serializer.Deserialize(data[offset:offset+8], &intVal); err.IsNotEmpty() {
+		stored, n, err := serialization.DecodeValue(data[offset:])
+		if err.IsNotEmpty() {
 			return err
 		}
-		offset += 8
-
-		val := common.NewValue(strVal, boolVal, floatVal, intVal)
-		nodes = append(nodes, data_node.NewNode(key, val))
+		offset += n
+		entry := data_node.NewNode(key, stored.Value)
+		if stored.HasOverflow() {
+			entry = data_node.NewOverflowNode(key, stored.Value, stored.Page, stored.Length)
+		}
+		if keyLength != 0 {
+			entry = entry.WithKeyChain(pagination.PageID(keyPage), keyLength)
+		}
+		nodes = append(nodes, entry)
 	}
 	node.SetNodes(nodes)
 
-	// 6. Deserialize child page IDs
+	// 6. Deserialize child page IDs (internal nodes only)
 	childRefs := make([]pagination.PageID, 0, numNodes+1)
-	for i := 0; i < int(numNodes)+1; i++ {
+	for i := 0; !node.IsLeaf() && i < int(numNodes)+1; i++ {
+		raw, err := field(data, offset, 4, "child page")
+		if err.IsNotEmpty() {
+			return err
+		}
 		var childID uint32
-		if err := tn.serializer.Deserialize(data[offset:offset+4], &childID); err.IsNotEmpty() {
+		if err := tn.serializer.Deserialize(raw, &childID); err.IsNotEmpty() {
 			return err
 		}
 		childRefs = append(childRefs, pagination.PageID(childID))
@@ -228,3 +236,12 @@ func (tn *TreeNodeSerializer[T]) Deserialize(data []byte, v T) lib.Error {
 
 	return lib.EmptyError()
 }
+
+// field returns the n bytes of data at offset, or a DeserializationError
+// naming what was read if they run past the end.
+func field(data []byte, offset, n int, what string) ([]byte, lib.Error) {
+	if offset+n > len(data) {
+		return nil, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %s needs bytes %d to %d, only %d are there", what, offset, offset+n, len(data)))
+	}
+	return data[offset : offset+n], lib.EmptyError()
+}
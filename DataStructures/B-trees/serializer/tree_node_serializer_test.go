@@ -0,0 +1,110 @@
+package serializer
+
+import (
+	"testing"
+
+	data_node "github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+func serializedNodes(t *testing.T) (*TreeNodeSerializer[*data_node.TreeNode], [][]byte) {
+	t.Helper()
+	tns := NewTreeNodeSerializer[*data_node.TreeNode](serialization.NewBinarySerializer())
+
+	leaf := data_node.NewLeafTreeNode(3)
+	leaf.SetNodes([]data_node.Node{
+		data_node.NewNode("a", common.NewNullValue()),
+		data_node.NewNode("b", common.NewBoolValue(true)),
+		data_node.NewNode("c", common.NewIntValue(7)),
+		data_node.NewNode("d", common.NewFloatValue(1.5)),
+		data_node.NewNode("e", common.NewStringValue("inline")),
+		data_node.NewOverflowNode("f", common.NewBytesValue(nil), 9, 5000),
+	})
+	internal := data_node.NewInternalNode(4)
+	internal.SetNodes([]data_node.Node{
+		data_node.NewSeparatorNode("m"),
+		data_node.NewSeparatorNode("n").WithKeyChain(11, 3000),
+	})
+	internal.SetChildTreeNodes([]pagination.PageID{3, 5, 6})
+
+	var pages [][]byte
+	for _, n := range []*data_node.TreeNode{leaf, internal} {
+		data, err := tns.Serialize(n)
+		if err.IsNotEmpty() {
+			t.Fatalf("serialize: %v", err.Errors())
+		}
+		pages = append(pages, data)
+	}
+	return tns, pages
+}
+
+func TestOverflowKeyRoundTrip(t *testing.T) {
+	tns, pages := serializedNodes(t)
+	internal := data_node.NewTreeNode()
+	if err := tns.Deserialize(pages[1], internal); err.IsNotEmpty() {
+		t.Fatalf("deserialize: %v", err.Errors())
+	}
+	// The key itself is left for the tree to read from the chain.
+	n := internal.Nodes()[1]
+	if !n.HasOverflowKey() || n.KeyOverflowPage() != 11 || n.KeyOverflowLength() != 3000 || n.PrimaryKey() != "" {
+		t.Fatalf("key chain at page %d, %d bytes, key %q", n.KeyOverflowPage(), n.KeyOverflowLength(), n.PrimaryKey())
+	}
+	if internal.Nodes()[0].HasOverflowKey() || len(internal.ChildTreeNodes()) != 3 {
+		t.Fatal("the inline separator or the children were not decoded")
+	}
+
+	unwritten := data_node.NewInternalNode(4)
+	unwritten.SetNodes([]data_node.Node{data_node.NewSeparatorNode("n").WithOverflowKey()})
+	unwritten.SetChildTreeNodes([]pagination.PageID{3, 5})
+	if _, err := tns.Serialize(unwritten); !err.ContainsError(lib.SerializationError) {
+		t.Fatalf("serialize a key without its chain: got %v, want a serialization error", err.Errors())
+	}
+}
+
+func TestDeserializeRejectsTruncatedNodes(t *testing.T) {
+	tns, pages := serializedNodes(t)
+	for _, data := range pages {
+		if err := tns.Deserialize(data, data_node.NewTreeNode()); err.IsNotEmpty() {
+			t.Fatalf("deserialize whole node: %v", err.Errors())
+		}
+		for n := 0; n < len(data); n++ {
+			err := tns.Deserialize(data[:n], data_node.NewTreeNode())
+			if !err.ContainsError(lib.DeserializationError) {
+				t.Fatalf("%d of %d bytes: got %v, want a deserialization error", n, len(data), err.Errors())
+			}
+		}
+	}
+}
+
+func TestDeserializeRejectsCorruptNodes(t *testing.T) {
+	tns, pages := serializedNodes(t)
+	leaf := pages[0]
+
+	corrupt := map[string]func(page []byte){
+		"leaf flag":   func(page []byte) { page[4] = 7 },
+		"entry count": func(page []byte) { page[5], page[6] = 0xff, 0xff },
+		"key length":  func(page []byte) { page[15], page[16] = 0xfe, 0xff },
+		"key reference": func(page []byte) {
+			page[15], page[16] = 0xff, 0xff
+			clear(page[17:21])
+		},
+		"value type":   func(page []byte) { page[18] = 0xee },
+		"overflow tag": func(page []byte) { page[len(leaf)-9] = 0xee },
+	}
+	for name, fn := range corrupt {
+		t.Run(name, func(t *testing.T) {
+			// A page read from disk is full size, so the damage must be
+			// caught with zeros rather than the end of the slice after it.
+			page := make([]byte, 4096)
+			copy(page, leaf)
+			fn(page)
+			err := tns.Deserialize(page, data_node.NewTreeNode())
+			if !err.ContainsError(lib.DeserializationError) {
+				t.Fatalf("got %v, want a deserialization error", err.Errors())
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package constants
+
+const (
+	// Every page of the index starts with one of these kinds, so a file of
+	// another structure is refused instead of misread.
+	DirectoryPageKind uint8 = 1
+	SegmentPageKind   uint8 = 2
+	BucketPageKind    uint8 = 3
+
+	// DirectoryHeaderSize is kind, global depth and segment count.
+	DirectoryHeaderSize = 4
+	// SegmentHeaderSize is kind and entry count.
+	SegmentHeaderSize = 5
+	// BucketHeaderSize is kind, page ID, local depth and entry count.
+	BucketHeaderSize = 8
+	PageIDSize       = 4
+
+	// A single entry may take at most 1/MaxEntryFraction of a bucket, so a
+	// full bucket always holds several keys its split can spread.
+	MaxEntryFraction = 4
+
+	// String values longer than 1/OverflowFraction of a page are moved to a
+	// chain of overflow pages and the bucket only keeps a reference to them.
+	OverflowFraction = 8
+)
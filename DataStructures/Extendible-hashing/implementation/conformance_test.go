@@ -0,0 +1,24 @@
+package implementation
+
+import (
+	"path/filepath"
+	"testing"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/conformance"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Harness{Open: openConformanceHash})
+}
+
+func openConformanceHash(t *testing.T, dir string) (datastructures.BaseDatabaseStructure, func() lib.Error) {
+	pager, err := pagination.NewPager(filepath.Join(dir, "conformance.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	return NewExtendibleHash(pager, serialization.NewBinarySerializer()), pager.Close
+}
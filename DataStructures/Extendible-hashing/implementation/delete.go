@@ -0,0 +1,123 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/node"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Delete removes primaryKey; deleting a key that is not stored does nothing.
+func (h *ExtendibleHash) Delete(primaryKey string) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.initialized(); err.IsNotEmpty() {
+		return err
+	}
+	return h.commit(apply(func() lib.Error { return h.delete(primaryKey) }))
+}
+
+func (h *ExtendibleHash) delete(primaryKey string) lib.Error {
+	idx := h.bucketIndex(primaryKey)
+	bucket, err := h.loadBucket(h.buckets[idx])
+	if err.IsNotEmpty() {
+		return err
+	}
+	existing, ok := bucket.Remove(primaryKey)
+	if !ok {
+		return lib.EmptyError()
+	}
+	if err := h.freeOverflow(existing); err.IsNotEmpty() {
+		return err
+	}
+	if len(bucket.Entries()) > 0 || bucket.LocalDepth() == 0 {
+		return h.saveBucket(bucket)
+	}
+	return h.merge(bucket, idx)
+}
+
+// merge folds the empty bucket at directory slot idx into its buddy, the
+// bucket it was split from, as long as the buddy has not been split further
+// since. An emptied buddy is merged in turn, and the directory is halved
+// while no bucket tells its two halves apart.
+func (h *ExtendibleHash) merge(bucket *node.Bucket, idx int) lib.Error {
+	merged := false
+	for len(bucket.Entries()) == 0 && bucket.LocalDepth() > 0 {
+		localDepth := bucket.LocalDepth()
+		buddyIdx := idx ^ 1<<(localDepth-1)
+		buddy, err := h.loadBucket(h.buckets[buddyIdx])
+		if err.IsNotEmpty() {
+			return err
+		}
+		if buddy.LocalDepth() != localDepth {
+			break
+		}
+
+		first := idx & (1<<localDepth - 1)
+		for j := first; j < len(h.buckets); j += 1 << localDepth {
+			h.buckets[j] = buddy.PageID()
+		}
+		buddy.SetLocalDepth(localDepth - 1)
+		if err := h.saveBucket(buddy); err.IsNotEmpty() {
+			return err
+		}
+		if err := h.saveSegments(first, len(h.buckets)); err.IsNotEmpty() {
+			return err
+		}
+		if err := h.pager.FreePage(bucket.PageID()); err.IsNotEmpty() {
+			return err
+		}
+		bucket, idx, merged = buddy, buddyIdx, true
+	}
+	if !merged {
+		if err := h.saveBucket(bucket); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return h.shrink()
+}
+
+// shrink halves the directory while its two halves are identical.
+func (h *ExtendibleHash) shrink() lib.Error {
+	oldLen := len(h.buckets)
+	for h.globalDepth > 0 && h.halvesMatch() {
+		h.buckets = h.buckets[:len(h.buckets)/2]
+		h.globalDepth--
+	}
+	if len(h.buckets) == oldLen {
+		return lib.EmptyError()
+	}
+	return h.saveShrunk()
+}
+
+func (h *ExtendibleHash) halvesMatch() bool {
+	half := len(h.buckets) / 2
+	for i := 0; i < half; i++ {
+		if h.buckets[i] != h.buckets[i+half] {
+			return false
+		}
+	}
+	return true
+}
+
+// saveShrunk frees the segments a shrunk directory no longer uses and writes
+// the last one left, which may now be only partly used.
+func (h *ExtendibleHash) saveShrunk() lib.Error {
+	perSegment := h.pointersPerSegment()
+	needed := (len(h.buckets) + perSegment - 1) / perSegment
+	for _, pageID := range h.segments[needed:] {
+		if err := h.pager.FreePage(pageID); err.IsNotEmpty() {
+			return err
+		}
+	}
+	h.segments = h.segments[:needed]
+	if err := h.saveSegments(len(h.buckets)-1, len(h.buckets)); err.IsNotEmpty() {
+		return err
+	}
+	return h.saveDirectory()
+}
@@ -0,0 +1,269 @@
+package implementation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sync"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/constants"
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/node"
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/serializer"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+var _ datastructures.BaseDatabaseStructure = (*ExtendibleHash)(nil)
+
+// ExtendibleHash is a disk-based hash index for exact-key lookups. A key is
+// stored in the bucket the directory points to at the lowest globalDepth
+// bits of its hash. A full bucket splits on the next bit, and when it
+// already uses every bit the directory has, the directory doubles first.
+// A bucket emptied by a delete merges back into its buddy, and the
+// directory halves again once no bucket needs its last bit.
+//
+// The header's root page is the directory page. The directory is also
+// kept in memory, so a lookup reads a single bucket page.
+//
+// Every operation commits with one Sync, which a logged pager turns into an
+// atomic commit; on failure it rolls back and the directory is reloaded.
+// Lookups share mu and writers hold it exclusively.
+type ExtendibleHash struct {
+	pager      pagination.BasePagination
+	serializer *serializer.PageSerializer
+
+	mu            sync.RWMutex
+	directoryPage pagination.PageID
+	globalDepth   uint8
+	segments      []pagination.PageID
+	// buckets holds the directory's 2^globalDepth bucket pointers.
+	buckets []pagination.PageID
+}
+
+func NewExtendibleHash(pager pagination.BasePagination, binarySerializer serialization.BaseSerializer) *ExtendibleHash {
+	return &ExtendibleHash{
+		pager:      pager,
+		serializer: serializer.NewPageSerializer(binarySerializer),
+	}
+}
+
+// Init opens the index stored in the pager. A fresh file gets a superblock,
+// a directory of depth 0 and one empty bucket.
+func (h *ExtendibleHash) Init() lib.Error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if logged, ok := h.pager.(pagination.LoggedPagination); ok {
+		if err := logged.Recover(); err.IsNotEmpty() {
+			return err
+		}
+	}
+	if h.pager.NumPages() == 0 {
+		if err := pagination.WriteHeader(h.pager, pagination.NewHeader(h.pager.PageSize())); err.IsNotEmpty() {
+			return err
+		}
+	}
+	header, err := pagination.ReadHeader(h.pager)
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	if header.RootPage == 0 {
+		err = h.create()
+	} else {
+		err = h.loadDirectory()
+	}
+	return h.commit(err)
+}
+
+// create lays out an empty index and records it in the header.
+func (h *ExtendibleHash) create() lib.Error {
+	var pages [3]pagination.PageID
+	for i := range pages {
+		pageID, err := h.pager.AllocatePage()
+		if err.IsNotEmpty() {
+			return err
+		}
+		pages[i] = pageID
+	}
+	directoryPage, segment, bucket := pages[0], pages[1], pages[2]
+
+	if err := h.saveBucket(node.NewBucket(bucket, 0)); err.IsNotEmpty() {
+		return err
+	}
+	h.directoryPage, h.globalDepth = directoryPage, 0
+	h.segments, h.buckets = []pagination.PageID{segment}, []pagination.PageID{bucket}
+	if err := h.saveSegments(0, 1); err.IsNotEmpty() {
+		return err
+	}
+	if err := h.saveDirectory(); err.IsNotEmpty() {
+		return err
+	}
+	// The allocations above changed the header, so it is read again.
+	header, err := pagination.ReadHeader(h.pager)
+	if err.IsNotEmpty() {
+		return err
+	}
+	header.RootPage = directoryPage
+	return pagination.WriteHeader(h.pager, header)
+}
+
+// apply runs op. A panic in op becomes its error, so that commit rolls the
+// operation back like any other failed one.
+func apply(op func() lib.Error) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+	return op()
+}
+
+// commit ends a mutating operation. On success every page it wrote is made
+// durable with a single Sync. On failure a logged pager drops the partial
+// writes, and the directory is reloaded to match the pages.
+func (h *ExtendibleHash) commit(opErr lib.Error) lib.Error {
+	if opErr.IsEmpty() {
+		return h.pager.Sync()
+	}
+	if logged, ok := h.pager.(pagination.LoggedPagination); ok {
+		if err := logged.Rollback(); err.IsNotEmpty() {
+			return err
+		}
+	}
+	if err := h.loadDirectory(); err.IsNotEmpty() {
+		return err
+	}
+	return opErr
+}
+
+func (h *ExtendibleHash) initialized() lib.Error {
+	if h.directoryPage == 0 {
+		return lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("extendible hash is not initialized"))
+	}
+	return lib.EmptyError()
+}
+
+// loadDirectory reads the directory and its segments into memory. Without
+// a directory in the header, the index is left uninitialized.
+func (h *ExtendibleHash) loadDirectory() lib.Error {
+	header, err := pagination.ReadHeader(h.pager)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if header.RootPage == 0 {
+		h.directoryPage, h.globalDepth, h.segments, h.buckets = 0, 0, nil, nil
+		return lib.EmptyError()
+	}
+	buf, err := h.pager.ReadPage(header.RootPage)
+	if err.IsNotEmpty() {
+		return err
+	}
+	directory, err := h.serializer.DeserializeDirectory(buf)
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	var buckets []pagination.PageID
+	for _, segmentPage := range directory.Segments() {
+		buf, err := h.pager.ReadPage(segmentPage)
+		if err.IsNotEmpty() {
+			return err
+		}
+		segment, err := h.serializer.DeserializeSegment(buf)
+		if err.IsNotEmpty() {
+			return err
+		}
+		buckets = append(buckets, segment.Buckets()...)
+	}
+	if len(buckets) != 1<<directory.GlobalDepth() {
+		return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("loadDirectory: global depth %d needs %d buckets, segments hold %d", directory.GlobalDepth(), 1<<directory.GlobalDepth(), len(buckets)))
+	}
+
+	h.directoryPage, h.globalDepth = header.RootPage, directory.GlobalDepth()
+	h.segments, h.buckets = directory.Segments(), buckets
+	return lib.EmptyError()
+}
+
+func (h *ExtendibleHash) saveDirectory() lib.Error {
+	buf, err := h.serializer.SerializeDirectory(node.NewDirectory(h.globalDepth, h.segments))
+	if err.IsNotEmpty() {
+		return err
+	}
+	return h.writePage(h.directoryPage, buf)
+}
+
+// saveSegments writes the segments holding the bucket pointers in
+// [from, to).
+func (h *ExtendibleHash) saveSegments(from, to int) lib.Error {
+	perSegment := h.pointersPerSegment()
+	for s := from / perSegment; s*perSegment < to; s++ {
+		end := min((s+1)*perSegment, len(h.buckets))
+		buf, err := h.serializer.SerializeSegment(node.NewSegment(h.buckets[s*perSegment : end]))
+		if err.IsNotEmpty() {
+			return err
+		}
+		if err := h.writePage(h.segments[s], buf); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+func (h *ExtendibleHash) loadBucket(pageID pagination.PageID) (*node.Bucket, lib.Error) {
+	buf, err := h.pager.ReadPage(pageID)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	bucket, err := h.serializer.DeserializeBucket(buf)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if bucket.PageID() != pageID {
+		return nil, lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("loadBucket: page %d holds bucket %d", pageID, bucket.PageID()))
+	}
+	return bucket, lib.EmptyError()
+}
+
+func (h *ExtendibleHash) saveBucket(bucket *node.Bucket) lib.Error {
+	buf, err := h.serializer.SerializeBucket(bucket)
+	if err.IsNotEmpty() {
+		return err
+	}
+	return h.writePage(bucket.PageID(), buf)
+}
+
+func (h *ExtendibleHash) writePage(pageID pagination.PageID, buf []byte) lib.Error {
+	if len(buf) > pagination.UsablePageSize(h.pager) {
+		return lib.EmptyError().AddErr(lib.SerializationError, fmt.Errorf("page %d needs %d bytes, only %d fit next to the page trailer", pageID, len(buf), pagination.UsablePageSize(h.pager)))
+	}
+	return h.pager.WritePage(pageID, buf)
+}
+
+// bucketIndex is the directory slot of key.
+func (h *ExtendibleHash) bucketIndex(key string) int {
+	return int(hashKey(key) & (uint64(len(h.buckets)) - 1))
+}
+
+// pointersPerSegment is the largest power of two of bucket pointers that
+// fits in a segment page, so that doubling the directory fills whole
+// segments.
+func (h *ExtendibleHash) pointersPerSegment() int {
+	fit := (pagination.UsablePageSize(h.pager) - constants.SegmentHeaderSize) / constants.PageIDSize
+	return 1 << (bits.Len(uint(fit)) - 1)
+}
+
+// maxGlobalDepth is the deepest directory whose segments the directory page
+// can list.
+func (h *ExtendibleHash) maxGlobalDepth() uint8 {
+	segments := (pagination.UsablePageSize(h.pager) - constants.DirectoryHeaderSize) / constants.PageIDSize
+	return uint8(bits.Len(uint(segments*h.pointersPerSegment())) - 1)
+}
+
+func hashKey(key string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(key))
+	return hasher.Sum64()
+}
@@ -0,0 +1,216 @@
+package implementation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/bufferpool"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// newSmallHash opens an index over 512 byte pages, so that a few thousand
+// keys split many buckets and spread the directory over several segments.
+func newSmallHash(t *testing.T) (*ExtendibleHash, pagination.BasePagination) {
+	t.Helper()
+	pager, err := pagination.NewPager(filepath.Join(t.TempDir(), "hash.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	t.Cleanup(func() { pager.Close() })
+	if err := pager.SetPageSize(pagination.MinPageSize); err.IsNotEmpty() {
+		t.Fatalf("set page size: %v", err.Errors())
+	}
+	h := NewExtendibleHash(pager, serialization.NewBinarySerializer())
+	if err := h.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return h, pager
+}
+
+// checkDirectory verifies that a bucket of local depth d is pointed at by
+// exactly the 2^(globalDepth-d) slots sharing its low d bits, and that it
+// only holds keys hashing to those slots.
+func checkDirectory(t *testing.T, h *ExtendibleHash) {
+	t.Helper()
+	if len(h.buckets) != 1<<h.globalDepth {
+		t.Fatalf("global depth %d with %d bucket pointers", h.globalDepth, len(h.buckets))
+	}
+	if len(h.segments)*h.pointersPerSegment() < len(h.buckets) || (len(h.segments)-1)*h.pointersPerSegment() >= len(h.buckets) {
+		t.Fatalf("%d segments for %d bucket pointers", len(h.segments), len(h.buckets))
+	}
+	slots := map[pagination.PageID]int{}
+	for _, pageID := range h.buckets {
+		slots[pageID]++
+	}
+	for idx, pageID := range h.buckets {
+		bucket, err := h.loadBucket(pageID)
+		if err.IsNotEmpty() {
+			t.Fatalf("load bucket: %v", err.Errors())
+		}
+		depth := bucket.LocalDepth()
+		if depth > h.globalDepth || slots[pageID] != 1<<(h.globalDepth-depth) {
+			t.Fatalf("bucket %d of depth %d has %d slots at global depth %d", pageID, depth, slots[pageID], h.globalDepth)
+		}
+		mask := uint64(1)<<depth - 1
+		for _, entry := range bucket.Entries() {
+			if hashKey(entry.Key())&mask != uint64(idx)&mask {
+				t.Fatalf("key %q is in bucket %d, which slot %d points at", entry.Key(), pageID, idx)
+			}
+		}
+	}
+}
+
+func TestDirectoryDoublesAsBucketsSplit(t *testing.T) {
+	h, pager := newSmallHash(t)
+	const keys = 4000
+	depths := map[uint8]bool{}
+	for i := 0; i < keys; i++ {
+		if err := h.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			t.Fatalf("insert: %v", err.Errors())
+		}
+		depths[h.globalDepth] = true
+	}
+	checkDirectory(t, h)
+	// Every depth on the way was reached by a doubling, one bit at a time.
+	for depth := uint8(0); depth <= h.globalDepth; depth++ {
+		if !depths[depth] {
+			t.Fatalf("global depth skipped %d on the way to %d", depth, h.globalDepth)
+		}
+	}
+	if len(h.segments) < 2 {
+		t.Fatalf("%d bucket pointers fit in one segment; use more keys", len(h.buckets))
+	}
+
+	// The directory and segments on disk match the ones in memory.
+	reopened := NewExtendibleHash(pager, serialization.NewBinarySerializer())
+	if err := reopened.Init(); err.IsNotEmpty() {
+		t.Fatalf("reopen: %v", err.Errors())
+	}
+	if reopened.globalDepth != h.globalDepth || fmt.Sprint(reopened.buckets) != fmt.Sprint(h.buckets) {
+		t.Fatalf("reopened directory differs: depth %d, want %d", reopened.globalDepth, h.globalDepth)
+	}
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%05d", i)
+		value, found, err := reopened.Search(key)
+		if err.IsNotEmpty() || !found || value.IntValue() != int64(i) {
+			t.Fatalf("search %s: %v, %v, %v", key, value, found, err.Errors())
+		}
+	}
+}
+
+func TestDirectoryHalvesAsBucketsMerge(t *testing.T) {
+	h, _ := newSmallHash(t)
+	const keys = 2000
+	for i := 0; i < keys; i++ {
+		if err := h.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			t.Fatalf("insert: %v", err.Errors())
+		}
+	}
+	grown := h.globalDepth
+
+	for i := 0; i < keys; i += 2 {
+		if err := h.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete: %v", err.Errors())
+		}
+	}
+	checkDirectory(t, h)
+	for i := 1; i < keys; i += 2 {
+		if err := h.Delete(fmt.Sprintf("key-%05d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete: %v", err.Errors())
+		}
+	}
+	checkDirectory(t, h)
+	if h.globalDepth != 0 || grown == 0 {
+		t.Fatalf("global depth %d after deleting every key, %d before", h.globalDepth, grown)
+	}
+}
+
+// panickyPager panics on the write that brings the count down to zero.
+type panickyPager struct {
+	pagination.LoggedPagination
+	writesLeft int
+}
+
+func (p *panickyPager) WritePage(id pagination.PageID, data []byte) lib.Error {
+	if p.writesLeft--; p.writesLeft == 0 {
+		panic(fmt.Sprintf("writing page %d", id))
+	}
+	return p.LoggedPagination.WritePage(id, data)
+}
+
+// TestPanicRollsBackTheOperation makes a bucket split panic halfway, after
+// it wrote a page. The pages it wrote are dropped and the directory
+// reloaded, so later commits do not carry the half-done split to disk.
+func TestPanicRollsBackTheOperation(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*ExtendibleHash, *wal.Pager) {
+		filePager, err := pagination.NewPager(filepath.Join(dir, "hash.db"))
+		if err.IsNotEmpty() {
+			t.Fatalf("open pager: %v", err.Errors())
+		}
+		if err := filePager.SetPageSize(pagination.MinPageSize); err.IsNotEmpty() {
+			t.Fatalf("set page size: %v", err.Errors())
+		}
+		walPager, err := wal.NewPager(bufferpool.NewBufferPool(filePager, 16, bufferpool.NewLRUReplacer(16)), filepath.Join(dir, "hash.db.wal"))
+		if err.IsNotEmpty() {
+			t.Fatalf("open wal: %v", err.Errors())
+		}
+		h := NewExtendibleHash(walPager, serialization.NewBinarySerializer())
+		if err := h.Init(); err.IsNotEmpty() {
+			t.Fatalf("init: %v", err.Errors())
+		}
+		return h, walPager
+	}
+	h, walPager := open()
+	pager := &panickyPager{LoggedPagination: walPager}
+	h.pager = pager
+
+	// Until one fails, every insert panics on its second write: those that
+	// write a single page get through, the first split does not.
+	failed := -1
+	const keys = 1000
+	for i := 0; i < keys; i++ {
+		if failed < 0 {
+			pager.writesLeft = 2
+		}
+		pages, depth, buckets := walPager.NumPages(), h.globalDepth, fmt.Sprint(h.buckets)
+		err := h.Insert(fmt.Sprintf("key-%05d", i), common.NewIntValue(int64(i)))
+		if errors.Is(err, lib.ErrPanicFound) && failed < 0 {
+			if walPager.NumPages() != pages || h.globalDepth != depth || fmt.Sprint(h.buckets) != buckets {
+				t.Fatalf("the failed split left %d pages and global depth %d, want %d and %d", walPager.NumPages(), h.globalDepth, pages, depth)
+			}
+			failed = i
+			continue
+		}
+		if err.IsNotEmpty() {
+			t.Fatalf("insert %d: %v", i, err.Errors())
+		}
+	}
+	if failed < 0 {
+		t.Fatal("no insert split a bucket")
+	}
+	if err := h.Delete(fmt.Sprintf("key-%05d", failed+1)); err.IsNotEmpty() {
+		t.Fatalf("delete: %v", err.Errors())
+	}
+	checkDirectory(t, h)
+	if err := walPager.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+
+	h, walPager = open()
+	defer walPager.Close()
+	checkDirectory(t, h)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%05d", i)
+		_, found, err := h.Search(key)
+		if want := i != failed && i != failed+1; err.IsNotEmpty() || found != want {
+			t.Fatalf("search %s: found %t, want %t, %v", key, found, want, err.Errors())
+		}
+	}
+}
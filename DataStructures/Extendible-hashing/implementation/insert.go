@@ -0,0 +1,136 @@
+package implementation
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func (h *ExtendibleHash) Insert(primaryKey string, value common.Value) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.initialized(); err.IsNotEmpty() {
+		return err
+	}
+	// Refusing the entry up front leaves nothing to roll back.
+	if err := h.checkEntry(primaryKey, value); err.IsNotEmpty() {
+		return err
+	}
+	return h.commit(apply(func() lib.Error { return h.insert(primaryKey, value) }))
+}
+
+// insert stores the entry in its bucket, or overwrites the key's entry
+// there, splitting the bucket until the entry fits.
+func (h *ExtendibleHash) insert(primaryKey string, value common.Value) lib.Error {
+	entry, err := h.newEntry(primaryKey, value)
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	for {
+		idx := h.bucketIndex(primaryKey)
+		bucket, err := h.loadBucket(h.buckets[idx])
+		if err.IsNotEmpty() {
+			return err
+		}
+
+		size := bucket.ByteSize() + entry.ByteSize()
+		existing, found := bucket.Find(primaryKey)
+		if found {
+			size -= existing.ByteSize()
+		}
+		if size <= pagination.UsablePageSize(h.pager) {
+			if found {
+				if err := h.freeOverflow(existing); err.IsNotEmpty() {
+					return err
+				}
+			}
+			bucket.Put(entry)
+			return h.saveBucket(bucket)
+		}
+
+		if err := h.split(bucket, idx); err.IsNotEmpty() {
+			return err
+		}
+	}
+}
+
+// split spreads the entries of the bucket at directory slot idx over itself
+// and a new bucket by the next bit of their hash, doubling the directory
+// first if the bucket already uses all of its bits.
+func (h *ExtendibleHash) split(bucket *node.Bucket, idx int) lib.Error {
+	localDepth := bucket.LocalDepth()
+	if localDepth == h.globalDepth {
+		if err := h.double(); err.IsNotEmpty() {
+			return err
+		}
+	}
+
+	newPageID, err := h.pager.AllocatePage()
+	if err.IsNotEmpty() {
+		return err
+	}
+	sibling := node.NewBucket(newPageID, localDepth+1)
+	bucket.SetLocalDepth(localDepth + 1)
+
+	var kept, moved []node.Entry
+	for _, entry := range bucket.Entries() {
+		if hashKey(entry.Key())>>localDepth&1 == 1 {
+			moved = append(moved, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	bucket.SetEntries(kept)
+	sibling.SetEntries(moved)
+	if err := h.saveBucket(bucket); err.IsNotEmpty() {
+		return err
+	}
+	if err := h.saveBucket(sibling); err.IsNotEmpty() {
+		return err
+	}
+
+	// The slots that pointed at the bucket and have the new bit set now
+	// point at the sibling.
+	first := idx&(1<<localDepth-1) | 1<<localDepth
+	last := first
+	for j := first; j < len(h.buckets); j += 1 << (localDepth + 1) {
+		h.buckets[j] = newPageID
+		last = j
+	}
+	return h.saveSegments(first, last+1)
+}
+
+// double grows the directory to the next global depth. Slot i+2^depth
+// starts out pointing at the same bucket as slot i.
+func (h *ExtendibleHash) double() lib.Error {
+	if h.globalDepth >= h.maxGlobalDepth() {
+		return lib.EmptyError().AddErr(lib.SystemError, fmt.Errorf("double: directory is at its maximum depth %d", h.maxGlobalDepth()))
+	}
+
+	oldLen := len(h.buckets)
+	h.buckets = append(h.buckets, h.buckets...)
+	h.globalDepth++
+	perSegment := h.pointersPerSegment()
+	for len(h.segments)*perSegment < len(h.buckets) {
+		pageID, err := h.pager.AllocatePage()
+		if err.IsNotEmpty() {
+			return err
+		}
+		h.segments = append(h.segments, pageID)
+	}
+	if err := h.saveSegments(oldLen, len(h.buckets)); err.IsNotEmpty() {
+		return err
+	}
+	return h.saveDirectory()
+}
@@ -0,0 +1,67 @@
+package implementation
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/constants"
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// newEntry builds the bucket entry for a key and value. A string or bytes
+// payload too large to keep inline is written to an overflow chain first.
+func (h *ExtendibleHash) newEntry(key string, value common.Value) (node.Entry, lib.Error) {
+	if err := h.checkEntry(key, value); err.IsNotEmpty() {
+		return node.Entry{}, err
+	}
+	if !h.needsOverflow(value) {
+		return node.NewEntry(key, value), lib.EmptyError()
+	}
+
+	page, err := serialization.WriteOverflowValue(h.pager, value)
+	if err.IsNotEmpty() {
+		return node.Entry{}, err
+	}
+	return node.NewOverflowEntry(key, serialization.PayloadValue(value.Type(), nil), page, uint32(value.Len())), lib.EmptyError()
+}
+
+// checkEntry rejects a key and value that cannot be stored, before anything
+// is written for them.
+func (h *ExtendibleHash) checkEntry(key string, value common.Value) lib.Error {
+	entry := node.NewEntry(key, value)
+	if h.needsOverflow(value) {
+		// Size the entry with a placeholder reference to its overflow chain.
+		entry = node.NewOverflowEntry(key, serialization.PayloadValue(value.Type(), nil), 1, uint32(value.Len()))
+	}
+	if limit := h.maxEntrySize(); entry.ByteSize() > limit {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("insert: entry for key %q takes %d bytes, at most %d fit in a bucket", key, entry.ByteSize(), limit))
+	}
+	return lib.EmptyError()
+}
+
+func (h *ExtendibleHash) maxEntrySize() int {
+	return (pagination.UsablePageSize(h.pager) - constants.BucketHeaderSize) / constants.MaxEntryFraction
+}
+
+func (h *ExtendibleHash) needsOverflow(value common.Value) bool {
+	return value.Len() > pagination.UsablePageSize(h.pager)/constants.OverflowFraction
+}
+
+// resolveValue returns the entry's value with its payload read back from the
+// overflow chain when it has one.
+func (h *ExtendibleHash) resolveValue(entry node.Entry) (common.Value, lib.Error) {
+	if !entry.HasOverflow() {
+		return entry.Value(), lib.EmptyError()
+	}
+	return serialization.ReadOverflowValue(h.pager, entry.Value().Type(), entry.OverflowPage(), entry.OverflowLength())
+}
+
+func (h *ExtendibleHash) freeOverflow(entry node.Entry) lib.Error {
+	if !entry.HasOverflow() {
+		return lib.EmptyError()
+	}
+	return pagination.FreeOverflow(h.pager, entry.OverflowPage())
+}
@@ -0,0 +1,36 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Search reads the one bucket key hashes to and reports whether the key is
+// stored there.
+func (h *ExtendibleHash) Search(primaryKey string) (_ common.Value, _ bool, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if err := h.initialized(); err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	bucket, err := h.loadBucket(h.buckets[h.bucketIndex(primaryKey)])
+	if err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	entry, ok := bucket.Find(primaryKey)
+	if !ok {
+		return common.Value{}, false, lib.EmptyError()
+	}
+	value, err := h.resolveValue(entry)
+	if err.IsNotEmpty() {
+		return common.Value{}, false, err
+	}
+	return value, true, lib.EmptyError()
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/constants"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+)
+
+// Bucket is a page of entries whose key hashes agree on their lowest
+// localDepth bits. Entries are kept in no particular order.
+type Bucket struct {
+	pageID     pagination.PageID
+	localDepth uint8
+	entries    []Entry
+}
+
+func NewBucket(pageID pagination.PageID, localDepth uint8) *Bucket {
+	return &Bucket{pageID: pageID, localDepth: localDepth}
+}
+
+func (b *Bucket) PageID() pagination.PageID {
+	return b.pageID
+}
+
+func (b *Bucket) SetPageID(pageID pagination.PageID) {
+	b.pageID = pageID
+}
+
+func (b *Bucket) LocalDepth() uint8 {
+	return b.localDepth
+}
+
+func (b *Bucket) SetLocalDepth(localDepth uint8) {
+	b.localDepth = localDepth
+}
+
+func (b *Bucket) Entries() []Entry {
+	return b.entries
+}
+
+func (b *Bucket) SetEntries(entries []Entry) {
+	b.entries = entries
+}
+
+func (b *Bucket) Find(key string) (Entry, bool) {
+	if idx := b.indexOf(key); idx >= 0 {
+		return b.entries[idx], true
+	}
+	return Entry{}, false
+}
+
+// Put stores entry, replacing the entry for the same key. The replaced
+// entry is returned so its overflow chain can be freed.
+func (b *Bucket) Put(entry Entry) (Entry, bool) {
+	if idx := b.indexOf(entry.Key()); idx >= 0 {
+		old := b.entries[idx]
+		b.entries[idx] = entry
+		return old, true
+	}
+	b.entries = append(b.entries, entry)
+	return Entry{}, false
+}
+
+func (b *Bucket) Remove(key string) (Entry, bool) {
+	idx := b.indexOf(key)
+	if idx < 0 {
+		return Entry{}, false
+	}
+	old := b.entries[idx]
+	last := len(b.entries) - 1
+	b.entries[idx] = b.entries[last]
+	b.entries = b.entries[:last]
+	return old, true
+}
+
+// ByteSize is the serialized size of the bucket.
+func (b *Bucket) ByteSize() int {
+	size := constants.BucketHeaderSize
+	for _, entry := range b.entries {
+		size += entry.ByteSize()
+	}
+	return size
+}
+
+func (b *Bucket) indexOf(key string) int {
+	for i, entry := range b.entries {
+		if entry.Key() == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,37 @@
+package node
+
+import "github.com/Kush/Database-internals/diskStorage/pagination"
+
+// Directory is the root page of the index. The 2^globalDepth bucket
+// pointers do not fit in one page past a small depth, so they are spread
+// over segment pages of a fixed number of pointers each, which the directory
+// lists in order.
+type Directory struct {
+	globalDepth uint8
+	segments    []pagination.PageID
+}
+
+func NewDirectory(globalDepth uint8, segments []pagination.PageID) *Directory {
+	return &Directory{globalDepth: globalDepth, segments: segments}
+}
+
+func (d *Directory) GlobalDepth() uint8 {
+	return d.globalDepth
+}
+
+func (d *Directory) Segments() []pagination.PageID {
+	return d.segments
+}
+
+// Segment is a page holding a slice of the directory's bucket pointers.
+type Segment struct {
+	buckets []pagination.PageID
+}
+
+func NewSegment(buckets []pagination.PageID) *Segment {
+	return &Segment{buckets: buckets}
+}
+
+func (s *Segment) Buckets() []pagination.PageID {
+	return s.buckets
+}
@@ -0,0 +1,62 @@
+package node
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+type Entry struct {
+	key   string
+	value common.Value
+	// overflowPage is the first page of the chain holding a string or bytes
+	// payload too large to be stored inline; the value itself then only
+	// keeps its type.
+	overflowPage   pagination.PageID
+	overflowLength uint32
+}
+
+func NewEntry(key string, value common.Value) Entry {
+	return Entry{key: key, value: value}
+}
+
+// NewOverflowEntry builds an entry whose string or bytes payload of length
+// bytes lives in the overflow chain starting at page.
+func NewOverflowEntry(key string, value common.Value, page pagination.PageID, length uint32) Entry {
+	return Entry{
+		key:            key,
+		value:          value,
+		overflowPage:   page,
+		overflowLength: length,
+	}
+}
+
+func (e Entry) Key() string {
+	return e.key
+}
+
+func (e Entry) Value() common.Value {
+	return e.value
+}
+
+func (e Entry) HasOverflow() bool {
+	return e.overflowPage != 0
+}
+
+func (e Entry) OverflowPage() pagination.PageID {
+	return e.overflowPage
+}
+
+func (e Entry) OverflowLength() uint32 {
+	return e.overflowLength
+}
+
+// ByteSize is the serialized size of the entry: the length-prefixed key and
+// the value as serialization.AppendValue encodes it, or the reference to its
+// overflow chain.
+func (e Entry) ByteSize() int {
+	if e.HasOverflow() {
+		return 2 + len(e.key) + serialization.OverflowValueSize
+	}
+	return 2 + len(e.key) + serialization.ValueSize(e.value)
+}
@@ -0,0 +1,177 @@
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/constants"
+	"github.com/Kush/Database-internals/DataStructures/Extendible-hashing/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// PageSerializer encodes the directory, segment and bucket pages of an
+// extendible hash index.
+type PageSerializer struct {
+	serializer serialization.BaseSerializer
+}
+
+func NewPageSerializer(serializer serialization.BaseSerializer) *PageSerializer {
+	return &PageSerializer{serializer: serializer}
+}
+
+func (ps *PageSerializer) SerializeDirectory(directory *node.Directory) ([]byte, lib.Error) {
+	buf := []byte{constants.DirectoryPageKind, directory.GlobalDepth()}
+	b, err := ps.serializer.Serialize(uint16(len(directory.Segments())))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf = append(buf, b...)
+	return ps.appendPageIDs(buf, directory.Segments())
+}
+
+func (ps *PageSerializer) DeserializeDirectory(data []byte) (*node.Directory, lib.Error) {
+	if err := checkKind(data, constants.DirectoryPageKind, constants.DirectoryHeaderSize); err.IsNotEmpty() {
+		return nil, err
+	}
+	globalDepth := data[1]
+	var count uint16
+	if err := ps.serializer.Deserialize(data[2:4], &count); err.IsNotEmpty() {
+		return nil, err
+	}
+	segments, err := ps.readPageIDs(data[constants.DirectoryHeaderSize:], int(count))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return node.NewDirectory(globalDepth, segments), lib.EmptyError()
+}
+
+func (ps *PageSerializer) SerializeSegment(segment *node.Segment) ([]byte, lib.Error) {
+	buf := []byte{constants.SegmentPageKind}
+	b, err := ps.serializer.Serialize(uint32(len(segment.Buckets())))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf = append(buf, b...)
+	return ps.appendPageIDs(buf, segment.Buckets())
+}
+
+func (ps *PageSerializer) DeserializeSegment(data []byte) (*node.Segment, lib.Error) {
+	if err := checkKind(data, constants.SegmentPageKind, constants.SegmentHeaderSize); err.IsNotEmpty() {
+		return nil, err
+	}
+	var count uint32
+	if err := ps.serializer.Deserialize(data[1:5], &count); err.IsNotEmpty() {
+		return nil, err
+	}
+	buckets, err := ps.readPageIDs(data[constants.SegmentHeaderSize:], int(count))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return node.NewSegment(buckets), lib.EmptyError()
+}
+
+func (ps *PageSerializer) SerializeBucket(bucket *node.Bucket) ([]byte, lib.Error) {
+	buf := make([]byte, 0, bucket.ByteSize())
+	buf = append(buf, constants.BucketPageKind)
+	b, err := ps.serializer.Serialize(uint32(bucket.PageID()))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf = append(buf, b...)
+	buf = append(buf, bucket.LocalDepth())
+	b, err = ps.serializer.Serialize(uint16(len(bucket.Entries())))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf = append(buf, b...)
+
+	for _, entry := range bucket.Entries() {
+		b, err := ps.serializer.Serialize(entry.Key())
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		buf = append(buf, b...)
+
+		if entry.HasOverflow() {
+			buf = serialization.AppendOverflowValue(buf, entry.Value().Type(), entry.OverflowPage(), entry.OverflowLength())
+		} else {
+			buf = serialization.AppendValue(buf, entry.Value())
+		}
+	}
+	return buf, lib.EmptyError()
+}
+
+func (ps *PageSerializer) DeserializeBucket(data []byte) (*node.Bucket, lib.Error) {
+	if err := checkKind(data, constants.BucketPageKind, constants.BucketHeaderSize); err.IsNotEmpty() {
+		return nil, err
+	}
+	var pageID uint32
+	if err := ps.serializer.Deserialize(data[1:5], &pageID); err.IsNotEmpty() {
+		return nil, err
+	}
+	bucket := node.NewBucket(pagination.PageID(pageID), data[5])
+	var count uint16
+	if err := ps.serializer.Deserialize(data[6:8], &count); err.IsNotEmpty() {
+		return nil, err
+	}
+
+	offset := constants.BucketHeaderSize
+	entries := make([]node.Entry, 0, count)
+	for i := 0; i < int(count); i++ {
+		var key string
+		if err := ps.serializer.Deserialize(data[offset:], &key); err.IsNotEmpty() {
+			return nil, err
+		}
+		offset += 2 + len(key)
+
+		stored, n, err := serialization.DecodeValue(data[offset:])
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		offset += n
+		if stored.HasOverflow() {
+			entries = append(entries, node.NewOverflowEntry(key, stored.Value, stored.Page, stored.Length))
+		} else {
+			entries = append(entries, node.NewEntry(key, stored.Value))
+		}
+	}
+	bucket.SetEntries(entries)
+	return bucket, lib.EmptyError()
+}
+
+func (ps *PageSerializer) appendPageIDs(buf []byte, pageIDs []pagination.PageID) ([]byte, lib.Error) {
+	for _, pageID := range pageIDs {
+		b, err := ps.serializer.Serialize(uint32(pageID))
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, lib.EmptyError()
+}
+
+func (ps *PageSerializer) readPageIDs(data []byte, count int) ([]pagination.PageID, lib.Error) {
+	if len(data) < count*constants.PageIDSize {
+		return nil, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %d page IDs need %d bytes, got %d", count, count*constants.PageIDSize, len(data)))
+	}
+	pageIDs := make([]pagination.PageID, count)
+	for i := range pageIDs {
+		var pageID uint32
+		if err := ps.serializer.Deserialize(data[i*constants.PageIDSize:(i+1)*constants.PageIDSize], &pageID); err.IsNotEmpty() {
+			return nil, err
+		}
+		pageIDs[i] = pagination.PageID(pageID)
+	}
+	return pageIDs, lib.EmptyError()
+}
+
+func checkKind(data []byte, kind uint8, headerSize int) lib.Error {
+	if len(data) < headerSize {
+		return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: page header needs %d bytes, got %d", headerSize, len(data)))
+	}
+	if data[0] != kind {
+		return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("invalid data: expected page kind %d, found %d", kind, data[0]))
+	}
+	return lib.EmptyError()
+}
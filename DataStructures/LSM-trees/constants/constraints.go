@@ -0,0 +1,25 @@
+package constants
+
+const (
+	// MemtableSize is the serialized size at which the memtable is frozen
+	// and flushed to a level 0 table.
+	MemtableSize = 4 << 20
+
+	// BlockSize is the size a data block of a table is cut at. Lookups read
+	// one block, so it trades index size against read amplification.
+	BlockSize = 4096
+
+	// BloomBitsPerKey gives a false positive rate of about 1%.
+	BloomBitsPerKey = 10
+
+	// Level 0 is compacted into level 1 once it holds L0CompactionTrigger
+	// tables. Level 1 may hold BaseLevelSize bytes and every further level
+	// LevelSizeMultiplier times more than the one above.
+	L0CompactionTrigger = 4
+	BaseLevelSize       = 10 << 20
+	LevelSizeMultiplier = 10
+	MaxLevels           = 7
+
+	// TargetTableSize is where compaction cuts its output into tables.
+	TargetTableSize = 2 << 20
+)
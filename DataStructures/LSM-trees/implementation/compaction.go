@@ -0,0 +1,151 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// compaction merges inputs from level into the overlapping tables of the
+// level below.
+type compaction struct {
+	level   int
+	inputs  []*tableHandle
+	overlap []*tableHandle
+}
+
+// maybeCompact runs compactions until every level is within its size
+// budget. Callers hold compactMu.
+func (t *LSMTree) maybeCompact() lib.Error {
+	for {
+		c := t.pickCompaction()
+		if c == nil {
+			return lib.EmptyError()
+		}
+		if err := t.compact(c); err.IsNotEmpty() {
+			return err
+		}
+	}
+}
+
+// pickCompaction returns the next compaction due, or nil. Level 0 goes
+// first once it holds L0CompactionTrigger tables, since every read checks
+// each of them; after that the level furthest over its budget.
+func (t *LSMTree) pickCompaction() *compaction {
+	v := t.current
+	if len(v.levels[0]) >= constants.L0CompactionTrigger {
+		c := &compaction{level: 0, inputs: append([]*tableHandle{}, v.levels[0]...)}
+		smallest, largest := keyRange(c.inputs)
+		c.overlap = v.overlapping(1, smallest, largest)
+		return c
+	}
+
+	best, bestScore := -1, 1.0
+	budget := float64(constants.BaseLevelSize)
+	for level := 1; level < constants.MaxLevels-1; level++ {
+		if score := float64(v.levelSize(level)) / budget; score > bestScore {
+			best, bestScore = level, score
+		}
+		budget *= constants.LevelSizeMultiplier
+	}
+	if best < 0 {
+		return nil
+	}
+
+	// Take the first table past the one compacted last, wrapping around.
+	handles := v.levels[best]
+	input := handles[0]
+	for _, handle := range handles {
+		if handle.table.Meta().Smallest > t.compactPointers[best] {
+			input = handle
+			break
+		}
+	}
+	meta := input.table.Meta()
+	return &compaction{
+		level:   best,
+		inputs:  []*tableHandle{input},
+		overlap: v.overlapping(best+1, meta.Smallest, meta.Largest),
+	}
+}
+
+// compact carries out c and installs the result. An input overlapping
+// nothing below is moved down as is, without rewriting it.
+func (t *LSMTree) compact(c *compaction) lib.Error {
+	next := t.current.clone()
+	next.levels[c.level] = without(next.levels[c.level], c.inputs)
+	next.levels[c.level+1] = without(next.levels[c.level+1], c.overlap)
+	_, largest := keyRange(c.inputs)
+
+	if len(c.overlap) == 0 && (c.level > 0 || len(c.inputs) == 1) {
+		next.levels[c.level+1] = append(next.levels[c.level+1], c.inputs...)
+		next.sortLevels()
+		if err := t.install(next, nil); err.IsNotEmpty() {
+			return err
+		}
+		t.compactPointers[c.level] = largest
+		return lib.EmptyError()
+	}
+
+	// Sources go from newest to oldest: level 0 tables by descending file
+	// number, then the level itself, then the level below.
+	var sources []entryIterator
+	if c.level == 0 {
+		for i := len(c.inputs) - 1; i >= 0; i-- {
+			sources = append(sources, c.inputs[i].table.Seek(""))
+		}
+	} else {
+		sources = append(sources, newLevelIterator(c.inputs, ""))
+	}
+	sources = append(sources, newLevelIterator(c.overlap, ""))
+
+	// A tombstone only has to be kept while an older entry for its key may
+	// still sit in a deeper level.
+	drop := func(entry node.Entry) bool {
+		if !entry.IsTombstone() {
+			return false
+		}
+		for level := c.level + 2; level < constants.MaxLevels; level++ {
+			if next.tableFor(level, entry.Key()) != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	handles, err := t.writeTables(newMergeIterator(sources), drop, true)
+	if err.IsNotEmpty() {
+		return err
+	}
+	next.levels[c.level+1] = append(next.levels[c.level+1], handles...)
+	next.sortLevels()
+	if err := t.install(next, nil); err.IsNotEmpty() {
+		return err
+	}
+	t.compactPointers[c.level] = largest
+	return lib.EmptyError()
+}
+
+// keyRange returns the smallest and largest key of a set of tables.
+func keyRange(handles []*tableHandle) (string, string) {
+	smallest, largest := handles[0].table.Meta().Smallest, handles[0].table.Meta().Largest
+	for _, handle := range handles[1:] {
+		meta := handle.table.Meta()
+		smallest, largest = min(smallest, meta.Smallest), max(largest, meta.Largest)
+	}
+	return smallest, largest
+}
+
+func without(handles, removed []*tableHandle) []*tableHandle {
+	drop := make(map[*tableHandle]bool, len(removed))
+	for _, handle := range removed {
+		drop[handle] = true
+	}
+	kept := handles[:0]
+	for _, handle := range handles {
+		if !drop[handle] {
+			kept = append(kept, handle)
+		}
+	}
+	return kept
+}
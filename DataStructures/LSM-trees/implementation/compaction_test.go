@@ -0,0 +1,162 @@
+package implementation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// newCompactingTree opens a tree whose memtable flushes every few KB, so a
+// few thousand writes go through many flushes and compactions.
+func newCompactingTree(t *testing.T, dir string) *LSMTree {
+	t.Helper()
+	tree := NewLSMTree(dir, serialization.NewBinarySerializer())
+	tree.SetMemtableSize(4 << 10)
+	if err := tree.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	return tree
+}
+
+// tableEntries returns every entry of every table of the current version,
+// by level.
+func tableEntries(t *testing.T, tree *LSMTree) [constants.MaxLevels][]node.Entry {
+	t.Helper()
+	view := tree.acquire()
+	defer tree.release(view.version)
+
+	var entries [constants.MaxLevels][]node.Entry
+	for level, handles := range view.version.levels {
+		for _, handle := range handles {
+			it := handle.table.Seek("")
+			for it.Next() {
+				entries[level] = append(entries[level], it.Entry())
+			}
+			if err := it.Err(); err.IsNotEmpty() {
+				t.Fatalf("read table %d: %v", handle.number, err.Errors())
+			}
+		}
+	}
+	return entries
+}
+
+func levelTables(tree *LSMTree, level int) int {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+	return len(tree.current.levels[level])
+}
+
+func TestCompactionMergesLevelZeroIntoLevelOne(t *testing.T) {
+	tree := newCompactingTree(t, t.TempDir())
+	defer tree.Close()
+
+	const keys = 1000
+	for round := 0; round < 3; round++ {
+		for i := 0; i < keys; i++ {
+			if err := tree.Insert(fmt.Sprintf("key-%04d", i), common.NewIntValue(int64(round*keys+i))); err.IsNotEmpty() {
+				t.Fatalf("insert: %v", err.Errors())
+			}
+		}
+	}
+	if n := levelTables(tree, 0); n >= constants.L0CompactionTrigger {
+		t.Fatalf("level 0 holds %d tables, compaction triggers at %d", n, constants.L0CompactionTrigger)
+	}
+	if levelTables(tree, 1) == 0 {
+		t.Fatal("nothing was compacted into level 1")
+	}
+
+	// Level 1 is sorted and holds every key at most once, however often it
+	// was overwritten.
+	level1 := tableEntries(t, tree)[1]
+	for i := 1; i < len(level1); i++ {
+		if level1[i-1].Key() >= level1[i].Key() {
+			t.Fatalf("level 1 has %q before %q", level1[i-1].Key(), level1[i].Key())
+		}
+	}
+	tree.mu.RLock()
+	handles := tree.current.levels[1]
+	for i := 1; i < len(handles); i++ {
+		if handles[i-1].table.Meta().Largest >= handles[i].table.Meta().Smallest {
+			tree.mu.RUnlock()
+			t.Fatalf("level 1 tables %d and %d overlap", handles[i-1].number, handles[i].number)
+		}
+	}
+	tree.mu.RUnlock()
+
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%04d", i)
+		value, found, err := tree.Search(key)
+		if err.IsNotEmpty() || !found || value.IntValue() != int64(2*keys+i) {
+			t.Fatalf("search %s: %v, %v, %v", key, value, found, err.Errors())
+		}
+	}
+}
+
+func TestCompactionDropsTombstones(t *testing.T) {
+	dir := t.TempDir()
+	tree := newCompactingTree(t, dir)
+
+	const keys = 1000
+	for i := 0; i < keys; i++ {
+		if err := tree.Insert(fmt.Sprintf("key-%04d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			t.Fatalf("insert: %v", err.Errors())
+		}
+	}
+	for i := 0; i < keys; i += 2 {
+		if err := tree.Delete(fmt.Sprintf("key-%04d", i)); err.IsNotEmpty() {
+			t.Fatalf("delete: %v", err.Errors())
+		}
+	}
+	// Deleted keys are hidden while their tombstones are still around.
+	if _, found, _ := tree.Search("key-0000"); found {
+		t.Fatal("found a deleted key")
+	}
+
+	// Write other keys until the memtable holding the tombstones is flushed,
+	// which changes the number of level 0 tables, and level 0 is then
+	// compacted away.
+	start, flushed := levelTables(tree, 0), false
+	for i := 0; !flushed || levelTables(tree, 0) > 0; i++ {
+		if err := tree.Insert(fmt.Sprintf("other-%05d", i), common.NewIntValue(int64(i))); err.IsNotEmpty() {
+			t.Fatalf("insert: %v", err.Errors())
+		}
+		flushed = flushed || levelTables(tree, 0) != start
+	}
+
+	// Nothing lies below level 1, so compaction dropped the tombstones
+	// together with the values they shadowed.
+	for level, entries := range tableEntries(t, tree) {
+		for _, entry := range entries {
+			if entry.IsTombstone() {
+				t.Fatalf("level %d still holds a tombstone for %q", level, entry.Key())
+			}
+		}
+	}
+
+	if err := tree.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+	tree = newCompactingTree(t, dir)
+	defer tree.Close()
+	count := 0
+	it := tree.Scan("key-", "key.")
+	for it.Next() {
+		count++
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		t.Fatalf("scan: %v", err.Errors())
+	}
+	if count != keys/2 {
+		t.Fatalf("scan found %d keys after reopening, want %d", count, keys/2)
+	}
+	for i := 0; i < keys; i++ {
+		_, found, err := tree.Search(fmt.Sprintf("key-%04d", i))
+		if err.IsNotEmpty() || found != (i%2 == 1) {
+			t.Fatalf("search key-%04d: found %v, %v", i, found, err.Errors())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package implementation
+
+import (
+	"testing"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/conformance"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// conformanceMemtableSize is small enough for the suite to flush and
+// compact many times.
+const conformanceMemtableSize = 16 << 10
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Harness{Open: openConformanceLSM})
+}
+
+func openConformanceLSM(t *testing.T, dir string) (datastructures.BaseDatabaseStructure, func() lib.Error) {
+	tree := NewLSMTree(dir, serialization.NewBinarySerializer())
+	tree.SetMemtableSize(conformanceMemtableSize)
+	return tree, tree.Close
+}
@@ -0,0 +1,278 @@
+package implementation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/memtable"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/serializer"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+var _ datastructures.BaseDatabaseStructure = (*LSMTree)(nil)
+
+// LSMTree is a write-optimized engine. Writes are appended to a log and
+// applied to an in-memory skip list; a full memtable is frozen and flushed
+// to an immutable table on level 0, and leveled compaction merges tables
+// down into levels that each hold LevelSizeMultiplier times more data.
+//
+// All files live in one directory: NNNNNN.sst tables, one NNNNNN.log per
+// memtable, and a MANIFEST naming the live tables and the oldest log still
+// needed. A file only counts once the manifest says so, so a crash leaves
+// at worst stray files, which Init removes.
+//
+// LSMTree is safe for concurrent use:
+//   - writers hold writeLatch shared while they log and apply a write, and
+//     freezing the memtable takes it exclusively,
+//   - mu guards the memtables and the current version; readers only hold
+//     it to take a reference to the version (see version.go),
+//   - compactMu serializes flushes and compactions, the only writers of the
+//     manifest.
+type LSMTree struct {
+	dir             string
+	entrySerializer *serializer.EntrySerializer
+	memtableSize    int
+
+	writeLatch sync.RWMutex
+	compactMu  sync.Mutex
+
+	mu  sync.RWMutex
+	mem *memtable.SkipList
+	log *wal.Log
+	// logNumber is the file number of log.
+	logNumber uint64
+	// immutables are frozen memtables waiting to be flushed, oldest first.
+	immutables     []*frozenMemtable
+	current        *version
+	nextFileNumber uint64
+	// compactPointers remember, per level, the largest key compacted last,
+	// so that compactions take turns over the key space.
+	compactPointers [constants.MaxLevels]string
+
+	refMu sync.Mutex
+}
+
+type frozenMemtable struct {
+	mem       *memtable.SkipList
+	log       *wal.Log
+	logNumber uint64
+}
+
+func NewLSMTree(dir string, binarySerializer serialization.BaseSerializer) *LSMTree {
+	return &LSMTree{
+		dir:             dir,
+		entrySerializer: serializer.NewEntrySerializer(binarySerializer),
+		memtableSize:    constants.MemtableSize,
+	}
+}
+
+// SetMemtableSize sets the size at which the memtable is flushed. It must be
+// called before Init.
+func (t *LSMTree) SetMemtableSize(bytes int) {
+	t.memtableSize = bytes
+}
+
+// Init opens the tree in its directory, creating it if needed. Writes that
+// were only in the log are recovered into a level 0 table.
+func (t *LSMTree) Init() lib.Error {
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("failed to create %s: %w", t.dir, err))
+	}
+
+	m, err := readManifest(t.manifestPath())
+	if err.IsNotEmpty() {
+		return err
+	}
+	// Files numbered past the manifest's counter may exist if a crash hit
+	// after they were created.
+	t.nextFileNumber = max(m.nextFileNumber, 1)
+	for _, ext := range []string{".sst", ".log"} {
+		numbers, err := t.listFiles(ext)
+		if err.IsNotEmpty() {
+			return err
+		}
+		if len(numbers) > 0 {
+			t.nextFileNumber = max(t.nextFileNumber, numbers[len(numbers)-1]+1)
+		}
+	}
+
+	loaded := newVersion()
+	for _, ref := range m.tables {
+		handle, err := t.openTable(ref.number)
+		if err.IsNotEmpty() {
+			loaded.closeTables()
+			return err
+		}
+		loaded.levels[ref.level] = append(loaded.levels[ref.level], handle)
+	}
+	loaded.sortLevels()
+	t.current = newVersion()
+	t.current.refs = 1
+
+	t.compactMu.Lock()
+	defer t.compactMu.Unlock()
+	if err := t.recover(m.logNumber, loaded); err.IsNotEmpty() {
+		loaded.closeTables()
+		return err
+	}
+	return t.maybeCompact()
+}
+
+// recover replays the logs not covered by the manifest into a table, starts
+// a fresh log, installs the loaded tables plus the recovered one and removes
+// files the new manifest does not reference.
+func (t *LSMTree) recover(oldestLog uint64, loaded *version) lib.Error {
+	logs, err := t.listFiles(".log")
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	mem := memtable.NewSkipList()
+	seq := uint64(0)
+	for _, number := range logs {
+		if number < oldestLog {
+			continue
+		}
+		log, err := wal.OpenLog(t.filePath(number, ".log"))
+		if err.IsNotEmpty() {
+			return err
+		}
+		var decodeErr lib.Error
+		err = log.Iterate(func(_ wal.LSN, payload []byte) bool {
+			entry, _, e := t.entrySerializer.Deserialize(payload)
+			if e.IsNotEmpty() {
+				decodeErr = e
+				return false
+			}
+			seq++
+			mem.Put(entry, seq)
+			return true
+		})
+		log.Close()
+		if err.IsNotEmpty() {
+			return err
+		}
+		if decodeErr.IsNotEmpty() {
+			return decodeErr
+		}
+	}
+
+	t.logNumber = t.newFileNumber()
+	log, err := wal.OpenLog(t.filePath(t.logNumber, ".log"))
+	if err.IsNotEmpty() {
+		return err
+	}
+	t.mem, t.log = memtable.NewSkipList(), log
+
+	if mem.Len() > 0 {
+		handles, err := t.writeTables(newMemIterator(mem.Seek("")), nil, false)
+		if err.IsNotEmpty() {
+			return err
+		}
+		loaded.levels[0] = append(loaded.levels[0], handles...)
+	}
+	if err := t.install(loaded, nil); err.IsNotEmpty() {
+		return err
+	}
+	return t.removeObsoleteFiles()
+}
+
+// Close makes every logged write durable and closes the tree's files.
+// Iterators must be closed first.
+func (t *LSMTree) Close() lib.Error {
+	t.writeLatch.Lock()
+	defer t.writeLatch.Unlock()
+	t.compactMu.Lock()
+	defer t.compactMu.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := t.log.Close()
+	for _, frozen := range t.immutables {
+		if e := frozen.log.Close(); e.IsNotEmpty() && err.IsEmpty() {
+			err = e
+		}
+	}
+	if e := t.current.closeTables(); e.IsNotEmpty() && err.IsEmpty() {
+		err = e
+	}
+	return err
+}
+
+func (t *LSMTree) newFileNumber() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	number := t.nextFileNumber
+	t.nextFileNumber++
+	return number
+}
+
+func (t *LSMTree) filePath(number uint64, ext string) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%06d%s", number, ext))
+}
+
+func (t *LSMTree) manifestPath() string {
+	return filepath.Join(t.dir, "MANIFEST")
+}
+
+// listFiles returns the numbers of the files with the given extension in
+// increasing order.
+func (t *LSMTree) listFiles(ext string) ([]uint64, lib.Error) {
+	dirEntries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("failed to list %s: %w", t.dir, err))
+	}
+	var numbers []uint64
+	for _, dirEntry := range dirEntries {
+		name, ok := strings.CutSuffix(dirEntry.Name(), ext)
+		if !ok {
+			continue
+		}
+		if number, err := strconv.ParseUint(name, 10, 64); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, lib.EmptyError()
+}
+
+// removeObsoleteFiles deletes tables and logs left behind by a crash.
+func (t *LSMTree) removeObsoleteFiles() lib.Error {
+	live := make(map[uint64]bool)
+	for _, level := range t.current.levels {
+		for _, handle := range level {
+			live[handle.number] = true
+		}
+	}
+	tables, err := t.listFiles(".sst")
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, number := range tables {
+		if !live[number] {
+			os.Remove(t.filePath(number, ".sst"))
+		}
+	}
+
+	logs, err := t.listFiles(".log")
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, number := range logs {
+		if number < t.logNumber {
+			os.Remove(t.filePath(number, ".log"))
+		}
+	}
+	os.Remove(t.manifestPath() + ".tmp")
+	return lib.EmptyError()
+}
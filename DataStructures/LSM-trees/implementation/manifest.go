@@ -0,0 +1,120 @@
+package implementation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// The manifest is rewritten as a whole on every change, to a temporary file
+// that is then renamed over the old one, so it is always either the old or
+// the new version. It holds
+//
+//	magic  nextFileNumber  logNumber  tableCount  (level number)*  crc32c
+//
+// where logNumber is the oldest log whose writes are not in a table yet. The
+// digit of the magic goes up whenever the encoding of the manifest, the
+// tables or the log entries changes, so an older directory is refused.
+const manifestMagic = 0x324e414d // "MAN2"
+
+type manifest struct {
+	nextFileNumber uint64
+	logNumber      uint64
+	tables         []tableRef
+}
+
+type tableRef struct {
+	level  int
+	number uint64
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// readManifest returns an empty manifest if there is none yet.
+func readManifest(path string) (manifest, lib.Error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest{}, lib.EmptyError()
+	}
+	if err != nil {
+		return manifest{}, lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("failed to read manifest %s: %w", path, err))
+	}
+
+	if len(data) < 28 || binary.LittleEndian.Uint32(data[0:4]) != manifestMagic {
+		return manifest{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("%s is not a manifest", path))
+	}
+	body := data[:len(data)-4]
+	if stored, computed := binary.LittleEndian.Uint32(data[len(body):]), crc32.Checksum(body, castagnoli); stored != computed {
+		return manifest{}, lib.EmptyError().AddErr(lib.ChecksumMismatchError, fmt.Errorf("manifest %s: checksum mismatch (stored %08x, computed %08x)", path, stored, computed))
+	}
+
+	m := manifest{
+		nextFileNumber: binary.LittleEndian.Uint64(body[4:12]),
+		logNumber:      binary.LittleEndian.Uint64(body[12:20]),
+	}
+	count := int(binary.LittleEndian.Uint32(body[20:24]))
+	if len(body) != 24+count*9 {
+		return manifest{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("manifest %s: %d bytes for %d tables", path, len(body), count))
+	}
+	for i := 0; i < count; i++ {
+		offset := 24 + i*9
+		ref := tableRef{level: int(body[offset]), number: binary.LittleEndian.Uint64(body[offset+1:])}
+		if ref.level >= constants.MaxLevels {
+			return manifest{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("manifest %s: table %d on level %d", path, ref.number, ref.level))
+		}
+		m.tables = append(m.tables, ref)
+	}
+	return m, lib.EmptyError()
+}
+
+func writeManifest(path string, m manifest) lib.Error {
+	data := binary.LittleEndian.AppendUint32(nil, manifestMagic)
+	data = binary.LittleEndian.AppendUint64(data, m.nextFileNumber)
+	data = binary.LittleEndian.AppendUint64(data, m.logNumber)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(m.tables)))
+	for _, ref := range m.tables {
+		data = append(data, uint8(ref.level))
+		data = binary.LittleEndian.AppendUint64(data, ref.number)
+	}
+	data = binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, castagnoli))
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to create manifest %s: %w", tmp, err))
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to write manifest %s: %w", path, err))
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) lib.Error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to open %s: %w", dir, err))
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to sync %s: %w", dir, err))
+	}
+	return lib.EmptyError()
+}
@@ -0,0 +1,159 @@
+package implementation
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/memtable"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/sstable"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// entryIterator yields entries, tombstones included, in key order.
+type entryIterator interface {
+	Next() bool
+	Entry() node.Entry
+	Err() lib.Error
+}
+
+// memIterator lets a memtable iterator take part in merges.
+type memIterator struct {
+	*memtable.Iterator
+}
+
+func newMemIterator(it *memtable.Iterator) entryIterator {
+	return memIterator{it}
+}
+
+func (memIterator) Err() lib.Error {
+	return lib.EmptyError()
+}
+
+// levelIterator walks the tables of a sorted level one after the other,
+// opening each only once the previous one is exhausted.
+type levelIterator struct {
+	handles []*tableHandle
+	start   string
+	current *sstable.Iterator
+	err     lib.Error
+}
+
+func newLevelIterator(handles []*tableHandle, start string) *levelIterator {
+	idx := sort.Search(len(handles), func(i int) bool { return handles[i].table.Meta().Largest >= start })
+	return &levelIterator{handles: handles[idx:], start: start, err: lib.EmptyError()}
+}
+
+func (it *levelIterator) Next() bool {
+	for {
+		if it.current != nil {
+			if it.current.Next() {
+				return true
+			}
+			if err := it.current.Err(); err.IsNotEmpty() {
+				it.err = err
+				return false
+			}
+		}
+		if len(it.handles) == 0 {
+			return false
+		}
+		it.current = it.handles[0].table.Seek(it.start)
+		it.handles = it.handles[1:]
+	}
+}
+
+func (it *levelIterator) Entry() node.Entry {
+	return it.current.Entry()
+}
+
+func (it *levelIterator) Err() lib.Error {
+	return it.err
+}
+
+// mergeIterator merges sources ordered from newest to oldest. Of several
+// entries for one key only the one from the newest source is returned.
+type mergeIterator struct {
+	sources []entryIterator
+	heap    mergeHeap
+	started bool
+	current node.Entry
+	err     lib.Error
+}
+
+type mergeItem struct {
+	entry  node.Entry
+	source int
+}
+
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].entry.Key() != h[j].entry.Key() {
+		return h[i].entry.Key() < h[j].entry.Key()
+	}
+	return h[i].source < h[j].source
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func newMergeIterator(sources []entryIterator) *mergeIterator {
+	return &mergeIterator{sources: sources, err: lib.EmptyError()}
+}
+
+func (it *mergeIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		for source := range it.sources {
+			if !it.advance(source) {
+				return false
+			}
+		}
+	}
+	if it.err.IsNotEmpty() || it.heap.Len() == 0 {
+		return false
+	}
+
+	top := heap.Pop(&it.heap).(mergeItem)
+	it.current = top.entry
+	if !it.advance(top.source) {
+		return false
+	}
+	// Older entries for the same key are shadowed.
+	for it.heap.Len() > 0 && it.heap[0].entry.Key() == top.entry.Key() {
+		shadowed := heap.Pop(&it.heap).(mergeItem)
+		if !it.advance(shadowed.source) {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *mergeIterator) Entry() node.Entry {
+	return it.current
+}
+
+func (it *mergeIterator) Err() lib.Error {
+	return it.err
+}
+
+// advance pushes the next entry of source, if any. It returns false once a
+// source failed.
+func (it *mergeIterator) advance(source int) bool {
+	if it.sources[source].Next() {
+		heap.Push(&it.heap, mergeItem{entry: it.sources[source].Entry(), source: source})
+		return true
+	}
+	if err := it.sources[source].Err(); err.IsNotEmpty() {
+		it.err = err
+		return false
+	}
+	return true
+}
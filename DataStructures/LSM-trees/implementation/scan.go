@@ -0,0 +1,128 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// ScanOptions controls the bounds of a range scan. An empty Start scans from
+// the smallest key and an empty End up to the largest one. Limit <= 0 means
+// no limit.
+type ScanOptions struct {
+	ExcludeStart bool
+	IncludeEnd   bool
+	Limit        int
+}
+
+// Iterator merges the memtables and tables in key order, returning the
+// newest entry of each key and skipping deleted ones. Callers advance it
+// with Next until it returns false, then check Err, or stop early with
+// Close.
+//
+// The iterator holds on to the version it started with, so its tables stay
+// around until it finishes. Writes to the memtable during the scan may or
+// may not be seen.
+type Iterator struct {
+	tree    *LSMTree
+	view    readView
+	merge   *mergeIterator
+	end     string
+	options ScanOptions
+	start   string
+	count   int
+	current node.Entry
+	done    bool
+	err     lib.Error
+}
+
+// Scan iterates over the half-open key range [start, end).
+func (t *LSMTree) Scan(start, end string) *Iterator {
+	return t.ScanWithOptions(start, end, ScanOptions{})
+}
+
+func (t *LSMTree) ScanWithOptions(start, end string, options ScanOptions) *Iterator {
+	view := t.acquire()
+	sources := []entryIterator{newMemIterator(view.mem.Seek(start))}
+	for i := len(view.immutables) - 1; i >= 0; i-- {
+		sources = append(sources, newMemIterator(view.immutables[i].mem.Seek(start)))
+	}
+	level0 := view.version.levels[0]
+	for i := len(level0) - 1; i >= 0; i-- {
+		sources = append(sources, level0[i].table.Seek(start))
+	}
+	for level := 1; level < constants.MaxLevels; level++ {
+		sources = append(sources, newLevelIterator(view.version.levels[level], start))
+	}
+
+	return &Iterator{
+		tree:    t,
+		view:    view,
+		merge:   newMergeIterator(sources),
+		start:   start,
+		end:     end,
+		options: options,
+		err:     lib.EmptyError(),
+	}
+}
+
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.options.Limit > 0 && it.count >= it.options.Limit {
+		return it.finish(lib.EmptyError())
+	}
+	for it.merge.Next() {
+		entry := it.merge.Entry()
+		if entry.IsTombstone() || (it.options.ExcludeStart && entry.Key() == it.start) {
+			continue
+		}
+		if it.pastEnd(entry.Key()) {
+			return it.finish(lib.EmptyError())
+		}
+		it.current = entry
+		it.count++
+		return true
+	}
+	return it.finish(it.merge.Err())
+}
+
+func (it *Iterator) Key() string {
+	return it.current.Key()
+}
+
+func (it *Iterator) Value() common.Value {
+	return it.current.Value()
+}
+
+func (it *Iterator) Err() lib.Error {
+	return it.err
+}
+
+// Close releases the iterator's version. Closing it again does nothing.
+func (it *Iterator) Close() {
+	it.finish(lib.EmptyError())
+}
+
+func (it *Iterator) pastEnd(key string) bool {
+	if it.end == "" {
+		return false
+	}
+	if it.options.IncludeEnd {
+		return key > it.end
+	}
+	return key >= it.end
+}
+
+func (it *Iterator) finish(err lib.Error) bool {
+	if !it.done {
+		it.done = true
+		it.tree.release(it.view.version)
+		it.err = err
+	}
+	it.merge = nil
+	it.current = node.Entry{}
+	return false
+}
@@ -0,0 +1,58 @@
+package implementation
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Search looks primaryKey up from the newest data to the oldest: the
+// memtable, the frozen memtables, level 0 and then one table per level.
+// The first entry found decides; a tombstone means the key is deleted.
+func (t *LSMTree) Search(primaryKey string) (_ common.Value, _ bool, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	view := t.acquire()
+	defer t.release(view.version)
+
+	entry, ok, err := view.get(primaryKey)
+	if err.IsNotEmpty() || !ok || entry.IsTombstone() {
+		return common.Value{}, false, err
+	}
+	return entry.Value(), true, lib.EmptyError()
+}
+
+func (view readView) get(key string) (node.Entry, bool, lib.Error) {
+	if entry, ok := view.mem.Get(key); ok {
+		return entry, true, lib.EmptyError()
+	}
+	for i := len(view.immutables) - 1; i >= 0; i-- {
+		if entry, ok := view.immutables[i].mem.Get(key); ok {
+			return entry, true, lib.EmptyError()
+		}
+	}
+
+	level0 := view.version.levels[0]
+	for i := len(level0) - 1; i >= 0; i-- {
+		entry, ok, err := level0[i].table.Get(key)
+		if err.IsNotEmpty() || ok {
+			return entry, ok, err
+		}
+	}
+	for level := 1; level < constants.MaxLevels; level++ {
+		handle := view.version.tableFor(level, key)
+		if handle == nil {
+			continue
+		}
+		entry, ok, err := handle.table.Get(key)
+		if err.IsNotEmpty() || ok {
+			return entry, ok, err
+		}
+	}
+	return node.Entry{}, false, lib.EmptyError()
+}
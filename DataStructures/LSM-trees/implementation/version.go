@@ -0,0 +1,193 @@
+package implementation
+
+import (
+	"os"
+	"sort"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/memtable"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/sstable"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// A version is the set of tables making up the tree at one point. Flushes
+// and compactions never change a version; they install a new one. Readers
+// take a reference to the version current when they start, so a table is
+// closed and deleted only once no version that lists it is still read.
+type version struct {
+	// levels[0] is ordered by file number, i.e. from oldest to newest, and
+	// its tables may overlap. Every other level is ordered by key and its
+	// tables do not overlap.
+	levels [constants.MaxLevels][]*tableHandle
+	// refs counts the readers of the version, plus one while it is current.
+	// It is guarded by refMu.
+	refs int
+}
+
+type tableHandle struct {
+	number uint64
+	table  *sstable.Table
+	// refs counts the versions listing the table, guarded by refMu.
+	refs int
+}
+
+// readView is what a reader looks at: the memtables and the version current
+// when it started.
+type readView struct {
+	mem        *memtable.SkipList
+	immutables []*frozenMemtable
+	version    *version
+}
+
+func newVersion() *version {
+	return &version{}
+}
+
+func (v *version) clone() *version {
+	next := newVersion()
+	for level, handles := range v.levels {
+		next.levels[level] = append([]*tableHandle{}, handles...)
+	}
+	return next
+}
+
+func (v *version) sortLevels() {
+	sort.Slice(v.levels[0], func(i, j int) bool { return v.levels[0][i].number < v.levels[0][j].number })
+	for level := 1; level < constants.MaxLevels; level++ {
+		handles := v.levels[level]
+		sort.Slice(handles, func(i, j int) bool { return handles[i].table.Meta().Smallest < handles[j].table.Meta().Smallest })
+	}
+}
+
+// levelSize is the total size in bytes of the tables of a level.
+func (v *version) levelSize(level int) int64 {
+	size := int64(0)
+	for _, handle := range v.levels[level] {
+		size += handle.table.Meta().Size
+	}
+	return size
+}
+
+// overlapping returns the tables of level whose key range meets
+// [smallest, largest].
+func (v *version) overlapping(level int, smallest, largest string) []*tableHandle {
+	var handles []*tableHandle
+	for _, handle := range v.levels[level] {
+		meta := handle.table.Meta()
+		if meta.Largest >= smallest && meta.Smallest <= largest {
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// tableFor returns the table of a sorted level that may hold key.
+func (v *version) tableFor(level int, key string) *tableHandle {
+	handles := v.levels[level]
+	idx := sort.Search(len(handles), func(i int) bool { return handles[i].table.Meta().Largest >= key })
+	if idx == len(handles) || handles[idx].table.Meta().Smallest > key {
+		return nil
+	}
+	return handles[idx]
+}
+
+// closeTables closes the files of every table without deleting them.
+func (v *version) closeTables() lib.Error {
+	err := lib.EmptyError()
+	for _, handles := range v.levels {
+		for _, handle := range handles {
+			if e := handle.table.Close(); e.IsNotEmpty() && err.IsEmpty() {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+func (t *LSMTree) openTable(number uint64) (*tableHandle, lib.Error) {
+	table, err := sstable.OpenTable(t.filePath(number, ".sst"), t.entrySerializer)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &tableHandle{number: number, table: table}, lib.EmptyError()
+}
+
+// acquire pins the current state for a reader, which must call release
+// with the view's version when done.
+func (t *LSMTree) acquire() readView {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	t.refMu.Lock()
+	t.current.refs++
+	t.refMu.Unlock()
+	return readView{
+		mem:        t.mem,
+		immutables: append([]*frozenMemtable{}, t.immutables...),
+		version:    t.current,
+	}
+}
+
+// release drops a reference to v. Tables no version lists anymore are
+// closed and deleted.
+func (t *LSMTree) release(v *version) {
+	t.refMu.Lock()
+	defer t.refMu.Unlock()
+
+	v.refs--
+	if v.refs > 0 {
+		return
+	}
+	for _, handles := range v.levels {
+		for _, handle := range handles {
+			handle.refs--
+			if handle.refs == 0 {
+				handle.table.Close()
+				os.Remove(handle.table.Path())
+			}
+		}
+	}
+}
+
+// install records next in the manifest and makes it current. If flushed is
+// set, the frozen memtable now stored in next's tables is dropped at the
+// same time, so readers see its entries in exactly one place. Callers hold
+// compactMu.
+func (t *LSMTree) install(next *version, flushed *frozenMemtable) lib.Error {
+	t.mu.RLock()
+	m := manifest{nextFileNumber: t.nextFileNumber, logNumber: t.logNumber}
+	for _, frozen := range t.immutables {
+		if frozen != flushed {
+			m.logNumber = min(m.logNumber, frozen.logNumber)
+		}
+	}
+	t.mu.RUnlock()
+	for level, handles := range next.levels {
+		for _, handle := range handles {
+			m.tables = append(m.tables, tableRef{level: level, number: handle.number})
+		}
+	}
+	if err := writeManifest(t.manifestPath(), m); err.IsNotEmpty() {
+		return err
+	}
+
+	t.refMu.Lock()
+	next.refs = 1
+	for _, handles := range next.levels {
+		for _, handle := range handles {
+			handle.refs++
+		}
+	}
+	t.refMu.Unlock()
+
+	t.mu.Lock()
+	previous := t.current
+	t.current = next
+	if flushed != nil && len(t.immutables) > 0 && t.immutables[0] == flushed {
+		t.immutables = t.immutables[1:]
+	}
+	t.mu.Unlock()
+
+	t.release(previous)
+	return lib.EmptyError()
+}
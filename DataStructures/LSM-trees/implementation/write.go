@@ -0,0 +1,184 @@
+package implementation
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/memtable"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/sstable"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func (t *LSMTree) Insert(primaryKey string, value common.Value) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	return t.write(node.NewEntry(primaryKey, value))
+}
+
+// Delete writes a tombstone for primaryKey, whether or not it is stored.
+func (t *LSMTree) Delete(primaryKey string) (err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = lib.Recovered(r)
+		}
+	}()
+
+	return t.write(node.NewTombstone(primaryKey))
+}
+
+// write logs entry and applies it to the memtable once it is durable.
+// Concurrent writers share the log's fsyncs. The LSN orders their entries
+// in the memtable the same way as in the log.
+func (t *LSMTree) write(entry node.Entry) lib.Error {
+	buf, err := t.entrySerializer.Serialize(entry)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if len(buf) > wal.MaxRecordSize {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write: entry for key %q takes %d bytes, at most %d fit in a log record", entry.Key(), len(buf), wal.MaxRecordSize))
+	}
+
+	t.writeLatch.RLock()
+	mem, log := t.mem, t.log
+	lsn := log.Append(buf)
+	err = log.Flush(lsn)
+	if err.IsEmpty() {
+		mem.Put(entry, uint64(lsn))
+	}
+	full := mem.ByteSize() >= t.memtableSize
+	t.writeLatch.RUnlock()
+
+	if err.IsNotEmpty() || !full {
+		return err
+	}
+	return t.rotate(mem)
+}
+
+// rotate freezes the full memtable behind a fresh log and flushes it. Of
+// several writers finding the same memtable full, only the first rotates.
+func (t *LSMTree) rotate(full *memtable.SkipList) lib.Error {
+	t.writeLatch.Lock()
+	if t.mem != full {
+		t.writeLatch.Unlock()
+		return lib.EmptyError()
+	}
+	number := t.newFileNumber()
+	log, err := wal.OpenLog(t.filePath(number, ".log"))
+	if err.IsNotEmpty() {
+		t.writeLatch.Unlock()
+		return err
+	}
+	t.mu.Lock()
+	t.immutables = append(t.immutables, &frozenMemtable{mem: t.mem, log: t.log, logNumber: t.logNumber})
+	t.mem, t.log, t.logNumber = memtable.NewSkipList(), log, number
+	t.mu.Unlock()
+	t.writeLatch.Unlock()
+
+	t.compactMu.Lock()
+	defer t.compactMu.Unlock()
+	if err := t.flushImmutables(); err.IsNotEmpty() {
+		return err
+	}
+	return t.maybeCompact()
+}
+
+// flushImmutables writes the frozen memtables to level 0, oldest first, and
+// deletes their logs. Callers hold compactMu.
+func (t *LSMTree) flushImmutables() lib.Error {
+	for {
+		t.mu.RLock()
+		if len(t.immutables) == 0 {
+			t.mu.RUnlock()
+			return lib.EmptyError()
+		}
+		frozen := t.immutables[0]
+		t.mu.RUnlock()
+
+		handles, err := t.writeTables(newMemIterator(frozen.mem.Seek("")), nil, false)
+		if err.IsNotEmpty() {
+			return err
+		}
+		next := t.current.clone()
+		next.levels[0] = append(next.levels[0], handles...)
+		if err := t.install(next, frozen); err.IsNotEmpty() {
+			return err
+		}
+
+		if err := frozen.log.Close(); err.IsNotEmpty() {
+			return err
+		}
+		os.Remove(t.filePath(frozen.logNumber, ".log"))
+	}
+}
+
+// writeTables writes the entries of it to new tables, leaving out those
+// drop returns true for. With split set, the output is cut into tables of
+// about TargetTableSize, at key boundaries; otherwise it all goes into one.
+func (t *LSMTree) writeTables(it entryIterator, drop func(node.Entry) bool, split bool) ([]*tableHandle, lib.Error) {
+	var handles []*tableHandle
+	var writer *sstable.Writer
+	var number uint64
+	fail := func(err lib.Error) ([]*tableHandle, lib.Error) {
+		if writer != nil {
+			writer.Abort()
+		}
+		for _, handle := range handles {
+			handle.table.Close()
+			os.Remove(handle.table.Path())
+		}
+		return nil, err
+	}
+	finish := func() lib.Error {
+		if _, err := writer.Finish(); err.IsNotEmpty() {
+			return err
+		}
+		writer = nil
+		handle, err := t.openTable(number)
+		if err.IsNotEmpty() {
+			os.Remove(t.filePath(number, ".sst"))
+			return err
+		}
+		handles = append(handles, handle)
+		return lib.EmptyError()
+	}
+
+	for it.Next() {
+		entry := it.Entry()
+		if drop != nil && drop(entry) {
+			continue
+		}
+		if writer == nil {
+			number = t.newFileNumber()
+			w, err := sstable.NewWriter(t.filePath(number, ".sst"), t.entrySerializer)
+			if err.IsNotEmpty() {
+				return fail(err)
+			}
+			writer = w
+		}
+		if err := writer.Add(entry); err.IsNotEmpty() {
+			return fail(err)
+		}
+		if split && writer.EstimatedSize() >= constants.TargetTableSize {
+			if err := finish(); err.IsNotEmpty() {
+				return fail(err)
+			}
+		}
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		return fail(err)
+	}
+	if writer != nil {
+		if err := finish(); err.IsNotEmpty() {
+			return fail(err)
+		}
+	}
+	return handles, lib.EmptyError()
+}
@@ -0,0 +1,162 @@
+package memtable
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+)
+
+const (
+	maxHeight = 16
+	// Each level links about a quarter of the nodes of the level below.
+	branching = 4
+)
+
+// SkipList is the sorted in-memory table writes go to before they are
+// flushed. It is safe for concurrent use. Keys are never unlinked, since a
+// deletion is stored as a tombstone, so iterators stay valid while writers
+// keep inserting.
+type SkipList struct {
+	mu       sync.RWMutex
+	head     *skipNode
+	height   int
+	rnd      *rand.Rand
+	byteSize int
+	count    int
+}
+
+type skipNode struct {
+	entry node.Entry
+	// seq orders writes to the same key that race to the list; the one
+	// with the higher seq wins regardless of which arrives first.
+	seq  uint64
+	next []*skipNode
+}
+
+func NewSkipList() *SkipList {
+	return &SkipList{
+		head:   &skipNode{next: make([]*skipNode, maxHeight)},
+		height: 1,
+		rnd:    rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// Put stores entry unless the list already holds a write to its key with a
+// higher seq.
+func (s *SkipList) Put(entry node.Entry, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var prev [maxHeight]*skipNode
+	x := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for x.next[level] != nil && x.next[level].entry.Key() < entry.Key() {
+			x = x.next[level]
+		}
+		prev[level] = x
+	}
+
+	if found := x.next[0]; found != nil && found.entry.Key() == entry.Key() {
+		if seq < found.seq {
+			return
+		}
+		s.byteSize += entry.ByteSize() - found.entry.ByteSize()
+		found.entry, found.seq = entry, seq
+		return
+	}
+
+	height := s.randomHeight()
+	if height > s.height {
+		for level := s.height; level < height; level++ {
+			prev[level] = s.head
+		}
+		s.height = height
+	}
+	n := &skipNode{entry: entry, seq: seq, next: make([]*skipNode, height)}
+	for level := 0; level < height; level++ {
+		n.next[level] = prev[level].next[level]
+		prev[level].next[level] = n
+	}
+	s.byteSize += entry.ByteSize()
+	s.count++
+}
+
+// Get returns the latest entry for key, which may be a tombstone.
+func (s *SkipList) Get(key string) (node.Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	x := s.seek(key)
+	if x == nil || x.entry.Key() != key {
+		return node.Entry{}, false
+	}
+	return x.entry, true
+}
+
+// ByteSize is the serialized size of all entries, i.e. roughly the size of
+// the table they will be flushed to.
+func (s *SkipList) ByteSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.byteSize
+}
+
+func (s *SkipList) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.count
+}
+
+// Seek returns an iterator positioned before the first key >= start.
+func (s *SkipList) Seek(start string) *Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Iterator{list: s, next: s.seek(start)}
+}
+
+// seek returns the first node with a key >= key. Callers hold mu.
+func (s *SkipList) seek(key string) *skipNode {
+	x := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for x.next[level] != nil && x.next[level].entry.Key() < key {
+			x = x.next[level]
+		}
+	}
+	return x.next[0]
+}
+
+func (s *SkipList) randomHeight() int {
+	height := 1
+	for height < maxHeight && s.rnd.Intn(branching) == 0 {
+		height++
+	}
+	return height
+}
+
+// Iterator walks a skip list in key order. Keys inserted behind it are not
+// seen; keys inserted ahead of it may be.
+type Iterator struct {
+	list    *SkipList
+	next    *skipNode
+	current node.Entry
+}
+
+func (it *Iterator) Next() bool {
+	it.list.mu.RLock()
+	defer it.list.mu.RUnlock()
+
+	if it.next == nil {
+		return false
+	}
+	it.current = it.next.entry
+	it.next = it.next.next[0]
+	return true
+}
+
+func (it *Iterator) Entry() node.Entry {
+	return it.current
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// Entry is a key with its value, or a tombstone recording that the key was
+// deleted. Tombstones shadow older values of the key until compaction
+// drops them at the bottom of the tree.
+type Entry struct {
+	key       string
+	value     common.Value
+	tombstone bool
+}
+
+func NewEntry(key string, value common.Value) Entry {
+	return Entry{key: key, value: value}
+}
+
+func NewTombstone(key string) Entry {
+	return Entry{key: key, tombstone: true}
+}
+
+func (e Entry) Key() string {
+	return e.key
+}
+
+func (e Entry) Value() common.Value {
+	return e.value
+}
+
+func (e Entry) IsTombstone() bool {
+	return e.tombstone
+}
+
+// ByteSize is the serialized size of the entry: the length-prefixed key, a
+// kind byte and, unless it is a tombstone, the value as
+// serialization.AppendValue encodes it.
+func (e Entry) ByteSize() int {
+	if e.tombstone {
+		return 4 + len(e.key) + 1
+	}
+	return 4 + len(e.key) + 1 + serialization.ValueSize(e.value)
+}
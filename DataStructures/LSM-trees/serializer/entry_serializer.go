@@ -0,0 +1,97 @@
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+const (
+	valueEntry     uint8 = 0
+	tombstoneEntry uint8 = 1
+)
+
+// EntrySerializer encodes entries for the log and the data blocks of
+// tables. Keys carry a 4 byte length, so unlike tree nodes they are not
+// limited to 64KB; values are encoded by serialization.AppendValue.
+type EntrySerializer struct {
+	serializer serialization.BaseSerializer
+}
+
+func NewEntrySerializer(serializer serialization.BaseSerializer) *EntrySerializer {
+	return &EntrySerializer{
+		serializer: serializer,
+	}
+}
+
+func (es *EntrySerializer) Serialize(entry node.Entry) ([]byte, lib.Error) {
+	buf := make([]byte, 0, entry.ByteSize())
+
+	buf, err := es.appendBytes(buf, []byte(entry.Key()))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if entry.IsTombstone() {
+		return append(buf, tombstoneEntry), lib.EmptyError()
+	}
+
+	buf = append(buf, valueEntry)
+	return serialization.AppendValue(buf, entry.Value()), lib.EmptyError()
+}
+
+// Deserialize decodes the entry at the start of data and returns it along
+// with the number of bytes consumed.
+func (es *EntrySerializer) Deserialize(data []byte) (node.Entry, int, lib.Error) {
+	key, offset, err := es.readBytes(data, 0)
+	if err.IsNotEmpty() {
+		return node.Entry{}, 0, err
+	}
+	if offset >= len(data) {
+		return node.Entry{}, 0, lib.EmptyError().AddErr(lib.InvalidByteLength, fmt.Errorf("entry for key %q is truncated", key))
+	}
+	kind := data[offset]
+	offset++
+	if kind == tombstoneEntry {
+		return node.NewTombstone(string(key)), offset, lib.EmptyError()
+	}
+	if kind != valueEntry {
+		return node.Entry{}, 0, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: entry kind %d for key %q", kind, key))
+	}
+
+	stored, n, err := serialization.DecodeValue(data[offset:])
+	if err.IsNotEmpty() {
+		return node.Entry{}, 0, err
+	}
+	if stored.HasOverflow() {
+		return node.Entry{}, 0, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: value of key %q refers to an overflow chain", key))
+	}
+	return node.NewEntry(string(key), stored.Value), offset + n, lib.EmptyError()
+}
+
+func (es *EntrySerializer) appendBytes(buf, data []byte) ([]byte, lib.Error) {
+	b, err := es.serializer.Serialize(uint32(len(data)))
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf = append(buf, b...)
+	return append(buf, data...), lib.EmptyError()
+}
+
+// readBytes reads a length-prefixed byte string at offset and returns it
+// with the offset just past it.
+func (es *EntrySerializer) readBytes(data []byte, offset int) ([]byte, int, lib.Error) {
+	if offset+4 > len(data) {
+		return nil, 0, lib.EmptyError().AddErr(lib.InvalidByteLength, fmt.Errorf("not enough bytes to read a length at offset %d", offset))
+	}
+	var length uint32
+	if err := es.serializer.Deserialize(data[offset:offset+4], &length); err.IsNotEmpty() {
+		return nil, 0, err
+	}
+	offset += 4
+	if uint64(offset)+uint64(length) > uint64(len(data)) {
+		return nil, 0, lib.EmptyError().AddErr(lib.InvalidByteLength, fmt.Errorf("not enough bytes to read %d bytes at offset %d", length, offset))
+	}
+	return data[offset : offset+int(length)], offset + int(length), lib.EmptyError()
+}
@@ -0,0 +1,75 @@
+package sstable
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// BloomFilter answers whether a table may hold a key, so that lookups of
+// missing keys rarely have to read a data block.
+type BloomFilter struct {
+	bits   []byte
+	probes uint8
+}
+
+// NewBloomFilter builds a filter over the given key hashes with bitsPerKey
+// bits for each of them.
+func NewBloomFilter(hashes []uint64, bitsPerKey int) *BloomFilter {
+	numBits := max(len(hashes)*bitsPerKey, 64)
+	// ln 2 * bits per key probes minimize the false positive rate.
+	probes := uint8(min(max(int(math.Round(float64(bitsPerKey)*math.Ln2)), 1), 30))
+
+	f := &BloomFilter{bits: make([]byte, (numBits+7)/8), probes: probes}
+	for _, h := range hashes {
+		f.add(h)
+	}
+	return f
+}
+
+func (f *BloomFilter) MayContain(key string) bool {
+	h1, h2 := probeHashes(hashKey(key))
+	numBits := uint64(len(f.bits) * 8)
+	for i := uint64(0); i < uint64(f.probes); i++ {
+		bit := (h1 + i*h2) % numBits
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode lays the filter out as its probe count followed by the bit array.
+func (f *BloomFilter) Encode() []byte {
+	return append([]byte{f.probes}, f.bits...)
+}
+
+func DecodeBloomFilter(data []byte) (*BloomFilter, lib.Error) {
+	if len(data) < 2 || data[0] == 0 {
+		return nil, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("invalid bloom filter of %d bytes", len(data)))
+	}
+	return &BloomFilter{probes: data[0], bits: append([]byte{}, data[1:]...)}, lib.EmptyError()
+}
+
+func (f *BloomFilter) add(h uint64) {
+	h1, h2 := probeHashes(h)
+	numBits := uint64(len(f.bits) * 8)
+	for i := uint64(0); i < uint64(f.probes); i++ {
+		bit := (h1 + i*h2) % numBits
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// probeHashes derives the two hashes the probes are combined from (double
+// hashing), so a key is hashed only once.
+func probeHashes(h uint64) (uint64, uint64) {
+	return h, h>>33 | h<<31 | 1
+}
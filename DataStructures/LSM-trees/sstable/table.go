@@ -0,0 +1,227 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/serializer"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Table is an open, immutable table file. Its filter and index are kept in
+// memory; data blocks are read on demand. It is safe for concurrent use.
+type Table struct {
+	file            *os.File
+	path            string
+	entrySerializer *serializer.EntrySerializer
+	index           []blockHandle
+	bloom           *BloomFilter
+	meta            Meta
+}
+
+func OpenTable(path string, entrySerializer *serializer.EntrySerializer) (*Table, lib.Error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to open table %s: %w", path, err))
+	}
+	t := &Table{file: file, path: path, entrySerializer: entrySerializer}
+	if e := t.load(); e.IsNotEmpty() {
+		file.Close()
+		return nil, e
+	}
+	return t, lib.EmptyError()
+}
+
+func (t *Table) Path() string {
+	return t.path
+}
+
+func (t *Table) Meta() Meta {
+	return t.meta
+}
+
+// Get returns the table's entry for key, which may be a tombstone.
+func (t *Table) Get(key string) (node.Entry, bool, lib.Error) {
+	if key < t.meta.Smallest || key > t.meta.Largest || !t.bloom.MayContain(key) {
+		return node.Entry{}, false, lib.EmptyError()
+	}
+	idx := t.blockFor(key)
+	if idx == len(t.index) {
+		return node.Entry{}, false, lib.EmptyError()
+	}
+	entries, err := t.readBlock(idx)
+	if err.IsNotEmpty() {
+		return node.Entry{}, false, err
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Key() >= key })
+	if i == len(entries) || entries[i].Key() != key {
+		return node.Entry{}, false, lib.EmptyError()
+	}
+	return entries[i], true, lib.EmptyError()
+}
+
+// Seek returns an iterator positioned before the first key >= start.
+func (t *Table) Seek(start string) *Iterator {
+	return &Iterator{table: t, start: start, block: t.blockFor(start), err: lib.EmptyError()}
+}
+
+func (t *Table) Close() lib.Error {
+	if err := t.file.Close(); err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to close table %s: %w", t.path, err))
+	}
+	return lib.EmptyError()
+}
+
+// blockFor is the index of the first block whose last key is >= key.
+func (t *Table) blockFor(key string) int {
+	return sort.Search(len(t.index), func(i int) bool { return t.index[i].lastKey >= key })
+}
+
+func (t *Table) load() lib.Error {
+	info, err := t.file.Stat()
+	if err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to stat table %s: %w", t.path, err))
+	}
+	if info.Size() < footerSize {
+		return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("table %s is too short for a footer", t.path))
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := t.file.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to read footer of %s: %w", t.path, err))
+	}
+	if binary.LittleEndian.Uint32(footer[32:36]) != tableMagic {
+		return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("%s is not a table", t.path))
+	}
+	if stored, computed := binary.LittleEndian.Uint32(footer[36:40]), crc32.Checksum(footer[:36], castagnoli); stored != computed {
+		return lib.EmptyError().AddErr(lib.ChecksumMismatchError, fmt.Errorf("footer of %s: checksum mismatch (stored %08x, computed %08x)", t.path, stored, computed))
+	}
+	indexOffset := binary.LittleEndian.Uint64(footer[0:8])
+	indexLength := binary.LittleEndian.Uint32(footer[8:12])
+	bloomOffset := binary.LittleEndian.Uint64(footer[12:20])
+	bloomLength := binary.LittleEndian.Uint32(footer[20:24])
+	t.meta.Count = binary.LittleEndian.Uint64(footer[24:32])
+	t.meta.Size = info.Size()
+
+	bloom, e := t.readChecked(bloomOffset, bloomLength)
+	if e.IsNotEmpty() {
+		return e
+	}
+	if t.bloom, e = DecodeBloomFilter(bloom); e.IsNotEmpty() {
+		return e
+	}
+
+	index, e := t.readChecked(indexOffset, indexLength)
+	if e.IsNotEmpty() {
+		return e
+	}
+	for offset := 0; offset < len(index); {
+		if offset+4 > len(index) {
+			return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("index of %s is truncated", t.path))
+		}
+		keyLength := int(binary.LittleEndian.Uint32(index[offset:]))
+		offset += 4
+		if offset+keyLength+12 > len(index) {
+			return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("index of %s is truncated", t.path))
+		}
+		handle := blockHandle{lastKey: string(index[offset : offset+keyLength])}
+		offset += keyLength
+		handle.offset = binary.LittleEndian.Uint64(index[offset:])
+		handle.length = binary.LittleEndian.Uint32(index[offset+8:])
+		offset += 12
+		t.index = append(t.index, handle)
+	}
+
+	if len(t.index) > 0 {
+		first, e := t.readBlock(0)
+		if e.IsNotEmpty() {
+			return e
+		}
+		if len(first) == 0 {
+			return lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("first block of %s is empty", t.path))
+		}
+		t.meta.Smallest = first[0].Key()
+		t.meta.Largest = t.index[len(t.index)-1].lastKey
+	}
+	return lib.EmptyError()
+}
+
+func (t *Table) readBlock(idx int) ([]node.Entry, lib.Error) {
+	handle := t.index[idx]
+	data, err := t.readChecked(handle.offset, handle.length)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+
+	var entries []node.Entry
+	for offset := 0; offset < len(data); {
+		entry, n, err := t.entrySerializer.Deserialize(data[offset:])
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		entries = append(entries, entry)
+		offset += n
+	}
+	return entries, lib.EmptyError()
+}
+
+// readChecked reads length bytes at offset and verifies the trailing
+// checksum, returning the data without it.
+func (t *Table) readChecked(offset uint64, length uint32) ([]byte, lib.Error) {
+	if length < checksumSize {
+		return nil, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("table %s: section at %d is only %d bytes", t.path, offset, length))
+	}
+	buf := make([]byte, length)
+	if _, err := t.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to read %d bytes at %d of %s: %w", length, offset, t.path, err))
+	}
+	data := buf[:length-checksumSize]
+	if stored, computed := binary.LittleEndian.Uint32(buf[length-checksumSize:]), crc32.Checksum(data, castagnoli); stored != computed {
+		return nil, lib.EmptyError().AddErr(lib.ChecksumMismatchError, fmt.Errorf("table %s at offset %d: checksum mismatch (stored %08x, computed %08x)", t.path, offset, stored, computed))
+	}
+	return data, lib.EmptyError()
+}
+
+// Iterator walks a table in key order, one block at a time.
+type Iterator struct {
+	table   *Table
+	start   string
+	block   int
+	entries []node.Entry
+	idx     int
+	current node.Entry
+	err     lib.Error
+}
+
+func (it *Iterator) Next() bool {
+	for it.idx >= len(it.entries) {
+		if it.err.IsNotEmpty() || it.block >= len(it.table.index) {
+			return false
+		}
+		entries, err := it.table.readBlock(it.block)
+		if err.IsNotEmpty() {
+			it.err = err
+			return false
+		}
+		it.block++
+		it.entries, it.idx = entries, 0
+		for it.idx < len(it.entries) && it.entries[it.idx].Key() < it.start {
+			it.idx++
+		}
+	}
+	it.current = it.entries[it.idx]
+	it.idx++
+	return true
+}
+
+func (it *Iterator) Entry() node.Entry {
+	return it.current
+}
+
+func (it *Iterator) Err() lib.Error {
+	return it.err
+}
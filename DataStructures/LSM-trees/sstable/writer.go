@@ -0,0 +1,194 @@
+package sstable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/constants"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/LSM-trees/serializer"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// A table file is laid out as
+//
+//	data block*  bloom filter  index  footer
+//
+// Data blocks hold serialized entries in key order. The index holds, for
+// every data block, its last key, offset and length. Blocks, filter and
+// index each end in the CRC32C of their contents; the fixed-size footer
+// locates the filter and index and ends in its own checksum.
+const (
+	footerSize   = 40
+	tableMagic   = 0x32545353 // "SST2"
+	checksumSize = 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Meta describes a finished table.
+type Meta struct {
+	Smallest string
+	Largest  string
+	Size     int64
+	Count    uint64
+}
+
+type blockHandle struct {
+	lastKey string
+	offset  uint64
+	length  uint32
+}
+
+// Writer builds a table from entries added in strictly increasing key
+// order. A failed or aborted table is removed again.
+type Writer struct {
+	file            *os.File
+	out             *bufio.Writer
+	path            string
+	entrySerializer *serializer.EntrySerializer
+	block           []byte
+	lastKey         string
+	index           []blockHandle
+	hashes          []uint64
+	offset          uint64
+	meta            Meta
+}
+
+func NewWriter(path string, entrySerializer *serializer.EntrySerializer) (*Writer, lib.Error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to create table %s: %w", path, err))
+	}
+	return &Writer{
+		file:            file,
+		out:             bufio.NewWriter(file),
+		path:            path,
+		entrySerializer: entrySerializer,
+	}, lib.EmptyError()
+}
+
+func (w *Writer) Add(entry node.Entry) lib.Error {
+	if w.meta.Count > 0 && entry.Key() <= w.lastKey {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("table %s: key %q added after %q", w.path, entry.Key(), w.lastKey))
+	}
+	buf, err := w.entrySerializer.Serialize(entry)
+	if err.IsNotEmpty() {
+		return err
+	}
+
+	if w.meta.Count == 0 {
+		w.meta.Smallest = entry.Key()
+	}
+	w.block = append(w.block, buf...)
+	w.lastKey = entry.Key()
+	w.hashes = append(w.hashes, hashKey(entry.Key()))
+	w.meta.Count++
+	if len(w.block) >= constants.BlockSize {
+		return w.flushBlock()
+	}
+	return lib.EmptyError()
+}
+
+// EstimatedSize is the size the table would have if finished now.
+func (w *Writer) EstimatedSize() int64 {
+	return int64(w.offset) + int64(len(w.block))
+}
+
+func (w *Writer) Count() uint64 {
+	return w.meta.Count
+}
+
+// Finish writes the filter, index and footer and makes the table durable.
+func (w *Writer) Finish() (Meta, lib.Error) {
+	if err := w.flushBlock(); err.IsNotEmpty() {
+		w.Abort()
+		return Meta{}, err
+	}
+
+	bloomOffset := w.offset
+	bloom := NewBloomFilter(w.hashes, constants.BloomBitsPerKey).Encode()
+	if err := w.write(bloom); err.IsNotEmpty() {
+		w.Abort()
+		return Meta{}, err
+	}
+
+	indexOffset := w.offset
+	var index []byte
+	for _, handle := range w.index {
+		index = binary.LittleEndian.AppendUint32(index, uint32(len(handle.lastKey)))
+		index = append(index, handle.lastKey...)
+		index = binary.LittleEndian.AppendUint64(index, handle.offset)
+		index = binary.LittleEndian.AppendUint32(index, handle.length)
+	}
+	if err := w.write(index); err.IsNotEmpty() {
+		w.Abort()
+		return Meta{}, err
+	}
+
+	footer := make([]byte, 0, footerSize)
+	footer = binary.LittleEndian.AppendUint64(footer, indexOffset)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(index)+checksumSize))
+	footer = binary.LittleEndian.AppendUint64(footer, bloomOffset)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(bloom)+checksumSize))
+	footer = binary.LittleEndian.AppendUint64(footer, w.meta.Count)
+	footer = binary.LittleEndian.AppendUint32(footer, tableMagic)
+	footer = binary.LittleEndian.AppendUint32(footer, crc32.Checksum(footer, castagnoli))
+	if _, err := w.out.Write(footer); err != nil {
+		w.Abort()
+		return Meta{}, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to write footer of %s: %w", w.path, err))
+	}
+	w.offset += footerSize
+
+	if err := w.out.Flush(); err != nil {
+		w.Abort()
+		return Meta{}, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to write table %s: %w", w.path, err))
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return Meta{}, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to sync table %s: %w", w.path, err))
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.path)
+		return Meta{}, lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to close table %s: %w", w.path, err))
+	}
+
+	w.meta.Largest = w.lastKey
+	w.meta.Size = int64(w.offset)
+	return w.meta, lib.EmptyError()
+}
+
+// Abort drops the unfinished table.
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.path)
+}
+
+func (w *Writer) flushBlock() lib.Error {
+	if len(w.block) == 0 {
+		return lib.EmptyError()
+	}
+	handle := blockHandle{lastKey: w.lastKey, offset: w.offset, length: uint32(len(w.block) + checksumSize)}
+	if err := w.write(w.block); err.IsNotEmpty() {
+		return err
+	}
+	w.index = append(w.index, handle)
+	w.block = w.block[:0]
+	return lib.EmptyError()
+}
+
+// write appends data followed by its checksum.
+func (w *Writer) write(data []byte) lib.Error {
+	if _, err := w.out.Write(data); err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to write table %s: %w", w.path, err))
+	}
+	sum := binary.LittleEndian.AppendUint32(nil, crc32.Checksum(data, castagnoli))
+	if _, err := w.out.Write(sum); err != nil {
+		return lib.EmptyError().AddErr(lib.SSTableError, fmt.Errorf("failed to write table %s: %w", w.path, err))
+	}
+	w.offset += uint64(len(data) + checksumSize)
+	return lib.EmptyError()
+}
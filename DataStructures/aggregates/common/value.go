@@ -1,69 +1,181 @@
 package common
 
+import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// ValueType tags which field of a Value is meaningful. The numeric values
+// are stored on disk, so new types must be appended.
+type ValueType uint8
+
+const (
+	NullType ValueType = iota
+	BoolType
+	IntType
+	FloatType
+	StringType
+	BytesType
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case NullType:
+		return "null"
+	case BoolType:
+		return "bool"
+	case IntType:
+		return "int64"
+	case FloatType:
+		return "float64"
+	case StringType:
+		return "string"
+	case BytesType:
+		return "bytes"
+	}
+	return "ValueType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Value is a tagged union of the types a key can be stored with. The zero
+// Value is NULL.
 type Value struct {
-	stringValue string // maximum 8 bytes
-	boolValue   bool
-	floatValue  float32
-	intValue    int64
+	valueType  ValueType
+	boolValue  bool
+	intValue   int64
+	floatValue float64
+	// stringValue holds the payload of both strings and byte slices.
+	stringValue string
 }
 
+// EmptyValue returns NULL.
 func EmptyValue() Value {
 	return Value{}
 }
 
+func NewNullValue() Value {
+	return Value{}
+}
+
 func NewBoolValue(boolValue bool) Value {
-	return NewValue("", boolValue, 0, 0)
+	return Value{valueType: BoolType, boolValue: boolValue}
 }
 
 func NewIntValue(intValue int64) Value {
-	return NewValue("", false, 0, intValue)
+	return Value{valueType: IntType, intValue: intValue}
 }
 
-func NewFloatValue(floatValue float32) Value {
-	return NewValue("", false, floatValue, 0)
+func NewFloatValue(floatValue float64) Value {
+	return Value{valueType: FloatType, floatValue: floatValue}
 }
 
 func NewStringValue(stringValue string) Value {
-	return NewValue(stringValue, false, 0, 0)
+	return Value{valueType: StringType, stringValue: stringValue}
 }
 
+// NewBytesValue copies bytesValue, so the caller may reuse it.
+func NewBytesValue(bytesValue []byte) Value {
+	return Value{valueType: BytesType, stringValue: string(bytesValue)}
+}
 
-func NewValue(
-	stringValue string,
-	boolValue bool,
-	floatValue float32,
-	intValue int64,
-) Value {
-	return Value{
-		stringValue: stringValue,
-		boolValue:   boolValue,
-		floatValue:  floatValue,
-		intValue:    intValue,
-	}
+func (c Value) Type() ValueType {
+	return c.valueType
 }
 
+func (c Value) IsNull() bool {
+	return c.valueType == NullType
+}
 
-func (c Value) StringValue() string {
-	return c.stringValue
+// Len is the size in bytes of a string or bytes payload, 0 for other types.
+func (c Value) Len() int {
+	return len(c.stringValue)
 }
 
+// The accessors below return the zero value of their type when the Value
+// holds a different type.
+
 func (c Value) BoolValue() bool {
-	return c.boolValue
+	return c.valueType == BoolType && c.boolValue
 }
 
-func (c Value) FloatValue() float32 {
+func (c Value) IntValue() int64 {
+	if c.valueType != IntType {
+		return 0
+	}
+	return c.intValue
+}
+
+func (c Value) FloatValue() float64 {
+	if c.valueType != FloatType {
+		return 0
+	}
 	return c.floatValue
 }
 
-func (c Value) IntValue() int64 {
-	return c.intValue
+func (c Value) StringValue() string {
+	if c.valueType != StringType {
+		return ""
+	}
+	return c.stringValue
 }
 
+// BytesValue returns a copy of the payload of a bytes Value.
+func (c Value) BytesValue() []byte {
+	if c.valueType != BytesType {
+		return nil
+	}
+	return []byte(c.stringValue)
+}
 
-func (c Value) IsEmpty() bool {
-	return c.stringValue == "" &&
-		!c.boolValue &&
-		c.floatValue == 0 &&
-		c.intValue == 0
+// Equal reports whether both values have the same type and payload. NaN
+// floats are equal to each other, in line with Compare.
+func (c Value) Equal(other Value) bool {
+	return c.Compare(other) == 0
 }
 
+// Compare orders values first by type, in the order of the ValueType
+// constants, then by payload. NaN sorts before every other float.
+func (c Value) Compare(other Value) int {
+	if c.valueType != other.valueType {
+		return cmp.Compare(c.valueType, other.valueType)
+	}
+	switch c.valueType {
+	case BoolType:
+		switch {
+		case c.boolValue == other.boolValue:
+			return 0
+		case !c.boolValue:
+			return -1
+		}
+		return 1
+	case IntType:
+		return cmp.Compare(c.intValue, other.intValue)
+	case FloatType:
+		return cmp.Compare(c.floatValue, other.floatValue)
+	case StringType:
+		return strings.Compare(c.stringValue, other.stringValue)
+	case BytesType:
+		return bytes.Compare([]byte(c.stringValue), []byte(other.stringValue))
+	}
+	return 0
+}
+
+// String renders the value for people: NULL, true/false, numbers in their
+// shortest form, strings as is and bytes as 0x-prefixed hex.
+func (c Value) String() string {
+	switch c.valueType {
+	case BoolType:
+		return strconv.FormatBool(c.boolValue)
+	case IntType:
+		return strconv.FormatInt(c.intValue, 10)
+	case FloatType:
+		return strconv.FormatFloat(c.floatValue, 'g', -1, 64)
+	case StringType:
+		return c.stringValue
+	case BytesType:
+		return "0x" + hex.EncodeToString([]byte(c.stringValue))
+	}
+	return "NULL"
+}
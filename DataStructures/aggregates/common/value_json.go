@@ -0,0 +1,130 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// jsonValue is how a Value is written as JSON: its type next to the payload,
+// so that reading it back gives the same Value. Bytes are base64 and floats
+// that JSON has no number for are the strings "NaN", "+Inf" and "-Inf".
+type jsonValue struct {
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (c Value) MarshalJSON() ([]byte, error) {
+	var payload any
+	switch c.valueType {
+	case BoolType:
+		payload = c.boolValue
+	case IntType:
+		payload = c.intValue
+	case FloatType:
+		if math.IsNaN(c.floatValue) || math.IsInf(c.floatValue, 0) {
+			payload = strconv.FormatFloat(c.floatValue, 'g', -1, 64)
+		} else {
+			payload = c.floatValue
+		}
+	case StringType:
+		payload = c.stringValue
+	case BytesType:
+		payload = base64.StdEncoding.EncodeToString([]byte(c.stringValue))
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{Type: c.valueType.String(), Value: raw})
+}
+
+// UnmarshalJSON reads what MarshalJSON writes, and also a bare payload
+// without the object around it. Without a type the payload decides: a JSON
+// string is a string, a number an int64 if it is integral and a float64
+// otherwise.
+func (c *Value) UnmarshalJSON(data []byte) error {
+	var v jsonValue
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		v.Value = trimmed
+	} else if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	raw := bytes.TrimSpace(v.Value)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		if v.Type != "" && v.Type != NullType.String() {
+			return fmt.Errorf("value of type %s is missing", v.Type)
+		}
+		*c = NewNullValue()
+		return nil
+	}
+
+	typ := v.Type
+	if typ == "" {
+		switch raw[0] {
+		case '"':
+			typ = StringType.String()
+		case 't', 'f':
+			typ = BoolType.String()
+		default:
+			typ = FloatType.String()
+			if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+				typ = IntType.String()
+			}
+		}
+	}
+
+	switch typ {
+	case BoolType.String():
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return fmt.Errorf("bool value: %w", err)
+		}
+		*c = NewBoolValue(b)
+	case IntType.String():
+		n, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("int64 value: %w", err)
+		}
+		*c = NewIntValue(n)
+	case FloatType.String():
+		var f float64
+		if raw[0] == '"' {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("float64 value: %w", err)
+			}
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return fmt.Errorf("float64 value: %w", err)
+			}
+		} else if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("float64 value: %w", err)
+		}
+		*c = NewFloatValue(f)
+	case StringType.String():
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("string value: %w", err)
+		}
+		*c = NewStringValue(s)
+	case BytesType.String():
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("bytes value: %w", err)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("bytes value: %w", err)
+		}
+		*c = NewBytesValue(b)
+	case NullType.String():
+		return fmt.Errorf("null value has payload %s", raw)
+	default:
+		return fmt.Errorf("unknown value type %q", typ)
+	}
+	return nil
+}
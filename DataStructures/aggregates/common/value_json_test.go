@@ -0,0 +1,67 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestValueJSONRoundTrip(t *testing.T) {
+	values := []Value{
+		NewNullValue(),
+		NewBoolValue(true),
+		NewIntValue(math.MaxInt64),
+		NewIntValue(-3),
+		NewFloatValue(2.5),
+		NewFloatValue(3),
+		NewFloatValue(math.Inf(-1)),
+		NewFloatValue(math.NaN()),
+		NewStringValue("héllo \"world\""),
+		NewBytesValue([]byte{0, 1, 0xff}),
+	}
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("marshal %v: %v", value, err)
+		}
+		var got Value
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+		if got.Type() != value.Type() || !got.Equal(value) {
+			t.Fatalf("%s decoded to %s %v, want %s %v", data, got.Type(), got, value.Type(), value)
+		}
+	}
+}
+
+func TestValueJSONInfersType(t *testing.T) {
+	cases := []struct {
+		json string
+		want Value
+	}{
+		{`{"value": "x"}`, NewStringValue("x")},
+		{`{"value": 12}`, NewIntValue(12)},
+		{`{"value": 1.5}`, NewFloatValue(1.5)},
+		{`{"value": false}`, NewBoolValue(false)},
+		{`{"value": null}`, NewNullValue()},
+		{`"bare"`, NewStringValue("bare")},
+		{`7`, NewIntValue(7)},
+		{`{"type": "float64", "value": 7}`, NewFloatValue(7)},
+	}
+	for _, tc := range cases {
+		var got Value
+		if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", tc.json, err)
+		}
+		if got.Type() != tc.want.Type() || !got.Equal(tc.want) {
+			t.Fatalf("%s decoded to %s %v, want %s %v", tc.json, got.Type(), got, tc.want.Type(), tc.want)
+		}
+	}
+
+	for _, bad := range []string{`{"type": "int64", "value": 1.5}`, `{"type": "uuid", "value": "x"}`, `{"type": "bytes", "value": "!!"}`, `{"type": "int64"}`} {
+		var got Value
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Fatalf("unmarshal %s succeeded with %v", bad, got)
+		}
+	}
+}
@@ -7,7 +7,18 @@ import (
 
 type BaseDatabaseStructure interface {
 	Insert(primaryKey string, value common.Value) lib.Error
-	Search(primaryKey string) (common.Value, lib.Error)
+	Search(primaryKey string) (common.Value, bool, lib.Error)
 	Delete(primaryKey string) lib.Error
 	Init() lib.Error
 }
+
+// Iterator is what the range scans of the ordered engines return. Callers
+// advance it with Next until it returns false, then check Err, or stop early
+// with Close.
+type Iterator interface {
+	Next() bool
+	Key() string
+	Value() common.Value
+	Err() lib.Error
+	Close()
+}
@@ -0,0 +1,366 @@
+// Package conformance checks that an implementation of
+// datastructures.BaseDatabaseStructure behaves like a map that survives
+// being closed and reopened. Each engine runs the suite from a test of its
+// own:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Harness{Open: openMyEngine})
+//	}
+package conformance
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+const (
+	defaultLargeKeyCount = 20000
+	shortLargeKeyCount   = 2000
+	modelOperations      = 4000
+	modelKeySpace        = 500
+	modelReopenEvery     = 1000
+)
+
+// Harness tells the suite how to open the engine under test.
+type Harness struct {
+	// Open returns the engine stored in dir, before Init, and a function
+	// closing its files. Opening the same dir again must find everything
+	// that was committed before the close.
+	Open func(t *testing.T, dir string) (datastructures.BaseDatabaseStructure, func() lib.Error)
+	// LargeKeyCount is the number of keys of the large tests; 0 picks a
+	// default that is smaller under -short.
+	LargeKeyCount int
+}
+
+// Run runs every conformance test against the engine as a subtest.
+func Run(t *testing.T, h Harness) {
+	t.Run("MissingKey", h.testMissingKey)
+	t.Run("ValueTypes", h.testValueTypes)
+	t.Run("Overwrite", h.testOverwrite)
+	t.Run("Delete", h.testDelete)
+	t.Run("Reopen", h.testReopen)
+	t.Run("Model", h.testModel)
+	t.Run("AscendingKeys", func(t *testing.T) { h.testLarge(t, ascending) })
+	t.Run("DescendingKeys", func(t *testing.T) { h.testLarge(t, descending) })
+	t.Run("RandomKeys", func(t *testing.T) { h.testLarge(t, shuffled) })
+}
+
+// store is an opened engine along with the state it is checked against.
+type store struct {
+	t      *testing.T
+	engine datastructures.BaseDatabaseStructure
+	close  func() lib.Error
+	closed bool
+}
+
+func (h Harness) open(t *testing.T, dir string) *store {
+	t.Helper()
+	engine, closeFn := h.Open(t, dir)
+	if err := engine.Init(); err.IsNotEmpty() {
+		t.Fatalf("init: %v", err.Errors())
+	}
+	s := &store{t: t, engine: engine, close: closeFn}
+	t.Cleanup(func() {
+		if !s.closed {
+			s.close()
+		}
+	})
+	return s
+}
+
+// reopen closes the engine and opens it again from dir.
+func (h Harness) reopen(s *store, dir string) *store {
+	s.t.Helper()
+	s.closed = true
+	if err := s.close(); err.IsNotEmpty() {
+		s.t.Fatalf("close: %v", err.Errors())
+	}
+	return h.open(s.t, dir)
+}
+
+func (s *store) insert(key string, value common.Value) {
+	s.t.Helper()
+	if err := s.engine.Insert(key, value); err.IsNotEmpty() {
+		s.t.Fatalf("insert %q: %v", key, err.Errors())
+	}
+}
+
+func (s *store) delete(key string) {
+	s.t.Helper()
+	if err := s.engine.Delete(key); err.IsNotEmpty() {
+		s.t.Fatalf("delete %q: %v", key, err.Errors())
+	}
+}
+
+func (s *store) expect(key string, want common.Value) {
+	s.t.Helper()
+	got, found, err := s.engine.Search(key)
+	if err.IsNotEmpty() {
+		s.t.Fatalf("search %q: %v", key, err.Errors())
+	}
+	if !found {
+		s.t.Fatalf("search %q: not found, want %v", key, want)
+	}
+	if got.Type() != want.Type() || !got.Equal(want) {
+		s.t.Fatalf("search %q: got %s %v, want %s %v", key, got.Type(), got, want.Type(), want)
+	}
+}
+
+func (s *store) expectMissing(key string) {
+	s.t.Helper()
+	got, found, err := s.engine.Search(key)
+	if err.IsNotEmpty() {
+		s.t.Fatalf("search %q: %v", key, err.Errors())
+	}
+	if found {
+		s.t.Fatalf("search %q: found %v, want no entry", key, got)
+	}
+}
+
+// expectAll checks every key of model, in key order so failures are
+// reproducible.
+func (s *store) expectAll(model map[string]common.Value) {
+	s.t.Helper()
+	keys := make([]string, 0, len(model))
+	for key := range model {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.expect(key, model[key])
+	}
+}
+
+func (h Harness) testMissingKey(t *testing.T) {
+	s := h.open(t, t.TempDir())
+	s.expectMissing("missing")
+
+	s.insert("present", common.NewIntValue(1))
+	s.expectMissing("missing")
+	s.expectMissing("presen")
+	s.expectMissing("present0")
+}
+
+// testValueTypes stores one value of every type, including a NULL and a
+// zero that must not be mistaken for a missing key, and a string large
+// enough to be kept outside the page of its key.
+func (h Harness) testValueTypes(t *testing.T) {
+	dir := t.TempDir()
+	s := h.open(t, dir)
+	values := map[string]common.Value{
+		"null":         common.NewNullValue(),
+		"bool-true":    common.NewBoolValue(true),
+		"bool-false":   common.NewBoolValue(false),
+		"int":          common.NewIntValue(-42),
+		"int-zero":     common.NewIntValue(0),
+		"float":        common.NewFloatValue(3.25),
+		"string":       common.NewStringValue("hello"),
+		"string-empty": common.NewStringValue(""),
+		"string-large": common.NewStringValue(strings.Repeat("large value ", 1000)),
+		"bytes":        common.NewBytesValue([]byte{0, 1, 2, 255}),
+		"bytes-large":  common.NewBytesValue([]byte(strings.Repeat("\x00\xff", 3000))),
+	}
+	for key, value := range values {
+		s.insert(key, value)
+	}
+	s.expectAll(values)
+
+	s = h.reopen(s, dir)
+	s.expectAll(values)
+}
+
+func (h Harness) testOverwrite(t *testing.T) {
+	s := h.open(t, t.TempDir())
+	s.insert("key", common.NewIntValue(1))
+	s.insert("key", common.NewIntValue(2))
+	s.expect("key", common.NewIntValue(2))
+
+	// Overwrites may change the type and size of the value.
+	large := common.NewStringValue(strings.Repeat("x", 10000))
+	s.insert("key", large)
+	s.expect("key", large)
+	s.insert("key", common.NewStringValue("small"))
+	s.expect("key", common.NewStringValue("small"))
+	s.insert("key", common.NewNullValue())
+	s.expect("key", common.NewNullValue())
+}
+
+func (h Harness) testDelete(t *testing.T) {
+	s := h.open(t, t.TempDir())
+	s.insert("a", common.NewIntValue(1))
+	s.insert("b", common.NewStringValue(strings.Repeat("b", 10000)))
+	s.insert("c", common.NewIntValue(3))
+
+	s.delete("b")
+	s.expectMissing("b")
+	s.expect("a", common.NewIntValue(1))
+	s.expect("c", common.NewIntValue(3))
+
+	// Deleting a key that is not stored is not an error.
+	s.delete("b")
+	s.delete("never-inserted")
+
+	s.insert("b", common.NewIntValue(2))
+	s.expect("b", common.NewIntValue(2))
+
+	s.delete("a")
+	s.delete("b")
+	s.delete("c")
+	for _, key := range []string{"a", "b", "c"} {
+		s.expectMissing(key)
+	}
+}
+
+func (h Harness) testReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := h.open(t, dir)
+	model := make(map[string]common.Value)
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%04d", i)
+		model[key] = common.NewStringValue(strings.Repeat(key, 1+i%5))
+		s.insert(key, model[key])
+	}
+	for i := 0; i < 500; i += 3 {
+		key := fmt.Sprintf("key-%04d", i)
+		delete(model, key)
+		s.delete(key)
+	}
+
+	s = h.reopen(s, dir)
+	s.expectAll(model)
+	for i := 0; i < 500; i += 3 {
+		s.expectMissing(fmt.Sprintf("key-%04d", i))
+	}
+
+	// The reopened engine keeps accepting writes.
+	s.insert("key-0000", common.NewIntValue(7))
+	s = h.reopen(s, dir)
+	s.expect("key-0000", common.NewIntValue(7))
+}
+
+// testModel runs random inserts, overwrites and deletes over a small key
+// space against a map, reopening the engine now and then.
+func (h Harness) testModel(t *testing.T) {
+	dir := t.TempDir()
+	s := h.open(t, dir)
+	rnd := rand.New(rand.NewSource(1))
+	model := make(map[string]common.Value)
+
+	for op := 1; op <= modelOperations; op++ {
+		key := fmt.Sprintf("k%03d", rnd.Intn(modelKeySpace))
+		switch n := rnd.Intn(10); {
+		case n < 3:
+			s.delete(key)
+			delete(model, key)
+		case n < 4:
+			s.expectModel(key, model)
+		default:
+			value := randomValue(rnd)
+			s.insert(key, value)
+			model[key] = value
+		}
+		if op%modelReopenEvery == 0 {
+			s = h.reopen(s, dir)
+		}
+	}
+
+	s.expectAll(model)
+	for i := 0; i < modelKeySpace; i++ {
+		s.expectModel(fmt.Sprintf("k%03d", i), model)
+	}
+}
+
+func (s *store) expectModel(key string, model map[string]common.Value) {
+	s.t.Helper()
+	if value, ok := model[key]; ok {
+		s.expect(key, value)
+	} else {
+		s.expectMissing(key)
+	}
+}
+
+func randomValue(rnd *rand.Rand) common.Value {
+	switch rnd.Intn(6) {
+	case 0:
+		return common.NewNullValue()
+	case 1:
+		return common.NewBoolValue(rnd.Intn(2) == 1)
+	case 2:
+		return common.NewIntValue(rnd.Int63() - rnd.Int63())
+	case 3:
+		return common.NewFloatValue(rnd.NormFloat64())
+	case 4:
+		// Mostly small, sometimes past the overflow threshold.
+		size := rnd.Intn(64)
+		if rnd.Intn(10) == 0 {
+			size = 1000 + rnd.Intn(6000)
+		}
+		return common.NewStringValue(strings.Repeat("s", size))
+	}
+	payload := make([]byte, 1+rnd.Intn(64))
+	rnd.Read(payload)
+	return common.NewBytesValue(payload)
+}
+
+type keyOrder int
+
+const (
+	ascending keyOrder = iota
+	descending
+	shuffled
+)
+
+// testLarge stores enough keys for a tree to split across several levels,
+// deletes every other one so that nodes merge, and checks the result after
+// a reopen.
+func (h Harness) testLarge(t *testing.T, order keyOrder) {
+	count := h.LargeKeyCount
+	if count == 0 {
+		count = defaultLargeKeyCount
+		if testing.Short() {
+			count = shortLargeKeyCount
+		}
+	}
+
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("large-key-%08d", i)
+	}
+	switch order {
+	case descending:
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	case shuffled:
+		rnd := rand.New(rand.NewSource(int64(count)))
+		rnd.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	}
+
+	dir := t.TempDir()
+	s := h.open(t, dir)
+	model := make(map[string]common.Value, count)
+	for i, key := range keys {
+		model[key] = common.NewIntValue(int64(i))
+		s.insert(key, model[key])
+	}
+	s.expectAll(model)
+
+	for i, key := range keys {
+		if i%2 == 0 {
+			delete(model, key)
+			s.delete(key)
+		}
+	}
+	s = h.reopen(s, dir)
+	s.expectAll(model)
+	for i, key := range keys {
+		if i%2 == 0 {
+			s.expectMissing(key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Command dbfsck checks the structure of a B+ tree file.
+//
+//	dbfsck [-json] [-wal path] [-copy-on-write] file.db
+//
+// If the file has a write-ahead log, which is looked for next to it unless
+// -wal names one, the committed work in it is recovered into the file first,
+// exactly as opening the tree would. The exit status is 0 for a sound file,
+// 1 when violations were found and 2 when the file could not be checked.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/diskStorage/bufferpool"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+const bufferPoolFrames = 64
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	walPath := flag.String("wal", "", "write-ahead log of the file (default: file.wal, if it exists)")
+	copyOnWrite := flag.Bool("copy-on-write", false, "the file is written in copy-on-write mode, which does not keep leaf links")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: dbfsck [flags] file.db\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	report, err := check(flag.Arg(0), *walPath, *copyOnWrite)
+	if err.IsNotEmpty() {
+		fmt.Fprintf(os.Stderr, "dbfsck: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if e := encoder.Encode(report); e != nil {
+			fmt.Fprintf(os.Stderr, "dbfsck: %v\n", e)
+			os.Exit(2)
+		}
+	} else {
+		printReport(flag.Arg(0), report)
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func check(path, walPath string, copyOnWrite bool) (implementation.VerifyReport, lib.Error) {
+	// Refuse a missing file rather than let the pager create it.
+	if _, e := os.Stat(path); e != nil {
+		return implementation.VerifyReport{}, lib.EmptyError().AddErr(lib.InvalidInputError, e)
+	}
+	if walPath == "" {
+		if _, e := os.Stat(path + ".wal"); e == nil {
+			walPath = path + ".wal"
+		}
+	}
+
+	pageSize, err := pagination.StoredPageSize(path)
+	if err.IsNotEmpty() {
+		return implementation.VerifyReport{}, err
+	}
+	filePager, err := pagination.NewPager(path)
+	if err.IsNotEmpty() {
+		return implementation.VerifyReport{}, err
+	}
+	if pageSize != 0 {
+		if err := filePager.SetPageSize(pageSize); err.IsNotEmpty() {
+			filePager.Close()
+			return implementation.VerifyReport{}, err
+		}
+	}
+	var pager pagination.BasePagination = filePager
+	if walPath != "" {
+		pool := bufferpool.NewBufferPool(filePager, bufferPoolFrames, bufferpool.NewLRUKReplacer(bufferPoolFrames, 2))
+		walPager, err := wal.NewPager(pool, walPath)
+		if err.IsNotEmpty() {
+			pool.Close()
+			return implementation.VerifyReport{}, err
+		}
+		if err := walPager.Recover(); err.IsNotEmpty() {
+			walPager.Close()
+			return implementation.VerifyReport{}, err
+		}
+		pager = walPager
+	}
+	defer pager.Close()
+
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := implementation.NewBPlusTree(0, pager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	tree.SetCopyOnWrite(copyOnWrite)
+	return tree.Verify()
+}
+
+func printReport(path string, report implementation.VerifyReport) {
+	fmt.Printf("%s: root page %d, height %d, %d keys\n", path, report.RootPage, report.Height, report.Keys)
+	fmt.Printf("pages: %d in file, %d tree, %d overflow, %d free\n", report.PageCount, report.TreePages, report.OverflowPages, report.FreePages)
+	if report.OK() {
+		fmt.Println("no violations found")
+		return
+	}
+	fmt.Printf("%d violations:\n", len(report.Violations))
+	for _, violation := range report.Violations {
+		fmt.Printf("  page %-6d %-20s %s\n", violation.Page, violation.Kind, violation.Detail)
+	}
+}
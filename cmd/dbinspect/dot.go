@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+)
+
+// writeDot renders the tree reachable from the root as a Graphviz digraph:
+// internal nodes list their separators between child ports, leaves show
+// their key range, and dashed edges follow the leaf next links.
+func (in *inspector) writeDot(w io.Writer) {
+	fmt.Fprintln(w, "digraph btree {")
+	fmt.Fprintln(w, "\tnode [shape=record, fontname=\"monospace\"];")
+
+	var leaves []pagination.PageID
+	visited := make(map[pagination.PageID]bool)
+	queue := []pagination.PageID{in.header.RootPage}
+	for len(queue) > 0 && in.header.RootPage != 0 {
+		pageID := queue[0]
+		queue = queue[1:]
+		if visited[pageID] {
+			continue
+		}
+		visited[pageID] = true
+
+		tn, err := in.loadNode(pageID)
+		if err.IsNotEmpty() {
+			fmt.Fprintf(w, "\tp%d [label=\"page %d\\n(unreadable)\", color=red];\n", pageID, pageID)
+			continue
+		}
+		if tn.IsLeaf() {
+			leaves = append(leaves, pageID)
+			fmt.Fprintf(w, "\tp%d [label=\"%s\"];\n", pageID, leafLabel(tn))
+			if tn.Next() != 0 {
+				fmt.Fprintf(w, "\tp%d -> p%d [style=dashed, constraint=false];\n", pageID, tn.Next())
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "\tp%d [label=\"%s\"];\n", pageID, internalLabel(tn))
+		for i, child := range tn.ChildTreeNodes() {
+			fmt.Fprintf(w, "\tp%d:c%d -> p%d;\n", pageID, i, child)
+			queue = append(queue, child)
+		}
+	}
+
+	if len(leaves) > 0 {
+		fmt.Fprint(w, "\t{rank=same;")
+		for _, leaf := range leaves {
+			fmt.Fprintf(w, " p%d;", leaf)
+		}
+		fmt.Fprintln(w, "}")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// internalLabel is a record of the page ID over the separators, with a
+// port for each child between them.
+func internalLabel(tn *node.TreeNode) string {
+	fields := []string{"<c0>"}
+	for i, n := range tn.Nodes() {
+		fields = append(fields, escapeRecord(n.PrimaryKey()), fmt.Sprintf("<c%d>", i+1))
+	}
+	return fmt.Sprintf("{page %d|{%s}}", tn.PageID(), strings.Join(fields, "|"))
+}
+
+func leafLabel(tn *node.TreeNode) string {
+	nodes := tn.Nodes()
+	if len(nodes) == 0 {
+		return fmt.Sprintf("{page %d (leaf)|empty}", tn.PageID())
+	}
+	return fmt.Sprintf("{page %d (leaf)|%s … %s|%d keys}", tn.PageID(), escapeRecord(nodes[0].PrimaryKey()), escapeRecord(nodes[len(nodes)-1].PrimaryKey()), len(nodes))
+}
+
+// escapeRecord escapes the characters that are special in a record label.
+func escapeRecord(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '{', '}', '|', '<', '>', '"', '\\', ' ':
+			b.WriteRune('\\')
+		case '\n':
+			b.WriteString("\\n")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// pageKind is what a page is used for, as far as the tree, its overflow
+// chains and the free list tell.
+type pageKind string
+
+const (
+	headerPage   pageKind = "header"
+	internalPage pageKind = "internal"
+	leafPage     pageKind = "leaf"
+	overflowPage pageKind = "overflow"
+	freePage     pageKind = "free"
+	// unreachablePage is claimed by nothing; it may still decode as a node.
+	unreachablePage pageKind = "unreachable"
+)
+
+type headerReport struct {
+	Version           uint16            `json:"version"`
+	PageSize          uint32            `json:"page_size"`
+	RootPage          pagination.PageID `json:"root_page"`
+	FreeListHead      pagination.PageID `json:"free_list_head"`
+	FreePageCount     uint32            `json:"free_page_count"`
+	PageCount         uint32            `json:"page_count"`
+	LastCheckpointLSN uint64            `json:"last_checkpoint_lsn"`
+}
+
+type pageReport struct {
+	Page  pagination.PageID `json:"page"`
+	Kind  pageKind          `json:"kind"`
+	Node  *nodeReport       `json:"node,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+type nodeReport struct {
+	PageID    pagination.PageID   `json:"page_id"`
+	Leaf      bool                `json:"leaf"`
+	NodeCount int                 `json:"node_count"`
+	Next      pagination.PageID   `json:"next"`
+	Parent    pagination.PageID   `json:"parent"`
+	Entries   []entryReport       `json:"entries"`
+	Children  []pagination.PageID `json:"children,omitempty"`
+}
+
+type entryReport struct {
+	Key string `json:"key"`
+	// KeyOverflowPage is set for a key kept in an overflow chain.
+	KeyOverflowPage pagination.PageID `json:"key_overflow_page,omitempty"`
+	Type            string            `json:"type,omitempty"`
+	// Value is left out for separators and values in overflow chains.
+	Value          string            `json:"value,omitempty"`
+	OverflowPage   pagination.PageID `json:"overflow_page,omitempty"`
+	OverflowLength uint32            `json:"overflow_length,omitempty"`
+}
+
+type inspector struct {
+	tree   *implementation.BPlusTree
+	pager  pagination.BasePagination
+	header pagination.Header
+	kinds  map[pagination.PageID]pageKind
+}
+
+// newInspector reads the header and classifies every page it can reach
+// from the root and the free list.
+func newInspector(tree *implementation.BPlusTree, pager pagination.BasePagination) (*inspector, lib.Error) {
+	header, err := pagination.ReadHeader(pager)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	in := &inspector{
+		tree:   tree,
+		pager:  pager,
+		header: header,
+		kinds:  map[pagination.PageID]pageKind{0: headerPage},
+	}
+
+	// A broken free list or chain only leaves its pages unclassified.
+	if pages, err := pagination.FreePages(pager); err.IsEmpty() {
+		for _, page := range pages {
+			in.kinds[page] = freePage
+		}
+	}
+	queue := []pagination.PageID{header.RootPage}
+	for len(queue) > 0 && header.RootPage != 0 {
+		pageID := queue[0]
+		queue = queue[1:]
+		if _, seen := in.kinds[pageID]; seen || uint32(pageID) >= header.PageCount {
+			continue
+		}
+		tn, err := in.loadNode(pageID)
+		if err.IsNotEmpty() {
+			continue
+		}
+		for _, entry := range tn.Nodes() {
+			if entry.HasOverflowKey() {
+				in.markOverflow(entry.KeyOverflowPage())
+			}
+		}
+		if !tn.IsLeaf() {
+			in.kinds[pageID] = internalPage
+			queue = append(queue, tn.ChildTreeNodes()...)
+			continue
+		}
+		in.kinds[pageID] = leafPage
+		for _, entry := range tn.Nodes() {
+			if entry.HasOverflow() {
+				in.markOverflow(entry.OverflowPage())
+			}
+		}
+	}
+	return in, lib.EmptyError()
+}
+
+func (in *inspector) markOverflow(head pagination.PageID) {
+	chain, err := pagination.OverflowPages(in.pager, head)
+	if err.IsNotEmpty() {
+		return
+	}
+	for _, page := range chain {
+		in.kinds[page] = overflowPage
+	}
+}
+
+func (in *inspector) kind(pageID pagination.PageID) pageKind {
+	if kind, ok := in.kinds[pageID]; ok {
+		return kind
+	}
+	return unreachablePage
+}
+
+// loadNode decodes a page as a tree node. The serializer trusts its input,
+// so a page that is not a node may make it panic.
+func (in *inspector) loadNode(pageID pagination.PageID) (tn *node.TreeNode, err lib.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			tn, err = nil, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("page %d does not decode as a tree node: %v", pageID, r))
+		}
+	}()
+	return in.tree.LoadTreeNode(pageID)
+}
+
+func (in *inspector) headerReport() headerReport {
+	h := in.header
+	return headerReport{
+		Version:           h.Version,
+		PageSize:          h.PageSize,
+		RootPage:          h.RootPage,
+		FreeListHead:      h.FreeListHead,
+		FreePageCount:     h.FreePageCount,
+		PageCount:         h.PageCount,
+		LastCheckpointLSN: h.LastCheckpointLSN,
+	}
+}
+
+// pageReport describes one page. Nodes are decoded, and so is an
+// unreachable page if it looks like one.
+func (in *inspector) pageReport(pageID pagination.PageID) pageReport {
+	report := pageReport{Page: pageID, Kind: in.kind(pageID)}
+	switch report.Kind {
+	case internalPage, leafPage, unreachablePage:
+	default:
+		return report
+	}
+	tn, err := in.loadNode(pageID)
+	if err.IsNotEmpty() {
+		report.Error = err.Error()
+		return report
+	}
+	report.Node = nodeReportOf(tn)
+	return report
+}
+
+func nodeReportOf(tn *node.TreeNode) *nodeReport {
+	report := &nodeReport{
+		PageID:    tn.PageID(),
+		Leaf:      tn.IsLeaf(),
+		NodeCount: tn.NodesCount(),
+		Next:      tn.Next(),
+		Parent:    tn.ParentNode(),
+		Entries:   make([]entryReport, 0, tn.NodesCount()),
+	}
+	for _, n := range tn.Nodes() {
+		entry := entryReport{Key: n.PrimaryKey(), KeyOverflowPage: n.KeyOverflowPage()}
+		if tn.IsLeaf() {
+			entry.Type = n.Value().Type().String()
+			if n.HasOverflow() {
+				entry.OverflowPage, entry.OverflowLength = n.OverflowPage(), n.OverflowLength()
+			} else {
+				entry.Value = n.Value().String()
+			}
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	if !tn.IsLeaf() {
+		report.Children = tn.ChildTreeNodes()
+	}
+	return report
+}
+
+// parsePages parses a comma-separated list of page IDs and ranges such as
+// "1,4-7", or "all".
+func parsePages(spec string, pageCount uint32) ([]pagination.PageID, error) {
+	if spec == "all" {
+		pages := make([]pagination.PageID, pageCount)
+		for i := range pages {
+			pages[i] = pagination.PageID(i)
+		}
+		return pages, nil
+	}
+
+	seen := make(map[pagination.PageID]bool)
+	var pages []pagination.PageID
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.ParseUint(from, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad page %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.ParseUint(to, 10, 32); err != nil || last < first {
+				return nil, fmt.Errorf("bad page range %q", part)
+			}
+		}
+		if last >= uint64(pageCount) {
+			return nil, fmt.Errorf("page %d is past the end of the file, which has %d pages", last, pageCount)
+		}
+		for id := first; id <= last; id++ {
+			if !seen[pagination.PageID(id)] {
+				seen[pagination.PageID(id)] = true
+				pages = append(pages, pagination.PageID(id))
+			}
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	return pages, nil
+}
@@ -0,0 +1,162 @@
+// Command dbinspect decodes the pages of a B+ tree file for debugging.
+//
+//	dbinspect [-json] [-pages list] [-dot] file.db
+//
+// Without flags it prints the page-0 header and what each page is used for.
+// -pages decodes the listed pages, given as IDs and ranges like "1,4-7" or
+// as "all": the header fields of each node, its keys and values, and its
+// child page IDs. -dot prints the whole tree as a Graphviz digraph instead.
+//
+// The file is opened read-only. Work committed to a write-ahead log but not
+// yet checkpointed into the file is not shown.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+type report struct {
+	Header headerReport     `json:"header"`
+	Kinds  map[pageKind]int `json:"page_kinds,omitempty"`
+	Pages  []pageReport     `json:"pages,omitempty"`
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print JSON instead of text")
+	pageSpec := flag.String("pages", "", "pages to decode, e.g. \"3\", \"1,4-7\" or \"all\"")
+	dot := flag.Bool("dot", false, "print the tree as a Graphviz digraph")
+	checksums := flag.Bool("ignore-checksums", false, "decode pages whose checksum does not match")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: dbinspect [flags] file.db\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	// With -ignore-checksums a damaged header is read with the default page
+	// size.
+	pageSize, err := pagination.StoredPageSize(path)
+	if err.IsNotEmpty() && !*checksums {
+		fatalf("%v", err)
+	}
+	pager, err := pagination.NewReadOnlyPager(path)
+	if err.IsNotEmpty() {
+		fatalf("%v", err)
+	}
+	defer pager.Close()
+	if pageSize != 0 {
+		if err := pager.SetPageSize(pageSize); err.IsNotEmpty() {
+			fatalf("%v", err)
+		}
+	}
+	if *checksums {
+		pager.SetChecksumPolicy(pagination.ChecksumLogOnly)
+	}
+	if info, e := os.Stat(path + ".wal"); e == nil && info.Size() > wal.EmptyLogSize {
+		fmt.Fprintf(os.Stderr, "dbinspect: %s.wal is not empty; work committed since the last checkpoint is not shown\n", path)
+	}
+
+	binarySerializer := serialization.NewBinarySerializer()
+	tree := implementation.NewBPlusTree(0, pager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+	in, err := newInspector(tree, pager)
+	if err.IsNotEmpty() {
+		fatalf("%v", err)
+	}
+
+	if *dot {
+		in.writeDot(os.Stdout)
+		return
+	}
+
+	out := report{Header: in.headerReport()}
+	if *pageSpec == "" {
+		out.Kinds = make(map[pageKind]int)
+		for page := pagination.PageID(0); uint32(page) < in.header.PageCount; page++ {
+			out.Kinds[in.kind(page)]++
+		}
+	} else {
+		pages, e := parsePages(*pageSpec, in.header.PageCount)
+		if e != nil {
+			fatalf("%v", e)
+		}
+		for _, page := range pages {
+			out.Pages = append(out.Pages, in.pageReport(page))
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if e := encoder.Encode(out); e != nil {
+			fatalf("%v", e)
+		}
+		return
+	}
+	printReport(out)
+}
+
+func printReport(out report) {
+	h := out.Header
+	fmt.Printf("format version %d, %d byte pages, %d pages\n", h.Version, h.PageSize, h.PageCount)
+	fmt.Printf("root page %d, free list head %d (%d free pages), last checkpoint LSN %d\n", h.RootPage, h.FreeListHead, h.FreePageCount, h.LastCheckpointLSN)
+
+	if out.Kinds != nil {
+		kinds := make([]string, 0, len(out.Kinds))
+		for kind := range out.Kinds {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Printf("  %-12s %d\n", kind, out.Kinds[pageKind(kind)])
+		}
+	}
+
+	for _, page := range out.Pages {
+		fmt.Printf("\npage %d: %s\n", page.Page, page.Kind)
+		if page.Error != "" {
+			fmt.Printf("  error: %s\n", page.Error)
+		}
+		n := page.Node
+		if n == nil {
+			continue
+		}
+		fmt.Printf("  page id %d, leaf %t, %d nodes, next %d, parent %d\n", n.PageID, n.Leaf, n.NodeCount, n.Next, n.Parent)
+		for i, entry := range n.Entries {
+			key := fmt.Sprintf("%q", entry.Key)
+			if entry.KeyOverflowPage != 0 {
+				key += fmt.Sprintf(" (key in overflow chain at page %d)", entry.KeyOverflowPage)
+			}
+			switch {
+			case !n.Leaf:
+				fmt.Printf("  [%d] %s\n", i, key)
+			case entry.OverflowPage != 0:
+				fmt.Printf("  [%d] %s = %s in overflow chain at page %d, %d bytes\n", i, key, entry.Type, entry.OverflowPage, entry.OverflowLength)
+			default:
+				fmt.Printf("  [%d] %s = %s %s\n", i, key, entry.Type, entry.Value)
+			}
+		}
+		if len(n.Children) > 0 {
+			fmt.Printf("  children %v\n", n.Children)
+		}
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "dbinspect: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,85 @@
+// Command httpserver serves an engine over HTTP with JSON bodies; see
+// package httpapi for the endpoints.
+//
+//	httpserver [-addr host:port] [-engine btree|lsm|hash] path
+//
+// path is the database file, or the directory of an LSM tree. On SIGINT or
+// SIGTERM the server finishes the requests in flight, syncs and closes the
+// engine, and exits.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Kush/Database-internals/pkg/engine"
+	"github.com/Kush/Database-internals/server/httpapi"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	kindName := flag.String("engine", string(engine.BPlusTree), "storage engine: btree, lsm or hash")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: httpserver [flags] path\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	kind, err := engine.ParseKind(*kindName)
+	if err.IsNotEmpty() {
+		log.Fatalf("httpserver: %v", err)
+	}
+	e, err := engine.Open(kind, flag.Arg(0))
+	if err.IsNotEmpty() {
+		log.Fatalf("httpserver: open %s: %v", flag.Arg(0), err)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           httpapi.NewServer(e),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	served := make(chan error, 1)
+	go func() {
+		log.Printf("httpserver: serving %s engine %s on %s", kind, flag.Arg(0), *addr)
+		served <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	status := 0
+	select {
+	case <-signals:
+	case serveErr := <-served:
+		if !errors.Is(serveErr, http.ErrServerClosed) {
+			log.Printf("httpserver: %v", serveErr)
+			status = 1
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := server.Shutdown(ctx); shutdownErr != nil {
+		log.Printf("httpserver: shutdown: %v", shutdownErr)
+		status = 1
+	}
+	if err := e.Close(); err.IsNotEmpty() {
+		log.Printf("httpserver: close: %v", err)
+		status = 1
+	}
+	os.Exit(status)
+}
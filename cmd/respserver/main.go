@@ -0,0 +1,79 @@
+// Command respserver serves an engine over the Redis protocol.
+//
+//	respserver [-addr host:port] [-engine btree|lsm|hash] path
+//
+// path is the database file, or the directory of an LSM tree. On SIGINT or
+// SIGTERM the server finishes the commands it has received, syncs and
+// closes the engine, and exits.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Kush/Database-internals/pkg/engine"
+	"github.com/Kush/Database-internals/server/resp"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6380", "address to listen on")
+	kindName := flag.String("engine", string(engine.BPlusTree), "storage engine: btree, lsm or hash")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: respserver [flags] path\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	kind, err := engine.ParseKind(*kindName)
+	if err.IsNotEmpty() {
+		log.Fatalf("respserver: %v", err)
+	}
+	e, err := engine.Open(kind, flag.Arg(0))
+	if err.IsNotEmpty() {
+		log.Fatalf("respserver: open %s: %v", flag.Arg(0), err)
+	}
+
+	server := resp.NewServer(e)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		log.Printf("respserver: serving %s engine %s on %s", kind, flag.Arg(0), *addr)
+		if err := server.ListenAndServe(*addr); err.IsNotEmpty() {
+			log.Printf("respserver: %v", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	status := 0
+	select {
+	case <-signals:
+	case <-served:
+		// Serving only stops on its own when it failed.
+		status = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err.IsNotEmpty() {
+		log.Printf("respserver: shutdown: %v", err)
+		status = 1
+	}
+	if err := e.Close(); err.IsNotEmpty() {
+		log.Printf("respserver: close: %v", err)
+		status = 1
+	}
+	os.Exit(status)
+}
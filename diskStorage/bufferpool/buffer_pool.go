@@ -0,0 +1,342 @@
+package bufferpool
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Flushes   uint64
+}
+
+// BufferPool caches a bounded number of pages of the wrapped pager in frames.
+// Callers either pin frames explicitly with FetchPage/UnpinPage or go through
+// the pagination.BasePagination methods, which pin for the duration of a copy.
+// Dirty frames are written back on eviction, Sync and Close.
+//
+// The latch is released while a miss writes back its victim and reads the
+// page in; the frame is marked loading meanwhile, and anyone wanting either
+// page waits on loaded until the I/O is done.
+type BufferPool struct {
+	mu     sync.Mutex
+	loaded *sync.Cond
+	// allocMu makes the header and free-list updates of AllocatePage and
+	// FreePage atomic; they span several page reads and writes.
+	allocMu    sync.Mutex
+	pager      pagination.BasePagination
+	frames     []*Frame
+	pageTable  map[pagination.PageID]FrameID
+	freeFrames []FrameID
+	replacer   Replacer
+	stats      Stats
+	// extent counts pages that only exist as dirty frames past the end of
+	// the wrapped file so far.
+	extent int
+	// writeBacks counts evicted dirty pages still being written back, which
+	// Sync and Close must wait for.
+	writeBacks int
+}
+
+var _ pagination.BasePagination = (*BufferPool)(nil)
+
+func NewBufferPool(pager pagination.BasePagination, capacity int, replacer Replacer) *BufferPool {
+	frames := make([]*Frame, capacity)
+	freeFrames := make([]FrameID, 0, capacity)
+	for i := range frames {
+		frames[i] = newFrame(pager.PageSize())
+		freeFrames = append(freeFrames, FrameID(i))
+	}
+	bp := &BufferPool{
+		pager:      pager,
+		frames:     frames,
+		pageTable:  make(map[pagination.PageID]FrameID, capacity),
+		freeFrames: freeFrames,
+		replacer:   replacer,
+	}
+	bp.loaded = sync.NewCond(&bp.mu)
+	return bp
+}
+
+// FetchPage pins the page in a frame, reading it from the pager on a miss.
+// Every successful FetchPage must be paired with an UnpinPage.
+func (bp *BufferPool) FetchPage(id pagination.PageID) (*Frame, lib.Error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return bp.fetchPage(id, true)
+}
+
+func (bp *BufferPool) UnpinPage(id pagination.PageID, dirty bool) lib.Error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return bp.unpinPage(id, dirty)
+}
+
+func (bp *BufferPool) FlushPage(id pagination.PageID) lib.Error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, ok := bp.resident(id)
+	if !ok {
+		return lib.EmptyError()
+	}
+	return bp.flushFrame(frame)
+}
+
+func (bp *BufferPool) FlushAll() lib.Error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return bp.flushAll()
+}
+
+func (bp *BufferPool) Stats() Stats {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return bp.stats
+}
+
+func (bp *BufferPool) ReadPage(id pagination.PageID) ([]byte, lib.Error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, err := bp.fetchPage(id, true)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf := make([]byte, len(frame.data))
+	copy(buf, frame.data)
+
+	return buf, bp.unpinPage(id, false)
+}
+
+// WritePage overwrites the leading len(data) bytes of the page, keeping the
+// rest of the page image as it was, which matches the pager's WriteAt semantics.
+func (bp *BufferPool) WritePage(id pagination.PageID, data []byte) lib.Error {
+	if len(data) > bp.pager.PageSize() {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write page: data length %d exceeds page size %d", len(data), bp.pager.PageSize()))
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, err := bp.fetchPage(id, int(id) < bp.pager.NumPages())
+	if err.IsNotEmpty() {
+		return err
+	}
+	copy(frame.data, data)
+	bp.extent = max(bp.extent, int(id)+1)
+
+	return bp.unpinPage(id, true)
+}
+
+func (bp *BufferPool) NumPages() int {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	return max(bp.extent, bp.pager.NumPages())
+}
+
+func (bp *BufferPool) PageSize() int {
+	return bp.pager.PageSize()
+}
+
+func (bp *BufferPool) AllocatePage() (pagination.PageID, lib.Error) {
+	bp.allocMu.Lock()
+	defer bp.allocMu.Unlock()
+
+	return pagination.AllocatePage(bp)
+}
+
+func (bp *BufferPool) FreePage(id pagination.PageID) lib.Error {
+	bp.allocMu.Lock()
+	defer bp.allocMu.Unlock()
+
+	return pagination.FreePage(bp, id)
+}
+
+func (bp *BufferPool) FreePageCount() (int, lib.Error) {
+	return pagination.FreePageCount(bp)
+}
+
+func (bp *BufferPool) Sync() lib.Error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if err := bp.flushAll(); err.IsNotEmpty() {
+		return err
+	}
+	return bp.pager.Sync()
+}
+
+func (bp *BufferPool) Close() lib.Error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if err := bp.flushAll(); err.IsNotEmpty() {
+		return err
+	}
+	if err := bp.pager.Sync(); err.IsNotEmpty() {
+		return err
+	}
+	return bp.pager.Close()
+}
+
+// fetchPage pins id in a frame. When load is false the page is known not to
+// exist on disk yet and the frame starts out zeroed. On a miss the latch is
+// released while the victim is written back and the page is read.
+func (bp *BufferPool) fetchPage(id pagination.PageID, load bool) (*Frame, lib.Error) {
+	if frame, ok := bp.resident(id); ok {
+		frameID := bp.pageTable[id]
+		bp.stats.Hits++
+		frame.pinCount++
+		bp.replacer.RecordAccess(frameID)
+		bp.replacer.SetEvictable(frameID, false)
+		return frame, lib.EmptyError()
+	}
+
+	bp.stats.Misses++
+	frameID, evicted, err := bp.victimFrame()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	frame := bp.frames[frameID]
+
+	// The frame is out of the replacer and mapped under both pages while it
+	// is loading, so nobody else touches it or loads either page meanwhile.
+	victim, writeBack := frame.pageID, frame.dirty
+	frame.loading = true
+	frame.dirty = false
+	bp.pageTable[id] = frameID
+	if writeBack {
+		bp.writeBacks++
+	}
+	bp.mu.Unlock()
+
+	writeErr, readErr := lib.EmptyError(), lib.EmptyError()
+	if writeBack {
+		writeErr = bp.pager.WritePage(victim, frame.data)
+	}
+	var buf []byte
+	if load && writeErr.IsEmpty() {
+		buf, readErr = bp.pager.ReadPage(id)
+	}
+
+	bp.mu.Lock()
+	defer bp.loaded.Broadcast()
+	frame.loading = false
+	if writeBack {
+		bp.writeBacks--
+	}
+	delete(bp.pageTable, id)
+
+	if writeErr.IsNotEmpty() {
+		// The victim stays resident, still dirty.
+		frame.dirty = true
+		bp.replacer.RecordAccess(frameID)
+		bp.replacer.SetEvictable(frameID, true)
+		return nil, writeErr
+	}
+	if writeBack {
+		bp.stats.Flushes++
+	}
+	if evicted {
+		delete(bp.pageTable, victim)
+		bp.stats.Evictions++
+	}
+	frame.reset()
+	if readErr.IsNotEmpty() {
+		bp.freeFrames = append(bp.freeFrames, frameID)
+		return nil, readErr
+	}
+	copy(frame.data, buf)
+
+	frame.pageID = id
+	frame.pinCount = 1
+	bp.pageTable[id] = frameID
+	bp.replacer.RecordAccess(frameID)
+	bp.replacer.SetEvictable(frameID, false)
+
+	return frame, lib.EmptyError()
+}
+
+// resident returns the frame holding id, first waiting out any I/O on it.
+func (bp *BufferPool) resident(id pagination.PageID) (*Frame, bool) {
+	for {
+		frameID, ok := bp.pageTable[id]
+		if !ok {
+			return nil, false
+		}
+		if frame := bp.frames[frameID]; !frame.loading {
+			return frame, true
+		}
+		bp.loaded.Wait()
+	}
+}
+
+func (bp *BufferPool) unpinPage(id pagination.PageID, dirty bool) lib.Error {
+	frameID, ok := bp.pageTable[id]
+	if !ok {
+		return lib.EmptyError().AddErr(lib.BufferPoolError, fmt.Errorf("unpin page %d: page is not resident", id))
+	}
+	frame := bp.frames[frameID]
+	if frame.pinCount == 0 {
+		return lib.EmptyError().AddErr(lib.BufferPoolError, fmt.Errorf("unpin page %d: page is not pinned", id))
+	}
+
+	frame.dirty = frame.dirty || dirty
+	frame.pinCount--
+	if frame.pinCount == 0 {
+		bp.replacer.SetEvictable(frameID, true)
+	}
+	return lib.EmptyError()
+}
+
+// victimFrame returns a free frame, or else evicts one from the replacer. An
+// evicted frame still holds its page, which the caller must write back if the
+// frame is dirty and then drop from the page table.
+func (bp *BufferPool) victimFrame() (FrameID, bool, lib.Error) {
+	if n := len(bp.freeFrames); n > 0 {
+		frameID := bp.freeFrames[n-1]
+		bp.freeFrames = bp.freeFrames[:n-1]
+		return frameID, false, lib.EmptyError()
+	}
+
+	frameID, ok := bp.replacer.Evict()
+	if !ok {
+		return 0, false, lib.EmptyError().AddErr(lib.BufferPoolError, fmt.Errorf("all %d frames are pinned", len(bp.frames)))
+	}
+	return frameID, true, lib.EmptyError()
+}
+
+func (bp *BufferPool) flushFrame(frame *Frame) lib.Error {
+	if !frame.dirty {
+		return lib.EmptyError()
+	}
+	if err := bp.pager.WritePage(frame.pageID, frame.data); err.IsNotEmpty() {
+		return err
+	}
+	frame.dirty = false
+	bp.stats.Flushes++
+	return lib.EmptyError()
+}
+
+func (bp *BufferPool) flushAll() lib.Error {
+	for bp.writeBacks > 0 {
+		bp.loaded.Wait()
+	}
+	for _, frameID := range bp.pageTable {
+		if err := bp.flushFrame(bp.frames[frameID]); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
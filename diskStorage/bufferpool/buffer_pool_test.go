@@ -0,0 +1,206 @@
+package bufferpool
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// countingPager counts the page writes that reach the file and can hold up
+// the reads of one page until released.
+type countingPager struct {
+	*pagination.Pager
+	mu      sync.Mutex
+	writes  map[pagination.PageID]int
+	blocked pagination.PageID
+	stuck   chan struct{}
+	release chan struct{}
+}
+
+func (p *countingPager) ReadPage(id pagination.PageID) ([]byte, lib.Error) {
+	p.mu.Lock()
+	release := p.release
+	if id != p.blocked {
+		release = nil
+	}
+	p.mu.Unlock()
+	if release != nil {
+		p.stuck <- struct{}{}
+		<-release
+	}
+	return p.Pager.ReadPage(id)
+}
+
+func (p *countingPager) WritePage(id pagination.PageID, data []byte) lib.Error {
+	p.mu.Lock()
+	p.writes[id]++
+	p.mu.Unlock()
+	return p.Pager.WritePage(id, data)
+}
+
+func (p *countingPager) writeCount(id pagination.PageID) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.writes[id]
+}
+
+// newTestPool opens a pool over a file holding pages 0 to pages-1, where page
+// id is filled with byte id+1.
+func newTestPool(t *testing.T, capacity, pages int) (*BufferPool, *countingPager) {
+	t.Helper()
+	filePager, err := pagination.NewPager(filepath.Join(t.TempDir(), "pool.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	for id := 0; id < pages; id++ {
+		if err := filePager.WritePage(pagination.PageID(id), page(byte(id+1))); err.IsNotEmpty() {
+			t.Fatalf("write page %d: %v", id, err.Errors())
+		}
+	}
+	pager := &countingPager{Pager: filePager, writes: map[pagination.PageID]int{}}
+	pool := NewBufferPool(pager, capacity, NewLRUReplacer(capacity))
+	t.Cleanup(func() { pool.Close() })
+	return pool, pager
+}
+
+// page returns page contents filled with b, leaving room for the trailer.
+func page(b byte) []byte {
+	return bytes.Repeat([]byte{b}, pagination.PageSize-pagination.PageTrailerSize)
+}
+
+func hasContents(data []byte, b byte) bool {
+	return bytes.HasPrefix(data, page(b))
+}
+
+func TestPinnedFramesAreNotEvicted(t *testing.T) {
+	pool, _ := newTestPool(t, 2, 3)
+
+	a, err := pool.FetchPage(1)
+	if err.IsNotEmpty() {
+		t.Fatalf("fetch: %v", err.Errors())
+	}
+	b, err := pool.FetchPage(2)
+	if err.IsNotEmpty() {
+		t.Fatalf("fetch: %v", err.Errors())
+	}
+	if a.PinCount() != 1 || !hasContents(a.Data(), 2) || !hasContents(b.Data(), 3) {
+		t.Fatalf("unexpected frames: pin count %d", a.PinCount())
+	}
+	if _, err := pool.FetchPage(0); !err.ContainsError(lib.BufferPoolError) {
+		t.Fatalf("fetch with every frame pinned: got %v, want a buffer pool error", err.Errors())
+	}
+
+	if err := pool.UnpinPage(1, false); err.IsNotEmpty() {
+		t.Fatalf("unpin: %v", err.Errors())
+	}
+	if err := pool.UnpinPage(1, false); !err.ContainsError(lib.BufferPoolError) {
+		t.Fatalf("second unpin: got %v, want a buffer pool error", err.Errors())
+	}
+	frame, err := pool.FetchPage(0)
+	if err.IsNotEmpty() {
+		t.Fatalf("fetch after unpin: %v", err.Errors())
+	}
+	if !hasContents(frame.Data(), 1) || b.PageID() != 2 || !hasContents(b.Data(), 3) {
+		t.Fatal("evicted the pinned frame")
+	}
+	pool.UnpinPage(0, false)
+	pool.UnpinPage(2, false)
+}
+
+func TestDirtyPagesAreFlushedOnEviction(t *testing.T) {
+	pool, pager := newTestPool(t, 2, 4)
+	pool.WritePage(0, page(9))
+	pool.WritePage(1, page(8))
+	if pager.writeCount(0) != 0 {
+		t.Fatal("a dirty page was written before it was evicted")
+	}
+
+	// Page 0 is the least recently used, so reading page 2 evicts it.
+	if _, err := pool.ReadPage(2); err.IsNotEmpty() {
+		t.Fatalf("read page 2: %v", err.Errors())
+	}
+	if pager.writeCount(0) != 1 || pager.writeCount(1) != 0 {
+		t.Fatalf("writes %v, want page 0 written back once", pager.writes)
+	}
+	data, err := pool.ReadPage(0)
+	if err.IsNotEmpty() {
+		t.Fatalf("read page 0: %v", err.Errors())
+	}
+	if !hasContents(data, 9) {
+		t.Fatal("page 0 lost its contents on eviction")
+	}
+
+	// Page 2 is clean, so evicting it writes nothing.
+	if _, err := pool.ReadPage(3); err.IsNotEmpty() {
+		t.Fatalf("read page 3: %v", err.Errors())
+	}
+	if pager.writeCount(2) != 0 {
+		t.Fatalf("a clean page was written back: %v", pager.writes)
+	}
+}
+
+func TestStats(t *testing.T) {
+	pool, _ := newTestPool(t, 2, 4)
+	pool.ReadPage(0)           // miss
+	pool.ReadPage(0)           // hit
+	pool.WritePage(0, page(9)) // hit
+	pool.ReadPage(1)           // miss
+	pool.ReadPage(2)           // miss, evicts and flushes page 0
+	pool.ReadPage(3)           // miss, evicts page 1
+	pool.WritePage(3, page(7)) // hit
+	if err := pool.FlushAll(); err.IsNotEmpty() {
+		t.Fatalf("flush: %v", err.Errors())
+	}
+
+	want := Stats{Hits: 3, Misses: 4, Evictions: 2, Flushes: 2}
+	if got := pool.Stats(); got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+}
+
+func TestMissDoesNotHoldTheLatchAcrossIO(t *testing.T) {
+	pool, pager := newTestPool(t, 4, 2)
+	pager.mu.Lock()
+	pager.blocked, pager.stuck, pager.release = 1, make(chan struct{}, 1), make(chan struct{})
+	pager.mu.Unlock()
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = pool.ReadPage(1)
+		}()
+	}
+	<-pager.stuck
+
+	// Page 0 stays readable while page 1 is stuck loading.
+	done := make(chan struct{})
+	go func() {
+		pool.ReadPage(0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a miss on another page waited for the stuck read")
+	}
+
+	close(pager.release)
+	wg.Wait()
+	for _, data := range results {
+		if !hasContents(data, 2) {
+			t.Fatal("a reader waiting on a loading page got the wrong contents")
+		}
+	}
+	// The second reader of page 1 waits for the first one's load.
+	if stats := pool.Stats(); stats.Misses != 2 || stats.Hits != 1 {
+		t.Fatalf("stats %+v, want one miss per page and one hit", stats)
+	}
+}
@@ -0,0 +1,74 @@
+package bufferpool
+
+// ClockReplacer approximates LRU with a single reference bit per frame and a
+// hand sweeping over the frames, giving each referenced frame a second chance.
+type ClockReplacer struct {
+	present    []bool
+	referenced []bool
+	evictable  []bool
+	hand       int
+	size       int
+}
+
+func NewClockReplacer(capacity int) *ClockReplacer {
+	return &ClockReplacer{
+		present:    make([]bool, capacity),
+		referenced: make([]bool, capacity),
+		evictable:  make([]bool, capacity),
+	}
+}
+
+func (r *ClockReplacer) RecordAccess(frameID FrameID) {
+	r.present[frameID] = true
+	r.referenced[frameID] = true
+}
+
+func (r *ClockReplacer) SetEvictable(frameID FrameID, evictable bool) {
+	if !r.present[frameID] || r.evictable[frameID] == evictable {
+		return
+	}
+	r.evictable[frameID] = evictable
+	if evictable {
+		r.size++
+	} else {
+		r.size--
+	}
+}
+
+func (r *ClockReplacer) Evict() (FrameID, bool) {
+	if r.size == 0 {
+		return 0, false
+	}
+	// Two full sweeps are enough: the first clears reference bits, the second
+	// is guaranteed to find an evictable frame with its bit cleared.
+	for i := 0; i < 2*len(r.present); i++ {
+		frameID := FrameID(r.hand)
+		r.hand = (r.hand + 1) % len(r.present)
+		if !r.present[frameID] || !r.evictable[frameID] {
+			continue
+		}
+		if r.referenced[frameID] {
+			r.referenced[frameID] = false
+			continue
+		}
+		r.Remove(frameID)
+		return frameID, true
+	}
+	return 0, false
+}
+
+func (r *ClockReplacer) Remove(frameID FrameID) {
+	if !r.present[frameID] {
+		return
+	}
+	if r.evictable[frameID] {
+		r.size--
+	}
+	r.present[frameID] = false
+	r.referenced[frameID] = false
+	r.evictable[frameID] = false
+}
+
+func (r *ClockReplacer) Size() int {
+	return r.size
+}
@@ -0,0 +1,46 @@
+package bufferpool
+
+import "github.com/Kush/Database-internals/diskStorage/pagination"
+
+type FrameID int
+
+type Frame struct {
+	pageID   pagination.PageID
+	data     []byte
+	pinCount int
+	dirty    bool
+	// loading is set while a miss writes back the frame's old page and reads
+	// the new one with the buffer pool latch released.
+	loading bool
+}
+
+func newFrame(pageSize int) *Frame {
+	return &Frame{
+		data: make([]byte, pageSize),
+	}
+}
+
+func (f *Frame) PageID() pagination.PageID {
+	return f.pageID
+}
+
+// Data returns the in-memory page image. It is only safe to use while the
+// frame is pinned.
+func (f *Frame) Data() []byte {
+	return f.data
+}
+
+func (f *Frame) PinCount() int {
+	return f.pinCount
+}
+
+func (f *Frame) IsDirty() bool {
+	return f.dirty
+}
+
+func (f *Frame) reset() {
+	f.pageID = 0
+	f.pinCount = 0
+	f.dirty = false
+	clear(f.data)
+}
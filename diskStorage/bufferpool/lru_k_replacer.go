@@ -0,0 +1,79 @@
+package bufferpool
+
+import "math"
+
+// LRUKReplacer evicts the frame whose k-th most recent access lies furthest
+// in the past. Frames with fewer than k recorded accesses have an infinite
+// backward k-distance and are evicted first, oldest first access breaking ties.
+// This keeps pages touched once by a scan from pushing out the hot upper
+// levels of the tree.
+type LRUKReplacer struct {
+	k         int
+	clock     uint64
+	history   map[FrameID][]uint64
+	evictable map[FrameID]bool
+}
+
+func NewLRUKReplacer(capacity int, k int) *LRUKReplacer {
+	if k < 1 {
+		k = 1
+	}
+	return &LRUKReplacer{
+		k:         k,
+		history:   make(map[FrameID][]uint64, capacity),
+		evictable: make(map[FrameID]bool, capacity),
+	}
+}
+
+func (r *LRUKReplacer) RecordAccess(frameID FrameID) {
+	r.clock++
+	accesses := append(r.history[frameID], r.clock)
+	if len(accesses) > r.k {
+		accesses = accesses[len(accesses)-r.k:]
+	}
+	r.history[frameID] = accesses
+}
+
+func (r *LRUKReplacer) SetEvictable(frameID FrameID, evictable bool) {
+	if _, ok := r.history[frameID]; !ok {
+		return
+	}
+	if evictable {
+		r.evictable[frameID] = true
+		return
+	}
+	delete(r.evictable, frameID)
+}
+
+func (r *LRUKReplacer) Evict() (FrameID, bool) {
+	var (
+		victim       FrameID
+		found        bool
+		bestDistance uint64
+		bestEarliest uint64 = math.MaxUint64
+	)
+	for frameID := range r.evictable {
+		accesses := r.history[frameID]
+		distance := uint64(math.MaxUint64)
+		if len(accesses) >= r.k {
+			distance = r.clock - accesses[0]
+		}
+		earliest := accesses[0]
+		if !found || distance > bestDistance || (distance == bestDistance && earliest < bestEarliest) {
+			victim, bestDistance, bestEarliest, found = frameID, distance, earliest, true
+		}
+	}
+	if found {
+		r.Remove(victim)
+	}
+	return victim, found
+}
+
+func (r *LRUKReplacer) Remove(frameID FrameID) {
+	delete(r.history, frameID)
+	delete(r.evictable, frameID)
+}
+
+func (r *LRUKReplacer) Size() int {
+	return len(r.evictable)
+}
@@ -0,0 +1,60 @@
+package bufferpool
+
+import "container/list"
+
+// LRUReplacer evicts the evictable frame that was accessed least recently.
+type LRUReplacer struct {
+	order     *list.List
+	elements  map[FrameID]*list.Element
+	evictable map[FrameID]bool
+}
+
+func NewLRUReplacer(capacity int) *LRUReplacer {
+	return &LRUReplacer{
+		order:     list.New(),
+		elements:  make(map[FrameID]*list.Element, capacity),
+		evictable: make(map[FrameID]bool, capacity),
+	}
+}
+
+func (r *LRUReplacer) RecordAccess(frameID FrameID) {
+	if elem, ok := r.elements[frameID]; ok {
+		r.order.MoveToBack(elem)
+		return
+	}
+	r.elements[frameID] = r.order.PushBack(frameID)
+}
+
+func (r *LRUReplacer) SetEvictable(frameID FrameID, evictable bool) {
+	if _, ok := r.elements[frameID]; !ok {
+		return
+	}
+	if evictable {
+		r.evictable[frameID] = true
+		return
+	}
+	delete(r.evictable, frameID)
+}
+
+func (r *LRUReplacer) Evict() (FrameID, bool) {
+	for elem := r.order.Front(); elem != nil; elem = elem.Next() {
+		frameID := elem.Value.(FrameID)
+		if r.evictable[frameID] {
+			r.Remove(frameID)
+			return frameID, true
+		}
+	}
+	return 0, false
+}
+
+func (r *LRUReplacer) Remove(frameID FrameID) {
+	if elem, ok := r.elements[frameID]; ok {
+		r.order.Remove(elem)
+		delete(r.elements, frameID)
+	}
+	delete(r.evictable, frameID)
+}
+
+func (r *LRUReplacer) Size() int {
+	return len(r.evictable)
+}
@@ -0,0 +1,12 @@
+package bufferpool
+
+// Replacer picks the frame to evict when the buffer pool is full. Only frames
+// marked evictable (i.e. unpinned) may be returned by Evict. Replacers are
+// always called with the buffer pool latch held.
+type Replacer interface {
+	RecordAccess(frameID FrameID)
+	SetEvictable(frameID FrameID, evictable bool)
+	Evict() (FrameID, bool)
+	Remove(frameID FrameID)
+	Size() int
+}
@@ -0,0 +1,86 @@
+package bufferpool
+
+import "testing"
+
+// access records an access to each frame and marks it evictable.
+func access(r Replacer, frameIDs ...FrameID) {
+	for _, frameID := range frameIDs {
+		r.RecordAccess(frameID)
+		r.SetEvictable(frameID, true)
+	}
+}
+
+func evictAll(t *testing.T, r Replacer) []FrameID {
+	t.Helper()
+	var order []FrameID
+	for r.Size() > 0 {
+		frameID, ok := r.Evict()
+		if !ok {
+			t.Fatalf("evict failed with size %d", r.Size())
+		}
+		order = append(order, frameID)
+	}
+	if _, ok := r.Evict(); ok {
+		t.Fatal("evicted from an empty replacer")
+	}
+	return order
+}
+
+func assertOrder(t *testing.T, got []FrameID, want ...FrameID) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("eviction order %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("eviction order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLRUReplacer(t *testing.T) {
+	r := NewLRUReplacer(4)
+	access(r, 0, 1, 2, 3)
+	r.RecordAccess(0)
+	r.SetEvictable(2, false)
+	r.Remove(3)
+	if r.Size() != 2 {
+		t.Fatalf("size %d, want 2", r.Size())
+	}
+	assertOrder(t, evictAll(t, r), 1, 0)
+
+	r.SetEvictable(2, true)
+	assertOrder(t, evictAll(t, r), 2)
+}
+
+func TestClockReplacer(t *testing.T) {
+	r := NewClockReplacer(4)
+	access(r, 0, 1, 2, 3)
+	r.SetEvictable(1, false)
+
+	// Every frame is referenced, so the first sweep clears the bits and the
+	// second evicts in hand order.
+	frameID, ok := r.Evict()
+	if !ok || frameID != 0 {
+		t.Fatalf("evicted %d, %v, want 0", frameID, ok)
+	}
+	// A frame accessed again gets a second chance.
+	r.RecordAccess(2)
+	assertOrder(t, evictAll(t, r), 3, 2)
+
+	r.SetEvictable(1, true)
+	assertOrder(t, evictAll(t, r), 1)
+}
+
+func TestLRUKReplacer(t *testing.T) {
+	r := NewLRUKReplacer(4, 2)
+	// Frames 0 and 1 are hot; 2 and 3 are touched once, as by a scan.
+	access(r, 0, 1, 0, 1, 2, 3)
+	// Frames short of k accesses go first, oldest first; then the frame
+	// whose second to last access is oldest.
+	assertOrder(t, evictAll(t, r), 2, 3, 0, 1)
+
+	access(r, 0, 1, 1, 0)
+	r.Remove(0)
+	assertOrder(t, evictAll(t, r), 1)
+}
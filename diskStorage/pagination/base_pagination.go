@@ -6,7 +6,21 @@ type BasePagination interface {
 	ReadPage(id PageID) ([]byte, lib.Error)
 	WritePage(id PageID, data []byte) lib.Error
 	NumPages() int
+	PageSize() int
 	AllocatePage() (PageID, lib.Error)
+	FreePage(id PageID) lib.Error
+	FreePageCount() (int, lib.Error)
 	Close() lib.Error
 	Sync() lib.Error
 }
+
+// LoggedPagination is implemented by pagers that stage writes in a redo log.
+// Sync commits everything written since the previous Sync as one atomic unit
+// and Rollback discards it. Recover replays committed work after a crash and
+// must run before the file is read.
+type LoggedPagination interface {
+	BasePagination
+	Recover() lib.Error
+	Rollback() lib.Error
+	Checkpoint() lib.Error
+}
@@ -0,0 +1,57 @@
+package pagination
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Every page ends in a trailer holding the CRC32C of the rest of the page.
+// The pager fills it in on WritePage and checks it on ReadPage; the layers
+// above must treat the last PageTrailerSize bytes of a page as reserved.
+const PageTrailerSize = 4
+
+type ChecksumPolicy int
+
+const (
+	// ChecksumVerify fails reads of pages whose checksum does not match.
+	ChecksumVerify ChecksumPolicy = iota
+	// ChecksumLogOnly records a warning and returns the page anyway.
+	ChecksumLogOnly
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// UsablePageSize is the number of bytes of a page available to its owner.
+func UsablePageSize(p BasePagination) int {
+	return p.PageSize() - PageTrailerSize
+}
+
+func sealPage(page []byte) {
+	body := page[:len(page)-PageTrailerSize]
+	binary.LittleEndian.PutUint32(page[len(body):], crc32.Checksum(body, castagnoli))
+}
+
+// verifyPage returns the stored and computed checksums and whether they
+// match. A page that is entirely zero was never written (a hole left by a
+// sparse write) and is accepted as is.
+func verifyPage(page []byte) (uint32, uint32, bool) {
+	body := page[:len(page)-PageTrailerSize]
+	stored := binary.LittleEndian.Uint32(page[len(body):])
+	computed := crc32.Checksum(body, castagnoli)
+	if stored == computed {
+		return stored, computed, true
+	}
+	if stored == 0 && isZero(body) {
+		return stored, computed, true
+	}
+	return stored, computed, false
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package pagination
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// corrupt flips a byte of page id directly in the file, behind the pager.
+func corrupt(t *testing.T, p *Pager, id PageID) {
+	t.Helper()
+	offset := int64(id)*int64(p.PageSize()) + 100
+	if _, err := p.file.WriteAt([]byte{0xff}, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChecksumMismatchIsDetected(t *testing.T) {
+	p := newTestPager(t, PageSize)
+	for _, id := range []PageID{1, 3} {
+		if err := p.WritePage(id, []byte("page contents")); err.IsNotEmpty() {
+			t.Fatalf("write: %v", err.Errors())
+		}
+	}
+	// Page 2 is a hole that was never written, which is not a mismatch.
+	for _, id := range []PageID{1, 2, 3} {
+		if _, err := p.ReadPage(id); err.IsNotEmpty() {
+			t.Fatalf("read intact page %d: %v", id, err.Errors())
+		}
+	}
+
+	corrupt(t, p, 3)
+	if _, err := p.ReadPage(3); !err.ContainsError(lib.ChecksumMismatchError) {
+		t.Fatalf("got %v, want a checksum mismatch", err.Errors())
+	}
+	if _, err := p.ReadPage(1); err.IsNotEmpty() {
+		t.Fatalf("read page 1 next to a corrupt page: %v", err.Errors())
+	}
+}
+
+func TestChecksumLogOnlyReturnsCorruptPages(t *testing.T) {
+	p := newTestPager(t, PageSize)
+	if err := p.WritePage(1, []byte("page contents")); err.IsNotEmpty() {
+		t.Fatalf("write: %v", err.Errors())
+	}
+	corrupt(t, p, 1)
+
+	p.SetChecksumPolicy(ChecksumLogOnly)
+	data, err := p.ReadPage(1)
+	if err.IsNotEmpty() {
+		t.Fatalf("read under ChecksumLogOnly: %v", err.Errors())
+	}
+	if !bytes.HasPrefix(data, []byte("page contents")) || data[100] != 0xff {
+		t.Fatal("ChecksumLogOnly did not return the page as stored")
+	}
+
+	p.SetChecksumPolicy(ChecksumVerify)
+	if _, err := p.ReadPage(1); !err.ContainsError(lib.ChecksumMismatchError) {
+		t.Fatalf("got %v, want a checksum mismatch", err.Errors())
+	}
+}
+
+func TestWritesRepairTheChecksum(t *testing.T) {
+	p := newTestPager(t, PageSize)
+	if err := p.WritePage(1, []byte("page contents")); err.IsNotEmpty() {
+		t.Fatalf("write: %v", err.Errors())
+	}
+	corrupt(t, p, 1)
+	full := make([]byte, UsablePageSize(p))
+	copy(full, "rewritten")
+	if err := p.WritePage(1, full); err.IsNotEmpty() {
+		t.Fatalf("rewrite: %v", err.Errors())
+	}
+	if _, err := p.ReadPage(1); err.IsNotEmpty() {
+		t.Fatalf("read rewritten page: %v", err.Errors())
+	}
+}
@@ -0,0 +1,159 @@
+package pagination
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Free pages are kept in a chain of trunk pages whose head and length live in
+// the page-0 header. A trunk page stores the next trunk at [0:4], the number
+// of free page IDs it holds at [4:8] and the IDs themselves after that.
+//
+// The helpers below only go through ReadPage/WritePage of the pagination
+// they are given, so a wrapper that caches or logs pages (buffer pool, WAL)
+// keeps the free list coherent with everything else it holds, and a logged
+// allocation that is rolled back is forgotten along with the header update.
+const trunkHeaderSize = 8
+
+func trunkCapacity(p BasePagination) int {
+	return (UsablePageSize(p) - trunkHeaderSize) / 4
+}
+
+// AllocatePage hands out a page from the free list, or grows the file by one
+// page when the list is empty. Either way the page is returned zeroed.
+func AllocatePage(p BasePagination) (PageID, lib.Error) {
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+
+	var allocated PageID
+	switch {
+	case h.FreeListHead == 0:
+		allocated = PageID(h.PageCount)
+		h.PageCount++
+	default:
+		trunk, err := p.ReadPage(h.FreeListHead)
+		if err.IsNotEmpty() {
+			return 0, err
+		}
+		entries := binary.LittleEndian.Uint32(trunk[4:8])
+		if entries > 0 {
+			// Take the last ID recorded in the head trunk.
+			offset := trunkHeaderSize + 4*(entries-1)
+			allocated = PageID(binary.LittleEndian.Uint32(trunk[offset:]))
+			binary.LittleEndian.PutUint32(trunk[4:8], entries-1)
+			if err := p.WritePage(h.FreeListHead, trunk[:trunkHeaderSize]); err.IsNotEmpty() {
+				return 0, err
+			}
+		} else {
+			// The head trunk is empty: reuse the trunk page itself.
+			allocated = h.FreeListHead
+			h.FreeListHead = PageID(binary.LittleEndian.Uint32(trunk[0:4]))
+		}
+		h.FreePageCount--
+	}
+
+	if err := WriteHeader(p, h); err.IsNotEmpty() {
+		return 0, err
+	}
+	if err := p.WritePage(allocated, make([]byte, p.PageSize())); err.IsNotEmpty() {
+		return 0, err
+	}
+	return allocated, lib.EmptyError()
+}
+
+// FreePage returns id to the free list so a later AllocatePage can reuse it.
+// A page that is the head trunk or recorded in it is refused as already
+// free, so that a double free does not hand the same page out twice; pages
+// in older trunks are not checked.
+func FreePage(p BasePagination, id PageID) lib.Error {
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if id == 0 || uint32(id) >= h.PageCount {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("free page: page %d cannot be freed", id))
+	}
+	if id == h.FreeListHead {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("free page: page %d is already free", id))
+	}
+
+	if h.FreeListHead != 0 {
+		trunk, err := p.ReadPage(h.FreeListHead)
+		if err.IsNotEmpty() {
+			return err
+		}
+		entries := binary.LittleEndian.Uint32(trunk[4:8])
+		if int(entries) > trunkCapacity(p) {
+			return lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("free page: trunk %d claims %d entries, at most %d fit", h.FreeListHead, entries, trunkCapacity(p)))
+		}
+		for i := uint32(0); i < entries; i++ {
+			if PageID(binary.LittleEndian.Uint32(trunk[trunkHeaderSize+4*i:])) == id {
+				return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("free page: page %d is already free", id))
+			}
+		}
+		if int(entries) < trunkCapacity(p) {
+			offset := trunkHeaderSize + 4*entries
+			binary.LittleEndian.PutUint32(trunk[offset:], uint32(id))
+			binary.LittleEndian.PutUint32(trunk[4:8], entries+1)
+			if err := p.WritePage(h.FreeListHead, trunk[:offset+4]); err.IsNotEmpty() {
+				return err
+			}
+			h.FreePageCount++
+			return WriteHeader(p, h)
+		}
+	}
+
+	// No room in the head trunk: the freed page becomes the new head trunk.
+	trunk := make([]byte, trunkHeaderSize)
+	binary.LittleEndian.PutUint32(trunk[0:4], uint32(h.FreeListHead))
+	if err := p.WritePage(id, trunk); err.IsNotEmpty() {
+		return err
+	}
+	h.FreeListHead = id
+	h.FreePageCount++
+	return WriteHeader(p, h)
+}
+
+func FreePageCount(p BasePagination) (int, lib.Error) {
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	return int(h.FreePageCount), lib.EmptyError()
+}
+
+// FreePages lists every page the free list holds, trunk pages included. It
+// stops with an error at a trunk outside the file or a chain that loops.
+func FreePages(p BasePagination) ([]PageID, lib.Error) {
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+
+	var pages []PageID
+	trunks := 0
+	for id := h.FreeListHead; id != 0; trunks++ {
+		if uint32(id) >= h.PageCount || trunks >= int(h.PageCount) {
+			return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("free list: trunk %d is outside the file or the chain loops", id))
+		}
+		trunk, err := p.ReadPage(id)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		entries := binary.LittleEndian.Uint32(trunk[4:8])
+		if int(entries) > trunkCapacity(p) {
+			return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("free list: trunk %d claims %d entries, at most %d fit", id, entries, trunkCapacity(p)))
+		}
+		pages = append(pages, id)
+		for i := uint32(0); i < entries; i++ {
+			offset := trunkHeaderSize + 4*i
+			pages = append(pages, PageID(binary.LittleEndian.Uint32(trunk[offset:])))
+		}
+		id = PageID(binary.LittleEndian.Uint32(trunk[0:4]))
+	}
+	return pages, lib.EmptyError()
+}
@@ -0,0 +1,150 @@
+package pagination
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// newTestPager opens a pager over a fresh file holding just a header. Small
+// pages keep the trunk capacity low.
+func newTestPager(t *testing.T, pageSize int) *Pager {
+	t.Helper()
+	p, err := NewPager(filepath.Join(t.TempDir(), "test.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	t.Cleanup(func() { p.Close() })
+	if err := p.SetPageSize(pageSize); err.IsNotEmpty() {
+		t.Fatalf("set page size: %v", err.Errors())
+	}
+	if err := WriteHeader(p, NewHeader(pageSize)); err.IsNotEmpty() {
+		t.Fatalf("write header: %v", err.Errors())
+	}
+	return p
+}
+
+func allocate(t *testing.T, p *Pager, n int) []PageID {
+	t.Helper()
+	ids := make([]PageID, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := p.AllocatePage()
+		if err.IsNotEmpty() {
+			t.Fatalf("allocate: %v", err.Errors())
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestFreedPagesAreReused(t *testing.T) {
+	p := newTestPager(t, MinPageSize)
+	if ids := allocate(t, p, 5); !slices.Equal(ids, []PageID{1, 2, 3, 4, 5}) {
+		t.Fatalf("allocated %v from an empty file", ids)
+	}
+	if err := p.WritePage(4, []byte("stale")); err.IsNotEmpty() {
+		t.Fatalf("write: %v", err.Errors())
+	}
+	for _, id := range []PageID{2, 4} {
+		if err := p.FreePage(id); err.IsNotEmpty() {
+			t.Fatalf("free %d: %v", id, err.Errors())
+		}
+	}
+	if n, _ := p.FreePageCount(); n != 2 {
+		t.Fatalf("free page count %d, want 2", n)
+	}
+
+	// Page 2 became the trunk holding page 4; the entries go first, then the
+	// trunk itself, and only then does the file grow.
+	if ids := allocate(t, p, 3); !slices.Equal(ids, []PageID{4, 2, 6}) {
+		t.Fatalf("allocated %v, want [4 2 6]", ids)
+	}
+	data, err := p.ReadPage(4)
+	if err.IsNotEmpty() {
+		t.Fatalf("read: %v", err.Errors())
+	}
+	if !isZero(data[:UsablePageSize(p)]) {
+		t.Fatal("a reused page kept its old contents")
+	}
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		t.Fatalf("read header: %v", err.Errors())
+	}
+	if h.FreeListHead != 0 || h.FreePageCount != 0 || h.PageCount != 7 {
+		t.Fatalf("unexpected header %+v", h)
+	}
+}
+
+func TestFreeListOverflowsIntoNewTrunks(t *testing.T) {
+	p := newTestPager(t, MinPageSize)
+	capacity := trunkCapacity(p)
+	ids := allocate(t, p, 2*capacity+3)
+	for _, id := range ids {
+		if err := p.FreePage(id); err.IsNotEmpty() {
+			t.Fatalf("free %d: %v", id, err.Errors())
+		}
+	}
+
+	// The first freed page starts a trunk, the next capacity pages fill it,
+	// and the one after that starts a second trunk, and so on.
+	h, err := ReadHeader(p)
+	if err.IsNotEmpty() {
+		t.Fatalf("read header: %v", err.Errors())
+	}
+	if h.FreeListHead != ids[2*capacity+2] || int(h.FreePageCount) != len(ids) {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	free, err := FreePages(p)
+	if err.IsNotEmpty() {
+		t.Fatalf("free pages: %v", err.Errors())
+	}
+	slices.Sort(free)
+	if !slices.Equal(free, ids) {
+		t.Fatalf("free list holds %d pages, want the %d freed", len(free), len(ids))
+	}
+
+	// Every page comes back exactly once before the file grows again.
+	reused := allocate(t, p, len(ids))
+	slices.Sort(reused)
+	if !slices.Equal(reused, ids) {
+		t.Fatal("allocation did not hand back exactly the freed pages")
+	}
+	if h, _ := ReadHeader(p); h.FreeListHead != 0 || h.FreePageCount != 0 || int(h.PageCount) != len(ids)+1 {
+		t.Fatalf("unexpected header %+v", h)
+	}
+}
+
+func TestFreePageRejectsPagesOutsideTheFile(t *testing.T) {
+	p := newTestPager(t, MinPageSize)
+	allocate(t, p, 2)
+	for _, id := range []PageID{0, 3} {
+		if err := p.FreePage(id); !err.ContainsError(lib.InvalidInputError) {
+			t.Fatalf("free %d: got %v, want an invalid input error", id, err.Errors())
+		}
+	}
+}
+
+func TestFreePageRejectsDoubleFree(t *testing.T) {
+	p := newTestPager(t, MinPageSize)
+	allocate(t, p, 4)
+	for _, id := range []PageID{2, 4} {
+		if err := p.FreePage(id); err.IsNotEmpty() {
+			t.Fatalf("free %d: %v", id, err.Errors())
+		}
+	}
+
+	// Page 2 is the head trunk and page 4 is recorded in it.
+	for _, id := range []PageID{2, 4} {
+		if err := p.FreePage(id); !err.ContainsError(lib.InvalidInputError) {
+			t.Fatalf("second free of %d: got %v, want an invalid input error", id, err.Errors())
+		}
+	}
+	if n, _ := p.FreePageCount(); n != 2 {
+		t.Fatalf("free page count %d, want 2", n)
+	}
+	if ids := allocate(t, p, 3); !slices.Equal(ids, []PageID{4, 2, 5}) {
+		t.Fatalf("allocated %v, want [4 2 5]", ids)
+	}
+}
@@ -0,0 +1,166 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+const (
+	// FormatVersion is bumped whenever a released on-disk layout of any page
+	// changes.
+	FormatVersion uint16 = 1
+	HeaderSize           = 48
+)
+
+var headerMagic = [8]byte{'D', 'B', 'I', 'N', 'T', 'R', 'N', 'L'}
+
+// Header is the superblock stored at the start of page 0.
+//
+//	[0:8]   magic "DBINTRNL"
+//	[8:10]  format version
+//	[10:12] reserved
+//	[12:16] page size
+//	[16:20] root page of the structure stored in the file
+//	[20:24] head of the free-list trunk chain
+//	[24:28] number of free pages
+//	[28:32] number of pages in use, including page 0 and free pages
+//	[32:40] LSN of the last checkpoint
+//	[40:44] reserved
+//	[44:48] CRC32C of bytes [0:44], like the page trailers
+type Header struct {
+	Version           uint16
+	PageSize          uint32
+	RootPage          PageID
+	FreeListHead      PageID
+	FreePageCount     uint32
+	PageCount         uint32
+	LastCheckpointLSN uint64
+}
+
+func NewHeader(pageSize int) Header {
+	return Header{
+		Version:   FormatVersion,
+		PageSize:  uint32(pageSize),
+		PageCount: 1,
+	}
+}
+
+func (h Header) Encode() []byte {
+	buf := make([]byte, HeaderSize)
+	copy(buf[0:8], headerMagic[:])
+	binary.LittleEndian.PutUint16(buf[8:10], h.Version)
+	binary.LittleEndian.PutUint32(buf[12:16], h.PageSize)
+	binary.LittleEndian.PutUint32(buf[16:20], uint32(h.RootPage))
+	binary.LittleEndian.PutUint32(buf[20:24], uint32(h.FreeListHead))
+	binary.LittleEndian.PutUint32(buf[24:28], h.FreePageCount)
+	binary.LittleEndian.PutUint32(buf[28:32], h.PageCount)
+	binary.LittleEndian.PutUint64(buf[32:40], h.LastCheckpointLSN)
+	binary.LittleEndian.PutUint32(buf[44:48], crc32.Checksum(buf[:44], castagnoli))
+	return buf
+}
+
+// HasHeaderMagic reports whether buf starts like a superblock, without the
+// checks DecodeHeader performs.
+func HasHeaderMagic(buf []byte) bool {
+	return len(buf) >= len(headerMagic) && bytes.Equal(buf[:len(headerMagic)], headerMagic[:])
+}
+
+// DecodeHeader parses and checks a superblock. It refuses anything that is
+// not a header written by this format version, or whose page size
+// SetPageSize would refuse.
+func DecodeHeader(buf []byte) (Header, lib.Error) {
+	if len(buf) < HeaderSize {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: need %d bytes, got %d", HeaderSize, len(buf)))
+	}
+	if !bytes.Equal(buf[0:8], headerMagic[:]) {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: bad magic %q, not a Database-internals file or written by a pre-header build", buf[0:8]))
+	}
+	// The version comes first: another version may checksum the header
+	// differently.
+	version := binary.LittleEndian.Uint16(buf[8:10])
+	if version != FormatVersion {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: format version %d is not supported, only version %d is", version, FormatVersion))
+	}
+	if checksum := binary.LittleEndian.Uint32(buf[44:48]); checksum != crc32.Checksum(buf[:44], castagnoli) {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: checksum mismatch (stored %08x)", checksum))
+	}
+	pageSize := binary.LittleEndian.Uint32(buf[12:16])
+	if !validPageSize(int(pageSize)) {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: page size %d is not a power of two from %d to %d", pageSize, MinPageSize, MaxPageSize))
+	}
+
+	return Header{
+		Version:           version,
+		PageSize:          pageSize,
+		RootPage:          PageID(binary.LittleEndian.Uint32(buf[16:20])),
+		FreeListHead:      PageID(binary.LittleEndian.Uint32(buf[20:24])),
+		FreePageCount:     binary.LittleEndian.Uint32(buf[24:28]),
+		PageCount:         binary.LittleEndian.Uint32(buf[28:32]),
+		LastCheckpointLSN: binary.LittleEndian.Uint64(buf[32:40]),
+	}, lib.EmptyError()
+}
+
+// StoredPageSize returns the page size recorded in the header of the file at
+// path, or 0 if the file does not exist or is empty. The header can be
+// checked without knowing the page size, since its checksum only covers
+// the header itself.
+func StoredPageSize(path string) (int, lib.Error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, lib.EmptyError()
+	}
+	if err != nil {
+		return 0, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("failed to open file %s: %w", path, err))
+	}
+	defer file.Close()
+
+	buf := make([]byte, HeaderSize)
+	n, err := io.ReadFull(file, buf)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return 0, lib.EmptyError()
+	}
+	if err != nil {
+		return 0, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: failed to read %s: %w", path, err))
+	}
+	h, e := DecodeHeader(buf)
+	if e.IsNotEmpty() {
+		return 0, e
+	}
+	return int(h.PageSize), lib.EmptyError()
+}
+
+// ReadHeader loads and validates the superblock of p, including that it was
+// written with p's page size.
+func ReadHeader(p BasePagination) (Header, lib.Error) {
+	if p.NumPages() == 0 {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: file is empty"))
+	}
+	buf, err := p.ReadPage(0)
+	if err.IsNotEmpty() {
+		return Header{}, err
+	}
+	h, err := DecodeHeader(buf)
+	if err.IsNotEmpty() {
+		return Header{}, err
+	}
+	if int(h.PageSize) != p.PageSize() {
+		return Header{}, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("header: file uses %d byte pages, pager is configured for %d", h.PageSize, p.PageSize()))
+	}
+	return h, lib.EmptyError()
+}
+
+// WriteHeader writes the header as a whole page, so that a fresh file holds
+// page 0 in full and not just the header bytes.
+func WriteHeader(p BasePagination, h Header) lib.Error {
+	page := make([]byte, p.PageSize())
+	copy(page, h.Encode())
+	return p.WritePage(0, page)
+}
@@ -0,0 +1,74 @@
+package pagination
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	h := NewHeader(8192)
+	h.RootPage, h.FreeListHead, h.FreePageCount, h.PageCount, h.LastCheckpointLSN = 3, 9, 4, 12, 77
+
+	decoded, err := DecodeHeader(h.Encode())
+	if err.IsNotEmpty() {
+		t.Fatalf("decode: %v", err.Errors())
+	}
+	if decoded != h {
+		t.Fatalf("decoded %+v, want %+v", decoded, h)
+	}
+}
+
+func TestDecodeHeaderRejectsBadHeaders(t *testing.T) {
+	withVersion := func(version uint16) []byte {
+		h := NewHeader(PageSize)
+		h.Version = version
+		return h.Encode()
+	}
+	badMagic := NewHeader(PageSize).Encode()
+	badMagic[0] = 'X'
+	badChecksum := NewHeader(PageSize).Encode()
+	binary.LittleEndian.PutUint32(badChecksum[16:20], 5)
+
+	for name, buf := range map[string][]byte{
+		"short":                        NewHeader(PageSize).Encode()[:HeaderSize-1],
+		"bad magic":                    badMagic,
+		"version zero":                 withVersion(0),
+		"newer version":                withVersion(FormatVersion + 1),
+		"checksum mismatch":            badChecksum,
+		"page size too small":          NewHeader(MinPageSize / 2).Encode(),
+		"page size too large":          NewHeader(MaxPageSize * 2).Encode(),
+		"page size not a power of two": NewHeader(3000).Encode(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeHeader(buf); !err.ContainsError(lib.FileFormatError) {
+				t.Fatalf("got %v, want a file format error", err.Errors())
+			}
+		})
+	}
+}
+
+func TestReadHeaderChecksThePageSize(t *testing.T) {
+	p := newTestPager(t, PageSize)
+	if err := WriteHeader(p, NewHeader(2*PageSize)); err.IsNotEmpty() {
+		t.Fatalf("write header: %v", err.Errors())
+	}
+	if _, err := ReadHeader(p); !err.ContainsError(lib.FileFormatError) {
+		t.Fatalf("got %v, want a file format error", err.Errors())
+	}
+}
+
+func TestStoredPageSize(t *testing.T) {
+	p := newTestPager(t, 1024)
+	if err := p.Sync(); err.IsNotEmpty() {
+		t.Fatalf("sync: %v", err.Errors())
+	}
+	if size, err := StoredPageSize(p.file.Name()); err.IsNotEmpty() || size != 1024 {
+		t.Fatalf("stored page size %d, %v, want 1024", size, err.Errors())
+	}
+	if size, err := StoredPageSize(filepath.Join(t.TempDir(), "missing.db")); err.IsNotEmpty() || size != 0 {
+		t.Fatalf("stored page size of a missing file %d, %v, want 0", size, err.Errors())
+	}
+}
@@ -0,0 +1,113 @@
+package pagination
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Data too large to live inside a page is spread over a chain of overflow
+// pages. Each one stores the next page of the chain at [0:4], the number of
+// data bytes it holds at [4:8] and the data after that. Like the free list,
+// the helpers only use the pagination they are given.
+const overflowHeaderSize = 8
+
+func overflowCapacity(p BasePagination) int {
+	return UsablePageSize(p) - overflowHeaderSize
+}
+
+// WriteOverflow stores data in a freshly allocated chain and returns its
+// first page.
+func WriteOverflow(p BasePagination, data []byte) (PageID, lib.Error) {
+	if len(data) == 0 {
+		return 0, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write overflow: no data"))
+	}
+
+	chunk := overflowCapacity(p)
+	pages := make([]PageID, 0, (len(data)+chunk-1)/chunk)
+	for offset := 0; offset < len(data); offset += chunk {
+		id, err := p.AllocatePage()
+		if err.IsNotEmpty() {
+			return 0, err
+		}
+		pages = append(pages, id)
+	}
+
+	for i, id := range pages {
+		part := data[i*chunk : min((i+1)*chunk, len(data))]
+		buf := make([]byte, overflowHeaderSize+len(part))
+		if i+1 < len(pages) {
+			binary.LittleEndian.PutUint32(buf[0:4], uint32(pages[i+1]))
+		}
+		binary.LittleEndian.PutUint32(buf[4:8], uint32(len(part)))
+		copy(buf[overflowHeaderSize:], part)
+		if err := p.WritePage(id, buf); err.IsNotEmpty() {
+			return 0, err
+		}
+	}
+	return pages[0], lib.EmptyError()
+}
+
+// ReadOverflow reassembles the length bytes stored in the chain starting at
+// head.
+func ReadOverflow(p BasePagination, head PageID, length int) ([]byte, lib.Error) {
+	data := make([]byte, 0, length)
+	for id := head; len(data) < length; {
+		page, err := readOverflowPage(p, id)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		used := int(binary.LittleEndian.Uint32(page[4:8]))
+		if used > overflowCapacity(p) || len(data)+used > length {
+			return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("read overflow: page %d of chain %d holds %d bytes, which does not fit a %d byte value", id, head, used, length))
+		}
+		data = append(data, page[overflowHeaderSize:overflowHeaderSize+used]...)
+
+		next := PageID(binary.LittleEndian.Uint32(page[0:4]))
+		if next == 0 && len(data) < length {
+			return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("read overflow: chain %d ends after %d of %d bytes", head, len(data), length))
+		}
+		id = next
+	}
+	return data, lib.EmptyError()
+}
+
+// FreeOverflow returns every page of the chain starting at head to the free
+// list.
+func FreeOverflow(p BasePagination, head PageID) lib.Error {
+	pages, err := OverflowPages(p, head)
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, id := range pages {
+		if err := p.FreePage(id); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// OverflowPages lists the pages of the chain starting at head.
+func OverflowPages(p BasePagination, head PageID) ([]PageID, lib.Error) {
+	var pages []PageID
+	for id := head; id != 0; {
+		if len(pages) >= p.NumPages() {
+			return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("overflow chain %d loops", head))
+		}
+		page, err := readOverflowPage(p, id)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		pages = append(pages, id)
+		id = PageID(binary.LittleEndian.Uint32(page[0:4]))
+	}
+	return pages, lib.EmptyError()
+}
+
+func readOverflowPage(p BasePagination, id PageID) ([]byte, lib.Error) {
+	if id == 0 || int(id) >= p.NumPages() {
+		return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("overflow chain points at page %d outside the file", id))
+	}
+	return p.ReadPage(id)
+}
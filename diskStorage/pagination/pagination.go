@@ -3,17 +3,31 @@ package pagination
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/Kush/Database-internals/lib"
 )
 
-const PageSize = 4096
+const (
+	// PageSize is the default page size.
+	PageSize = 4096
+	// MinPageSize and MaxPageSize bound the page sizes a pager accepts.
+	MinPageSize = 512
+	MaxPageSize = 64 << 10
+)
 
 type PageID uint32
 
+// Pager is safe for concurrent use. mu keeps a page from being read while
+// it is half written, and makes the read-modify-write of a partial write
+// atomic; allocMu does the same for the header and free-list updates of
+// AllocatePage and FreePage.
 type Pager struct {
-	file     *os.File
-	pageSize int
+	mu             sync.RWMutex
+	allocMu        sync.Mutex
+	file           *os.File
+	pageSize       int
+	checksumPolicy ChecksumPolicy
 }
 
 func NewPager(path string) (*Pager, lib.Error) {
@@ -27,28 +41,109 @@ func NewPager(path string) (*Pager, lib.Error) {
 	}, lib.EmptyError()
 }
 
-func (p *Pager) ReadPage(id PageID) ([]byte, lib.Error) {
-	buf := make([]byte, p.pageSize)
-	offset := int64(id) * int64(p.pageSize)
-	_, err := p.file.ReadAt(buf, offset)
+// NewReadOnlyPager opens an existing file for reading only; writes to it
+// fail.
+func NewReadOnlyPager(path string) (*Pager, lib.Error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("failed to read page %d: %w", id, err))
+		return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("failed to open file %s: %w", path, err))
+	}
+	return &Pager{
+		file:     file,
+		pageSize: PageSize,
+	}, lib.EmptyError()
+}
+
+// SetPageSize sets the page size, a power of two from MinPageSize to
+// MaxPageSize. It must be called before the first page is read or written,
+// and match the page size of an existing file.
+func (p *Pager) SetPageSize(pageSize int) lib.Error {
+	if !validPageSize(pageSize) {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("page size %d is not a power of two from %d to %d", pageSize, MinPageSize, MaxPageSize))
+	}
+	p.pageSize = pageSize
+	return lib.EmptyError()
+}
+
+func validPageSize(pageSize int) bool {
+	return pageSize >= MinPageSize && pageSize <= MaxPageSize && pageSize&(pageSize-1) == 0
+}
+
+func (p *Pager) SetChecksumPolicy(policy ChecksumPolicy) {
+	p.checksumPolicy = policy
+}
+
+func (p *Pager) ReadPage(id PageID) ([]byte, lib.Error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.readPage(id)
+}
+
+func (p *Pager) readPage(id PageID) ([]byte, lib.Error) {
+	buf, err := p.readRawPage(id)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := p.verify(id, buf); err.IsNotEmpty() {
+		return nil, err
 	}
 	return buf, lib.EmptyError()
 }
 
+// WritePage writes data at the start of the page and seals the page with a
+// fresh checksum. Shorter writes keep the rest of the current page contents.
 func (p *Pager) WritePage(id PageID, data []byte) lib.Error {
-	// if len(data) != p.pageSize {
-	// 	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write page: data length %d does not match page size %d", len(data), p.pageSize))
-	// }
+	if len(data) > p.pageSize {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write page: data length %d exceeds page size %d", len(data), p.pageSize))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	page := make([]byte, p.pageSize)
+	if len(data) < p.pageSize-PageTrailerSize && int(id) < p.NumPages() {
+		current, err := p.readPage(id)
+		if err.IsNotEmpty() {
+			return err
+		}
+		copy(page, current)
+	}
+	copy(page, data)
+	sealPage(page)
+
 	offset := int64(id) * int64(p.pageSize)
-	_, err := p.file.WriteAt(data, offset)
+	_, err := p.file.WriteAt(page, offset)
 	if err != nil {
 		return lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("failed to write page %d: %w", id, err))
 	}
 	return lib.EmptyError()
 }
 
+func (p *Pager) readRawPage(id PageID) ([]byte, lib.Error) {
+	buf := make([]byte, p.pageSize)
+	offset := int64(id) * int64(p.pageSize)
+	_, err := p.file.ReadAt(buf, offset)
+	if err != nil {
+		return nil, lib.EmptyError().AddErr(lib.PaginationError, fmt.Errorf("failed to read page %d: %w", id, err))
+	}
+	return buf, lib.EmptyError()
+}
+
+func (p *Pager) verify(id PageID, page []byte) lib.Error {
+	stored, computed, ok := verifyPage(page)
+	if ok {
+		return lib.EmptyError()
+	}
+
+	mismatch := fmt.Errorf("page %d: checksum mismatch (stored %08x, computed %08x)", id, stored, computed)
+	if p.checksumPolicy == ChecksumLogOnly {
+		lib.EmptyError().AddWarning(lib.ChecksumMismatchError, mismatch)
+		return lib.EmptyError()
+	}
+	return lib.EmptyError().AddErr(lib.ChecksumMismatchError, mismatch)
+}
+
 func (p *Pager) NumPages() int {
 	info, err := p.file.Stat()
 	if err != nil {
@@ -58,16 +153,26 @@ func (p *Pager) NumPages() int {
 	return int(info.Size() / int64(p.pageSize))
 }
 
+func (p *Pager) PageSize() int {
+	return p.pageSize
+}
+
 func (p *Pager) AllocatePage() (PageID, lib.Error) {
-	currentNumPages := p.NumPages()
+	p.allocMu.Lock()
+	defer p.allocMu.Unlock()
 
-	newPageID := PageID(currentNumPages)
-	page := make([]byte, p.pageSize)
-	if err := p.WritePage(newPageID, page); err.IsNotEmpty() {
-		return 0, err
-	}
+	return AllocatePage(p)
+}
+
+func (p *Pager) FreePage(id PageID) lib.Error {
+	p.allocMu.Lock()
+	defer p.allocMu.Unlock()
+
+	return FreePage(p, id)
+}
 
-	return newPageID, lib.EmptyError()
+func (p *Pager) FreePageCount() (int, lib.Error) {
+	return FreePageCount(p)
 }
 
 
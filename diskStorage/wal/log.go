@@ -0,0 +1,306 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+type LSN uint64
+
+const (
+	logMagic       uint32 = 0x314c4157 // "WAL1"
+	logHeaderSize         = 16
+	recordOverhead        = 16 // payload length, crc32, lsn
+)
+
+// EmptyLogSize is the size of a log file without records, as after a
+// checkpoint.
+const EmptyLogSize = logHeaderSize
+
+// MaxRecordSize is the largest payload Append may be given. A longer length
+// field is taken for garbage when the log is read back.
+const MaxRecordSize = 1 << 24
+
+// Log is an append-only file of checksummed, LSN-numbered records. Appends
+// are buffered in memory; Flush makes them durable and lets concurrent
+// callers share a single write+fsync (group commit). A record whose frame or
+// checksum is incomplete marks the torn tail of the log and it, together
+// with everything after it, is discarded when the log is opened.
+//
+// A failed write or fsync leaves the log failed: the records of that flush
+// may or may not be on disk, and as their LSNs are taken, any record written
+// after them would sit behind a gap and be dropped as torn tail on the next
+// open. Every later Flush therefore fails too, until the log is reopened.
+type Log struct {
+	mu          sync.Mutex
+	flushDone   *sync.Cond
+	file        *os.File
+	size        int64
+	buffer      []byte
+	nextLSN     LSN
+	bufferedLSN LSN
+	durableLSN  LSN
+	flushing    bool
+	failed      error
+}
+
+func OpenLog(path string) (*Log, lib.Error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to open log %s: %w", path, err))
+	}
+	l := &Log{file: file}
+	l.flushDone = sync.NewCond(&l.mu)
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to stat log %s: %w", path, err))
+	}
+	if info.Size() < logHeaderSize {
+		if e := l.reset(1); e.IsNotEmpty() {
+			file.Close()
+			return nil, e
+		}
+		return l, lib.EmptyError()
+	}
+
+	header := make([]byte, logHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return nil, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to read log header: %w", err))
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != logMagic {
+		file.Close()
+		return nil, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("%s is not a write-ahead log", path))
+	}
+	l.nextLSN = LSN(binary.LittleEndian.Uint64(header[8:16]))
+
+	end, lastLSN, e := l.scan(nil)
+	if e.IsNotEmpty() {
+		file.Close()
+		return nil, e
+	}
+	if lastLSN != 0 {
+		l.nextLSN = lastLSN + 1
+	}
+	// Drop the torn tail so new records are appended right after the last
+	// complete one.
+	if end < info.Size() {
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to drop torn log tail: %w", err))
+		}
+	}
+	l.size = end
+	l.bufferedLSN = l.nextLSN - 1
+	l.durableLSN = l.nextLSN - 1
+
+	return l, lib.EmptyError()
+}
+
+// Append buffers a record and returns its LSN. It is not durable until a
+// Flush covering that LSN returns.
+func (l *Log) Append(payload []byte) LSN {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lsn := l.nextLSN
+	l.nextLSN++
+
+	frame := make([]byte, recordOverhead+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(frame[8:16], uint64(lsn))
+	copy(frame[16:], payload)
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(frame[8:]))
+
+	l.buffer = append(l.buffer, frame...)
+	l.bufferedLSN = lsn
+	return lsn
+}
+
+// Flush blocks until every record up to and including upTo is on stable
+// storage. Whoever finds no flush in progress writes out the whole buffer,
+// so callers arriving meanwhile are committed by the same fsync.
+func (l *Log) Flush(upTo LSN) lib.Error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.durableLSN < upTo {
+		if l.failed != nil {
+			return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("flush: log failed earlier and must be reopened: %w", l.failed))
+		}
+		if l.flushing {
+			l.flushDone.Wait()
+			continue
+		}
+		if upTo > l.bufferedLSN {
+			return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("flush: lsn %d has not been appended", upTo))
+		}
+
+		l.flushing = true
+		buf, last, offset := l.buffer, l.bufferedLSN, l.size
+		l.buffer = nil
+		l.mu.Unlock()
+
+		_, werr := l.file.WriteAt(buf, offset)
+		if werr == nil {
+			werr = l.file.Sync()
+		}
+
+		l.mu.Lock()
+		l.flushing = false
+		l.flushDone.Broadcast()
+		if werr != nil {
+			l.failed = werr
+			return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to flush log up to lsn %d: %w", last, werr))
+		}
+		l.size = offset + int64(len(buf))
+		l.durableLSN = last
+	}
+	return lib.EmptyError()
+}
+
+// Iterate calls fn for every durable record in LSN order until fn returns false.
+func (l *Log) Iterate(fn func(lsn LSN, payload []byte) bool) lib.Error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _, err := l.scan(fn)
+	return err
+}
+
+// Truncate discards every record. LSNs keep increasing across truncations.
+func (l *Log) Truncate() lib.Error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.flushing {
+		l.flushDone.Wait()
+	}
+	if l.failed != nil {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("truncate: log failed earlier and must be reopened: %w", l.failed))
+	}
+	if len(l.buffer) > 0 {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("truncate: log has unflushed records"))
+	}
+	return l.reset(l.nextLSN)
+}
+
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size + int64(len(l.buffer))
+}
+
+func (l *Log) NextLSN() LSN {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.nextLSN
+}
+
+func (l *Log) DurableLSN() LSN {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.durableLSN
+}
+
+// SetNextLSN moves the LSN counter forward, e.g. to continue after the
+// checkpoint LSN recorded in the database header. It never moves backwards.
+func (l *Log) SetNextLSN(lsn LSN) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lsn <= l.nextLSN {
+		return
+	}
+	l.nextLSN = lsn
+	l.bufferedLSN = lsn - 1
+	if l.durableLSN < lsn-1 && len(l.buffer) == 0 {
+		l.durableLSN = lsn - 1
+	}
+}
+
+// Close flushes the log and closes the file, which it also does if the
+// flush fails.
+func (l *Log) Close() lib.Error {
+	if err := l.Flush(l.NextLSN() - 1); err.IsNotEmpty() {
+		l.file.Close()
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to close log: %w", err))
+	}
+	return lib.EmptyError()
+}
+
+func (l *Log) reset(baseLSN LSN) lib.Error {
+	header := make([]byte, logHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], logMagic)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(baseLSN))
+
+	if err := l.file.Truncate(0); err != nil {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to truncate log: %w", err))
+	}
+	if _, err := l.file.WriteAt(header, 0); err != nil {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to write log header: %w", err))
+	}
+	if err := l.file.Sync(); err != nil {
+		return lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to sync log: %w", err))
+	}
+	l.size = logHeaderSize
+	l.nextLSN = baseLSN
+	l.bufferedLSN = baseLSN - 1
+	l.durableLSN = baseLSN - 1
+	return lib.EmptyError()
+}
+
+// scan walks the records on disk and returns the offset just past the last
+// complete record together with its LSN.
+func (l *Log) scan(fn func(lsn LSN, payload []byte) bool) (int64, LSN, lib.Error) {
+	offset := int64(logHeaderSize)
+	var lastLSN LSN
+	frame := make([]byte, recordOverhead)
+	for {
+		if _, err := l.file.ReadAt(frame, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offset, lastLSN, lib.EmptyError()
+			}
+			return 0, 0, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to read log at offset %d: %w", offset, err))
+		}
+		payloadLen := binary.LittleEndian.Uint32(frame[0:4])
+		checksum := binary.LittleEndian.Uint32(frame[4:8])
+		lsn := LSN(binary.LittleEndian.Uint64(frame[8:16]))
+		if payloadLen > MaxRecordSize {
+			return offset, lastLSN, lib.EmptyError()
+		}
+
+		body := make([]byte, 8+int(payloadLen))
+		copy(body, frame[8:16])
+		if _, err := l.file.ReadAt(body[8:], offset+recordOverhead); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offset, lastLSN, lib.EmptyError()
+			}
+			return 0, 0, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("failed to read log at offset %d: %w", offset, err))
+		}
+		if crc32.ChecksumIEEE(body) != checksum || (lastLSN != 0 && lsn != lastLSN+1) {
+			return offset, lastLSN, lib.EmptyError()
+		}
+
+		lastLSN = lsn
+		offset += recordOverhead + int64(payloadLen)
+		if fn != nil && !fn(lsn, body[8:]) {
+			return offset, lastLSN, lib.EmptyError()
+		}
+	}
+}
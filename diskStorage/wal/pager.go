@@ -0,0 +1,341 @@
+package wal
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+const DefaultCheckpointThreshold = 4 << 20
+
+type commitBatch struct {
+	lsn    LSN
+	images map[pagination.PageID][]byte
+	order  []pagination.PageID
+}
+
+// Pager puts a write-ahead log in front of another pager. Writes are staged
+// in memory until Sync, which logs the after-image of every touched page plus
+// a commit record, waits for the log to be durable and only then writes the
+// pages through. A crash at any point therefore leaves either all or none of
+// the pages written between two Syncs. Once the log grows past the
+// checkpoint threshold the wrapped pager is synced and the log truncated.
+type Pager struct {
+	mu sync.Mutex
+	// allocMu makes the header and free-list updates of AllocatePage and
+	// FreePage atomic; they span several page reads and writes.
+	allocMu             sync.Mutex
+	pager               pagination.BasePagination
+	log                 *Log
+	pending             map[pagination.PageID][]byte
+	pendingOrder        []pagination.PageID
+	committing          []*commitBatch
+	nextTxID            uint64
+	checkpointThreshold int64
+}
+
+var _ pagination.LoggedPagination = (*Pager)(nil)
+
+func NewPager(pager pagination.BasePagination, logPath string) (*Pager, lib.Error) {
+	log, err := OpenLog(logPath)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &Pager{
+		pager:               pager,
+		log:                 log,
+		pending:             make(map[pagination.PageID][]byte),
+		nextTxID:            1,
+		checkpointThreshold: DefaultCheckpointThreshold,
+	}, lib.EmptyError()
+}
+
+func (p *Pager) SetCheckpointThreshold(bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.checkpointThreshold = bytes
+}
+
+func (p *Pager) ReadPage(id pagination.PageID) ([]byte, lib.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	image, err := p.currentImage(id)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	buf := make([]byte, len(image))
+	copy(buf, image)
+	return buf, lib.EmptyError()
+}
+
+// WritePage stages the write; like the pager it only replaces the leading
+// len(data) bytes of the page.
+func (p *Pager) WritePage(id pagination.PageID, data []byte) lib.Error {
+	if len(data) > p.pager.PageSize() {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("write page: data length %d exceeds page size %d", len(data), p.pager.PageSize()))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	image, ok := p.pending[id]
+	if !ok {
+		current, err := p.currentImage(id)
+		if err.IsNotEmpty() {
+			return err
+		}
+		image = make([]byte, p.pager.PageSize())
+		copy(image, current)
+		p.pending[id] = image
+		p.pendingOrder = append(p.pendingOrder, id)
+	}
+	copy(image, data)
+	return lib.EmptyError()
+}
+
+// NumPages also counts pages that so far only exist in the log or staging area.
+func (p *Pager) NumPages() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	numPages := p.pager.NumPages()
+	for id := range p.pending {
+		numPages = max(numPages, int(id)+1)
+	}
+	for _, batch := range p.committing {
+		for id := range batch.images {
+			numPages = max(numPages, int(id)+1)
+		}
+	}
+	return numPages
+}
+
+func (p *Pager) PageSize() int {
+	return p.pager.PageSize()
+}
+
+func (p *Pager) AllocatePage() (pagination.PageID, lib.Error) {
+	p.allocMu.Lock()
+	defer p.allocMu.Unlock()
+
+	return pagination.AllocatePage(p)
+}
+
+func (p *Pager) FreePage(id pagination.PageID) lib.Error {
+	p.allocMu.Lock()
+	defer p.allocMu.Unlock()
+
+	return pagination.FreePage(p, id)
+}
+
+func (p *Pager) FreePageCount() (int, lib.Error) {
+	return pagination.FreePageCount(p)
+}
+
+// Sync commits every page written since the previous Sync. Concurrent
+// callers share log flushes.
+func (p *Pager) Sync() lib.Error {
+	p.mu.Lock()
+	if len(p.pending) == 0 {
+		p.mu.Unlock()
+		return lib.EmptyError()
+	}
+
+	txID := p.nextTxID
+	p.nextTxID++
+	batch := &commitBatch{images: p.pending, order: p.pendingOrder}
+	for _, id := range batch.order {
+		p.log.Append(Record{Type: PageImageRecord, TxID: txID, PageID: id, Image: batch.images[id]}.Encode())
+	}
+	batch.lsn = p.log.Append(Record{Type: CommitRecord, TxID: txID}.Encode())
+	p.committing = append(p.committing, batch)
+	p.pending = make(map[pagination.PageID][]byte)
+	p.pendingOrder = nil
+	p.mu.Unlock()
+
+	flushErr := p.log.Flush(batch.lsn)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if flushErr.IsNotEmpty() {
+		p.dropBatch(batch)
+		return flushErr
+	}
+	if err := p.applyDurable(); err.IsNotEmpty() {
+		return err
+	}
+	if p.checkpointThreshold > 0 && p.log.Size() >= p.checkpointThreshold {
+		return p.checkpoint()
+	}
+	return lib.EmptyError()
+}
+
+// Rollback discards every page written since the previous Sync.
+func (p *Pager) Rollback() lib.Error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = make(map[pagination.PageID][]byte)
+	p.pendingOrder = nil
+	return lib.EmptyError()
+}
+
+// Recover replays the page images of every transaction whose commit record
+// made it to the log and ignores the rest, then checkpoints.
+func (p *Pager) Recover() lib.Error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Keep LSNs increasing even if the log file itself was lost.
+	if header, ok := p.storedHeader(); ok {
+		p.log.SetNextLSN(LSN(header.LastCheckpointLSN) + 1)
+	}
+
+	var (
+		txID     uint64
+		images   []Record
+		applyErr = lib.EmptyError()
+	)
+	iterErr := p.log.Iterate(func(_ LSN, payload []byte) bool {
+		record, err := DecodeRecord(payload)
+		if err.IsNotEmpty() {
+			applyErr = err
+			return false
+		}
+		if record.TxID != txID {
+			txID, images = record.TxID, images[:0]
+		}
+		switch record.Type {
+		case PageImageRecord:
+			images = append(images, record)
+		case CommitRecord:
+			for _, image := range images {
+				if err := p.pager.WritePage(image.PageID, image.Image); err.IsNotEmpty() {
+					applyErr = err
+					return false
+				}
+			}
+			images = images[:0]
+		}
+		return true
+	})
+	if iterErr.IsNotEmpty() {
+		return iterErr
+	}
+	if applyErr.IsNotEmpty() {
+		return applyErr
+	}
+
+	return p.checkpoint()
+}
+
+// Checkpoint makes the wrapped pager durable and truncates the log.
+func (p *Pager) Checkpoint() lib.Error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.checkpoint()
+}
+
+func (p *Pager) Close() lib.Error {
+	if err := p.Sync(); err.IsNotEmpty() {
+		return err
+	}
+	if err := p.Checkpoint(); err.IsNotEmpty() {
+		return err
+	}
+	if err := p.log.Close(); err.IsNotEmpty() {
+		return err
+	}
+	return p.pager.Close()
+}
+
+func (p *Pager) checkpoint() lib.Error {
+	if err := p.log.Flush(p.log.NextLSN() - 1); err.IsNotEmpty() {
+		return err
+	}
+	if err := p.applyDurable(); err.IsNotEmpty() {
+		return err
+	}
+	if err := p.recordCheckpoint(); err.IsNotEmpty() {
+		return err
+	}
+	if err := p.pager.Sync(); err.IsNotEmpty() {
+		return err
+	}
+	return p.log.Truncate()
+}
+
+// recordCheckpoint stamps the header with the LSN up to which everything is
+// about to be durable in the database file. Files without a header yet are
+// left alone.
+func (p *Pager) recordCheckpoint() lib.Error {
+	header, ok := p.storedHeader()
+	if !ok {
+		return lib.EmptyError()
+	}
+	header.LastCheckpointLSN = uint64(p.log.NextLSN() - 1)
+	return pagination.WriteHeader(p.pager, header)
+}
+
+// storedHeader returns the superblock of the wrapped pager, if it has a
+// valid one.
+func (p *Pager) storedHeader() (pagination.Header, bool) {
+	if p.pager.NumPages() == 0 {
+		return pagination.Header{}, false
+	}
+	buf, err := p.pager.ReadPage(0)
+	if err.IsNotEmpty() || !pagination.HasHeaderMagic(buf) {
+		return pagination.Header{}, false
+	}
+	header, err := pagination.DecodeHeader(buf)
+	return header, err.IsEmpty()
+}
+
+// applyDurable writes through, in commit order, every batch whose commit
+// record is on stable storage.
+func (p *Pager) applyDurable() lib.Error {
+	durable := p.log.DurableLSN()
+	for len(p.committing) > 0 && p.committing[0].lsn <= durable {
+		batch := p.committing[0]
+		for _, id := range batch.order {
+			if err := p.pager.WritePage(id, batch.images[id]); err.IsNotEmpty() {
+				return err
+			}
+		}
+		p.committing = p.committing[1:]
+	}
+	return lib.EmptyError()
+}
+
+func (p *Pager) dropBatch(batch *commitBatch) {
+	for i, b := range p.committing {
+		if b == batch {
+			p.committing = append(p.committing[:i], p.committing[i+1:]...)
+			return
+		}
+	}
+}
+
+// currentImage returns the newest version of a page: staged, committed but
+// not yet written through, or on disk. Pages past the end of the file read
+// as zeroes.
+func (p *Pager) currentImage(id pagination.PageID) ([]byte, lib.Error) {
+	if image, ok := p.pending[id]; ok {
+		return image, lib.EmptyError()
+	}
+	for i := len(p.committing) - 1; i >= 0; i-- {
+		if image, ok := p.committing[i].images[id]; ok {
+			return image, lib.EmptyError()
+		}
+	}
+	if int(id) >= p.pager.NumPages() {
+		return make([]byte, p.pager.PageSize()), lib.EmptyError()
+	}
+	return p.pager.ReadPage(id)
+}
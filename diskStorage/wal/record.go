@@ -0,0 +1,54 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+type RecordType uint8
+
+const (
+	PageImageRecord RecordType = iota + 1
+	CommitRecord
+)
+
+// Record is a physical redo record: either the full after-image of one page
+// written by a transaction, or the marker that makes a transaction's images
+// eligible for replay.
+type Record struct {
+	Type   RecordType
+	TxID   uint64
+	PageID pagination.PageID
+	Image  []byte
+}
+
+func (r Record) Encode() []byte {
+	buf := make([]byte, 13+len(r.Image))
+	buf[0] = byte(r.Type)
+	binary.LittleEndian.PutUint64(buf[1:9], r.TxID)
+	binary.LittleEndian.PutUint32(buf[9:13], uint32(r.PageID))
+	copy(buf[13:], r.Image)
+	return buf
+}
+
+func DecodeRecord(payload []byte) (Record, lib.Error) {
+	if len(payload) < 13 {
+		return Record{}, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("record of %d bytes is too short", len(payload)))
+	}
+	record := Record{
+		Type:   RecordType(payload[0]),
+		TxID:   binary.LittleEndian.Uint64(payload[1:9]),
+		PageID: pagination.PageID(binary.LittleEndian.Uint32(payload[9:13])),
+	}
+	switch record.Type {
+	case PageImageRecord:
+		record.Image = payload[13:]
+	case CommitRecord:
+	default:
+		return Record{}, lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("unknown record type %d", record.Type))
+	}
+	return record, lib.EmptyError()
+}
@@ -0,0 +1,216 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func records(t *testing.T, path string) []string {
+	t.Helper()
+	l, err := OpenLog(path)
+	if err.IsNotEmpty() {
+		t.Fatalf("open log: %v", err.Errors())
+	}
+	defer l.Close()
+	var payloads []string
+	if err := l.Iterate(func(_ LSN, payload []byte) bool {
+		payloads = append(payloads, string(payload))
+		return true
+	}); err.IsNotEmpty() {
+		t.Fatalf("iterate: %v", err.Errors())
+	}
+	return payloads
+}
+
+// failWrites makes the writes of l fail until the returned function is
+// called, by swapping its file for a read-only handle on the same file.
+func failWrites(t *testing.T, l *Log) func() {
+	t.Helper()
+	readOnly, err := os.Open(l.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.mu.Lock()
+	file := l.file
+	l.file = readOnly
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		l.file = file
+		l.mu.Unlock()
+		readOnly.Close()
+	}
+}
+
+func TestLogDropsTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	l, err := OpenLog(path)
+	if err.IsNotEmpty() {
+		t.Fatalf("open log: %v", err.Errors())
+	}
+	for i := 0; i < 3; i++ {
+		l.Append([]byte(fmt.Sprintf("record-%d", i)))
+	}
+	if err := l.Close(); err.IsNotEmpty() {
+		t.Fatalf("close: %v", err.Errors())
+	}
+
+	// A crash in the middle of the next flush leaves half a record behind.
+	file, ferr := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if ferr != nil {
+		t.Fatal(ferr)
+	}
+	file.Write([]byte{9, 0, 0, 0, 1, 2})
+	file.Close()
+
+	l, err = OpenLog(path)
+	if err.IsNotEmpty() {
+		t.Fatalf("reopen log: %v", err.Errors())
+	}
+	if err := l.Flush(l.Append([]byte("record-3"))); err.IsNotEmpty() {
+		t.Fatalf("flush: %v", err.Errors())
+	}
+	l.Close()
+
+	want := []string{"record-0", "record-1", "record-2", "record-3"}
+	if got := records(t, path); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("records = %q, want %q", got, want)
+	}
+}
+
+func TestLogFailsAfterWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	l, err := OpenLog(path)
+	if err.IsNotEmpty() {
+		t.Fatalf("open log: %v", err.Errors())
+	}
+	if err := l.Flush(l.Append([]byte("durable"))); err.IsNotEmpty() {
+		t.Fatalf("flush: %v", err.Errors())
+	}
+
+	restore := failWrites(t, l)
+	lost := l.Append([]byte("lost"))
+	if err := l.Flush(lost); err.IsEmpty() || !errors.Is(err, lib.ErrWAL) {
+		t.Fatalf("flush with a failing file returned %v", err.Errors())
+	}
+	restore()
+
+	// The file works again, but the LSN of the lost record is taken: a
+	// record written now would follow a gap. Neither it nor the lost one
+	// may be reported durable.
+	if err := l.Flush(lost); err.IsEmpty() {
+		t.Fatalf("flush of the lost record succeeded")
+	}
+	if err := l.Flush(l.Append([]byte("after"))); err.IsEmpty() {
+		t.Fatalf("flush after a failed flush succeeded")
+	}
+	if err := l.Truncate(); err.IsEmpty() {
+		t.Fatalf("truncate after a failed flush succeeded")
+	}
+	if err := l.Close(); err.IsEmpty() {
+		t.Fatalf("close after a failed flush succeeded")
+	}
+
+	if got := records(t, path); len(got) != 1 || got[0] != "durable" {
+		t.Fatalf("records = %q, want only the durable one", got)
+	}
+}
+
+func openTestPager(t *testing.T, dir string) *Pager {
+	t.Helper()
+	filePager, err := pagination.NewPager(filepath.Join(dir, "test.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open pager: %v", err.Errors())
+	}
+	p, err := NewPager(filePager, filepath.Join(dir, "test.db.wal"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open wal pager: %v", err.Errors())
+	}
+	if err := p.Recover(); err.IsNotEmpty() {
+		t.Fatalf("recover: %v", err.Errors())
+	}
+	return p
+}
+
+// crash drops p without writing anything back: the log file and the
+// database file are left as they are.
+func crash(p *Pager) {
+	p.log.file.Close()
+	p.pager.Close()
+}
+
+func pageImage(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 64)
+}
+
+func checkPage(t *testing.T, p *Pager, id pagination.PageID, want []byte) {
+	t.Helper()
+	got, err := p.ReadPage(id)
+	if err.IsNotEmpty() {
+		t.Fatalf("read page %d: %v", id, err.Errors())
+	}
+	if !bytes.Equal(got[:len(want)], want) {
+		t.Fatalf("page %d starts with %v, want %v", id, got[:4], want[:4])
+	}
+}
+
+func TestRecoverReplaysCommittedTransactions(t *testing.T) {
+	dir := t.TempDir()
+	p := openTestPager(t, dir)
+
+	// A committed transaction that never made it to the database file, and
+	// one whose commit record was never written.
+	p.log.Append(Record{Type: PageImageRecord, TxID: 1, PageID: 1, Image: pageImage(1)}.Encode())
+	p.log.Append(Record{Type: PageImageRecord, TxID: 1, PageID: 2, Image: pageImage(1)}.Encode())
+	p.log.Append(Record{Type: CommitRecord, TxID: 1}.Encode())
+	last := p.log.Append(Record{Type: PageImageRecord, TxID: 2, PageID: 2, Image: pageImage(2)}.Encode())
+	if err := p.log.Flush(last); err.IsNotEmpty() {
+		t.Fatalf("flush: %v", err.Errors())
+	}
+	crash(p)
+
+	p = openTestPager(t, dir)
+	defer p.Close()
+	checkPage(t, p, 1, pageImage(1))
+	checkPage(t, p, 2, pageImage(1))
+	if p.log.Size() != logHeaderSize {
+		t.Fatalf("log holds %d bytes after recovery, want it truncated", p.log.Size())
+	}
+}
+
+func TestSyncAfterFailedFlushLosesNothingCommitted(t *testing.T) {
+	dir := t.TempDir()
+	p := openTestPager(t, dir)
+	p.SetCheckpointThreshold(0)
+
+	if err := p.WritePage(1, pageImage(1)); err.IsNotEmpty() {
+		t.Fatalf("write: %v", err.Errors())
+	}
+	if err := p.Sync(); err.IsNotEmpty() {
+		t.Fatalf("sync: %v", err.Errors())
+	}
+
+	restore := failWrites(t, p.log)
+	p.WritePage(2, pageImage(2))
+	if err := p.Sync(); err.IsEmpty() {
+		t.Fatalf("sync with a failing log succeeded")
+	}
+	restore()
+	p.WritePage(3, pageImage(3))
+	if err := p.Sync(); err.IsEmpty() {
+		t.Fatalf("sync after a failed flush succeeded")
+	}
+	crash(p)
+
+	p = openTestPager(t, dir)
+	defer p.Close()
+	checkPage(t, p, 1, pageImage(1))
+	checkPage(t, p, 3, make([]byte, 64))
+}
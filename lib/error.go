@@ -1,10 +1,23 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
-	"runtime"
+	"log"
+	"runtime/debug"
+	"sort"
+	"strings"
 )
 
+// Error collects errors by ErrorCode. It implements error, and unwraps to one
+// error per code that matches both the code's sentinel and the cause:
+//
+//	errors.Is(err, lib.ErrWAL)
+//	errors.As(err, &pathErr)
+//
+// An empty Error is not nil as an error value, so callers that hold a lib.Error
+// keep checking IsNotEmpty, and code that passes it on as an error converts it
+// with Err first. FromError goes the other way.
 type Error struct {
 	errs map[ErrorCode]error
 }
@@ -65,13 +78,92 @@ func (c Error) AddErr(code ErrorCode, err error) Error {
 		c.errs = make(map[ErrorCode]error)
 	}
 	c.errs[code] = err
-	_, file, line, _ := runtime.Caller(1)
-	fmt.Printf("Error added: %s at %s:%d\n", code.ToString(), file, line)
 	return c
 }
 
+// Err returns nil for an empty Error and c otherwise, for handing the result
+// to code that checks err != nil.
+func (c Error) Err() error {
+	if c.IsEmpty() {
+		return nil
+	}
+	return c
+}
+
+func (c Error) Error() string {
+	codes := c.sortedCodes()
+	msgs := make([]string, 0, len(codes))
+	for _, code := range codes {
+		msgs = append(msgs, codedError{code: code, err: c.errs[code]}.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (c Error) Unwrap() []error {
+	codes := c.sortedCodes()
+	errs := make([]error, 0, len(codes))
+	for _, code := range codes {
+		errs = append(errs, codedError{code: code, err: c.errs[code]})
+	}
+	return errs
+}
+
+func (c Error) sortedCodes() []ErrorCode {
+	codes := c.ErrorCodes()
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// FromError turns a plain error into an Error. An Error found in its chain is
+// returned as is; otherwise err is filed under the code of the first sentinel
+// it matches, or SystemError.
+func FromError(err error) Error {
+	if err == nil {
+		return EmptyError()
+	}
+	var e Error
+	if errors.As(err, &e) {
+		return e
+	}
+	code := SystemError
+	var s sentinel
+	if errors.As(err, &s) {
+		code = ErrorCode(s)
+	}
+	return EmptyError().AddErr(code, err)
+}
+
+// Recovered turns the value of a recovered panic into a PanicFound error.
+func Recovered(r any) Error {
+	if err, ok := r.(error); ok {
+		return EmptyError().AddErr(PanicFound, fmt.Errorf("panic recovered: %w , stack : %v", err, string(debug.Stack())))
+	}
+	return EmptyError().AddErr(PanicFound, fmt.Errorf("panic recovered: %+v , stack : %v", r, string(debug.Stack())))
+}
+
+// codedError is a single entry of an Error.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e codedError) Error() string {
+	if e.err == nil {
+		return string(e.code)
+	}
+	return fmt.Sprintf("%s: %v", e.code, e.err)
+}
+
+func (e codedError) Unwrap() []error {
+	if e.err == nil {
+		return []error{sentinel(e.code)}
+	}
+	return []error{sentinel(e.code), e.err}
+}
+
+// AddWarning leaves c as it is and only logs err, to the standard logger
+// rather than stdout, which belongs to the program's own output.
 func (c Error) AddWarning(code ErrorCode, err error) Error {
-	_, file, line, _ := runtime.Caller(1)
-	fmt.Printf("Warning recorded: %s at %s:%d\n", code.ToString(), file, line)
+	log.Printf("warning: %s: %v", code.ToString(), err)
 	return c
 }
@@ -3,17 +3,57 @@ package lib
 type ErrorCode string
 
 const (
-	SystemError          ErrorCode = "SystemError"
-	InvalidInputError    ErrorCode = "InvalidInputError"
-	PaginationError      ErrorCode = "PaginationError"
-	SerializationError   ErrorCode = "SerializationError"
-	InvalidByteLength    ErrorCode = "InvalidByteLength"
-	DeserializationError ErrorCode = "DeserializationError"
-	UnsupportedTypeError ErrorCode = "UnsupportedTypeError"
-	PanicFound           ErrorCode = "PanicFoundError"
-	InitError            ErrorCode = "InitError"
+	SystemError           ErrorCode = "SystemError"
+	InvalidInputError     ErrorCode = "InvalidInputError"
+	PaginationError       ErrorCode = "PaginationError"
+	SerializationError    ErrorCode = "SerializationError"
+	InvalidByteLength     ErrorCode = "InvalidByteLength"
+	DeserializationError  ErrorCode = "DeserializationError"
+	UnsupportedTypeError  ErrorCode = "UnsupportedTypeError"
+	PanicFound            ErrorCode = "PanicFoundError"
+	InitError             ErrorCode = "InitError"
+	BufferPoolError       ErrorCode = "BufferPoolError"
+	WALError              ErrorCode = "WALError"
+	FileFormatError       ErrorCode = "FileFormatError"
+	ChecksumMismatchError ErrorCode = "ChecksumMismatchError"
+	TransactionError      ErrorCode = "TransactionError"
+	SSTableError          ErrorCode = "SSTableError"
+	NetworkError          ErrorCode = "NetworkError"
 )
 
 func (e ErrorCode) ToString() string {
 	return string(e)
 }
+
+// Sentinels of the error codes, for use with errors.Is.
+var (
+	ErrSystem            = SystemError.Sentinel()
+	ErrInvalidInput      = InvalidInputError.Sentinel()
+	ErrPagination        = PaginationError.Sentinel()
+	ErrSerialization     = SerializationError.Sentinel()
+	ErrInvalidByteLength = InvalidByteLength.Sentinel()
+	ErrDeserialization   = DeserializationError.Sentinel()
+	ErrUnsupportedType   = UnsupportedTypeError.Sentinel()
+	ErrPanicFound        = PanicFound.Sentinel()
+	ErrInit              = InitError.Sentinel()
+	ErrBufferPool        = BufferPoolError.Sentinel()
+	ErrWAL               = WALError.Sentinel()
+	ErrFileFormat        = FileFormatError.Sentinel()
+	ErrChecksumMismatch  = ChecksumMismatchError.Sentinel()
+	ErrTransaction       = TransactionError.Sentinel()
+	ErrSSTable           = SSTableError.Sentinel()
+	ErrNetwork           = NetworkError.Sentinel()
+)
+
+// Sentinel returns the error that every error filed under e matches with
+// errors.Is.
+func (e ErrorCode) Sentinel() error {
+	return sentinel(e)
+}
+
+// sentinel is comparable, so the sentinels of equal codes are equal.
+type sentinel ErrorCode
+
+func (s sentinel) Error() string {
+	return string(s)
+}
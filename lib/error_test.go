@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestErrorIsAndAs(t *testing.T) {
+	cause := &fs.PathError{Op: "open", Path: "db", Err: fs.ErrNotExist}
+	err := EmptyError().AddErr(WALError, cause).AddErr(InitError, errors.New("init failed"))
+
+	var asError error = err
+	if !errors.Is(asError, ErrWAL) || !errors.Is(asError, ErrInit) {
+		t.Fatalf("errors.Is does not match the codes of %v", err)
+	}
+	if errors.Is(asError, ErrPagination) {
+		t.Fatalf("errors.Is matches a code %v does not have", err)
+	}
+	if !errors.Is(asError, fs.ErrNotExist) {
+		t.Fatalf("errors.Is does not reach the cause of %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(asError, &pathErr) || pathErr != cause {
+		t.Fatalf("errors.As does not reach the cause of %v", err)
+	}
+
+	want := "InitError: init failed; WALError: open db: file does not exist"
+	if got := err.Error(); got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorErr(t *testing.T) {
+	if err := EmptyError().Err(); err != nil {
+		t.Fatalf("empty Error converts to %v, want nil", err)
+	}
+	err := EmptyError().AddErr(SystemError, errors.New("boom")).Err()
+	if !errors.Is(err, ErrSystem) {
+		t.Fatalf("converted Error %v does not match ErrSystem", err)
+	}
+}
+
+func TestFromError(t *testing.T) {
+	if e := FromError(nil); e.IsNotEmpty() {
+		t.Fatalf("FromError(nil) = %v, want empty", e)
+	}
+
+	original := EmptyError().AddErr(TransactionError, errors.New("aborted"))
+	wrapped := fmtWrap(original)
+	if e := FromError(wrapped); !e.ContainsError(TransactionError) || len(e.Errors()) != 1 {
+		t.Fatalf("FromError does not unwrap to the original Error, got %v", e)
+	}
+
+	if e := FromError(fmtWrap(ErrChecksumMismatch)); !e.ContainsError(ChecksumMismatchError) {
+		t.Fatalf("FromError does not file a sentinel under its code, got %v", e)
+	}
+	if e := FromError(errors.New("plain")); !e.ContainsError(SystemError) {
+		t.Fatalf("FromError does not file a plain error under SystemError, got %v", e)
+	}
+}
+
+func TestRecovered(t *testing.T) {
+	recovered := func(f func()) (err Error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = Recovered(r)
+			}
+		}()
+		f()
+		return EmptyError()
+	}
+
+	err := recovered(func() { panic("bad page") })
+	if !errors.Is(err, ErrPanicFound) {
+		t.Fatalf("recovered panic %v does not match ErrPanicFound", err)
+	}
+
+	cause := errors.New("index out of range")
+	err = recovered(func() { panic(cause) })
+	if !errors.Is(err, cause) {
+		t.Fatalf("recovered panic %v does not wrap the panic's error", err)
+	}
+}
+
+func fmtWrap(err error) error {
+	return &wrapper{err: err}
+}
+
+type wrapper struct{ err error }
+
+func (w *wrapper) Error() string { return "wrapped: " + w.err.Error() }
+func (w *wrapper) Unwrap() error { return w.err }
@@ -0,0 +1,378 @@
+// Package engine opens a storage engine together with the pager stack it
+// runs on, for the commands and servers that let a user pick one.
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	btree "github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/node"
+	"github.com/Kush/Database-internals/DataStructures/B-trees/serializer"
+	hashing "github.com/Kush/Database-internals/DataStructures/Extendible-hashing/implementation"
+	lsm "github.com/Kush/Database-internals/DataStructures/LSM-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/bufferpool"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/diskStorage/wal"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+type Kind string
+
+const (
+	// BPlusTree stores the tree in a file, with its write-ahead log next to
+	// it as file.wal.
+	BPlusTree Kind = "btree"
+	// LSMTree stores its tables and logs in a directory.
+	LSMTree Kind = "lsm"
+	// ExtendibleHash stores the index in a file like BPlusTree. It cannot
+	// scan ranges.
+	ExtendibleHash Kind = "hash"
+)
+
+var Kinds = []Kind{BPlusTree, LSMTree, ExtendibleHash}
+
+// ParseKind accepts the names of the Kind constants.
+func ParseKind(s string) (Kind, lib.Error) {
+	for _, kind := range Kinds {
+		if string(kind) == s {
+			return kind, lib.EmptyError()
+		}
+	}
+	names := make([]string, len(Kinds))
+	for i, kind := range Kinds {
+		names[i] = string(kind)
+	}
+	return "", lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("unknown engine %q, want one of %s", s, strings.Join(names, ", ")))
+}
+
+const bufferPoolFrames = 64
+
+// ScanOptions mirrors the scan options of the ordered engines.
+type ScanOptions struct {
+	ExcludeStart bool
+	IncludeEnd   bool
+	Limit        int
+}
+
+// Engine is an initialized engine. Its Insert, Search and Delete go straight
+// to the engine and are safe for concurrent use.
+type Engine struct {
+	datastructures.BaseDatabaseStructure
+	kind Kind
+	path string
+	scan func(start, end string, options ScanOptions) datastructures.Iterator
+	// tree is set for the B+ tree, the only engine with transactions.
+	tree *btree.BPlusTree
+	// pager is nil for engines that manage their own files.
+	pager pagination.LoggedPagination
+	close func() lib.Error
+	// reserved is set on the view WithReservedKeys returns.
+	reserved bool
+}
+
+// Options are the settings an engine is created with.
+type Options struct {
+	// PageSize is the page size of a new B+ tree or hash file; 0 means
+	// pagination.PageSize. An existing file keeps its own, and a different
+	// PageSize is refused.
+	PageSize int
+}
+
+// Open opens or creates the engine of the given kind at path and recovers
+// the work its log holds.
+func Open(kind Kind, path string) (*Engine, lib.Error) {
+	return OpenWithOptions(kind, path, Options{})
+}
+
+func OpenWithOptions(kind Kind, path string, options Options) (*Engine, lib.Error) {
+	binarySerializer := serialization.NewBinarySerializer()
+	e := &Engine{kind: kind, path: path}
+
+	switch kind {
+	case LSMTree:
+		tree := lsm.NewLSMTree(path, binarySerializer)
+		if err := tree.Init(); err.IsNotEmpty() {
+			return nil, err
+		}
+		e.BaseDatabaseStructure, e.close = tree, tree.Close
+		e.scan = func(start, end string, options ScanOptions) datastructures.Iterator {
+			return tree.ScanWithOptions(start, end, lsm.ScanOptions(options))
+		}
+		return e, lib.EmptyError()
+
+	case BPlusTree, ExtendibleHash:
+		walPager, err := openLoggedPager(path, options.PageSize)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		e.pager, e.close = walPager, walPager.Close
+
+		if kind == BPlusTree {
+			tree := btree.NewBPlusTree(0, walPager, serializer.NewTreeNodeSerializer[*node.TreeNode](binarySerializer), binarySerializer)
+			e.BaseDatabaseStructure = tree
+			e.scan = func(start, end string, options ScanOptions) datastructures.Iterator {
+				return tree.ScanWithOptions(start, end, btree.ScanOptions(options))
+			}
+			e.tree = tree
+		} else {
+			e.BaseDatabaseStructure = hashing.NewExtendibleHash(walPager, binarySerializer)
+		}
+		if err := e.Init(); err.IsNotEmpty() {
+			walPager.Close()
+			return nil, err
+		}
+		return e, lib.EmptyError()
+	}
+	return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("unknown engine %q", kind))
+}
+
+// openLoggedPager stacks a write-ahead log on a buffer pool on the file.
+func openLoggedPager(path string, pageSize int) (*wal.Pager, lib.Error) {
+	stored, err := pagination.StoredPageSize(path)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	switch {
+	case stored != 0 && pageSize != 0 && stored != pageSize:
+		return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("%s uses %d byte pages, not %d", path, stored, pageSize))
+	case stored != 0:
+		pageSize = stored
+	case pageSize == 0:
+		pageSize = pagination.PageSize
+	}
+
+	filePager, err := pagination.NewPager(path)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := filePager.SetPageSize(pageSize); err.IsNotEmpty() {
+		filePager.Close()
+		return nil, err
+	}
+	bufferPool := bufferpool.NewBufferPool(filePager, bufferPoolFrames, bufferpool.NewLRUKReplacer(bufferPoolFrames, 2))
+	walPager, err := wal.NewPager(bufferPool, path+".wal")
+	if err.IsNotEmpty() {
+		bufferPool.Close()
+		return nil, err
+	}
+	return walPager, lib.EmptyError()
+}
+
+func (e *Engine) Kind() Kind {
+	return e.kind
+}
+
+func (e *Engine) Path() string {
+	return e.path
+}
+
+// Ordered reports whether the engine can scan key ranges.
+func (e *Engine) Ordered() bool {
+	return e.scan != nil
+}
+
+// Scan iterates over the keys from start to end in increasing order, with
+// the bounds of the engines' own scans: an empty start or end is unbounded.
+// Reserved keys are skipped and do not count towards the limit.
+func (e *Engine) Scan(start, end string, options ScanOptions) (datastructures.Iterator, lib.Error) {
+	if e.scan == nil {
+		return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("the %s engine cannot scan key ranges", e.kind))
+	}
+	if e.reserved {
+		return e.scan(start, end, options), lib.EmptyError()
+	}
+	limit := options.Limit
+	options.Limit = 0
+	return &visibleIterator{Iterator: e.scan(start, end, options), limit: limit}, lib.EmptyError()
+}
+
+// ReservedPrefix starts the keys that packages built on an engine keep next
+// to the plain entries, such as the tables of pkg/relational. An Engine
+// does not show them and refuses to write them, so that plain key-value
+// commands cannot see or damage them; WithReservedKeys lifts that.
+const ReservedPrefix = "\x00sql\x00"
+
+func Reserved(key string) bool {
+	return strings.HasPrefix(key, ReservedPrefix)
+}
+
+// CheckKey refuses a reserved key, for the writes that do not go through an
+// Engine, such as those of a transaction.
+func CheckKey(key string) lib.Error {
+	if Reserved(key) {
+		return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("key %q is reserved for SQL tables", key))
+	}
+	return lib.EmptyError()
+}
+
+// WithReservedKeys returns a view of e that reads and writes reserved keys
+// like any other. It shares e's files, so only one of them is closed.
+func (e *Engine) WithReservedKeys() *Engine {
+	view := *e
+	view.reserved = true
+	return &view
+}
+
+func (e *Engine) Insert(primaryKey string, value common.Value) lib.Error {
+	if !e.reserved {
+		if err := CheckKey(primaryKey); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return e.BaseDatabaseStructure.Insert(primaryKey, value)
+}
+
+// Search does not find reserved keys.
+func (e *Engine) Search(primaryKey string) (common.Value, bool, lib.Error) {
+	if !e.reserved && Reserved(primaryKey) {
+		return common.Value{}, false, lib.EmptyError()
+	}
+	return e.BaseDatabaseStructure.Search(primaryKey)
+}
+
+func (e *Engine) Delete(primaryKey string) lib.Error {
+	if !e.reserved {
+		if err := CheckKey(primaryKey); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return e.BaseDatabaseStructure.Delete(primaryKey)
+}
+
+// visibleIterator skips reserved keys and applies the limit of the scan,
+// which the engine's own iterator would apply counting them.
+type visibleIterator struct {
+	datastructures.Iterator
+	limit int
+	seen  int
+}
+
+func (it *visibleIterator) Next() bool {
+	if it.limit > 0 && it.seen == it.limit {
+		it.Close()
+		return false
+	}
+	for it.Iterator.Next() {
+		if !Reserved(it.Key()) {
+			it.seen++
+			return true
+		}
+	}
+	return false
+}
+
+// Op is one write of a batch: an insert, or a delete if Delete is set.
+type Op struct {
+	Key    string
+	Value  common.Value
+	Delete bool
+}
+
+// Atomic reports whether the engine has transactions, and so whether Batch
+// applies all of a batch or nothing.
+func (e *Engine) Atomic() bool {
+	return e.tree != nil
+}
+
+// Batch applies ops in order. On a B+ tree it runs them in one transaction;
+// the other engines apply them one by one and stop at the first failure,
+// keeping the writes before it.
+func (e *Engine) Batch(ops []Op) lib.Error {
+	if !e.reserved {
+		for _, op := range ops {
+			if err := CheckKey(op.Key); err.IsNotEmpty() {
+				return err
+			}
+		}
+	}
+	if e.tree == nil {
+		for _, op := range ops {
+			if err := apply(e, op); err.IsNotEmpty() {
+				return err
+			}
+		}
+		return lib.EmptyError()
+	}
+
+	tx, err := e.Begin()
+	if err.IsNotEmpty() {
+		return err
+	}
+	defer tx.Rollback()
+	for _, op := range ops {
+		if err := apply(tx, op); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Begin starts a transaction on an engine that has them; see
+// BPlusTree.Begin for what it holds up.
+func (e *Engine) Begin() (*btree.Tx, lib.Error) {
+	if e.tree == nil {
+		return nil, lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("the %s engine does not support transactions", e.kind))
+	}
+	return e.tree.Begin()
+}
+
+type writer interface {
+	Insert(primaryKey string, value common.Value) lib.Error
+	Delete(primaryKey string) lib.Error
+}
+
+func apply(w writer, op Op) lib.Error {
+	if op.Delete {
+		return w.Delete(op.Key)
+	}
+	return w.Insert(op.Key, op.Value)
+}
+
+// Stats describes the engine. The page counts are only set for the engines
+// stored in a paged file.
+type Stats struct {
+	Kind      Kind   `json:"kind"`
+	Path      string `json:"path"`
+	Ordered   bool   `json:"ordered"`
+	Atomic    bool   `json:"atomic_batches"`
+	PageSize  int    `json:"page_size,omitempty"`
+	Pages     int    `json:"pages,omitempty"`
+	FreePages int    `json:"free_pages,omitempty"`
+}
+
+func (e *Engine) Stats() (Stats, lib.Error) {
+	stats := Stats{Kind: e.kind, Path: e.path, Ordered: e.Ordered(), Atomic: e.Atomic()}
+	if e.pager == nil {
+		return stats, lib.EmptyError()
+	}
+	freePages, err := e.pager.FreePageCount()
+	if err.IsNotEmpty() {
+		return Stats{}, err
+	}
+	stats.PageSize, stats.Pages, stats.FreePages = e.pager.PageSize(), e.pager.NumPages(), freePages
+	return stats, lib.EmptyError()
+}
+
+// Sync commits whatever the pager still has staged. Every operation commits
+// on its own, so this only matters before shutting down.
+func (e *Engine) Sync() lib.Error {
+	switch {
+	case e.tree != nil:
+		return e.tree.Sync()
+	case e.pager == nil:
+		return lib.EmptyError()
+	}
+	return e.pager.Sync()
+}
+
+func (e *Engine) Close() lib.Error {
+	if err := e.Sync(); err.IsNotEmpty() {
+		e.close()
+		return err
+	}
+	return e.close()
+}
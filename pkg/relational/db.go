@@ -0,0 +1,523 @@
+// Package relational is a small SQL layer over the B+ tree engine: tables
+// with typed columns and a primary key, a catalog stored in the same file as
+// the rows, and statements run by an iterator model executor. See parser.go
+// for the statements it understands.
+package relational
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// DB runs statements against the tables of an engine. Each statement that
+// writes is applied in one transaction, so it changes all the rows it
+// should or none. It is safe for concurrent use.
+type DB struct {
+	engine *engine.Engine
+	// writeMu serializes the statements that write, so that what they check
+	// before writing, such as a primary key being free, still holds when
+	// they write. Writes made to the engine directly are not held up.
+	writeMu sync.Mutex
+}
+
+// Open needs a B+ tree engine, the one with transactions. The catalog is
+// read from the engine for every statement, so nothing is loaded up front.
+// The tables are kept under reserved keys, which e itself does not show.
+func Open(e *engine.Engine) (*DB, lib.Error) {
+	if !e.Atomic() {
+		return nil, invalid("SQL tables need the %s engine, not %s", engine.BPlusTree, e.Kind())
+	}
+	return &DB{engine: e.WithReservedKeys()}, lib.EmptyError()
+}
+
+// Rows is the result of a statement. A SELECT streams its rows: call Next
+// until it returns false, then check Err, and Close the Rows when stopping
+// early. Any other statement has run by the time Query returns and only
+// has a Tag.
+type Rows struct {
+	columns []string
+	plan    operator
+	row     []common.Value
+	err     lib.Error
+	tag     string
+}
+
+func (r *Rows) Columns() []string {
+	return r.columns
+}
+
+func (r *Rows) Next() bool {
+	if r.plan == nil {
+		return false
+	}
+	row, ok, err := r.plan.Next()
+	if !ok || err.IsNotEmpty() {
+		r.err = err
+		r.Close()
+		return false
+	}
+	r.row = row
+	return true
+}
+
+// Row is the row Next moved to, with a value per column.
+func (r *Rows) Row() []common.Value {
+	return r.row
+}
+
+func (r *Rows) Err() lib.Error {
+	return r.err
+}
+
+// Tag names what a statement other than SELECT did, in the style of
+// Postgres: INSERT 3, UPDATE 0, CREATE TABLE. It is empty for a SELECT.
+func (r *Rows) Tag() string {
+	return r.tag
+}
+
+// Close releases the scan of a SELECT. Closing Rows again does nothing.
+func (r *Rows) Close() {
+	if r.plan != nil {
+		r.plan.Close()
+		r.plan = nil
+	}
+}
+
+// Query runs one statement.
+func (db *DB) Query(query string) (*Rows, lib.Error) {
+	stmt, err := parse(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if s, ok := stmt.(*selectStatement); ok {
+		return db.query(s)
+	}
+
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+	var tag string
+	switch s := stmt.(type) {
+	case *createTableStatement:
+		tag, err = "CREATE TABLE", db.createTable(s)
+	case *dropTableStatement:
+		tag, err = "DROP TABLE", db.dropTable(s)
+	case *insertStatement:
+		var n int
+		n, err = db.insert(s)
+		tag = fmt.Sprintf("INSERT %d", n)
+	case *updateStatement:
+		var n int
+		n, err = db.update(s)
+		tag = fmt.Sprintf("UPDATE %d", n)
+	case *deleteStatement:
+		var n int
+		n, err = db.delete(s)
+		tag = fmt.Sprintf("DELETE %d", n)
+	}
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &Rows{tag: tag, err: lib.EmptyError()}, lib.EmptyError()
+}
+
+// Exec runs a statement for its effect. The rows of a SELECT are read to
+// the end and dropped.
+func (db *DB) Exec(query string) (string, lib.Error) {
+	rows, err := db.Query(query)
+	if err.IsNotEmpty() {
+		return "", err
+	}
+	for rows.Next() {
+	}
+	return rows.Tag(), rows.Err()
+}
+
+// Table reads the schema of a table from the catalog.
+func (db *DB) Table(name string) (*Schema, bool, lib.Error) {
+	stored, found, err := db.engine.Search(catalogKey(strings.ToLower(name)))
+	if err.IsNotEmpty() || !found {
+		return nil, false, err
+	}
+	schema, err := parseSchema(stored)
+	if err.IsNotEmpty() {
+		return nil, false, err
+	}
+	return schema, true, lib.EmptyError()
+}
+
+// Tables lists the schemas of every table in name order.
+func (db *DB) Tables() ([]*Schema, lib.Error) {
+	it, err := db.engine.Scan(catalogPrefix, catalogEnd(), engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	defer it.Close()
+	var schemas []*Schema
+	for it.Next() {
+		schema, err := parseSchema(it.Value())
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, it.Err()
+}
+
+// Dump calls emit with statements that recreate every table and its rows in
+// a database without them: the CREATE TABLE of each table in name order,
+// each followed by an INSERT per row. A string with a line break or a float
+// that is not finite has no literal a line of a script can hold, and fails
+// the dump.
+func (db *DB) Dump(emit func(statement string)) lib.Error {
+	schemas, err := db.Tables()
+	if err.IsNotEmpty() {
+		return err
+	}
+	for _, schema := range schemas {
+		emit(schema.String())
+		it, err := db.engine.Scan(tableStart(schema.Name), tableEnd(schema.Name), engine.ScanOptions{})
+		if err.IsNotEmpty() {
+			return err
+		}
+		for it.Next() {
+			row, err := decodeRow(it.Value(), len(schema.Columns))
+			if err.IsNotEmpty() {
+				it.Close()
+				return err
+			}
+			literals := make([]string, len(row))
+			for i, value := range row {
+				if literals[i], err = sqlLiteral(value); err.IsNotEmpty() {
+					it.Close()
+					return err
+				}
+			}
+			emit(fmt.Sprintf("INSERT INTO %s VALUES (%s)", schema.Name, strings.Join(literals, ", ")))
+		}
+		if err := it.Err(); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// sqlLiteral writes value the way the parser reads it back.
+func sqlLiteral(value common.Value) (string, lib.Error) {
+	switch value.Type() {
+	case common.NullType:
+		return "NULL", lib.EmptyError()
+	case common.BoolType:
+		return strings.ToUpper(strconv.FormatBool(value.BoolValue())), lib.EmptyError()
+	case common.IntType:
+		// Numbers carry no sign, and the smallest int64 has no positive
+		// counterpart.
+		if value.IntValue() == math.MinInt64 {
+			return "(-9223372036854775807 - 1)", lib.EmptyError()
+		}
+		return strconv.FormatInt(value.IntValue(), 10), lib.EmptyError()
+	case common.FloatType:
+		if f := value.FloatValue(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, 64), lib.EmptyError()
+		}
+	case common.StringType:
+		if !strings.ContainsAny(value.StringValue(), "\r\n") {
+			return "'" + strings.ReplaceAll(value.StringValue(), "'", "''") + "'", lib.EmptyError()
+		}
+	case common.BytesType:
+		return "X'" + hex.EncodeToString(value.BytesValue()) + "'", lib.EmptyError()
+	}
+	return "", invalid("%s %v has no SQL literal", typeName(value.Type()), value)
+}
+
+func parseSchema(stored common.Value) (*Schema, lib.Error) {
+	stmt, err := parse(stored.StringValue())
+	create, ok := stmt.(*createTableStatement)
+	if stored.Type() != common.StringType || err.IsNotEmpty() || !ok {
+		return nil, lib.EmptyError().AddErr(lib.FileFormatError, fmt.Errorf("catalog entry %s is not a CREATE TABLE statement", stored))
+	}
+	return create.schema, lib.EmptyError()
+}
+
+func (db *DB) mustTable(name string) (*Schema, lib.Error) {
+	schema, found, err := db.Table(name)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if !found {
+		return nil, invalid("no table named %s", name)
+	}
+	return schema, lib.EmptyError()
+}
+
+func (db *DB) query(s *selectStatement) (*Rows, lib.Error) {
+	schema, err := db.mustTable(s.table)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if s.where != nil {
+		if err := s.where.bind(schema); err.IsNotEmpty() {
+			return nil, err
+		}
+	}
+
+	root := planScan(db.engine, schema, s.where)
+	var columns []string
+	if s.items == nil {
+		for _, column := range schema.Columns {
+			columns = append(columns, column.Name)
+		}
+	} else {
+		values := make([]expr, len(s.items))
+		for i, item := range s.items {
+			if err := item.value.bind(schema); err.IsNotEmpty() {
+				return nil, err
+			}
+			values[i] = item.value
+			columns = append(columns, item.name)
+		}
+		root = &projection{child: root, values: values}
+	}
+	if s.limit > 0 {
+		root = &limit{child: root, n: s.limit}
+	}
+
+	if err := root.Open(); err.IsNotEmpty() {
+		root.Close()
+		return nil, err
+	}
+	return &Rows{columns: columns, plan: root, err: lib.EmptyError()}, lib.EmptyError()
+}
+
+func (db *DB) createTable(s *createTableStatement) lib.Error {
+	_, found, err := db.Table(s.schema.Name)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if found {
+		if s.ifNotExists {
+			return lib.EmptyError()
+		}
+		return invalid("table %s already exists", s.schema.Name)
+	}
+	return db.engine.Insert(catalogKey(s.schema.Name), common.NewStringValue(s.schema.String()))
+}
+
+// dropTable deletes the rows and the catalog entry of a table in one go.
+func (db *DB) dropTable(s *dropTableStatement) lib.Error {
+	_, found, err := db.Table(s.table)
+	if err.IsNotEmpty() {
+		return err
+	}
+	if !found {
+		if s.ifExists {
+			return lib.EmptyError()
+		}
+		return invalid("no table named %s", s.table)
+	}
+
+	it, err := db.engine.Scan(tableStart(s.table), tableEnd(s.table), engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		return err
+	}
+	var ops []engine.Op
+	for it.Next() {
+		ops = append(ops, engine.Op{Key: it.Key(), Delete: true})
+	}
+	it.Close()
+	if err := it.Err(); err.IsNotEmpty() {
+		return err
+	}
+	return db.write(append(ops, engine.Op{Key: catalogKey(s.table), Delete: true}))
+}
+
+func (db *DB) insert(s *insertStatement) (int, lib.Error) {
+	schema, err := db.mustTable(s.table)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	// positions[i] is the column the i-th value of a row goes to.
+	positions := make([]int, len(schema.Columns))
+	for i := range positions {
+		positions[i] = i
+	}
+	if s.columns != nil {
+		positions = positions[:0]
+		seen := make(map[int]bool)
+		for _, name := range s.columns {
+			i := schema.ColumnIndex(name)
+			if i < 0 {
+				return 0, invalid("table %s has no column %s", schema.Name, name)
+			}
+			if seen[i] {
+				return 0, invalid("column %s is listed twice", name)
+			}
+			seen[i] = true
+			positions = append(positions, i)
+		}
+	}
+
+	var ops []engine.Op
+	keys := make(map[string]bool)
+	for _, values := range s.rows {
+		if len(values) != len(positions) {
+			return 0, invalid("INSERT has %d values for %d columns", len(values), len(positions))
+		}
+		row := make([]common.Value, len(schema.Columns))
+		for i, value := range values {
+			if err := value.bind(nil); err.IsNotEmpty() {
+				return 0, err
+			}
+			if row[positions[i]], err = value.eval(nil); err.IsNotEmpty() {
+				return 0, err
+			}
+		}
+		if err := schema.checkRow(row); err.IsNotEmpty() {
+			return 0, err
+		}
+		key := rowKey(schema.Name, row[schema.PrimaryKey])
+		if err := db.claimKey(schema, key, row, keys, nil); err.IsNotEmpty() {
+			return 0, err
+		}
+		ops = append(ops, engine.Op{Key: key, Value: encodeRow(row)})
+	}
+	return len(ops), db.write(ops)
+}
+
+// claimKey adds the key of row to claimed, unless another row of the
+// statement has it already or a stored row keeps it, one that the statement
+// does not free.
+func (db *DB) claimKey(schema *Schema, key string, row []common.Value, claimed, freed map[string]bool) lib.Error {
+	duplicate := claimed[key]
+	if !duplicate && !freed[key] {
+		_, found, err := db.engine.Search(key)
+		if err.IsNotEmpty() {
+			return err
+		}
+		duplicate = found
+	}
+	if duplicate {
+		return invalid("duplicate primary key %s = %s in table %s", schema.Columns[schema.PrimaryKey].Name, row[schema.PrimaryKey], schema.Name)
+	}
+	claimed[key] = true
+	return lib.EmptyError()
+}
+
+// update computes every new row from the old one before writing any, so
+// SET a = b, b = a swaps the columns. A row whose primary key changes moves
+// to its new key.
+func (db *DB) update(s *updateStatement) (int, lib.Error) {
+	schema, err := db.mustTable(s.table)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	columns := make([]int, len(s.assignments))
+	for i, a := range s.assignments {
+		columns[i] = schema.ColumnIndex(a.column)
+		if columns[i] < 0 {
+			return 0, invalid("table %s has no column %s", schema.Name, a.column)
+		}
+		for _, earlier := range columns[:i] {
+			if earlier == columns[i] {
+				return 0, invalid("column %s is set twice", a.column)
+			}
+		}
+		if err := a.value.bind(schema); err.IsNotEmpty() {
+			return 0, err
+		}
+	}
+	rows, err := db.collect(schema, s.where)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+
+	oldKeys := make([]string, len(rows))
+	freed := make(map[string]bool)
+	for i, row := range rows {
+		oldKeys[i] = rowKey(schema.Name, row[schema.PrimaryKey])
+		freed[oldKeys[i]] = true
+	}
+	var inserts []engine.Op
+	newKeys := make(map[string]bool)
+	for _, row := range rows {
+		updated := append([]common.Value(nil), row...)
+		for i, a := range s.assignments {
+			if updated[columns[i]], err = a.value.eval(row); err.IsNotEmpty() {
+				return 0, err
+			}
+		}
+		if err := schema.checkRow(updated); err.IsNotEmpty() {
+			return 0, err
+		}
+		key := rowKey(schema.Name, updated[schema.PrimaryKey])
+		if err := db.claimKey(schema, key, updated, newKeys, freed); err.IsNotEmpty() {
+			return 0, err
+		}
+		inserts = append(inserts, engine.Op{Key: key, Value: encodeRow(updated)})
+	}
+
+	var ops []engine.Op
+	for _, key := range oldKeys {
+		if !newKeys[key] {
+			ops = append(ops, engine.Op{Key: key, Delete: true})
+		}
+	}
+	return len(rows), db.write(append(ops, inserts...))
+}
+
+func (db *DB) delete(s *deleteStatement) (int, lib.Error) {
+	schema, err := db.mustTable(s.table)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	rows, err := db.collect(schema, s.where)
+	if err.IsNotEmpty() {
+		return 0, err
+	}
+	ops := make([]engine.Op, len(rows))
+	for i, row := range rows {
+		ops[i] = engine.Op{Key: rowKey(schema.Name, row[schema.PrimaryKey]), Delete: true}
+	}
+	return len(rows), db.write(ops)
+}
+
+// collect reads every row where holds for, binding it first. They are all
+// read before any is written, so a statement never sees its own writes.
+func (db *DB) collect(schema *Schema, where expr) ([][]common.Value, lib.Error) {
+	if where != nil {
+		if err := where.bind(schema); err.IsNotEmpty() {
+			return nil, err
+		}
+	}
+	plan := planScan(db.engine, schema, where)
+	defer plan.Close()
+	if err := plan.Open(); err.IsNotEmpty() {
+		return nil, err
+	}
+	var rows [][]common.Value
+	for {
+		row, ok, err := plan.Next()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if !ok {
+			return rows, lib.EmptyError()
+		}
+		rows = append(rows, row)
+	}
+}
+
+// write applies the writes of a statement in one transaction.
+func (db *DB) write(ops []engine.Op) lib.Error {
+	if len(ops) == 0 {
+		return lib.EmptyError()
+	}
+	return db.engine.Batch(ops)
+}
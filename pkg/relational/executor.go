@@ -0,0 +1,280 @@
+package relational
+
+import (
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// operator is a node of a query plan in the iterator model: Open prepares
+// it, each Next pulls one row up from its children, and Close releases what
+// Open took. The root is pulled one row at a time, so a plan streams.
+type operator interface {
+	Open() lib.Error
+	// Next returns the next row, or false once there are no more.
+	Next() ([]common.Value, bool, lib.Error)
+	Close()
+}
+
+// tableScan reads the rows of a table whose keys are in [start, end).
+type tableScan struct {
+	engine     *engine.Engine
+	schema     *Schema
+	start, end string
+	it         datastructures.Iterator
+}
+
+func (s *tableScan) Open() lib.Error {
+	if s.start >= s.end {
+		return lib.EmptyError()
+	}
+	it, err := s.engine.Scan(s.start, s.end, engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		return err
+	}
+	s.it = it
+	return lib.EmptyError()
+}
+
+func (s *tableScan) Next() ([]common.Value, bool, lib.Error) {
+	if s.it == nil {
+		return nil, false, lib.EmptyError()
+	}
+	if !s.it.Next() {
+		return nil, false, s.it.Err()
+	}
+	row, err := decodeRow(s.it.Value(), len(s.schema.Columns))
+	if err.IsNotEmpty() {
+		return nil, false, err
+	}
+	return row, true, lib.EmptyError()
+}
+
+func (s *tableScan) Close() {
+	if s.it != nil {
+		s.it.Close()
+		s.it = nil
+	}
+}
+
+// filter passes on the rows predicate is true for.
+type filter struct {
+	child     operator
+	predicate expr
+}
+
+func (f *filter) Open() lib.Error {
+	return f.child.Open()
+}
+
+func (f *filter) Next() ([]common.Value, bool, lib.Error) {
+	for {
+		row, ok, err := f.child.Next()
+		if !ok || err.IsNotEmpty() {
+			return nil, false, err
+		}
+		keep, err := f.predicate.eval(row)
+		if err.IsNotEmpty() {
+			return nil, false, err
+		}
+		if keep.IsNull() {
+			continue
+		}
+		ok, err = truth(keep, "WHERE")
+		if err.IsNotEmpty() {
+			return nil, false, err
+		}
+		if ok {
+			return row, true, lib.EmptyError()
+		}
+	}
+}
+
+func (f *filter) Close() {
+	f.child.Close()
+}
+
+// projection computes the selected expressions of each row.
+type projection struct {
+	child  operator
+	values []expr
+}
+
+func (p *projection) Open() lib.Error {
+	return p.child.Open()
+}
+
+func (p *projection) Next() ([]common.Value, bool, lib.Error) {
+	row, ok, err := p.child.Next()
+	if !ok || err.IsNotEmpty() {
+		return nil, false, err
+	}
+	out := make([]common.Value, len(p.values))
+	for i, value := range p.values {
+		if out[i], err = value.eval(row); err.IsNotEmpty() {
+			return nil, false, err
+		}
+	}
+	return out, true, lib.EmptyError()
+}
+
+func (p *projection) Close() {
+	p.child.Close()
+}
+
+// limit stops after n rows without pulling more from its child.
+type limit struct {
+	child operator
+	n     int
+	seen  int
+}
+
+func (l *limit) Open() lib.Error {
+	l.seen = 0
+	return l.child.Open()
+}
+
+func (l *limit) Next() ([]common.Value, bool, lib.Error) {
+	if l.seen >= l.n {
+		return nil, false, lib.EmptyError()
+	}
+	row, ok, err := l.child.Next()
+	if ok {
+		l.seen++
+	}
+	return row, ok, err
+}
+
+func (l *limit) Close() {
+	l.child.Close()
+}
+
+// planScan returns the rows of the table that where holds for: a scan of
+// the primary key range the WHERE clause allows, filtered by the whole
+// clause. where must be bound to schema already.
+func planScan(e *engine.Engine, schema *Schema, where expr) operator {
+	start, end := keyRange(schema, where)
+	var root operator = &tableScan{engine: e, schema: schema, start: start, end: end}
+	if where != nil {
+		root = &filter{child: root, predicate: where}
+	}
+	return root
+}
+
+// keyBound is one end of a primary key range.
+type keyBound struct {
+	value     common.Value
+	inclusive bool
+	set       bool
+}
+
+// keyRange narrows the scan of a table to the keys that the comparisons of
+// the primary key with literals, ANDed at the top of where, allow. Anything
+// else is left to the filter.
+func keyRange(schema *Schema, where expr) (string, string) {
+	var lower, upper keyBound
+	for _, conjunct := range conjuncts(where) {
+		switch e := conjunct.(type) {
+		case *binaryExpr:
+			op, bound, ok := keyComparison(schema, e)
+			if !ok {
+				continue
+			}
+			switch op {
+			case "=":
+				lower.tighten(bound, true, 1)
+				upper.tighten(bound, true, -1)
+			case ">", ">=":
+				lower.tighten(bound, op == ">=", 1)
+			case "<", "<=":
+				upper.tighten(bound, op == "<=", -1)
+			}
+		case *betweenExpr:
+			if e.not || !isKeyColumn(schema, e.operand) {
+				continue
+			}
+			lo, loOK := keyLiteral(schema, e.lo)
+			hi, hiOK := keyLiteral(schema, e.hi)
+			if loOK && hiOK {
+				lower.tighten(lo, true, 1)
+				upper.tighten(hi, true, -1)
+			}
+		}
+	}
+
+	start, end := tableStart(schema.Name), tableEnd(schema.Name)
+	if lower.set {
+		start = rowKey(schema.Name, lower.value)
+		if !lower.inclusive {
+			start += "\x00"
+		}
+	}
+	if upper.set {
+		end = rowKey(schema.Name, upper.value)
+		if upper.inclusive {
+			end += "\x00"
+		}
+	}
+	return start, end
+}
+
+// tighten replaces the bound with value if that is more selective; direction
+// is 1 for a lower bound and -1 for an upper one.
+func (b *keyBound) tighten(value common.Value, inclusive bool, direction int) {
+	if b.set {
+		c := value.Compare(b.value) * direction
+		if c < 0 || (c == 0 && (inclusive || !b.inclusive)) {
+			return
+		}
+	}
+	*b = keyBound{value: value, inclusive: inclusive, set: true}
+}
+
+func conjuncts(e expr) []expr {
+	if and, ok := e.(*binaryExpr); ok && and.op == "AND" {
+		return append(conjuncts(and.left), conjuncts(and.right)...)
+	}
+	if e == nil {
+		return nil
+	}
+	return []expr{e}
+}
+
+// keyComparison reads pk op literal, or literal op pk, as a comparison of
+// the primary key.
+func keyComparison(schema *Schema, e *binaryExpr) (string, common.Value, bool) {
+	mirrored := map[string]string{"=": "=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+	op, ok := mirrored[e.op]
+	if !ok {
+		return "", common.Value{}, false
+	}
+	if isKeyColumn(schema, e.left) {
+		value, ok := keyLiteral(schema, e.right)
+		return e.op, value, ok
+	}
+	if isKeyColumn(schema, e.right) {
+		value, ok := keyLiteral(schema, e.left)
+		return op, value, ok
+	}
+	return "", common.Value{}, false
+}
+
+func isKeyColumn(schema *Schema, e expr) bool {
+	column, ok := e.(*columnRef)
+	return ok && column.index == schema.PrimaryKey
+}
+
+// keyLiteral converts a literal to a value of the primary key's type, if it
+// has one that compares the same way.
+func keyLiteral(schema *Schema, e expr) (common.Value, bool) {
+	lit, ok := e.(*literal)
+	if !ok || lit.value.IsNull() {
+		return common.Value{}, false
+	}
+	value, err := coerce(lit.value, schema.Columns[schema.PrimaryKey])
+	if err.IsNotEmpty() {
+		return common.Value{}, false
+	}
+	return value, schema.checkKey(value).IsEmpty()
+}
@@ -0,0 +1,292 @@
+package relational
+
+import (
+	"math"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// expr is a scalar expression over the columns of one row. Comparisons and
+// logic follow SQL's three-valued logic: NULL stands for unknown, so NULL =
+// NULL is NULL, and a WHERE clause only keeps the rows it is true for.
+type expr interface {
+	// bind resolves column names against schema, which is nil where no
+	// columns are in scope.
+	bind(schema *Schema) lib.Error
+	eval(row []common.Value) (common.Value, lib.Error)
+}
+
+type literal struct {
+	value common.Value
+}
+
+type columnRef struct {
+	name  string
+	index int
+}
+
+// unaryExpr is NOT or a minus sign.
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+// binaryExpr is AND, OR, a comparison or arithmetic.
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type betweenExpr struct {
+	operand, lo, hi expr
+	not             bool
+}
+
+type isNullExpr struct {
+	operand expr
+	not     bool
+}
+
+func (e *literal) bind(*Schema) lib.Error {
+	return lib.EmptyError()
+}
+
+func (e *literal) eval([]common.Value) (common.Value, lib.Error) {
+	return e.value, lib.EmptyError()
+}
+
+func (e *columnRef) bind(schema *Schema) lib.Error {
+	if schema == nil {
+		return invalid("column %s cannot be used here", e.name)
+	}
+	e.index = schema.ColumnIndex(e.name)
+	if e.index < 0 {
+		return invalid("table %s has no column %s", schema.Name, e.name)
+	}
+	return lib.EmptyError()
+}
+
+func (e *columnRef) eval(row []common.Value) (common.Value, lib.Error) {
+	return row[e.index], lib.EmptyError()
+}
+
+func (e *unaryExpr) bind(schema *Schema) lib.Error {
+	return e.operand.bind(schema)
+}
+
+func (e *unaryExpr) eval(row []common.Value) (common.Value, lib.Error) {
+	v, err := e.operand.eval(row)
+	if err.IsNotEmpty() || v.IsNull() {
+		return v, err
+	}
+	if e.op == "NOT" {
+		b, err := truth(v, "NOT")
+		if err.IsNotEmpty() {
+			return common.Value{}, err
+		}
+		return common.NewBoolValue(!b), lib.EmptyError()
+	}
+	switch v.Type() {
+	case common.IntType:
+		if v.IntValue() == math.MinInt64 {
+			return common.Value{}, invalid("-(%d) overflows int64", v.IntValue())
+		}
+		return common.NewIntValue(-v.IntValue()), lib.EmptyError()
+	case common.FloatType:
+		return common.NewFloatValue(-v.FloatValue()), lib.EmptyError()
+	}
+	return common.Value{}, invalid("cannot negate %s %s", typeName(v.Type()), v)
+}
+
+func (e *binaryExpr) bind(schema *Schema) lib.Error {
+	if err := e.left.bind(schema); err.IsNotEmpty() {
+		return err
+	}
+	return e.right.bind(schema)
+}
+
+func (e *binaryExpr) eval(row []common.Value) (common.Value, lib.Error) {
+	left, err := e.left.eval(row)
+	if err.IsNotEmpty() {
+		return common.Value{}, err
+	}
+	right, err := e.right.eval(row)
+	if err.IsNotEmpty() {
+		return common.Value{}, err
+	}
+
+	switch e.op {
+	case "AND", "OR":
+		return logic(e.op, left, right)
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+		if left.IsNull() || right.IsNull() {
+			return common.NewNullValue(), lib.EmptyError()
+		}
+		c, err := compare(left, right)
+		if err.IsNotEmpty() {
+			return common.Value{}, err
+		}
+		return common.NewBoolValue(holds(e.op, c)), lib.EmptyError()
+	}
+	if left.IsNull() || right.IsNull() {
+		return common.NewNullValue(), lib.EmptyError()
+	}
+	return arithmetic(e.op, left, right)
+}
+
+func (e *betweenExpr) bind(schema *Schema) lib.Error {
+	for _, operand := range []expr{e.operand, e.lo, e.hi} {
+		if err := operand.bind(schema); err.IsNotEmpty() {
+			return err
+		}
+	}
+	return lib.EmptyError()
+}
+
+// eval computes lo <= operand AND operand <= hi, negated for NOT BETWEEN.
+func (e *betweenExpr) eval(row []common.Value) (common.Value, lib.Error) {
+	above := &binaryExpr{op: "<=", left: e.lo, right: e.operand}
+	below := &binaryExpr{op: "<=", left: e.operand, right: e.hi}
+	var result expr = &binaryExpr{op: "AND", left: above, right: below}
+	if e.not {
+		result = &unaryExpr{op: "NOT", operand: result}
+	}
+	return result.eval(row)
+}
+
+func (e *isNullExpr) bind(schema *Schema) lib.Error {
+	return e.operand.bind(schema)
+}
+
+func (e *isNullExpr) eval(row []common.Value) (common.Value, lib.Error) {
+	v, err := e.operand.eval(row)
+	if err.IsNotEmpty() {
+		return common.Value{}, err
+	}
+	return common.NewBoolValue(v.IsNull() != e.not), lib.EmptyError()
+}
+
+// truth reads a non-NULL boolean operand of op.
+func truth(v common.Value, op string) (bool, lib.Error) {
+	if v.Type() != common.BoolType {
+		return false, invalid("%s needs booleans, got %s %s", op, typeName(v.Type()), v)
+	}
+	return v.BoolValue(), lib.EmptyError()
+}
+
+// logic is AND and OR over true, false and NULL: a false operand decides an
+// AND and a true one an OR, even when the other is NULL.
+func logic(op string, left, right common.Value) (common.Value, lib.Error) {
+	decisive := op == "OR"
+	unknown := false
+	for _, v := range []common.Value{left, right} {
+		if v.IsNull() {
+			unknown = true
+			continue
+		}
+		b, err := truth(v, op)
+		if err.IsNotEmpty() {
+			return common.Value{}, err
+		}
+		if b == decisive {
+			return common.NewBoolValue(decisive), lib.EmptyError()
+		}
+	}
+	if unknown {
+		return common.NewNullValue(), lib.EmptyError()
+	}
+	return common.NewBoolValue(!decisive), lib.EmptyError()
+}
+
+// compare orders two non-NULL values of the same type, or two numbers.
+func compare(left, right common.Value) (int, lib.Error) {
+	if left.Type() == right.Type() {
+		return left.Compare(right), lib.EmptyError()
+	}
+	if l, r, ok := asFloats(left, right); ok {
+		return common.NewFloatValue(l).Compare(common.NewFloatValue(r)), lib.EmptyError()
+	}
+	return 0, invalid("cannot compare %s %s with %s %s", typeName(left.Type()), left, typeName(right.Type()), right)
+}
+
+func holds(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// asFloats converts two numbers, at least one of them a float, to floats.
+func asFloats(left, right common.Value) (float64, float64, bool) {
+	l, lok := asFloat(left)
+	r, rok := asFloat(right)
+	return l, r, lok && rok
+}
+
+func asFloat(v common.Value) (float64, bool) {
+	switch v.Type() {
+	case common.IntType:
+		return float64(v.IntValue()), true
+	case common.FloatType:
+		return v.FloatValue(), true
+	}
+	return 0, false
+}
+
+// arithmetic applies + - * / to two numbers. Integers stay integers, and
+// overflowing int64 or dividing one by zero is an error; a float operand
+// makes the result a float.
+func arithmetic(op string, left, right common.Value) (common.Value, lib.Error) {
+	if left.Type() == common.IntType && right.Type() == common.IntType {
+		a, b := left.IntValue(), right.IntValue()
+		var r int64
+		overflow := false
+		switch op {
+		case "+":
+			r = a + b
+			overflow = (b > 0 && r < a) || (b < 0 && r > a)
+		case "-":
+			r = a - b
+			overflow = (b < 0 && r < a) || (b > 0 && r > a)
+		case "*":
+			r = a * b
+			overflow = a != 0 && (r/a != b || (a == -1 && b == math.MinInt64))
+		case "/":
+			if b == 0 {
+				return common.Value{}, invalid("division by zero")
+			}
+			if a == math.MinInt64 && b == -1 {
+				overflow = true
+			}
+			r = a / b
+		}
+		if overflow {
+			return common.Value{}, invalid("%d %s %d overflows int64", a, op, b)
+		}
+		return common.NewIntValue(r), lib.EmptyError()
+	}
+
+	a, b, ok := asFloats(left, right)
+	if !ok {
+		return common.Value{}, invalid("%s needs numbers, got %s %s and %s %s", op, typeName(left.Type()), left, typeName(right.Type()), right)
+	}
+	switch op {
+	case "+":
+		return common.NewFloatValue(a + b), lib.EmptyError()
+	case "-":
+		return common.NewFloatValue(a - b), lib.EmptyError()
+	case "*":
+		return common.NewFloatValue(a * b), lib.EmptyError()
+	}
+	return common.NewFloatValue(a / b), lib.EmptyError()
+}
@@ -0,0 +1,110 @@
+package relational
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	numberToken
+	bytesToken
+	symbolToken
+	endToken
+)
+
+type sqlToken struct {
+	kind tokenKind
+	text string
+}
+
+// symbols lists the operators and punctuation, two-byte ones first so that
+// they win over their one-byte prefixes.
+var symbols = []string{"<=", ">=", "<>", "!=", "=", "<", ">", "(", ")", ",", "*", "+", "-", "/", ";"}
+
+// tokenize splits a statement into words, literals and symbols. Strings are
+// in single quotes with a quote inside written twice, and X'00ff' is a bytes
+// literal. Numbers carry no sign; the parser reads a leading minus.
+func tokenize(query string) ([]sqlToken, lib.Error) {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			// A comment runs to the end of the line.
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '\'' || ((c == 'x' || c == 'X') && i+1 < len(query) && query[i+1] == '\''):
+			kind, start := stringToken, i+1
+			if c != '\'' {
+				kind, start = bytesToken, i+2
+			}
+			var b strings.Builder
+			j := start
+			for ; j < len(query); j++ {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						b.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(query[j])
+			}
+			if j >= len(query) {
+				return nil, syntaxError("unterminated string at %q", query[i:])
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: b.String()})
+			i = j + 1
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			j := i + 1
+			for j < len(query) && (isDigit(query[j]) || strings.IndexByte(".eE", query[j]) >= 0 || ((query[j] == '-' || query[j] == '+') && (query[j-1] == 'e' || query[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: numberToken, text: query[i:j]})
+			i = j
+		case isWordByte(c):
+			j := i + 1
+			for j < len(query) && (isWordByte(query[j]) || isDigit(query[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: wordToken, text: query[i:j]})
+			i = j
+		default:
+			symbol := ""
+			for _, s := range symbols {
+				if strings.HasPrefix(query[i:], s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol == "" {
+				return nil, syntaxError("unexpected character %q", c)
+			}
+			tokens = append(tokens, sqlToken{kind: symbolToken, text: symbol})
+			i += len(symbol)
+		}
+	}
+	return append(tokens, sqlToken{kind: endToken}), lib.EmptyError()
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func syntaxError(format string, args ...any) lib.Error {
+	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("syntax error: "+format, args...))
+}
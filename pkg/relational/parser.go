@@ -0,0 +1,651 @@
+package relational
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// The statements, with case-insensitive keywords and names:
+//
+//	CREATE TABLE [IF NOT EXISTS] t (col type [PRIMARY KEY] [NOT NULL], ...
+//	    [, PRIMARY KEY (col)])
+//	DROP TABLE [IF EXISTS] t
+//	INSERT INTO t [(col, ...)] VALUES (expr, ...) [, (expr, ...) ...]
+//	SELECT * | expr [AS name], ... FROM t [WHERE expr] [LIMIT n]
+//	UPDATE t SET col = expr [, col = expr ...] [WHERE expr]
+//	DELETE FROM t [WHERE expr]
+//
+// Types are INT, FLOAT, TEXT, BOOL and BYTES, with the usual aliases.
+// Expressions combine columns and literals with OR, AND, NOT, the
+// comparisons = != <> < <= > >=, [NOT] BETWEEN lo AND hi, IS [NOT] NULL
+// and + - * /. Literals are 'strings', numbers, TRUE, FALSE, NULL and
+// X'00ff' bytes.
+
+type createTableStatement struct {
+	schema      *Schema
+	ifNotExists bool
+}
+
+type dropTableStatement struct {
+	table    string
+	ifExists bool
+}
+
+type insertStatement struct {
+	table string
+	// columns is nil when the values are for every column in order.
+	columns []string
+	rows    [][]expr
+}
+
+type selectStatement struct {
+	table string
+	// items is nil for SELECT *.
+	items []selectItem
+	where expr
+	// limit is 0 when there is none.
+	limit int
+}
+
+type selectItem struct {
+	value expr
+	name  string
+}
+
+type updateStatement struct {
+	table       string
+	assignments []assignment
+	where       expr
+}
+
+type assignment struct {
+	column string
+	value  expr
+}
+
+type deleteStatement struct {
+	table string
+	where expr
+}
+
+type parser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func parse(query string) (any, lib.Error) {
+	tokens, err := tokenize(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var stmt any
+	switch {
+	case p.keyword("CREATE"):
+		stmt, err = p.createTable()
+	case p.keyword("DROP"):
+		stmt, err = p.dropTable()
+	case p.keyword("INSERT"):
+		stmt, err = p.insert()
+	case p.keyword("SELECT"):
+		stmt, err = p.selectStatement()
+	case p.keyword("UPDATE"):
+		stmt, err = p.update()
+	case p.keyword("DELETE"):
+		stmt, err = p.delete()
+	default:
+		return nil, syntaxError("expected CREATE, DROP, INSERT, SELECT, UPDATE or DELETE, got %s", p.describe())
+	}
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	p.symbol(";")
+	if p.peek().kind != endToken {
+		return nil, syntaxError("unexpected %s at the end", p.describe())
+	}
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) createTable() (*createTableStatement, lib.Error) {
+	if err := p.expectKeywords("TABLE"); err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt := &createTableStatement{}
+	if p.keyword("IF") {
+		if err := p.expectKeywords("NOT", "EXISTS"); err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.ifNotExists = true
+	}
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err.IsNotEmpty() {
+		return nil, err
+	}
+
+	schema := &Schema{Name: name, PrimaryKey: -1}
+	primaryKey := ""
+	for {
+		if p.keyword("PRIMARY") {
+			if err := p.expectKeywords("KEY"); err.IsNotEmpty() {
+				return nil, err
+			}
+			if primaryKey != "" {
+				return nil, syntaxError("table %s has more than one primary key", name)
+			}
+			if err := p.expectSymbol("("); err.IsNotEmpty() {
+				return nil, err
+			}
+			if primaryKey, err = p.name("column name"); err.IsNotEmpty() {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err.IsNotEmpty() {
+				return nil, err
+			}
+		} else {
+			column, isKey, err := p.columnDefinition()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			if isKey {
+				if primaryKey != "" {
+					return nil, syntaxError("table %s has more than one primary key", name)
+				}
+				primaryKey = column.Name
+			}
+			schema.Columns = append(schema.Columns, column)
+		}
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err.IsNotEmpty() {
+		return nil, err
+	}
+
+	if primaryKey != "" {
+		schema.PrimaryKey = schema.ColumnIndex(primaryKey)
+		if schema.PrimaryKey < 0 {
+			return nil, invalid("primary key %s is not a column of table %s", primaryKey, name)
+		}
+	}
+	if err := schema.validate(); err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt.schema = schema
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) columnDefinition() (Column, bool, lib.Error) {
+	name, err := p.name("column name")
+	if err.IsNotEmpty() {
+		return Column{}, false, err
+	}
+	t := p.next()
+	valueType, ok := parseType(t.text)
+	if t.kind != wordToken || !ok {
+		return Column{}, false, syntaxError("expected the type of column %s, got %s", name, t.text)
+	}
+	column, isKey := Column{Name: name, Type: valueType}, false
+	for {
+		switch {
+		case p.keyword("PRIMARY"):
+			if err := p.expectKeywords("KEY"); err.IsNotEmpty() {
+				return Column{}, false, err
+			}
+			isKey = true
+		case p.keyword("NOT"):
+			if err := p.expectKeywords("NULL"); err.IsNotEmpty() {
+				return Column{}, false, err
+			}
+			column.NotNull = true
+		default:
+			return column, isKey, lib.EmptyError()
+		}
+	}
+}
+
+func (p *parser) dropTable() (*dropTableStatement, lib.Error) {
+	if err := p.expectKeywords("TABLE"); err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt := &dropTableStatement{}
+	if p.keyword("IF") {
+		if err := p.expectKeywords("EXISTS"); err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.ifExists = true
+	}
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt.table = name
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) insert() (*insertStatement, lib.Error) {
+	if err := p.expectKeywords("INTO"); err.IsNotEmpty() {
+		return nil, err
+	}
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt := &insertStatement{table: name}
+	if p.symbol("(") {
+		for {
+			column, err := p.name("column name")
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, column)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err.IsNotEmpty() {
+			return nil, err
+		}
+	}
+	if err := p.expectKeywords("VALUES"); err.IsNotEmpty() {
+		return nil, err
+	}
+	for {
+		if err := p.expectSymbol("("); err.IsNotEmpty() {
+			return nil, err
+		}
+		var row []expr
+		for {
+			value, err := p.expression()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			row = append(row, value)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.rows = append(stmt.rows, row)
+		if !p.symbol(",") {
+			return stmt, lib.EmptyError()
+		}
+	}
+}
+
+func (p *parser) selectStatement() (*selectStatement, lib.Error) {
+	stmt := &selectStatement{}
+	if !p.symbol("*") {
+		for {
+			value, err := p.expression()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			item := selectItem{value: value}
+			if p.keyword("AS") {
+				if item.name, err = p.name("column alias"); err.IsNotEmpty() {
+					return nil, err
+				}
+			} else if column, ok := value.(*columnRef); ok {
+				item.name = column.name
+			} else {
+				item.name = "column" + strconv.Itoa(len(stmt.items)+1)
+			}
+			stmt.items = append(stmt.items, item)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if err := p.expectKeywords("FROM"); err.IsNotEmpty() {
+		return nil, err
+	}
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt.table = name
+	if stmt.where, err = p.where(); err.IsNotEmpty() {
+		return nil, err
+	}
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, e := strconv.Atoi(t.text)
+		if t.kind != numberToken || e != nil || n < 1 {
+			return nil, syntaxError("LIMIT needs a positive integer, got %s", t.text)
+		}
+		stmt.limit = n
+	}
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) update() (*updateStatement, lib.Error) {
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := p.expectKeywords("SET"); err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt := &updateStatement{table: name}
+	for {
+		column, err := p.name("column name")
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err.IsNotEmpty() {
+			return nil, err
+		}
+		value, err := p.expression()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.assignments = append(stmt.assignments, assignment{column: column, value: value})
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if stmt.where, err = p.where(); err.IsNotEmpty() {
+		return nil, err
+	}
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) delete() (*deleteStatement, lib.Error) {
+	if err := p.expectKeywords("FROM"); err.IsNotEmpty() {
+		return nil, err
+	}
+	name, err := p.name("table name")
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	stmt := &deleteStatement{table: name}
+	if stmt.where, err = p.where(); err.IsNotEmpty() {
+		return nil, err
+	}
+	return stmt, lib.EmptyError()
+}
+
+// where reads an optional WHERE clause; the expression is nil without one.
+func (p *parser) where() (expr, lib.Error) {
+	if !p.keyword("WHERE") {
+		return nil, lib.EmptyError()
+	}
+	return p.expression()
+}
+
+// expression reads an expression, lowest precedence first: OR, AND, NOT,
+// comparisons, + and -, * and /, unary minus.
+func (p *parser) expression() (expr, lib.Error) {
+	left, err := p.conjunction()
+	for err.IsEmpty() && p.keyword("OR") {
+		var right expr
+		if right, err = p.conjunction(); err.IsEmpty() {
+			left = &binaryExpr{op: "OR", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) conjunction() (expr, lib.Error) {
+	left, err := p.negation()
+	for err.IsEmpty() && p.keyword("AND") {
+		var right expr
+		if right, err = p.negation(); err.IsEmpty() {
+			left = &binaryExpr{op: "AND", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) negation() (expr, lib.Error) {
+	if p.keyword("NOT") {
+		operand, err := p.negation()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", operand: operand}, lib.EmptyError()
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, lib.Error) {
+	left, err := p.sum()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.symbol(op) {
+			right, err := p.sum()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			return &binaryExpr{op: op, left: left, right: right}, lib.EmptyError()
+		}
+	}
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if err := p.expectKeywords("NULL"); err.IsNotEmpty() {
+			return nil, err
+		}
+		return &isNullExpr{operand: left, not: not}, lib.EmptyError()
+	}
+
+	start := p.pos
+	not := p.keyword("NOT")
+	if !p.keyword("BETWEEN") {
+		p.pos = start
+		return left, lib.EmptyError()
+	}
+	lo, err := p.sum()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := p.expectKeywords("AND"); err.IsNotEmpty() {
+		return nil, err
+	}
+	hi, err := p.sum()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &betweenExpr{operand: left, lo: lo, hi: hi, not: not}, lib.EmptyError()
+}
+
+func (p *parser) sum() (expr, lib.Error) {
+	left, err := p.product()
+	for err.IsEmpty() {
+		op := ""
+		switch {
+		case p.symbol("+"):
+			op = "+"
+		case p.symbol("-"):
+			op = "-"
+		default:
+			return left, lib.EmptyError()
+		}
+		var right expr
+		if right, err = p.product(); err.IsEmpty() {
+			left = &binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) product() (expr, lib.Error) {
+	left, err := p.unary()
+	for err.IsEmpty() {
+		op := ""
+		switch {
+		case p.symbol("*"):
+			op = "*"
+		case p.symbol("/"):
+			op = "/"
+		default:
+			return left, lib.EmptyError()
+		}
+		var right expr
+		if right, err = p.unary(); err.IsEmpty() {
+			left = &binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) unary() (expr, lib.Error) {
+	if !p.symbol("-") {
+		return p.primary()
+	}
+	// A negative number is read whole, so that the smallest int64 fits.
+	if t := p.peek(); t.kind == numberToken {
+		p.pos++
+		return number("-" + t.text)
+	}
+	operand, err := p.unary()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &unaryExpr{op: "-", operand: operand}, lib.EmptyError()
+}
+
+func (p *parser) primary() (expr, lib.Error) {
+	t := p.next()
+	switch t.kind {
+	case stringToken:
+		return &literal{value: common.NewStringValue(t.text)}, lib.EmptyError()
+	case bytesToken:
+		b, e := hex.DecodeString(t.text)
+		if e != nil {
+			return nil, syntaxError("invalid bytes literal X'%s'", t.text)
+		}
+		return &literal{value: common.NewBytesValue(b)}, lib.EmptyError()
+	case numberToken:
+		return number(t.text)
+	case symbolToken:
+		if t.text == "(" {
+			inner, err := p.expression()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err.IsNotEmpty() {
+				return nil, err
+			}
+			return inner, lib.EmptyError()
+		}
+	case wordToken:
+		switch strings.ToUpper(t.text) {
+		case "TRUE", "FALSE":
+			return &literal{value: common.NewBoolValue(strings.EqualFold(t.text, "TRUE"))}, lib.EmptyError()
+		case "NULL":
+			return &literal{value: common.NewNullValue()}, lib.EmptyError()
+		}
+		if !isReserved(t.text) {
+			return &columnRef{name: strings.ToLower(t.text)}, lib.EmptyError()
+		}
+	}
+	if t.kind != endToken {
+		p.pos--
+	}
+	return nil, syntaxError("expected an expression, got %s", p.describe())
+}
+
+func number(text string) (expr, lib.Error) {
+	if n, e := strconv.ParseInt(text, 10, 64); e == nil {
+		return &literal{value: common.NewIntValue(n)}, lib.EmptyError()
+	}
+	f, e := strconv.ParseFloat(text, 64)
+	if e != nil {
+		return nil, syntaxError("invalid number %s", text)
+	}
+	return &literal{value: common.NewFloatValue(f)}, lib.EmptyError()
+}
+
+// reserved are the keywords that cannot name a table or column.
+var reserved = []string{
+	"AND", "AS", "BETWEEN", "CREATE", "DELETE", "DROP", "FALSE", "FROM", "IF", "INSERT", "INTO", "IS", "LIMIT",
+	"NOT", "NULL", "OR", "PRIMARY", "SELECT", "SET", "TABLE", "TRUE", "UPDATE", "VALUES", "WHERE",
+}
+
+func isReserved(word string) bool {
+	for _, r := range reserved {
+		if strings.EqualFold(r, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// name reads a table or column name. Names are folded to lower case.
+func (p *parser) name(what string) (string, lib.Error) {
+	t := p.peek()
+	if t.kind != wordToken || isReserved(t.text) {
+		return "", syntaxError("expected a %s, got %s", what, p.describe())
+	}
+	p.pos++
+	return strings.ToLower(t.text), lib.EmptyError()
+}
+
+func (p *parser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() sqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == wordToken && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeywords(words ...string) lib.Error {
+	for _, word := range words {
+		if !p.keyword(word) {
+			return syntaxError("expected %s, got %s", word, p.describe())
+		}
+	}
+	return lib.EmptyError()
+}
+
+func (p *parser) symbol(s string) bool {
+	if t := p.peek(); t.kind == symbolToken && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(s string) lib.Error {
+	if !p.symbol(s) {
+		return syntaxError("expected %s, got %s", s, p.describe())
+	}
+	return lib.EmptyError()
+}
+
+func (p *parser) describe() string {
+	t := p.peek()
+	switch t.kind {
+	case endToken:
+		return "end of statement"
+	case stringToken:
+		return "'" + t.text + "'"
+	case bytesToken:
+		return "X'" + t.text + "'"
+	}
+	return t.text
+}
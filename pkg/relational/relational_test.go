@@ -0,0 +1,375 @@
+package relational
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+func openEngine(t *testing.T, kind engine.Kind, path string) *engine.Engine {
+	t.Helper()
+	e, err := engine.Open(kind, path)
+	if err.IsNotEmpty() {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	return e
+}
+
+func openDB(t *testing.T, path string) *DB {
+	t.Helper()
+	e := openEngine(t, engine.BPlusTree, path)
+	t.Cleanup(func() {
+		if err := e.Close(); err.IsNotEmpty() {
+			t.Errorf("close: %v", err)
+		}
+	})
+	db, err := Open(e)
+	if err.IsNotEmpty() {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func mustExec(t *testing.T, db *DB, query string) string {
+	t.Helper()
+	tag, err := db.Exec(query)
+	if err.IsNotEmpty() {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return tag
+}
+
+// query returns the rows of a SELECT with the values of each row joined
+// by commas.
+func query(t *testing.T, db *DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err.IsNotEmpty() {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	got := []string{}
+	for rows.Next() {
+		values := make([]string, len(rows.Row()))
+		for i, v := range rows.Row() {
+			values[i] = v.String()
+		}
+		got = append(got, strings.Join(values, ","))
+	}
+	if err := rows.Err(); err.IsNotEmpty() {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return got
+}
+
+func TestStatements(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "sql.db"))
+
+	mustExec(t, db, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT NOT NULL, score FLOAT, admin BOOL)")
+	if tag := mustExec(t, db, "INSERT INTO users VALUES (3, 'carol', 7.5, FALSE), (1, 'alice', 9, TRUE), (-2, 'bob', NULL, FALSE)"); tag != "INSERT 3" {
+		t.Fatalf("insert tag = %q", tag)
+	}
+	mustExec(t, db, "insert into USERS (name, id) values ('dave', 10);")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT * FROM users", []string{"-2,bob,NULL,false", "1,alice,9,true", "3,carol,7.5,false", "10,dave,NULL,NULL"}},
+		{"SELECT name FROM users WHERE id = 3", []string{"carol"}},
+		{"SELECT id FROM users WHERE id > 1", []string{"3", "10"}},
+		{"SELECT id FROM users WHERE 1 >= id", []string{"-2", "1"}},
+		{"SELECT id FROM users WHERE id BETWEEN 0 AND 3 AND admin = FALSE", []string{"3"}},
+		{"SELECT id FROM users WHERE id NOT BETWEEN 0 AND 3", []string{"-2", "10"}},
+		{"SELECT name FROM users WHERE score IS NULL", []string{"bob", "dave"}},
+		{"SELECT name FROM users WHERE score > 8 OR name = 'bob'", []string{"bob", "alice"}},
+		{"SELECT name FROM users WHERE NOT (score < 8)", []string{"alice"}},
+		{"SELECT name, score * 2 AS doubled, id + 1 FROM users WHERE score <> 9", []string{"carol,15,4"}},
+		{"SELECT id FROM users LIMIT 2", []string{"-2", "1"}},
+		{"SELECT id FROM users WHERE id > 5 AND id < 2", []string{}},
+	}
+	for _, tt := range tests {
+		if got := query(t, db, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	rows, err := db.Query("SELECT name, score * 2 AS doubled, id + 1 FROM users LIMIT 1")
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if got := rows.Columns(); !reflect.DeepEqual(got, []string{"name", "doubled", "column3"}) {
+		t.Errorf("columns = %q", got)
+	}
+
+	if tag := mustExec(t, db, "UPDATE users SET score = score + 1, admin = TRUE WHERE score IS NOT NULL"); tag != "UPDATE 2" {
+		t.Fatalf("update tag = %q", tag)
+	}
+	// Moving rows to keys the statement frees is allowed.
+	mustExec(t, db, "UPDATE users SET id = id + 1 WHERE id >= 1")
+	if got, want := query(t, db, "SELECT id, score, admin FROM users"), []string{"-2,NULL,false", "2,10,true", "4,8.5,true", "11,NULL,NULL"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after update = %q, want %q", got, want)
+	}
+
+	if tag := mustExec(t, db, "DELETE FROM users WHERE admin"); tag != "DELETE 2" {
+		t.Fatalf("delete tag = %q", tag)
+	}
+	if got := query(t, db, "SELECT name FROM users"); !reflect.DeepEqual(got, []string{"bob", "dave"}) {
+		t.Fatalf("after delete = %q", got)
+	}
+
+	mustExec(t, db, "CREATE TABLE tags (name TEXT, data BYTES, PRIMARY KEY (name))")
+	mustExec(t, db, "CREATE TABLE IF NOT EXISTS tags (x INT PRIMARY KEY)")
+	mustExec(t, db, "INSERT INTO tags VALUES ('b', X'00ff'), ('a', NULL), ('', X'')")
+	if got := query(t, db, "SELECT * FROM tags WHERE name < 'b'"); !reflect.DeepEqual(got, []string{",0x", "a,NULL"}) {
+		t.Fatalf("tags = %q", got)
+	}
+
+	tables, err := db.Tables()
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0].String() != "CREATE TABLE tags (name TEXT PRIMARY KEY, data BYTES)" {
+		t.Fatalf("tables = %v", tables)
+	}
+	mustExec(t, db, "DROP TABLE tags")
+	mustExec(t, db, "DROP TABLE IF EXISTS tags")
+	if _, found, _ := db.Table("tags"); found {
+		t.Fatal("tags still exists after DROP TABLE")
+	}
+	it, _ := db.engine.Scan(tableStart("tags"), tableEnd("tags"), engine.ScanOptions{})
+	defer it.Close()
+	if it.Next() {
+		t.Fatalf("DROP TABLE left row %q", it.Key())
+	}
+}
+
+func TestCatalogPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sql.db")
+	e := openEngine(t, engine.BPlusTree, path)
+	db, err := Open(e)
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	mustExec(t, db, "CREATE TABLE t (k TEXT PRIMARY KEY, v INT)")
+	mustExec(t, db, "INSERT INTO t VALUES ('x', 1), ('y', 2)")
+	if err := e.Close(); err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+
+	db = openDB(t, path)
+	schema, found, err := db.Table("T")
+	if err.IsNotEmpty() || !found {
+		t.Fatalf("table t: found %t, %v", found, err)
+	}
+	if schema.PrimaryKey != 0 || len(schema.Columns) != 2 || schema.Columns[1].Type != common.IntType {
+		t.Fatalf("schema = %s", schema)
+	}
+	if got := query(t, db, "SELECT v FROM t"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Fatalf("rows = %q", got)
+	}
+}
+
+func TestDumpAndHiddenKeys(t *testing.T) {
+	dir := t.TempDir()
+	e := openEngine(t, engine.BPlusTree, filepath.Join(dir, "sql.db"))
+	defer e.Close()
+	db, err := Open(e)
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	mustExec(t, db, "CREATE TABLE t (k INT PRIMARY KEY, f FLOAT, s TEXT, b BYTES, ok BOOL)")
+	mustExec(t, db, "INSERT INTO t VALUES (-9223372036854775807 - 1, -2.5e-300, 'it''s', X'00ff', TRUE), (7, 2, NULL, X'', FALSE)")
+	if err := e.Insert("plain", common.NewIntValue(1)); err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+
+	// The engine shows only the plain entry and refuses the table keys.
+	it, err := e.Scan("", "", engine.ScanOptions{})
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Err(); err.IsNotEmpty() || !reflect.DeepEqual(keys, []string{"plain"}) {
+		t.Fatalf("scan found %q, %v", keys, err)
+	}
+	if _, found, _ := e.Search(catalogKey("t")); found {
+		t.Fatal("search found the catalog entry")
+	}
+	if err := e.Delete(catalogKey("t")); !err.ContainsError(lib.InvalidInputError) {
+		t.Fatalf("delete the catalog entry: %v", err)
+	}
+	if err := e.Batch([]engine.Op{{Key: "other", Value: common.NewIntValue(2)}, {Key: rowKey("t", common.NewIntValue(7)), Delete: true}}); !err.ContainsError(lib.InvalidInputError) {
+		t.Fatalf("batch deleting a row: %v", err)
+	}
+	if _, found, _ := e.Search("other"); found {
+		t.Fatal("the refused batch was applied in part")
+	}
+
+	var statements []string
+	if err := db.Dump(func(statement string) { statements = append(statements, statement) }); err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	other := openDB(t, filepath.Join(dir, "other.db"))
+	for _, statement := range statements {
+		mustExec(t, other, statement)
+	}
+	want := []string{"-9223372036854775808,-2.5e-300,it's,0x00ff,true", "7,2,NULL,0x,false"}
+	for _, d := range []*DB{db, other} {
+		if got := query(t, d, "SELECT * FROM t"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("rows = %q, want %q", got, want)
+		}
+	}
+
+	mustExec(t, db, "INSERT INTO t VALUES (8, 1, 'two\nlines', NULL, NULL)")
+	if err := db.Dump(func(string) {}); !err.ContainsError(lib.InvalidInputError) {
+		t.Fatalf("dump a string with a line break: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "sql.db"))
+	mustExec(t, db, "CREATE TABLE t (id INT PRIMARY KEY, f FLOAT, s TEXT NOT NULL)")
+	mustExec(t, db, "INSERT INTO t VALUES (1, 1.5, 'a'), (2, 2, 'b')")
+
+	bad := []string{
+		"SELEC * FROM t",
+		"SELECT * FROM t WHERE",
+		"SELECT * FROM t LIMIT 0",
+		"SELECT * FROM missing",
+		"SELECT nope FROM t",
+		"SELECT * FROM t WHERE s = 1",
+		"SELECT * FROM t WHERE id / 0 = 1",
+		"SELECT * FROM t WHERE id",
+		"CREATE TABLE t (id INT PRIMARY KEY)",
+		"CREATE TABLE u (id INT)",
+		"CREATE TABLE u (id INT PRIMARY KEY, id TEXT)",
+		"CREATE TABLE u (id INT PRIMARY KEY, x DATE)",
+		"CREATE TABLE select (id INT PRIMARY KEY)",
+		"INSERT INTO t VALUES (1, 0.5, 'dup')",
+		"INSERT INTO t VALUES (3, 0.5, 'x'), (3, 0.5, 'y')",
+		"INSERT INTO t VALUES (NULL, 0.5, 'x')",
+		"INSERT INTO t VALUES (3, 0.5, NULL)",
+		"INSERT INTO t VALUES (3, 'x', 'y')",
+		"INSERT INTO t VALUES (3.5, 1, 'y')",
+		"INSERT INTO t VALUES (3, 1)",
+		"INSERT INTO t (id, id) VALUES (3, 4)",
+		"INSERT INTO t VALUES (id, 1, 'x')",
+		"INSERT INTO t VALUES (9223372036854775807 + 1, 1, 'x')",
+		"UPDATE t SET id = 2 WHERE id = 1",
+		"UPDATE t SET s = NULL",
+		"UPDATE t SET f = 1, f = 2",
+		"DROP TABLE missing",
+		"DELETE FROM t WHERE 'unterminated",
+	}
+	for _, q := range bad {
+		_, err := db.Exec(q)
+		if err.IsEmpty() {
+			t.Errorf("%s: no error", q)
+		} else if !errors.Is(err, lib.ErrInvalidInput) {
+			t.Errorf("%s: %v is not an invalid input error", q, err)
+		}
+	}
+	// None of the failed statements wrote anything.
+	if got := query(t, db, "SELECT * FROM t"); !reflect.DeepEqual(got, []string{"1,1.5,a", "2,2,b"}) {
+		t.Fatalf("rows = %q", got)
+	}
+
+	lsm := openEngine(t, engine.LSMTree, filepath.Join(t.TempDir(), "lsm"))
+	defer lsm.Close()
+	if _, err := Open(lsm); err.IsEmpty() {
+		t.Fatal("Open accepted an LSM tree")
+	}
+}
+
+func TestKeyEncodingOrder(t *testing.T) {
+	values := [][]common.Value{
+		{common.NewIntValue(math.MinInt64), common.NewIntValue(-1), common.NewIntValue(0), common.NewIntValue(1), common.NewIntValue(math.MaxInt64)},
+		{common.NewFloatValue(math.Inf(-1)), common.NewFloatValue(-2.5), common.NewFloatValue(-math.SmallestNonzeroFloat64), common.NewFloatValue(0), common.NewFloatValue(0.5), common.NewFloatValue(math.Inf(1))},
+		{common.NewStringValue(""), common.NewStringValue("a"), common.NewStringValue("a\x00"), common.NewStringValue("b")},
+		{common.NewBoolValue(false), common.NewBoolValue(true)},
+	}
+	for _, ordered := range values {
+		keys := make([]string, len(ordered))
+		for i, v := range ordered {
+			keys[i] = rowKey("t", v)
+		}
+		if !sort.StringsAreSorted(keys) {
+			t.Errorf("keys of %v are out of order", ordered)
+		}
+	}
+	if rowKey("t", common.NewFloatValue(math.Copysign(0, -1))) != rowKey("t", common.NewFloatValue(0)) {
+		t.Error("-0 and 0 have different keys")
+	}
+}
+
+func TestRowEncoding(t *testing.T) {
+	row := []common.Value{
+		common.NewNullValue(), common.NewBoolValue(true), common.NewIntValue(-7), common.NewFloatValue(2.5),
+		common.NewStringValue("text"), common.NewBytesValue([]byte{0, 1, 2}),
+	}
+	got, err := decodeRow(encodeRow(row), len(row))
+	if err.IsNotEmpty() {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, row) {
+		t.Fatalf("decoded %v, want %v", got, row)
+	}
+
+	encoded := encodeRow(row).BytesValue()
+	for _, corrupt := range []common.Value{
+		common.NewStringValue("row"),
+		common.NewBytesValue(encoded[:len(encoded)-1]),
+		common.NewBytesValue(append(encoded, 0)),
+	} {
+		if _, err := decodeRow(corrupt, len(row)); !errors.Is(err, lib.ErrDeserialization) {
+			t.Errorf("decoding %v: %v", corrupt, err)
+		}
+	}
+	if _, err := decodeRow(encodeRow(row), len(row)+1); err.IsEmpty() {
+		t.Error("decoded a row with a column missing")
+	}
+}
+
+func TestKeyRange(t *testing.T) {
+	schema := &Schema{Name: "t", Columns: []Column{{Name: "id", Type: common.FloatType}, {Name: "x", Type: common.IntType}}}
+	key := func(f float64) string { return rowKey("t", common.NewFloatValue(f)) }
+
+	tests := []struct {
+		where      string
+		start, end string
+	}{
+		{"x = 1", tableStart("t"), tableEnd("t")},
+		{"id = 2", key(2), key(2) + "\x00"},
+		{"id > 1 AND id <= 5", key(1) + "\x00", key(5) + "\x00"},
+		{"3 > id AND x = 1 AND id >= 1 AND id > 0", key(1), key(3)},
+		{"id BETWEEN 1 AND 4 AND id < 4", key(1), key(4)},
+		{"id > 1 OR id < 0", tableStart("t"), tableEnd("t")},
+		{"id = 'a'", tableStart("t"), tableEnd("t")},
+	}
+	for _, tt := range tests {
+		stmt, err := parse("SELECT * FROM t WHERE " + tt.where)
+		if err.IsNotEmpty() {
+			t.Fatal(err)
+		}
+		where := stmt.(*selectStatement).where
+		if err := where.bind(schema); err.IsNotEmpty() {
+			t.Fatal(err)
+		}
+		if start, end := keyRange(schema, where); start != tt.start || end != tt.end {
+			t.Errorf("%s: range [%q, %q), want [%q, %q)", tt.where, start, end, tt.start, tt.end)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package relational
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Column is a typed column of a table. Any column but the primary key may
+// hold NULL unless it is NotNull.
+type Column struct {
+	Name    string
+	Type    common.ValueType
+	NotNull bool
+}
+
+// Schema describes a table. Rows are stored, and so scanned, in the order
+// of their primary key, which is a single column.
+type Schema struct {
+	Name       string
+	Columns    []Column
+	PrimaryKey int
+}
+
+// typeNames maps the column types a CREATE TABLE accepts to value types. The
+// first name of each type is the one the catalog stores.
+var typeNames = []struct {
+	names     []string
+	valueType common.ValueType
+}{
+	{[]string{"INT", "INTEGER", "BIGINT"}, common.IntType},
+	{[]string{"FLOAT", "REAL", "DOUBLE"}, common.FloatType},
+	{[]string{"TEXT", "VARCHAR", "STRING"}, common.StringType},
+	{[]string{"BOOL", "BOOLEAN"}, common.BoolType},
+	{[]string{"BYTES", "BLOB"}, common.BytesType},
+}
+
+func parseType(name string) (common.ValueType, bool) {
+	for _, t := range typeNames {
+		for _, n := range t.names {
+			if strings.EqualFold(n, name) {
+				return t.valueType, true
+			}
+		}
+	}
+	return common.NullType, false
+}
+
+func typeName(valueType common.ValueType) string {
+	for _, t := range typeNames {
+		if t.valueType == valueType {
+			return t.names[0]
+		}
+	}
+	return valueType.String()
+}
+
+// ColumnIndex returns the position of the named column, or -1.
+func (s *Schema) ColumnIndex(name string) int {
+	for i, column := range s.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// String is the CREATE TABLE statement of the table, which is also how the
+// catalog stores it.
+func (s *Schema) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (", s.Name)
+	for i, column := range s.Columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s %s", column.Name, typeName(column.Type))
+		if i == s.PrimaryKey {
+			b.WriteString(" PRIMARY KEY")
+		} else if column.NotNull {
+			b.WriteString(" NOT NULL")
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (s *Schema) validate() lib.Error {
+	if len(s.Columns) == 0 {
+		return invalid("table %s has no columns", s.Name)
+	}
+	seen := make(map[string]bool)
+	for _, column := range s.Columns {
+		if seen[column.Name] {
+			return invalid("table %s has two columns named %s", s.Name, column.Name)
+		}
+		seen[column.Name] = true
+	}
+	if s.PrimaryKey < 0 || s.PrimaryKey >= len(s.Columns) {
+		return invalid("table %s needs a primary key", s.Name)
+	}
+	s.Columns[s.PrimaryKey].NotNull = true
+	return lib.EmptyError()
+}
+
+// checkRow coerces row to the column types in place and enforces NOT NULL.
+func (s *Schema) checkRow(row []common.Value) lib.Error {
+	for i, column := range s.Columns {
+		value, err := coerce(row[i], column)
+		if err.IsNotEmpty() {
+			return err
+		}
+		row[i] = value
+	}
+	return s.checkKey(row[s.PrimaryKey])
+}
+
+// checkKey refuses NaN as a primary key: encodeKey would sort it after
+// +Inf, while Compare puts it first.
+func (s *Schema) checkKey(pk common.Value) lib.Error {
+	if pk.Type() == common.FloatType && math.IsNaN(pk.FloatValue()) {
+		return invalid("primary key %s of table %s cannot be NaN", s.Columns[s.PrimaryKey].Name, s.Name)
+	}
+	return lib.EmptyError()
+}
+
+// coerce converts value to the type of column. Only integers widen, to
+// floats; any other mismatch is an error.
+func coerce(value common.Value, column Column) (common.Value, lib.Error) {
+	switch {
+	case value.IsNull():
+		if column.NotNull {
+			return value, invalid("column %s cannot be NULL", column.Name)
+		}
+		return value, lib.EmptyError()
+	case value.Type() == column.Type:
+		return value, lib.EmptyError()
+	case value.Type() == common.IntType && column.Type == common.FloatType:
+		return common.NewFloatValue(float64(value.IntValue())), lib.EmptyError()
+	}
+	return value, invalid("column %s is %s, got %s %s", column.Name, typeName(column.Type), typeName(value.Type()), value)
+}
+
+func invalid(format string, args ...any) lib.Error {
+	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,122 @@
+package relational
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+	"github.com/Kush/Database-internals/pkg/serialization"
+)
+
+// Tables share the key space of the engine with plain entries. Their keys
+// start with engine.ReservedPrefix, which an engine hides from and refuses
+// to plain key-value commands:
+//
+//	\x00sql\x00table\x00<table>          the CREATE TABLE statement
+//	\x00sql\x00row\x00<table>\x00<pk>    a row, as encodeRow writes it
+//
+// Table names are identifiers, so a table's rows are exactly the keys
+// between tableStart and tableEnd.
+const (
+	catalogPrefix = engine.ReservedPrefix + "table\x00"
+	rowPrefix     = engine.ReservedPrefix + "row\x00"
+)
+
+func catalogKey(table string) string {
+	return catalogPrefix + table
+}
+
+func catalogEnd() string {
+	return catalogPrefix[:len(catalogPrefix)-1] + "\x01"
+}
+
+func tableStart(table string) string {
+	return rowPrefix + table + "\x00"
+}
+
+func tableEnd(table string) string {
+	return rowPrefix + table + "\x01"
+}
+
+func rowKey(table string, pk common.Value) string {
+	return tableStart(table) + string(encodeKey(pk))
+}
+
+// encodeKey writes a primary key so that the byte order of the encodings is
+// the order of the values: integers big endian with the sign bit flipped,
+// floats with the sign bit flipped for positive numbers and every bit for
+// negative ones. Strings and bytes are the last part of the key and need no
+// terminator.
+func encodeKey(pk common.Value) []byte {
+	switch pk.Type() {
+	case common.BoolType:
+		if pk.BoolValue() {
+			return []byte{1}
+		}
+		return []byte{0}
+	case common.IntType:
+		return binary.BigEndian.AppendUint64(nil, uint64(pk.IntValue())^(1<<63))
+	case common.FloatType:
+		f := pk.FloatValue()
+		if f == 0 {
+			// -0 equals 0, so it must not get a key of its own.
+			f = 0
+		}
+		bits := math.Float64bits(f)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(nil, bits)
+	case common.StringType:
+		return []byte(pk.StringValue())
+	}
+	return pk.BytesValue()
+}
+
+// encodeRow packs a row into one bytes value: the column count, then each
+// value as serialization.AppendValue encodes it.
+func encodeRow(row []common.Value) common.Value {
+	buf := binary.AppendUvarint(nil, uint64(len(row)))
+	for _, value := range row {
+		buf = serialization.AppendValue(buf, value)
+	}
+	return common.NewBytesValue(buf)
+}
+
+// decodeRow reads a row that encodeRow wrote for a table of columns columns.
+func decodeRow(stored common.Value, columns int) ([]common.Value, lib.Error) {
+	if stored.Type() != common.BytesType {
+		return nil, corruptRow("stored as %s", stored.Type())
+	}
+	data := stored.BytesValue()
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count != uint64(columns) {
+		return nil, corruptRow("has a bad column count, want %d", columns)
+	}
+	offset := n
+	row := make([]common.Value, columns)
+	for i := range row {
+		value, n, err := serialization.DecodeValue(data[offset:])
+		if err.IsNotEmpty() {
+			return nil, corruptRow("has a bad value at column %d: %v", i, err)
+		}
+		if value.HasOverflow() {
+			return nil, corruptRow("refers to an overflow chain at column %d", i)
+		}
+		row[i] = value.Value
+		offset += n
+	}
+	if offset != len(data) {
+		return nil, corruptRow("has %d trailing bytes", len(data)-offset)
+	}
+	return row, lib.EmptyError()
+}
+
+func corruptRow(format string, args ...any) lib.Error {
+	return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("row "+format, args...))
+}
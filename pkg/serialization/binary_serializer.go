@@ -51,6 +51,9 @@ func (s *BinarySerializer) Serialize(val any) ([]byte, lib.Error) {
 		return buf, lib.EmptyError()
 	case string:
 		strBytes := []byte(v)
+		if len(strBytes) > math.MaxUint16 {
+			return nil, lib.EmptyError().AddErr(lib.SerializationError, fmt.Errorf("string of %d bytes exceeds the %d byte limit", len(strBytes), math.MaxUint16))
+		}
 		strLen := uint16(len(strBytes))
 
 		buf := make([]byte, 2+len(strBytes))
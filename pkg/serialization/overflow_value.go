@@ -0,0 +1,28 @@
+package serialization
+
+import (
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// WriteOverflowValue stores the payload of a string or bytes value in a new
+// overflow chain and returns the chain's first page, which the engine then
+// encodes with AppendOverflowValue.
+func WriteOverflowValue(p pagination.BasePagination, value common.Value) (pagination.PageID, lib.Error) {
+	payload := []byte(value.StringValue())
+	if value.Type() == common.BytesType {
+		payload = value.BytesValue()
+	}
+	return pagination.WriteOverflow(p, payload)
+}
+
+// ReadOverflowValue reads back a payload of length bytes from the chain
+// starting at head as a value of valueType.
+func ReadOverflowValue(p pagination.BasePagination, valueType common.ValueType, head pagination.PageID, length uint32) (common.Value, lib.Error) {
+	data, err := pagination.ReadOverflow(p, head, int(length))
+	if err.IsNotEmpty() {
+		return common.Value{}, err
+	}
+	return PayloadValue(valueType, data), lib.EmptyError()
+}
@@ -0,0 +1,149 @@
+package serialization
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/diskStorage/pagination"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// Every engine encodes a common.Value the same way: its type tag, then the
+// payload. Null has none, bool takes one byte, int and float 8 little endian
+// bytes, and string and bytes a uvarint length followed by the bytes.
+//
+// A string or bytes payload kept in an overflow chain instead has
+// overflowTag set on its type tag, followed by the chain's first page and
+// the payload length, 4 bytes each.
+const overflowTag uint8 = 0x80
+
+// OverflowValueSize is the encoded size of a value kept in an overflow chain.
+const OverflowValueSize = 1 + 4 + 4
+
+// StoredValue is a decoded value. When Page is not 0 the payload lives in the
+// overflow chain starting there and Value is an empty value of its type.
+type StoredValue struct {
+	Value  common.Value
+	Page   pagination.PageID
+	Length uint32
+}
+
+func (s StoredValue) HasOverflow() bool {
+	return s.Page != 0
+}
+
+// AppendValue appends the encoding of value to buf.
+func AppendValue(buf []byte, value common.Value) []byte {
+	buf = append(buf, uint8(value.Type()))
+	switch value.Type() {
+	case common.BoolType:
+		if value.BoolValue() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case common.IntType:
+		return binary.LittleEndian.AppendUint64(buf, uint64(value.IntValue()))
+	case common.FloatType:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(value.FloatValue()))
+	case common.StringType:
+		buf = binary.AppendUvarint(buf, uint64(value.Len()))
+		return append(buf, value.StringValue()...)
+	case common.BytesType:
+		buf = binary.AppendUvarint(buf, uint64(value.Len()))
+		return append(buf, value.BytesValue()...)
+	}
+	return buf
+}
+
+// AppendOverflowValue appends a reference to a string or bytes payload of
+// length bytes stored in the overflow chain starting at page.
+func AppendOverflowValue(buf []byte, valueType common.ValueType, page pagination.PageID, length uint32) []byte {
+	buf = append(buf, uint8(valueType)|overflowTag)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(page))
+	return binary.LittleEndian.AppendUint32(buf, length)
+}
+
+// ValueSize is the number of bytes AppendValue takes for value.
+func ValueSize(value common.Value) int {
+	switch value.Type() {
+	case common.BoolType:
+		return 1 + 1
+	case common.IntType, common.FloatType:
+		return 1 + 8
+	case common.StringType, common.BytesType:
+		var length [binary.MaxVarintLen64]byte
+		return 1 + binary.PutUvarint(length[:], uint64(value.Len())) + value.Len()
+	}
+	return 1
+}
+
+// DecodeValue decodes the value at the start of data and returns it along
+// with the number of bytes consumed.
+func DecodeValue(data []byte) (StoredValue, int, lib.Error) {
+	if len(data) < 1 {
+		return StoredValue{}, 0, truncatedValue("value")
+	}
+	tag := data[0]
+	valueType := common.ValueType(tag &^ overflowTag)
+	if tag&overflowTag != 0 {
+		if valueType != common.StringType && valueType != common.BytesType {
+			return StoredValue{}, 0, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %s value in an overflow chain", valueType))
+		}
+		if len(data) < OverflowValueSize {
+			return StoredValue{}, 0, truncatedValue("overflow reference")
+		}
+		page := pagination.PageID(binary.LittleEndian.Uint32(data[1:5]))
+		if page == 0 {
+			return StoredValue{}, 0, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: overflow chain at page 0"))
+		}
+		stored := StoredValue{
+			Value:  PayloadValue(valueType, nil),
+			Page:   page,
+			Length: binary.LittleEndian.Uint32(data[5:9]),
+		}
+		return stored, OverflowValueSize, lib.EmptyError()
+	}
+
+	data = data[1:]
+	switch valueType {
+	case common.NullType:
+		return StoredValue{Value: common.NewNullValue()}, 1, lib.EmptyError()
+	case common.BoolType:
+		if len(data) < 1 {
+			return StoredValue{}, 0, truncatedValue("bool value")
+		}
+		return StoredValue{Value: common.NewBoolValue(data[0] == 1)}, 1 + 1, lib.EmptyError()
+	case common.IntType, common.FloatType:
+		if len(data) < 8 {
+			return StoredValue{}, 0, truncatedValue(valueType.String() + " value")
+		}
+		bits := binary.LittleEndian.Uint64(data[:8])
+		value := common.NewIntValue(int64(bits))
+		if valueType == common.FloatType {
+			value = common.NewFloatValue(math.Float64frombits(bits))
+		}
+		return StoredValue{Value: value}, 1 + 8, lib.EmptyError()
+	case common.StringType, common.BytesType:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return StoredValue{}, 0, truncatedValue(valueType.String() + " value")
+		}
+		payload := data[n : n+int(length)]
+		return StoredValue{Value: PayloadValue(valueType, payload)}, 1 + n + int(length), lib.EmptyError()
+	}
+	return StoredValue{}, 0, lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: unknown value type %d", valueType))
+}
+
+// PayloadValue builds a string or bytes value of the given type.
+func PayloadValue(valueType common.ValueType, payload []byte) common.Value {
+	if valueType == common.BytesType {
+		return common.NewBytesValue(payload)
+	}
+	return common.NewStringValue(string(payload))
+}
+
+func truncatedValue(what string) lib.Error {
+	return lib.EmptyError().AddErr(lib.DeserializationError, fmt.Errorf("invalid data: %s is truncated", what))
+}
@@ -0,0 +1,70 @@
+package serialization
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	values := []common.Value{
+		common.NewNullValue(),
+		common.NewBoolValue(true),
+		common.NewIntValue(-42),
+		common.NewFloatValue(2.5),
+		common.NewStringValue(""),
+		common.NewStringValue(strings.Repeat("s", 300)),
+		common.NewBytesValue([]byte{0, 1, 2}),
+	}
+	var buf []byte
+	for _, value := range values {
+		before := len(buf)
+		buf = AppendValue(buf, value)
+		if size := len(buf) - before; size != ValueSize(value) {
+			t.Fatalf("%v: encoded %d bytes, ValueSize says %d", value, size, ValueSize(value))
+		}
+	}
+	buf = AppendOverflowValue(buf, common.BytesType, 7, 9000)
+
+	for _, want := range values {
+		stored, n, err := DecodeValue(buf)
+		if err.IsNotEmpty() {
+			t.Fatalf("decode %v: %v", want, err.Errors())
+		}
+		if stored.HasOverflow() || !stored.Value.Equal(want) || stored.Value.Type() != want.Type() {
+			t.Fatalf("decoded %+v, want %v", stored, want)
+		}
+		buf = buf[n:]
+	}
+	stored, n, err := DecodeValue(buf)
+	if err.IsNotEmpty() {
+		t.Fatalf("decode overflow reference: %v", err.Errors())
+	}
+	if n != OverflowValueSize || stored.Page != 7 || stored.Length != 9000 || stored.Value.Type() != common.BytesType {
+		t.Fatalf("decoded %+v from an overflow reference", stored)
+	}
+}
+
+func TestDecodeValueRejectsBadData(t *testing.T) {
+	str := AppendValue(nil, common.NewStringValue("hello"))
+	overflowInt := AppendOverflowValue(nil, common.StringType, 3, 10)
+	overflowInt[0] = uint8(common.IntType) | overflowTag
+
+	for name, data := range map[string][]byte{
+		"empty":            nil,
+		"truncated int":    AppendValue(nil, common.NewIntValue(1))[:5],
+		"truncated string": str[:len(str)-1],
+		"unknown type":     {0x7f},
+		"overflowed int":   overflowInt,
+		"overflow page 0":  AppendOverflowValue(nil, common.StringType, 0, 10),
+		"short reference":  AppendOverflowValue(nil, common.StringType, 3, 10)[:6],
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := DecodeValue(data); !err.ContainsError(lib.DeserializationError) {
+				t.Fatalf("got %v, want a deserialization error", err.Errors())
+			}
+		})
+	}
+}
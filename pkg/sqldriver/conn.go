@@ -0,0 +1,404 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+
+	datastructures "github.com/Kush/Database-internals/DataStructures"
+	btree "github.com/Kush/Database-internals/DataStructures/B-trees/implementation"
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// conn is one connection. database/sql never uses a connection from two
+// goroutines at once.
+type conn struct {
+	shared *sharedEngine
+	// tx is the running transaction, if any.
+	tx     *btree.Tx
+	closed bool
+}
+
+var (
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	parsed, err := parseStatement(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &stmt{conn: c, parsed: parsed}, nil
+}
+
+// Close rolls back a transaction left running.
+func (c *conn) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.tx != nil {
+		c.tx.Rollback()
+		c.tx = nil
+	}
+	return c.shared.release().Err()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a transaction. It is serializable whatever the options
+// ask for, as nothing else runs until it finishes.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	if c.tx != nil {
+		return nil, lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("a transaction is already running")).Err()
+	}
+	tx, err := c.shared.engine.Begin()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	c.tx = tx
+	return &transaction{conn: c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	parsed, err := parseStatement(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return c.exec(ctx, parsed, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	parsed, err := parseStatement(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return c.query(ctx, parsed, args)
+}
+
+type transaction struct {
+	conn *conn
+}
+
+func (t *transaction) Commit() error {
+	tx := t.conn.tx
+	t.conn.tx = nil
+	if tx == nil {
+		return lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("commit: transaction already finished")).Err()
+	}
+	return tx.Commit().Err()
+}
+
+func (t *transaction) Rollback() error {
+	tx := t.conn.tx
+	t.conn.tx = nil
+	if tx == nil {
+		return lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("rollback: transaction already finished")).Err()
+	}
+	return tx.Rollback().Err()
+}
+
+type stmt struct {
+	conn   *conn
+	parsed *statement
+}
+
+var (
+	_ driver.StmtExecContext  = (*stmt)(nil)
+	_ driver.StmtQueryContext = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.parsed.numInput
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.exec(ctx, s.parsed, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.query(ctx, s.parsed, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return namedArgs
+}
+
+// writer is what writes go to: the engine, or the running transaction.
+type writer interface {
+	Insert(primaryKey string, value common.Value) lib.Error
+	Search(primaryKey string) (common.Value, bool, lib.Error)
+	Delete(primaryKey string) lib.Error
+}
+
+func (c *conn) target() writer {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.shared.engine
+}
+
+func (c *conn) exec(ctx context.Context, parsed *statement, args []driver.NamedValue) (driver.Result, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	switch parsed.kind {
+	case insertStatement:
+		return c.insert(parsed, args)
+	case deleteStatement:
+		return c.delete(parsed, args)
+	}
+	return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("SELECT returns rows; use Query")).Err()
+}
+
+// insert writes every pair, as one batch when there are several; see
+// engine.Batch for when that is atomic.
+func (c *conn) insert(parsed *statement, args []driver.NamedValue) (driver.Result, error) {
+	ops := make([]engine.Op, 0, len(parsed.pairs))
+	for _, p := range parsed.pairs {
+		key, err := resolveKey(p.key, args)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		value, err := resolve(p.value, args)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		ops = append(ops, engine.Op{Key: key, Value: value})
+	}
+
+	var err lib.Error
+	switch {
+	case c.tx != nil:
+		for _, op := range ops {
+			if err = c.tx.Insert(op.Key, op.Value); err.IsNotEmpty() {
+				break
+			}
+		}
+	case len(ops) == 1:
+		err = c.shared.engine.Insert(ops[0].Key, ops[0].Value)
+	default:
+		err = c.shared.engine.Batch(ops)
+	}
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return driver.RowsAffected(len(ops)), nil
+}
+
+// delete reports how many of the keys it matched were stored.
+func (c *conn) delete(parsed *statement, args []driver.NamedValue) (driver.Result, error) {
+	if !parsed.where.between {
+		key, err := resolveKey(parsed.where.lo, args)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		_, found, err := c.target().Search(key)
+		if err.IsEmpty() && found {
+			err = c.target().Delete(key)
+		}
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if !found {
+			return driver.RowsAffected(0), nil
+		}
+		return driver.RowsAffected(1), nil
+	}
+
+	it, err := c.scan(parsed.where, args, 0)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	var ops []engine.Op
+	for it.Next() {
+		ops = append(ops, engine.Op{Key: it.Key(), Delete: true})
+	}
+	it.Close()
+	if err := it.Err(); err.IsNotEmpty() {
+		return nil, err
+	}
+	if err := c.shared.engine.Batch(ops); err.IsNotEmpty() {
+		return nil, err
+	}
+	return driver.RowsAffected(len(ops)), nil
+}
+
+func (c *conn) query(ctx context.Context, parsed *statement, args []driver.NamedValue) (driver.Rows, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	if parsed.kind != selectStatement {
+		return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("only SELECT returns rows; use Exec")).Err()
+	}
+	limit := 0
+	if parsed.limit != nil {
+		value, err := resolve(*parsed.limit, args)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if value.Type() != common.IntType || value.IntValue() < 0 {
+			return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("LIMIT must be a non-negative integer, got %v", value)).Err()
+		}
+		if value.IntValue() == 0 {
+			return &pointRows{}, nil
+		}
+		limit = int(value.IntValue())
+	}
+
+	if parsed.where != nil && !parsed.where.between {
+		key, err := resolveKey(parsed.where.lo, args)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		value, found, err := c.target().Search(key)
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if !found {
+			return &pointRows{}, nil
+		}
+		return &pointRows{key: key, value: value, pending: true}, nil
+	}
+
+	it, err := c.scan(parsed.where, args, limit)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &scanRows{it: it}, nil
+}
+
+// scan iterates over the keys w selects, or all of them if w is nil.
+func (c *conn) scan(w *where, args []driver.NamedValue, limit int) (datastructures.Iterator, lib.Error) {
+	if c.tx != nil {
+		return nil, lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("range reads are not supported inside a transaction"))
+	}
+	options := engine.ScanOptions{Limit: limit}
+	var lo, hi string
+	if w != nil {
+		var err lib.Error
+		if lo, err = resolveKey(w.lo, args); err.IsNotEmpty() {
+			return nil, err
+		}
+		if hi, err = resolveKey(w.hi, args); err.IsNotEmpty() {
+			return nil, err
+		}
+		if hi < lo {
+			return emptyIterator{}, lib.EmptyError()
+		}
+		options.IncludeEnd = true
+		// An empty bound means unbounded to the engines; no key is below
+		// "", but a BETWEEN ending at "" only selects "" itself.
+		if hi == "" {
+			return c.scanEmptyKey()
+		}
+	}
+	it, err := c.shared.engine.Scan(lo, hi, options)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return it, lib.EmptyError()
+}
+
+func (c *conn) scanEmptyKey() (datastructures.Iterator, lib.Error) {
+	_, found, err := c.shared.engine.Search("")
+	if err.IsNotEmpty() || !found {
+		return emptyIterator{}, err
+	}
+	it, err := c.shared.engine.Scan("", "", engine.ScanOptions{Limit: 1})
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return it, lib.EmptyError()
+}
+
+type emptyIterator struct{}
+
+func (emptyIterator) Next() bool          { return false }
+func (emptyIterator) Key() string         { return "" }
+func (emptyIterator) Value() common.Value { return common.Value{} }
+func (emptyIterator) Err() lib.Error      { return lib.EmptyError() }
+func (emptyIterator) Close()              {}
+
+var columns = []string{"key", "value"}
+
+// pointRows holds the result of a lookup: one row, or none.
+type pointRows struct {
+	key     string
+	value   common.Value
+	pending bool
+}
+
+func (r *pointRows) Columns() []string {
+	return columns
+}
+
+func (r *pointRows) Close() error {
+	r.pending = false
+	return nil
+}
+
+func (r *pointRows) Next(dest []driver.Value) error {
+	if !r.pending {
+		return io.EOF
+	}
+	r.pending = false
+	dest[0], dest[1] = r.key, toDriverValue(r.value)
+	return nil
+}
+
+// scanRows streams the rows of a range from the engine.
+type scanRows struct {
+	it datastructures.Iterator
+}
+
+func (r *scanRows) Columns() []string {
+	return columns
+}
+
+func (r *scanRows) Close() error {
+	r.it.Close()
+	return nil
+}
+
+func (r *scanRows) Next(dest []driver.Value) error {
+	if !r.it.Next() {
+		if err := r.it.Err(); err.IsNotEmpty() {
+			return err
+		}
+		return io.EOF
+	}
+	dest[0], dest[1] = r.it.Key(), toDriverValue(r.it.Value())
+	return nil
+}
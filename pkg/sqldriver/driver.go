@@ -0,0 +1,163 @@
+// Package sqldriver is a database/sql driver for the engines of this
+// repository. Importing it registers the driver as "dbinternals":
+//
+//	db, err := sql.Open("dbinternals", "data.db")
+//	db.Exec("INSERT key = ?", "user:1", 42)
+//	rows, err := db.Query("SELECT WHERE key BETWEEN ? AND ?", "user:", "user:~")
+//
+// The data source name is the path of the database, optionally followed by
+// settings: "data.db?engine=lsm" or "data.db?page_size=8192". The engine is
+// a B+ tree by default; see engine.Kind. The statements are described in
+// parse.go.
+//
+// Every connection to the same path shares one engine, which is opened with
+// the first connection and closed with the last. Transactions need the B+
+// tree engine, and hold up every other connection until they finish, since
+// a B+ tree transaction has the tree to itself so that nobody sees its
+// writes before the commit. Inside a transaction only single keys can be
+// read or deleted; range reads are refused.
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+const DriverName = "dbinternals"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	config, err := parseDSN(dsn)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &connector{driver: d, config: config}, nil
+}
+
+type config struct {
+	path    string
+	kind    engine.Kind
+	options engine.Options
+}
+
+func parseDSN(dsn string) (config, lib.Error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	if path == "" {
+		return config{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("data source name %q has no path", dsn))
+	}
+	c := config{path: path, kind: engine.BPlusTree}
+	query, e := url.ParseQuery(rawQuery)
+	if e != nil {
+		return config{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("data source name %q: %w", dsn, e))
+	}
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case "engine":
+			kind, err := engine.ParseKind(value)
+			if err.IsNotEmpty() {
+				return config{}, err
+			}
+			c.kind = kind
+		case "page_size":
+			n, e := strconv.Atoi(value)
+			if e != nil {
+				return config{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("page_size %q is not a number", value))
+			}
+			c.options.PageSize = n
+		default:
+			return config{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("unknown setting %q, want engine or page_size", name))
+		}
+	}
+	return c, lib.EmptyError()
+}
+
+type connector struct {
+	driver *Driver
+	config config
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
+	shared, err := acquire(c.config)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &conn{shared: shared}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sharedEngine is an engine with the connections that use it.
+type sharedEngine struct {
+	engine *engine.Engine
+	key    string
+	refs   int
+}
+
+var shared = struct {
+	sync.Mutex
+	engines map[string]*sharedEngine
+}{engines: make(map[string]*sharedEngine)}
+
+func acquire(c config) (*sharedEngine, lib.Error) {
+	key, e := filepath.Abs(c.path)
+	if e != nil {
+		return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("path %s: %w", c.path, e))
+	}
+
+	shared.Lock()
+	defer shared.Unlock()
+	if s, ok := shared.engines[key]; ok {
+		if s.engine.Kind() != c.kind {
+			return nil, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("%s is open as a %s engine, not %s", c.path, s.engine.Kind(), c.kind))
+		}
+		s.refs++
+		return s, lib.EmptyError()
+	}
+	opened, err := engine.OpenWithOptions(c.kind, c.path, c.options)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	s := &sharedEngine{engine: opened, key: key, refs: 1}
+	shared.engines[key] = s
+	return s, lib.EmptyError()
+}
+
+func (s *sharedEngine) release() lib.Error {
+	shared.Lock()
+	defer shared.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return lib.EmptyError()
+	}
+	delete(shared.engines, s.key)
+	return s.engine.Close()
+}
@@ -0,0 +1,204 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+func openDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("close: %v", err)
+		}
+	})
+	return db
+}
+
+func mustExec(t *testing.T, db interface {
+	Exec(string, ...any) (sql.Result, error)
+}, query string, args ...any) int64 {
+	t.Helper()
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	n, _ := result.RowsAffected()
+	return n
+}
+
+type row struct {
+	key   string
+	value any
+}
+
+func queryRows(t *testing.T, db *sql.DB, query string, args ...any) []row {
+	t.Helper()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key, &r.value); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return got
+}
+
+func TestStatements(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "sql.db"))
+
+	if n := mustExec(t, db, "INSERT 'a' = 1, 'b' = 2.5, 'c' = TRUE"); n != 3 {
+		t.Fatalf("insert affected %d rows", n)
+	}
+	mustExec(t, db, "insert ? = ?", "d", "text")
+	mustExec(t, db, "INSERT $2 = $1;", []byte{1, 2}, "e")
+	mustExec(t, db, "INSERT 'f' = NULL")
+
+	got := queryRows(t, db, "SELECT * WHERE key = ?", "b")
+	if !reflect.DeepEqual(got, []row{{"b", 2.5}}) {
+		t.Fatalf("point select = %v", got)
+	}
+	got = queryRows(t, db, "SELECT WHERE key BETWEEN 'b' AND ?", "e")
+	want := []row{{"b", 2.5}, {"c", true}, {"d", "text"}, {"e", []byte{1, 2}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("range select = %v, want %v", got, want)
+	}
+	got = queryRows(t, db, "SELECT LIMIT 2")
+	if !reflect.DeepEqual(got, []row{{"a", int64(1)}, {"b", 2.5}}) {
+		t.Fatalf("select with limit = %v", got)
+	}
+	if got := queryRows(t, db, "SELECT WHERE key = 'f'"); len(got) != 1 || got[0].value != nil {
+		t.Fatalf("NULL value = %v", got)
+	}
+	if got := queryRows(t, db, "SELECT WHERE key = 'missing'"); len(got) != 0 {
+		t.Fatalf("missing key returned %v", got)
+	}
+
+	if n := mustExec(t, db, "DELETE WHERE key = 'a'"); n != 1 {
+		t.Fatalf("delete affected %d rows", n)
+	}
+	if n := mustExec(t, db, "DELETE WHERE key = 'a'"); n != 0 {
+		t.Fatalf("second delete affected %d rows", n)
+	}
+	if n := mustExec(t, db, "DELETE WHERE key BETWEEN 'c' AND 'e'"); n != 3 {
+		t.Fatalf("range delete affected %d rows", n)
+	}
+	got = queryRows(t, db, "SELECT")
+	if !reflect.DeepEqual(got, []row{{"b", 2.5}, {"f", nil}}) {
+		t.Fatalf("remaining rows = %v", got)
+	}
+
+	// Times are stored as strings.
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mustExec(t, db, "INSERT 't' = ?", at)
+	if got := queryRows(t, db, "SELECT WHERE key = 't'"); got[0].value != at.Format(time.RFC3339Nano) {
+		t.Fatalf("time stored as %v", got[0].value)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "sql.db"))
+
+	for _, query := range []string{
+		"UPDATE 'a' = 1",
+		"INSERT 'a' 1",
+		"INSERT 'a' = ?, $1 = 2",
+		"DELETE",
+		"SELECT WHERE value = 1",
+		"SELECT WHERE key = 'unterminated",
+	} {
+		if _, err := db.Exec(query); err == nil {
+			t.Errorf("%s: no error", query)
+		}
+	}
+
+	_, err := db.Exec("INSERT ? = 1", 42)
+	if !errors.Is(err, lib.ErrInvalidInput) {
+		t.Fatalf("non-string key: %v, want an InvalidInputError", err)
+	}
+	if _, err := db.Exec("INSERT 'a' = ?"); err == nil {
+		t.Fatalf("missing argument: no error")
+	}
+	if _, err := db.Query("INSERT 'a' = 1"); err == nil {
+		t.Fatalf("Query of an INSERT: no error")
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "sql.db"))
+	mustExec(t, db, "INSERT 'kept' = 1")
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	mustExec(t, tx, "INSERT 'a' = 1")
+	mustExec(t, tx, "DELETE WHERE key = 'kept'")
+	var value int64
+	if err := tx.QueryRow("SELECT WHERE key = 'a'").Scan(new(string), &value); err != nil || value != 1 {
+		t.Fatalf("read own write: %d, %v", value, err)
+	}
+	if _, err := tx.Query("SELECT"); !errors.Is(err, lib.ErrTransaction) {
+		t.Fatalf("range read in a transaction: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if got := queryRows(t, db, "SELECT"); !reflect.DeepEqual(got, []row{{"kept", int64(1)}}) {
+		t.Fatalf("after rollback: %v", got)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	mustExec(t, tx, "INSERT 'b' = 2")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := queryRows(t, db, "SELECT"); len(got) != 2 {
+		t.Fatalf("after commit: %v", got)
+	}
+
+	lsm := openDB(t, filepath.Join(t.TempDir(), "lsm")+"?engine=lsm")
+	if _, err := lsm.Begin(); !errors.Is(err, lib.ErrTransaction) {
+		t.Fatalf("begin on an LSM tree: %v", err)
+	}
+}
+
+func TestSharedEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sql.db")
+	first := openDB(t, path)
+	second := openDB(t, path)
+	mustExec(t, first, "INSERT 'k' = 'v'")
+	if got := queryRows(t, second, "SELECT"); len(got) != 1 {
+		t.Fatalf("second handle sees %v", got)
+	}
+	other, err := sql.Open(DriverName, path+"?engine=hash")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer other.Close()
+	if err := other.Ping(); err == nil {
+		t.Fatalf("opened an open B+ tree file as a hash index")
+	}
+}
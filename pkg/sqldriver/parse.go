@@ -0,0 +1,366 @@
+package sqldriver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+)
+
+// The statements the driver understands, with case-insensitive keywords:
+//
+//	INSERT key = value [, key = value ...]
+//	SELECT [*] [WHERE key = k | WHERE key BETWEEN lo AND hi] [LIMIT n]
+//	DELETE WHERE key = k | DELETE WHERE key BETWEEN lo AND hi
+//
+// Keys and values are literals or placeholders, either all ? or all $1, $2...
+// Literals are 'strings', with a quote inside written twice, integers,
+// floats, TRUE, FALSE, NULL and X'00ff' bytes. Keys must be strings; BETWEEN
+// includes both bounds. A SELECT returns the columns key and value.
+
+type statementKind int
+
+const (
+	insertStatement statementKind = iota
+	selectStatement
+	deleteStatement
+)
+
+type statement struct {
+	kind  statementKind
+	pairs []pair
+	// where is nil for a SELECT of every key.
+	where *where
+	// limit is nil when there is none.
+	limit    *operand
+	numInput int
+}
+
+type pair struct {
+	key, value operand
+}
+
+type where struct {
+	// between is set for a range; otherwise lo is the key.
+	between bool
+	lo, hi  operand
+}
+
+// operand is a literal, or the placeholder for argument param when param
+// is not 0.
+type operand struct {
+	literal common.Value
+	param   int
+}
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	numberToken
+	bytesToken
+	paramToken
+	symbolToken
+	endToken
+)
+
+type sqlToken struct {
+	kind tokenKind
+	text string
+}
+
+func tokenizeStatement(query string) ([]sqlToken, lib.Error) {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '\'' || ((c == 'x' || c == 'X') && i+1 < len(query) && query[i+1] == '\''):
+			kind, start := stringToken, i+1
+			if c != '\'' {
+				kind, start = bytesToken, i+2
+			}
+			var b strings.Builder
+			j := start
+			for ; j < len(query); j++ {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						b.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(query[j])
+			}
+			if j >= len(query) {
+				return nil, parseError("unterminated string at %q", query[i:])
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: b.String()})
+			i = j + 1
+		case c == '?':
+			tokens = append(tokens, sqlToken{kind: paramToken, text: "?"})
+			i++
+		case c == '$':
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, parseError("expected a number after $")
+			}
+			tokens = append(tokens, sqlToken{kind: paramToken, text: query[i:j]})
+			i = j
+		case isDigit(c) || ((c == '-' || c == '+' || c == '.') && i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.')):
+			j := i + 1
+			for j < len(query) && (isDigit(query[j]) || strings.IndexByte(".eE", query[j]) >= 0 || ((query[j] == '-' || query[j] == '+') && (query[j-1] == 'e' || query[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: numberToken, text: query[i:j]})
+			i = j
+		case isWordByte(c):
+			j := i + 1
+			for j < len(query) && (isWordByte(query[j]) || isDigit(query[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: wordToken, text: query[i:j]})
+			i = j
+		case strings.IndexByte("=,*;", c) >= 0:
+			tokens = append(tokens, sqlToken{kind: symbolToken, text: string(c)})
+			i++
+		default:
+			return nil, parseError("unexpected character %q", c)
+		}
+	}
+	return append(tokens, sqlToken{kind: endToken}), lib.EmptyError()
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func parseError(format string, args ...any) lib.Error {
+	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("syntax error: "+format, args...))
+}
+
+type parser struct {
+	tokens []sqlToken
+	pos    int
+	// numbered is set once a $N placeholder is seen, positional once a ?
+	// is; a statement cannot mix them.
+	numbered, positional bool
+	numInput             int
+}
+
+func parseStatement(query string) (*statement, lib.Error) {
+	tokens, err := tokenizeStatement(query)
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var stmt *statement
+	switch {
+	case p.keyword("INSERT"):
+		stmt, err = p.insert()
+	case p.keyword("SELECT"):
+		stmt, err = p.selectStatement()
+	case p.keyword("DELETE"):
+		stmt, err = p.delete()
+	default:
+		return nil, parseError("expected INSERT, SELECT or DELETE, got %s", p.describe())
+	}
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	p.symbol(";")
+	if p.peek().kind != endToken {
+		return nil, parseError("unexpected %s at the end", p.describe())
+	}
+	stmt.numInput = p.numInput
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) insert() (*statement, lib.Error) {
+	stmt := &statement{kind: insertStatement}
+	for {
+		key, err := p.operand()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		if !p.symbol("=") {
+			return nil, parseError("expected = after the key, got %s", p.describe())
+		}
+		value, err := p.operand()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.pairs = append(stmt.pairs, pair{key: key, value: value})
+		if !p.symbol(",") {
+			return stmt, lib.EmptyError()
+		}
+	}
+}
+
+func (p *parser) selectStatement() (*statement, lib.Error) {
+	stmt := &statement{kind: selectStatement}
+	p.symbol("*")
+	if p.keyword("WHERE") {
+		w, err := p.where()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.where = w
+	}
+	if p.keyword("LIMIT") {
+		limit, err := p.operand()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		stmt.limit = &limit
+	}
+	return stmt, lib.EmptyError()
+}
+
+func (p *parser) delete() (*statement, lib.Error) {
+	if !p.keyword("WHERE") {
+		return nil, parseError("DELETE needs a WHERE clause")
+	}
+	w, err := p.where()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &statement{kind: deleteStatement, where: w}, lib.EmptyError()
+}
+
+func (p *parser) where() (*where, lib.Error) {
+	if !p.keyword("KEY") {
+		return nil, parseError("expected key after WHERE, got %s", p.describe())
+	}
+	if p.symbol("=") {
+		key, err := p.operand()
+		if err.IsNotEmpty() {
+			return nil, err
+		}
+		return &where{lo: key}, lib.EmptyError()
+	}
+	if !p.keyword("BETWEEN") {
+		return nil, parseError("expected = or BETWEEN, got %s", p.describe())
+	}
+	lo, err := p.operand()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	if !p.keyword("AND") {
+		return nil, parseError("expected AND, got %s", p.describe())
+	}
+	hi, err := p.operand()
+	if err.IsNotEmpty() {
+		return nil, err
+	}
+	return &where{between: true, lo: lo, hi: hi}, lib.EmptyError()
+}
+
+func (p *parser) operand() (operand, lib.Error) {
+	t := p.next()
+	switch t.kind {
+	case stringToken:
+		return operand{literal: common.NewStringValue(t.text)}, lib.EmptyError()
+	case bytesToken:
+		b, e := hex.DecodeString(t.text)
+		if e != nil {
+			return operand{}, parseError("invalid bytes literal X'%s'", t.text)
+		}
+		return operand{literal: common.NewBytesValue(b)}, lib.EmptyError()
+	case numberToken:
+		if n, e := strconv.ParseInt(t.text, 10, 64); e == nil {
+			return operand{literal: common.NewIntValue(n)}, lib.EmptyError()
+		}
+		f, e := strconv.ParseFloat(t.text, 64)
+		if e != nil {
+			return operand{}, parseError("invalid number %s", t.text)
+		}
+		return operand{literal: common.NewFloatValue(f)}, lib.EmptyError()
+	case paramToken:
+		return p.placeholder(t.text)
+	case wordToken:
+		switch strings.ToUpper(t.text) {
+		case "TRUE", "FALSE":
+			return operand{literal: common.NewBoolValue(strings.EqualFold(t.text, "TRUE"))}, lib.EmptyError()
+		case "NULL":
+			return operand{literal: common.NewNullValue()}, lib.EmptyError()
+		}
+	}
+	if t.kind != endToken {
+		p.pos--
+	}
+	return operand{}, parseError("expected a literal or placeholder, got %s", p.describe())
+}
+
+func (p *parser) placeholder(text string) (operand, lib.Error) {
+	if text == "?" {
+		if p.numbered {
+			return operand{}, parseError("cannot mix ? and $N placeholders")
+		}
+		p.positional = true
+		p.numInput++
+		return operand{param: p.numInput}, lib.EmptyError()
+	}
+	if p.positional {
+		return operand{}, parseError("cannot mix ? and $N placeholders")
+	}
+	p.numbered = true
+	n, e := strconv.Atoi(text[1:])
+	if e != nil || n < 1 {
+		return operand{}, parseError("invalid placeholder %s", text)
+	}
+	p.numInput = max(p.numInput, n)
+	return operand{param: n}, lib.EmptyError()
+}
+
+func (p *parser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() sqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == wordToken && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) symbol(s string) bool {
+	if t := p.peek(); t.kind == symbolToken && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) describe() string {
+	t := p.peek()
+	switch t.kind {
+	case endToken:
+		return "end of statement"
+	case stringToken:
+		return "'" + t.text + "'"
+	}
+	return t.text
+}
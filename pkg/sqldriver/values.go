@@ -0,0 +1,81 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// toDriverValue maps a stored value to the driver.Value of the same type:
+// int64, float64, bool, string, []byte, or nil for NULL.
+func toDriverValue(value common.Value) driver.Value {
+	switch value.Type() {
+	case common.BoolType:
+		return value.BoolValue()
+	case common.IntType:
+		return value.IntValue()
+	case common.FloatType:
+		return value.FloatValue()
+	case common.StringType:
+		return value.StringValue()
+	case common.BytesType:
+		return value.BytesValue()
+	}
+	return nil
+}
+
+// fromDriverValue is the inverse of toDriverValue. database/sql has already
+// converted the arguments to driver.Values; a time.Time is stored as an
+// RFC 3339 string, since common.Value has no time type.
+func fromDriverValue(v driver.Value) (common.Value, lib.Error) {
+	switch v := v.(type) {
+	case nil:
+		return common.NewNullValue(), lib.EmptyError()
+	case bool:
+		return common.NewBoolValue(v), lib.EmptyError()
+	case int64:
+		return common.NewIntValue(v), lib.EmptyError()
+	case float64:
+		return common.NewFloatValue(v), lib.EmptyError()
+	case string:
+		return common.NewStringValue(v), lib.EmptyError()
+	case []byte:
+		return common.NewBytesValue(v), lib.EmptyError()
+	case time.Time:
+		return common.NewStringValue(v.Format(time.RFC3339Nano)), lib.EmptyError()
+	}
+	return common.Value{}, lib.EmptyError().AddErr(lib.UnsupportedTypeError, fmt.Errorf("unsupported argument type %T", v))
+}
+
+// resolve gives the value of an operand, taking placeholders from args.
+func resolve(o operand, args []driver.NamedValue) (common.Value, lib.Error) {
+	if o.param == 0 {
+		return o.literal, lib.EmptyError()
+	}
+	for _, arg := range args {
+		if arg.Ordinal == o.param {
+			return fromDriverValue(arg.Value)
+		}
+	}
+	return common.Value{}, lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("missing argument %d", o.param))
+}
+
+// resolveKey is resolve for operands that must be keys.
+func resolveKey(o operand, args []driver.NamedValue) (string, lib.Error) {
+	value, err := resolve(o, args)
+	if err.IsNotEmpty() {
+		return "", err
+	}
+	if value.Type() != common.StringType {
+		return "", lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("keys are strings, got %s %v", value.Type(), value))
+	}
+	// Refused here rather than by the engine, which transactions bypass.
+	if err := engine.CheckKey(value.StringValue()); err.IsNotEmpty() {
+		return "", err
+	}
+	return value.StringValue(), lib.EmptyError()
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+// statusByCode gives the status of each error code. An error with several
+// codes gets the status of the first of them in statusPriority.
+var statusByCode = map[lib.ErrorCode]int{
+	lib.InvalidInputError:    http.StatusBadRequest,
+	lib.UnsupportedTypeError: http.StatusBadRequest,
+	lib.InvalidByteLength:    http.StatusBadRequest,
+	lib.TransactionError:     http.StatusConflict,
+	lib.InitError:            http.StatusServiceUnavailable,
+}
+
+// statusPriority puts the client's mistakes first: retrying those does not
+// help, whatever else went wrong.
+var statusPriority = []lib.ErrorCode{
+	lib.InvalidInputError,
+	lib.UnsupportedTypeError,
+	lib.InvalidByteLength,
+	lib.TransactionError,
+	lib.InitError,
+}
+
+// statusOf maps err to an HTTP status and the code reported with it. Codes
+// without a status of their own are server errors.
+func statusOf(err lib.Error) (int, lib.ErrorCode) {
+	for _, code := range statusPriority {
+		if err.ContainsError(code) {
+			return statusByCode[code], code
+		}
+	}
+	codes := err.ErrorCodes()
+	if len(codes) == 0 {
+		return http.StatusInternalServerError, lib.SystemError
+	}
+	code := codes[0]
+	for _, c := range codes[1:] {
+		if c < code {
+			code = c
+		}
+	}
+	if status, ok := statusByCode[code]; ok {
+		return status, code
+	}
+	return http.StatusInternalServerError, code
+}
+
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err lib.Error) {
+	status, code := statusOf(err)
+	message := err.Error()
+	if err.ContainsError(lib.PanicFound) {
+		// The message of a recovered panic carries its stack.
+		message = "internal error"
+	}
+	s.writeErrorStatus(w, status, string(code), message)
+}
+
+func (s *Server) writeErrorStatus(w http.ResponseWriter, status int, code, message string) {
+	s.errors.Add(1)
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: message}})
+}
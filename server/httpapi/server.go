@@ -0,0 +1,260 @@
+// Package httpapi serves an engine over HTTP with JSON bodies:
+//
+//	GET    /keys/{key}                     the value of key
+//	PUT    /keys/{key}                     store the value in the body
+//	DELETE /keys/{key}                     delete key
+//	GET    /keys?start=&end=&limit=        the entries in [start, end)
+//	POST   /batch                          apply several writes
+//	GET    /stats                          engine and request counters
+//
+// Values are written as {"type": "int64", "value": 42}, which keeps their
+// type; see common.Value.MarshalJSON. Keys may contain slashes.
+//
+// Errors are {"error": {"code": ..., "message": ...}} with the status
+// derived from the lib.ErrorCode, see statusOf.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+const (
+	defaultRangeLimit = 100
+	maxRangeLimit     = 10000
+	maxBodyBytes      = 64 << 20
+	maxBatchOps       = 10000
+)
+
+// Server is an http.Handler. The engine is opened and closed by the caller.
+type Server struct {
+	engine  *engine.Engine
+	mux     *http.ServeMux
+	started time.Time
+
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+func NewServer(e *engine.Engine) *Server {
+	s := &Server{engine: e, mux: http.NewServeMux(), started: time.Now()}
+	s.mux.HandleFunc("GET /keys/{key...}", s.getKey)
+	s.mux.HandleFunc("PUT /keys/{key...}", s.putKey)
+	s.mux.HandleFunc("DELETE /keys/{key...}", s.deleteKey)
+	s.mux.HandleFunc("GET /keys", s.rangeKeys)
+	s.mux.HandleFunc("POST /batch", s.batch)
+	s.mux.HandleFunc("GET /stats", s.stats)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	s.mux.ServeHTTP(w, r)
+}
+
+type entry struct {
+	Key   string       `json:"key"`
+	Value common.Value `json:"value"`
+}
+
+func (s *Server) getKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.key(w, r)
+	if !ok {
+		return
+	}
+	value, found, err := s.engine.Search(key)
+	if err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	if !found {
+		s.writeErrorStatus(w, http.StatusNotFound, "NotFound", fmt.Sprintf("key %q not found", key))
+		return
+	}
+	writeJSON(w, http.StatusOK, entry{Key: key, Value: value})
+}
+
+func (s *Server) putKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.key(w, r)
+	if !ok {
+		return
+	}
+	var value common.Value
+	if !s.decode(w, r, &value) {
+		return
+	}
+	if err := s.engine.Insert(key, value); err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteKey succeeds whether or not the key is stored.
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.key(w, r)
+	if !ok {
+		return
+	}
+	if err := s.engine.Delete(key); err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type rangeResponse struct {
+	Entries []entry `json:"entries"`
+	// Next is the start of the following page, if the limit cut the range
+	// short.
+	Next *string `json:"next,omitempty"`
+}
+
+func (s *Server) rangeKeys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultRangeLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, e := strconv.Atoi(raw)
+		if e != nil || n < 1 || n > maxRangeLimit {
+			s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), fmt.Sprintf("limit must be between 1 and %d", maxRangeLimit))
+			return
+		}
+		limit = n
+	}
+
+	// One entry past the limit tells where the next page starts.
+	it, err := s.engine.Scan(query.Get("start"), query.Get("end"), engine.ScanOptions{Limit: limit + 1})
+	if err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	defer it.Close()
+	response := rangeResponse{Entries: []entry{}}
+	for it.Next() {
+		if len(response.Entries) == limit {
+			next := it.Key()
+			response.Next = &next
+			break
+		}
+		response.Entries = append(response.Entries, entry{Key: it.Key(), Value: it.Value()})
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+type batchRequest struct {
+	Ops []batchOp `json:"ops"`
+}
+
+type batchOp struct {
+	// Op is "put" or "delete".
+	Op    string        `json:"op"`
+	Key   string        `json:"key"`
+	Value *common.Value `json:"value,omitempty"`
+}
+
+type batchResponse struct {
+	Applied int  `json:"applied"`
+	Atomic  bool `json:"atomic"`
+}
+
+// batch applies the writes in order, atomically on engines that can. The
+// whole batch is checked before any of it is applied.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	var request batchRequest
+	if !s.decode(w, r, &request) {
+		return
+	}
+	if len(request.Ops) > maxBatchOps {
+		s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), fmt.Sprintf("a batch holds at most %d ops", maxBatchOps))
+		return
+	}
+	ops := make([]engine.Op, 0, len(request.Ops))
+	for i, op := range request.Ops {
+		if op.Key == "" {
+			s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), fmt.Sprintf("op %d: key is empty", i))
+			return
+		}
+		switch op.Op {
+		case "put":
+			if op.Value == nil {
+				s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), fmt.Sprintf("op %d: put without a value", i))
+				return
+			}
+			ops = append(ops, engine.Op{Key: op.Key, Value: *op.Value})
+		case "delete":
+			ops = append(ops, engine.Op{Key: op.Key, Delete: true})
+		default:
+			s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), fmt.Sprintf("op %d: unknown op %q, want put or delete", i, op.Op))
+			return
+		}
+	}
+
+	if err := s.engine.Batch(ops); err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, batchResponse{Applied: len(ops), Atomic: s.engine.Atomic()})
+}
+
+type statsResponse struct {
+	Engine        engine.Stats `json:"engine"`
+	UptimeSeconds int64        `json:"uptime_seconds"`
+	Requests      int64        `json:"requests"`
+	Errors        int64        `json:"errors"`
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.engine.Stats()
+	if err.IsNotEmpty() {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statsResponse{
+		Engine:        stats,
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		Requests:      s.requests.Load(),
+		Errors:        s.errors.Load(),
+	})
+}
+
+func (s *Server) key(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.PathValue("key")
+	if key == "" {
+		s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), "key is empty")
+		return "", false
+	}
+	return key, true
+}
+
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if e := decoder.Decode(v); e != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(e, &tooLarge) {
+			s.writeErrorStatus(w, http.StatusRequestEntityTooLarge, string(lib.InvalidInputError), e.Error())
+			return false
+		}
+		s.writeErrorStatus(w, http.StatusBadRequest, string(lib.InvalidInputError), "invalid JSON body: "+e.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,169 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+func startServer(t *testing.T, kind engine.Kind) *httptest.Server {
+	t.Helper()
+	e, err := engine.Open(kind, filepath.Join(t.TempDir(), "http.db"))
+	if err.IsNotEmpty() {
+		t.Fatalf("open engine: %v", err)
+	}
+	server := httptest.NewServer(NewServer(e))
+	t.Cleanup(func() {
+		server.Close()
+		if err := e.Close(); err.IsNotEmpty() {
+			t.Errorf("close engine: %v", err)
+		}
+	})
+	return server
+}
+
+func request(t *testing.T, server *httptest.Server, method, path, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func expectStatus(t *testing.T, server *httptest.Server, method, path, body string, want int) string {
+	t.Helper()
+	status, response := request(t, server, method, path, body)
+	if status != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, path, status, want, response)
+	}
+	return response
+}
+
+func TestKeys(t *testing.T) {
+	server := startServer(t, engine.BPlusTree)
+
+	expectStatus(t, server, "GET", "/keys/a", "", http.StatusNotFound)
+	expectStatus(t, server, "PUT", "/keys/a", `{"type": "int64", "value": 42}`, http.StatusNoContent)
+	expectStatus(t, server, "PUT", "/keys/dir/b", `"text"`, http.StatusNoContent)
+
+	var got entry
+	json.Unmarshal([]byte(expectStatus(t, server, "GET", "/keys/a", "", http.StatusOK)), &got)
+	if got.Key != "a" || got.Value.Type() != common.IntType || got.Value.IntValue() != 42 {
+		t.Fatalf("GET /keys/a = %+v", got)
+	}
+	json.Unmarshal([]byte(expectStatus(t, server, "GET", "/keys/dir/b", "", http.StatusOK)), &got)
+	if got.Key != "dir/b" || got.Value.StringValue() != "text" {
+		t.Fatalf("GET /keys/dir/b = %+v", got)
+	}
+
+	expectStatus(t, server, "DELETE", "/keys/a", "", http.StatusNoContent)
+	expectStatus(t, server, "GET", "/keys/a", "", http.StatusNotFound)
+
+	expectStatus(t, server, "PUT", "/keys/a", `{"type": "uuid", "value": 1}`, http.StatusBadRequest)
+	expectStatus(t, server, "PUT", "/keys/a", `{`, http.StatusBadRequest)
+
+	// Keys too long for a tree node are stored in overflow pages.
+	long := strings.Repeat("k", 5000)
+	expectStatus(t, server, "PUT", "/keys/"+long, `1`, http.StatusNoContent)
+	json.Unmarshal([]byte(expectStatus(t, server, "GET", "/keys/"+long, "", http.StatusOK)), &got)
+	if got.Key != long || got.Value.IntValue() != 1 {
+		t.Fatalf("GET a %d byte key = %d byte key, %v", len(long), len(got.Key), got.Value)
+	}
+}
+
+func TestRange(t *testing.T) {
+	server := startServer(t, engine.BPlusTree)
+	for i := 0; i < 25; i++ {
+		expectStatus(t, server, "PUT", fmt.Sprintf("/keys/k%02d", i), fmt.Sprint(i), http.StatusNoContent)
+	}
+
+	var keys []string
+	path := "/keys?start=k03&end=k20&limit=5"
+	for {
+		var page rangeResponse
+		json.Unmarshal([]byte(expectStatus(t, server, "GET", path, "", http.StatusOK)), &page)
+		for _, e := range page.Entries {
+			keys = append(keys, e.Key)
+		}
+		if page.Next == nil {
+			break
+		}
+		path = "/keys?start=" + *page.Next + "&end=k20&limit=5"
+	}
+	if len(keys) != 17 || keys[0] != "k03" || keys[16] != "k19" {
+		t.Fatalf("range returned %v", keys)
+	}
+
+	expectStatus(t, server, "GET", "/keys?limit=0", "", http.StatusBadRequest)
+}
+
+func TestBatch(t *testing.T) {
+	server := startServer(t, engine.BPlusTree)
+	expectStatus(t, server, "PUT", "/keys/old", `1`, http.StatusNoContent)
+
+	body := `{"ops": [
+		{"op": "put", "key": "x", "value": {"type": "float64", "value": 1.5}},
+		{"op": "put", "key": "y", "value": {"type": "bool", "value": true}},
+		{"op": "delete", "key": "old"}
+	]}`
+	var response batchResponse
+	json.Unmarshal([]byte(expectStatus(t, server, "POST", "/batch", body, http.StatusOK)), &response)
+	if response.Applied != 3 || !response.Atomic {
+		t.Fatalf("batch response %+v", response)
+	}
+	expectStatus(t, server, "GET", "/keys/x", "", http.StatusOK)
+	expectStatus(t, server, "GET", "/keys/old", "", http.StatusNotFound)
+
+	long := strings.Repeat("k", 5000)
+	body = fmt.Sprintf(`{"ops": [{"op": "put", "key": "z", "value": 1}, {"op": "put", "key": %q, "value": 1}]}`, long)
+	expectStatus(t, server, "POST", "/batch", body, http.StatusOK)
+	expectStatus(t, server, "GET", "/keys/z", "", http.StatusOK)
+	expectStatus(t, server, "GET", "/keys/"+long, "", http.StatusOK)
+
+	expectStatus(t, server, "POST", "/batch", `{"ops": [{"op": "upsert", "key": "z"}]}`, http.StatusBadRequest)
+}
+
+func TestStats(t *testing.T) {
+	server := startServer(t, engine.LSMTree)
+	expectStatus(t, server, "GET", "/keys/missing", "", http.StatusNotFound)
+
+	var stats statsResponse
+	json.Unmarshal([]byte(expectStatus(t, server, "GET", "/stats", "", http.StatusOK)), &stats)
+	if stats.Engine.Kind != engine.LSMTree || !stats.Engine.Ordered || stats.Requests != 2 || stats.Errors != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	cases := []struct {
+		err  lib.Error
+		want int
+	}{
+		{lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("bad")), http.StatusBadRequest},
+		{lib.EmptyError().AddErr(lib.TransactionError, fmt.Errorf("busy")), http.StatusConflict},
+		{lib.EmptyError().AddErr(lib.InitError, fmt.Errorf("closed")), http.StatusServiceUnavailable},
+		{lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("disk")), http.StatusInternalServerError},
+		{lib.EmptyError().AddErr(lib.WALError, fmt.Errorf("disk")).AddErr(lib.InvalidInputError, fmt.Errorf("bad")), http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if got, _ := statusOf(tc.err); got != tc.want {
+			t.Errorf("statusOf(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kush/Database-internals/DataStructures/aggregates/common"
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// The commands below follow their Redis counterparts. Values are stored as
+// strings; GET returns a value stored some other way, through another
+// interface, in its printed form.
+//
+//	PING [message]
+//	ECHO message
+//	GET key
+//	SET key value [NX | XX] [GET]
+//	DEL key [key ...]
+//	EXISTS key [key ...]
+//	SCAN cursor [MATCH pattern] [COUNT count]
+//	RANGE start end [LIMIT count]
+//	INFO [section ...]
+//	SELECT index, COMMAND, QUIT
+//
+// RANGE is not a Redis command. It returns the keys in [start, end) with
+// their values, as a flat array of key, value, key, value... An empty start
+// or end leaves that side unbounded.
+type command struct {
+	// arity is the exact number of arguments, command name included, or
+	// minus the minimum if it is negative.
+	arity   int
+	handler func(s *Server, c *conn, args [][]byte) bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":    {-1, (*Server).ping},
+		"echo":    {2, (*Server).echo},
+		"get":     {2, (*Server).get},
+		"set":     {-3, (*Server).set},
+		"del":     {-2, (*Server).del},
+		"exists":  {-2, (*Server).exists},
+		"scan":    {-2, (*Server).scan},
+		"range":   {-3, (*Server).rangeCommand},
+		"info":    {-1, (*Server).info},
+		"select":  {2, (*Server).selectCommand},
+		"command": {-1, (*Server).commandCommand},
+		"quit":    {-1, (*Server).quit},
+	}
+}
+
+const (
+	defaultScanCount = 10
+	// maxCursors bounds the SCAN cursors kept for clients to continue.
+	maxCursors = 4096
+)
+
+// execute runs one command and reports whether the connection should close.
+func (s *Server) execute(c *conn, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error(fmt.Sprintf("ERR unknown command '%s'", name))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return false
+	}
+	return cmd.handler(s, c, args)
+}
+
+func (s *Server) ping(c *conn, args [][]byte) bool {
+	switch len(args) {
+	case 1:
+		c.w.simple("PONG")
+	case 2:
+		c.w.bulk(string(args[1]))
+	default:
+		c.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+	return false
+}
+
+func (s *Server) echo(c *conn, args [][]byte) bool {
+	c.w.bulk(string(args[1]))
+	return false
+}
+
+func (s *Server) get(c *conn, args [][]byte) bool {
+	value, found, err := s.engine.Search(string(args[1]))
+	if err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+	writeValue(c.w, value, found)
+	return false
+}
+
+func (s *Server) set(c *conn, args [][]byte) bool {
+	key, value := string(args[1]), common.NewStringValue(string(args[2]))
+	var nx, xx, get bool
+	for _, arg := range args[3:] {
+		switch strings.ToLower(string(arg)) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "get":
+			get = true
+		case "ex", "px", "exat", "pxat", "keepttl":
+			c.w.error("ERR expiration is not supported")
+			return false
+		default:
+			c.w.error("ERR syntax error")
+			return false
+		}
+	}
+	if nx && xx {
+		c.w.error("ERR syntax error")
+		return false
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var old common.Value
+	var exists bool
+	if nx || xx || get {
+		var err lib.Error
+		if old, exists, err = s.engine.Search(key); err.IsNotEmpty() {
+			c.w.error("ERR " + err.Error())
+			return false
+		}
+	}
+	if (nx && exists) || (xx && !exists) {
+		if get {
+			writeValue(c.w, old, exists)
+		} else {
+			c.w.null()
+		}
+		return false
+	}
+	if err := s.engine.Insert(key, value); err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+	if get {
+		writeValue(c.w, old, exists)
+	} else {
+		c.w.simple("OK")
+	}
+	return false
+}
+
+func (s *Server) del(c *conn, args [][]byte) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	deleted := int64(0)
+	for _, arg := range args[1:] {
+		key := string(arg)
+		_, found, err := s.engine.Search(key)
+		if err.IsEmpty() && found {
+			err = s.engine.Delete(key)
+			deleted++
+		}
+		if err.IsNotEmpty() {
+			c.w.error("ERR " + err.Error())
+			return false
+		}
+	}
+	c.w.integer(deleted)
+	return false
+}
+
+func (s *Server) exists(c *conn, args [][]byte) bool {
+	count := int64(0)
+	for _, arg := range args[1:] {
+		_, found, err := s.engine.Search(string(arg))
+		if err.IsNotEmpty() {
+			c.w.error("ERR " + err.Error())
+			return false
+		}
+		if found {
+			count++
+		}
+	}
+	c.w.integer(count)
+	return false
+}
+
+// scan resumes after the last key the cursor returned. Cursors are numbers,
+// as clients expect, that stand for a key kept in the server's cursor table.
+// COUNT is the number of keys looked at, of which MATCH may drop some.
+func (s *Server) scan(c *conn, args [][]byte) bool {
+	cursor, e := strconv.ParseUint(string(args[1]), 10, 64)
+	if e != nil {
+		c.w.error("ERR invalid cursor")
+		return false
+	}
+	pattern, count := "", defaultScanCount
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.w.error("ERR syntax error")
+			return false
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			n, e := strconv.Atoi(string(args[i+1]))
+			if e != nil || n < 1 {
+				c.w.error("ERR value is not an integer or out of range")
+				return false
+			}
+			count = n
+		default:
+			c.w.error("ERR syntax error")
+			return false
+		}
+	}
+
+	start, excludeStart := "", false
+	if cursor != 0 {
+		key, ok := s.cursors.get(cursor)
+		if !ok {
+			c.w.error("ERR invalid cursor")
+			return false
+		}
+		start, excludeStart = key, true
+	}
+	// One key more than needed tells whether the scan is complete.
+	it, err := s.engine.Scan(start, "", engine.ScanOptions{ExcludeStart: excludeStart, Limit: count + 1})
+	if err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+	defer it.Close()
+
+	var keys []string
+	examined, last, more := 0, "", false
+	for it.Next() {
+		if examined == count {
+			more = true
+			break
+		}
+		examined++
+		last = it.Key()
+		if pattern == "" || matchGlob(pattern, last) {
+			keys = append(keys, last)
+		}
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+
+	next := uint64(0)
+	if more {
+		next = s.cursors.add(last)
+	}
+	c.w.array(2)
+	c.w.bulk(strconv.FormatUint(next, 10))
+	c.w.array(len(keys))
+	for _, key := range keys {
+		c.w.bulk(key)
+	}
+	return false
+}
+
+func (s *Server) rangeCommand(c *conn, args [][]byte) bool {
+	limit := 0
+	switch {
+	case len(args) == 5 && strings.EqualFold(string(args[3]), "limit"):
+		n, e := strconv.Atoi(string(args[4]))
+		if e != nil || n < 0 {
+			c.w.error("ERR value is not an integer or out of range")
+			return false
+		}
+		limit = n
+	case len(args) != 3:
+		c.w.error("ERR syntax error")
+		return false
+	}
+
+	it, err := s.engine.Scan(string(args[1]), string(args[2]), engine.ScanOptions{Limit: limit})
+	if err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+	defer it.Close()
+	// The reply starts with its length, so the entries are collected first.
+	var entries []string
+	for it.Next() {
+		entries = append(entries, it.Key(), printValue(it.Value()))
+	}
+	if err := it.Err(); err.IsNotEmpty() {
+		c.w.error("ERR " + err.Error())
+		return false
+	}
+	c.w.array(len(entries))
+	for _, entry := range entries {
+		c.w.bulk(entry)
+	}
+	return false
+}
+
+func (s *Server) info(c *conn, args [][]byte) bool {
+	sections := map[string]bool{}
+	for _, arg := range args[1:] {
+		sections[strings.ToLower(string(arg))] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["default"] || sections["everything"]
+
+	var b strings.Builder
+	section := func(name string, fields ...any) {
+		if !all && !sections[strings.ToLower(name)] {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", name)
+		for i := 0; i+1 < len(fields); i += 2 {
+			fmt.Fprintf(&b, "%s:%v\r\n", fields[i], fields[i+1])
+		}
+	}
+	section("Server",
+		"engine", s.engine.Kind(),
+		"path", s.engine.Path(),
+		"ordered", s.engine.Ordered(),
+		"uptime_in_seconds", int64(time.Since(s.started).Seconds()))
+	section("Clients", "connected_clients", s.connectedClients())
+	section("Stats",
+		"total_connections_received", s.connectionsReceived.Load(),
+		"total_commands_processed", s.commandsProcessed.Load())
+	c.w.bulk(b.String())
+	return false
+}
+
+// selectCommand accepts database 0, the only one there is, for clients that
+// select it on connect.
+func (s *Server) selectCommand(c *conn, args [][]byte) bool {
+	if string(args[1]) != "0" {
+		c.w.error("ERR DB index is out of range")
+		return false
+	}
+	c.w.simple("OK")
+	return false
+}
+
+// commandCommand answers the command introspection some clients do on
+// connect with an empty list.
+func (s *Server) commandCommand(c *conn, args [][]byte) bool {
+	c.w.array(0)
+	return false
+}
+
+func (s *Server) quit(c *conn, args [][]byte) bool {
+	c.w.simple("OK")
+	return true
+}
+
+func writeValue(w *writer, value common.Value, found bool) {
+	if !found {
+		w.null()
+		return
+	}
+	w.bulk(printValue(value))
+}
+
+// printValue returns the payload of strings and bytes as is and prints the
+// other types.
+func printValue(value common.Value) string {
+	switch value.Type() {
+	case common.StringType:
+		return value.StringValue()
+	case common.BytesType:
+		return string(value.BytesValue())
+	}
+	return value.String()
+}
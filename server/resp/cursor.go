@@ -0,0 +1,121 @@
+package resp
+
+import "sync"
+
+// cursorTable maps SCAN cursors to the key the scan stopped at. It is shared
+// by all connections, since clients with a connection pool may continue a
+// scan on another connection, and forgets the oldest cursors beyond its
+// capacity.
+type cursorTable struct {
+	mu       sync.Mutex
+	capacity int
+	next     uint64
+	keys     map[uint64]string
+	order    []uint64
+}
+
+func newCursorTable(capacity int) *cursorTable {
+	return &cursorTable{capacity: capacity, next: 1, keys: make(map[uint64]string)}
+}
+
+func (t *cursorTable) add(key string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cursor := t.next
+	t.next++
+	t.keys[cursor] = key
+	t.order = append(t.order, cursor)
+	if len(t.order) > t.capacity {
+		delete(t.keys, t.order[0])
+		t.order = t.order[1:]
+	}
+	return cursor
+}
+
+func (t *cursorTable) get(cursor uint64) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key, ok := t.keys[cursor]
+	return key, ok
+}
+
+// matchGlob matches key against a Redis glob pattern: * and ? wildcards,
+// [abc], [^abc] and [a-z] classes, and \ escapes.
+func matchGlob(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchGlob(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			rest, ok := matchClass(pattern[1:], key[0])
+			if !ok {
+				return false
+			}
+			key = key[1:]
+			pattern = rest
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches b against the class at the start of pattern, which
+// follows the opening bracket, and returns the pattern after the class.
+func matchClass(pattern string, b byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == b
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (lo <= b && b <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == b
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return pattern, matched != negate
+}
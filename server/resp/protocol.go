@@ -0,0 +1,220 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/Kush/Database-internals/lib"
+)
+
+const (
+	// maxInlineLength bounds a command line, and the header lines of a
+	// multibulk command.
+	maxInlineLength = 64 << 10
+	maxArgs         = 1 << 20
+	maxBulkLength   = 64 << 20
+)
+
+// reader parses the commands of one connection: RESP arrays of bulk strings,
+// as clients send them, or inline commands typed by hand.
+type reader struct {
+	br *bufio.Reader
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{br: bufio.NewReader(r)}
+}
+
+// buffered reports whether more pipelined input is waiting.
+func (r *reader) buffered() bool {
+	return r.br.Buffered() > 0
+}
+
+// readCommand returns the arguments of the next command, or none once the
+// client is gone. Malformed input is an InvalidInputError, after which the
+// connection cannot be resynchronized.
+func (r *reader) readCommand() ([][]byte, lib.Error) {
+	for {
+		line, err := r.readLine()
+		if err.IsNotEmpty() || line == nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			args := bytes.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			return args, lib.EmptyError()
+		}
+
+		n, e := strconv.Atoi(string(line[1:]))
+		if e != nil || n > maxArgs {
+			return nil, protocolError("invalid multibulk length")
+		}
+		if n <= 0 {
+			continue
+		}
+		args := make([][]byte, 0, n)
+		for i := 0; i < n; i++ {
+			arg, err := r.readBulk()
+			if err.IsNotEmpty() {
+				return nil, err
+			}
+			if arg == nil {
+				return nil, protocolError("unexpected end of input")
+			}
+			args = append(args, arg)
+		}
+		return args, lib.EmptyError()
+	}
+}
+
+func (r *reader) readBulk() ([]byte, lib.Error) {
+	line, err := r.readLine()
+	if err.IsNotEmpty() || line == nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return nil, protocolError(fmt.Sprintf("expected '$', got '%s'", printable(line)))
+	}
+	n, e := strconv.Atoi(string(line[1:]))
+	if e != nil || n < 0 || n > maxBulkLength {
+		return nil, protocolError("invalid bulk length")
+	}
+	buf := make([]byte, n+2)
+	if _, e := io.ReadFull(r.br, buf); e != nil {
+		return nil, readError(e)
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, protocolError("bulk string not terminated by CRLF")
+	}
+	return buf[:n], lib.EmptyError()
+}
+
+// readLine returns the next line without its line ending, or nil once the
+// client is gone.
+func (r *reader) readLine() ([]byte, lib.Error) {
+	var line []byte
+	for {
+		chunk, isPrefix, e := r.br.ReadLine()
+		if e != nil {
+			if len(line) > 0 || !isClosed(e) {
+				return nil, readError(e)
+			}
+			return nil, lib.EmptyError()
+		}
+		line = append(line, chunk...)
+		if len(line) > maxInlineLength {
+			return nil, protocolError("too big inline request")
+		}
+		if !isPrefix {
+			// ReadLine hands out its buffer, which the next read reuses.
+			if line == nil {
+				line = []byte{}
+			}
+			return append([]byte(nil), line...), lib.EmptyError()
+		}
+	}
+}
+
+// isClosed tells a client hanging up, or the server closing the connection
+// or expiring its deadline on shutdown, from a real failure.
+func isClosed(e error) bool {
+	return errors.Is(e, io.EOF) || errors.Is(e, net.ErrClosed) || isTimeout(e)
+}
+
+func isTimeout(e error) bool {
+	var netErr net.Error
+	return errors.As(e, &netErr) && netErr.Timeout()
+}
+
+func readError(e error) lib.Error {
+	if errors.Is(e, io.EOF) || errors.Is(e, io.ErrUnexpectedEOF) {
+		return protocolError("unexpected end of input")
+	}
+	return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("read: %w", e))
+}
+
+func protocolError(msg string) lib.Error {
+	return lib.EmptyError().AddErr(lib.InvalidInputError, fmt.Errorf("Protocol error: %s", msg))
+}
+
+func printable(b []byte) string {
+	if len(b) > 16 {
+		b = b[:16]
+	}
+	return strconv.Quote(string(b))
+}
+
+// writer buffers replies until the connection flushes them, which it does
+// once it has answered every pipelined command it has read.
+type writer struct {
+	bw *bufio.Writer
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{bw: bufio.NewWriter(w)}
+}
+
+func (w *writer) simple(s string) {
+	w.bw.WriteByte('+')
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// error writes an error reply. msg starts with the error kind, like
+// "ERR unknown command".
+func (w *writer) error(msg string) {
+	w.bw.WriteByte('-')
+	w.bw.WriteString(singleLine(msg))
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.bw.WriteByte(':')
+	w.bw.WriteString(strconv.FormatInt(n, 10))
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.bw.WriteByte('$')
+	w.bw.WriteString(strconv.Itoa(len(s)))
+	w.bw.WriteString("\r\n")
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	w.bw.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.bw.WriteByte('*')
+	w.bw.WriteString(strconv.Itoa(n))
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) flush() lib.Error {
+	if e := w.bw.Flush(); e != nil {
+		return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("write: %w", e))
+	}
+	return lib.EmptyError()
+}
+
+// singleLine keeps a status or error message from breaking the framing.
+func singleLine(s string) string {
+	return string(bytes.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, []byte(s)))
+}
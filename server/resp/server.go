@@ -0,0 +1,222 @@
+// Package resp serves an engine over the Redis protocol (RESP2), so that
+// Redis clients can use it. See commands.go for the commands it understands.
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+// Server answers each connection on its own goroutine. A connection reads
+// commands as they arrive and flushes its replies whenever it has caught up
+// with the input, so pipelined commands are answered in one write.
+//
+// Shutdown stops accepting, lets every connection finish the commands it has
+// already received, and syncs the engine. Closing the engine is left to the
+// caller, who opened it.
+type Server struct {
+	engine  *engine.Engine
+	logger  *log.Logger
+	cursors *cursorTable
+	started time.Time
+
+	// writeMu serializes the writes, so that conditional and counting
+	// writes such as SET NX or DEL see no other write in between.
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closing   bool
+	wg        sync.WaitGroup
+
+	connectionsReceived atomic.Int64
+	commandsProcessed   atomic.Int64
+}
+
+func NewServer(e *engine.Engine) *Server {
+	return &Server{
+		engine:    e,
+		logger:    log.Default(),
+		cursors:   newCursorTable(maxCursors),
+		started:   time.Now(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// SetLogger sets where connection errors are logged. It must be called
+// before Serve.
+func (s *Server) SetLogger(logger *log.Logger) {
+	s.logger = logger
+}
+
+func (s *Server) ListenAndServe(addr string) lib.Error {
+	l, e := net.Listen("tcp", addr)
+	if e != nil {
+		return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("listen on %s: %w", addr, e))
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Shutdown, after which it returns an
+// empty error. Serve closes l.
+func (s *Server) Serve(l net.Listener) lib.Error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		l.Close()
+		return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("serve: server is shut down"))
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, e := l.Accept()
+		if e != nil {
+			if s.shuttingDown() {
+				return lib.EmptyError()
+			}
+			var netErr net.Error
+			if errors.As(e, &netErr) && netErr.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("accept: %w", e))
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return lib.EmptyError()
+		}
+		s.connectionsReceived.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops the server gracefully and syncs the engine. If ctx ends
+// before every connection is done, the rest are closed and Shutdown
+// returns without syncing.
+func (s *Server) Shutdown(ctx context.Context) lib.Error {
+	s.mu.Lock()
+	s.closing = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	// Waking the readers up lets each connection answer what it has read
+	// and then finish.
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return lib.EmptyError().AddErr(lib.NetworkError, fmt.Errorf("shutdown: %w", ctx.Err()))
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.engine.Sync()
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// track registers conn, unless the server is shutting down.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *Server) connectedClients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// conn is the state of one client connection.
+type conn struct {
+	r *reader
+	w *writer
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	defer s.untrack(nc)
+	defer nc.Close()
+
+	c := &conn{r: newReader(nc), w: newWriter(nc)}
+	for {
+		args, err := c.r.readCommand()
+		if err.IsNotEmpty() {
+			switch {
+			case err.ContainsError(lib.InvalidInputError):
+				c.w.error("ERR " + err.Errors()[lib.InvalidInputError].Error())
+				c.w.flush()
+			case s.shuttingDown() && isTimeout(err.Err()):
+				// Shutdown woke the reader up in the middle of a command,
+				// which the client had not finished sending.
+			default:
+				s.logger.Printf("resp: %s: %v", nc.RemoteAddr(), err)
+			}
+			return
+		}
+		if args == nil {
+			c.w.flush()
+			return
+		}
+
+		s.commandsProcessed.Add(1)
+		quit := s.execute(c, args)
+		if quit || !c.r.buffered() {
+			if err := c.w.flush(); err.IsNotEmpty() {
+				s.logger.Printf("resp: %s: %v", nc.RemoteAddr(), err)
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kush/Database-internals/lib"
+	"github.com/Kush/Database-internals/pkg/engine"
+)
+
+func startServer(t *testing.T, kind engine.Kind, path string) (*Server, *engine.Engine, string) {
+	t.Helper()
+	e, err := engine.Open(kind, path)
+	if err.IsNotEmpty() {
+		t.Fatalf("open engine: %v", err)
+	}
+	l, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("listen: %v", listenErr)
+	}
+	server := NewServer(e)
+	server.SetLogger(log.New(io.Discard, "", 0))
+	go server.Serve(l)
+	return server, e, l.Addr().String()
+}
+
+func stopServer(t *testing.T, server *Server, e *engine.Engine) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err.IsNotEmpty() {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := e.Close(); err.IsNotEmpty() {
+		t.Fatalf("close engine: %v", err)
+	}
+}
+
+// client sends commands as RESP arrays and decodes replies into strings,
+// nil, int64, errors or slices of those.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, encode(args...)); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func encode(args ...string) string {
+	msg := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		msg += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return msg
+}
+
+func (c *client) do(args ...string) any {
+	c.t.Helper()
+	c.send(args...)
+	return c.reply()
+}
+
+func (c *client) reply() any {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read reply: %v", err)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return fmt.Errorf("%s", line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatalf("read bulk: %v", err)
+		}
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]any, n)
+		for i := range items {
+			items[i] = c.reply()
+		}
+		return items
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func expect(t *testing.T, got, want any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	server, e, addr := startServer(t, engine.BPlusTree, filepath.Join(t.TempDir(), "resp.db"))
+	defer stopServer(t, server, e)
+	c := dial(t, addr)
+
+	expect(t, c.do("PING"), "PONG")
+	expect(t, c.do("ping", "hello"), "hello")
+	expect(t, c.do("GET", "k"), nil)
+	expect(t, c.do("SET", "k", "v1"), "OK")
+	expect(t, c.do("GET", "k"), "v1")
+	expect(t, c.do("SET", "k", "v2", "NX"), nil)
+	expect(t, c.do("SET", "k", "v2", "XX", "GET"), "v1")
+	expect(t, c.do("SET", "other", "x", "XX"), nil)
+	expect(t, c.do("EXISTS", "k", "other", "k"), int64(2))
+	expect(t, c.do("DEL", "k", "other"), int64(1))
+	expect(t, c.do("GET", "k"), nil)
+
+	if _, ok := c.do("SET", "k").(error); !ok {
+		t.Fatalf("SET with too few arguments did not fail")
+	}
+	if _, ok := c.do("FLUSHALL").(error); !ok {
+		t.Fatalf("unknown command did not fail")
+	}
+	if _, ok := c.do("SET", "k", "v", "EX", "10").(error); !ok {
+		t.Fatalf("SET with an expiration did not fail")
+	}
+	if info, ok := c.do("INFO", "server").(string); !ok || !strings.Contains(info, "\r\nengine:btree\r\n") {
+		t.Fatalf("INFO server = %q", info)
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	server, e, addr := startServer(t, engine.BPlusTree, filepath.Join(t.TempDir(), "resp.db"))
+	defer stopServer(t, server, e)
+	c := dial(t, addr)
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		c.send("SET", fmt.Sprintf("key%03d", i), strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		c.send("GET", fmt.Sprintf("key%03d", i))
+	}
+	for i := 0; i < n; i++ {
+		expect(t, c.reply(), "OK")
+	}
+	for i := 0; i < n; i++ {
+		expect(t, c.reply(), strconv.Itoa(i))
+	}
+
+	// Inline commands, as typed into telnet, work too.
+	io.WriteString(c.conn, "PING\r\nEXISTS key000 missing\r\n")
+	expect(t, c.reply(), "PONG")
+	expect(t, c.reply(), int64(1))
+}
+
+func TestScanAndRange(t *testing.T) {
+	server, e, addr := startServer(t, engine.BPlusTree, filepath.Join(t.TempDir(), "resp.db"))
+	defer stopServer(t, server, e)
+	c := dial(t, addr)
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("user:%02d", i)
+		want = append(want, key)
+		c.do("SET", key, strconv.Itoa(i))
+	}
+	c.do("SET", "other", "x")
+
+	var got []string
+	cursor := "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "user:*", "COUNT", "7").([]any)
+		for _, key := range reply[1].([]any) {
+			got = append(got, key.(string))
+		}
+		cursor = reply[0].(string)
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(got)
+	expect(t, got, want)
+
+	expect(t, c.do("RANGE", "user:10", "user:13"), []any{"user:10", "10", "user:11", "11", "user:12", "12"})
+	expect(t, c.do("RANGE", "user:48", "", "LIMIT", "2"), []any{"user:48", "48", "user:49", "49"})
+	if _, ok := c.do("SCAN", "12345").(error); !ok {
+		t.Fatalf("SCAN with an unknown cursor did not fail")
+	}
+}
+
+func TestScanUnorderedEngine(t *testing.T) {
+	server, e, addr := startServer(t, engine.ExtendibleHash, filepath.Join(t.TempDir(), "resp.db"))
+	defer stopServer(t, server, e)
+	c := dial(t, addr)
+
+	expect(t, c.do("SET", "k", "v"), "OK")
+	expect(t, c.do("GET", "k"), "v")
+	if _, ok := c.do("SCAN", "0").(error); !ok {
+		t.Fatalf("SCAN on a hash index did not fail")
+	}
+}
+
+func TestShutdownIsGraceful(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resp.db")
+	server, e, addr := startServer(t, engine.BPlusTree, path)
+	c := dial(t, addr)
+
+	// Pipelined commands the server has received are still answered. They
+	// go out in one write, small enough to be read at once.
+	batch := ""
+	for i := 0; i < 50; i++ {
+		batch += encode("SET", fmt.Sprintf("key%03d", i), "v")
+	}
+	io.WriteString(c.conn, batch)
+	expect(t, c.reply(), "OK")
+	stopServer(t, server, e)
+	for i := 1; i < 50; i++ {
+		expect(t, c.reply(), "OK")
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Fatalf("connection still open after shutdown: %v", err)
+	}
+	l, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("listen: %v", listenErr)
+	}
+	if err := server.Serve(l); !errors.Is(err, lib.ErrNetwork) {
+		t.Fatalf("serve after shutdown returned %v, want a network error", err)
+	}
+
+	server, e, addr = startServer(t, engine.BPlusTree, path)
+	defer stopServer(t, server, e)
+	expect(t, dial(t, addr).do("EXISTS", "key000", "key049"), int64(2))
+}
+
+func TestShutdownDuringCommandIsNotLogged(t *testing.T) {
+	server, e, addr := startServer(t, engine.BPlusTree, filepath.Join(t.TempDir(), "resp.db"))
+	var logged strings.Builder
+	server.SetLogger(log.New(&logged, "", 0))
+	c := dial(t, addr)
+
+	// Half an argument: the deadline of the shutdown expires while the
+	// server waits for the rest.
+	expect(t, c.do("PING"), "PONG")
+	io.WriteString(c.conn, "*2\r\n$3\r\nGET\r\n$5\r\nke")
+	time.Sleep(50 * time.Millisecond)
+	stopServer(t, server, e)
+	if rest, err := io.ReadAll(c.r); err != nil || len(rest) != 0 {
+		t.Fatalf("connection still open after shutdown: %q, %v", rest, err)
+	}
+	if logged.Len() != 0 {
+		t.Fatalf("shutdown logged %q", logged.String())
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"*:*:end", "a:b:c:end", true},
+	}
+	for _, tc := range cases {
+		if got := matchGlob(tc.pattern, tc.key); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %t, want %t", tc.pattern, tc.key, got, tc.want)
+		}
+	}
+}